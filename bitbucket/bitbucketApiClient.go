@@ -25,8 +25,10 @@ type APIClient interface {
 	GetAccessToken(context.Context) (bbcontracts.AccessToken, error)
 	GetAuthenticatedRepositoryURL(bbcontracts.AccessToken, string) (string, error)
 	GetEstafetteManifest(context.Context, bbcontracts.AccessToken, bbcontracts.RepositoryPushEvent) (bool, string, error)
+	GetCommitHash(context.Context, bbcontracts.AccessToken, string, string) (string, error)
 
 	JobVarsFunc() func(context.Context, string, string, string) (string, string, error)
+	ManifestFunc() func(context.Context, string, string, string, string) (string, bool, string, error)
 }
 
 type apiClientImpl struct {
@@ -160,6 +162,79 @@ func (bb *apiClientImpl) GetEstafetteManifest(ctx context.Context, accessToken b
 	return
 }
 
+// GetCommitHash returns the hash of the commit a branch, tag or hash refers to
+func (bb *apiClientImpl) GetCommitHash(ctx context.Context, accessToken bbcontracts.AccessToken, fullName, ref string) (hash string, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BitbucketApi::GetCommitHash")
+	defer span.Finish()
+
+	commitURL, err := getCommitURL(fullName, ref)
+	if err != nil {
+		return
+	}
+
+	// track call via prometheus
+	bb.prometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "bitbucket"}).Inc()
+
+	// create client, in order to add headers
+	client := pester.NewExtendedClient(&http.Client{Transport: &nethttp.Transport{}})
+	client.MaxRetries = 3
+	client.Backoff = pester.ExponentialJitterBackoff
+	client.KeepLog = true
+	client.Timeout = time.Second * 10
+	request, err := http.NewRequest("GET", commitURL, nil)
+
+	if err != nil {
+		return
+	}
+
+	// add tracing context
+	request = request.WithContext(opentracing.ContextWithSpan(request.Context(), span))
+
+	// collect additional information on setting up connections
+	request, ht := nethttp.TraceRequest(span.Tracer(), request)
+
+	// add headers
+	request.Header.Add("Authorization", fmt.Sprintf("Bearer %v", accessToken.AccessToken))
+
+	// perform actual request
+	response, err := client.Do(request)
+	if err != nil {
+		return
+	}
+
+	defer response.Body.Close()
+	ht.Finish()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Retrieving commit for ref %v of repository %v failed with status code %v", ref, fullName, response.StatusCode)
+	}
+
+	var commit bbcontracts.Commit
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &commit)
+	if err != nil {
+		return
+	}
+
+	return commit.Hash, nil
+}
+
+// getCommitURL returns the api url for the commit a branch, tag or hash points to; the ref is user input, so it gets escaped and can't traverse to other paths
+func getCommitURL(fullName, ref string) (string, error) {
+	if ref == "" || strings.Contains(ref, "..") {
+		return "", fmt.Errorf("Ref %v is not valid", ref)
+	}
+
+	return fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%v/commit/%v", fullName, url.PathEscape(ref)), nil
+}
+
 // JobVarsFunc returns a function that can get an access token and authenticated url for a repository
 func (bb *apiClientImpl) JobVarsFunc() func(context.Context, string, string, string) (string, string, error) {
 	return func(ctx context.Context, repoSource, repoOwner, repoName string) (token string, url string, err error) {
@@ -177,3 +252,41 @@ func (bb *apiClientImpl) JobVarsFunc() func(context.Context, string, string, str
 		return accessToken.AccessToken, url, nil
 	}
 }
+
+// ManifestFunc returns a function that resolves a branch, tag or hash of a repository to a commit hash and gets the manifest at that commit
+func (bb *apiClientImpl) ManifestFunc() func(context.Context, string, string, string, string) (string, bool, string, error) {
+	return func(ctx context.Context, repoSource, repoOwner, repoName, ref string) (revision string, exists bool, manifest string, err error) {
+		// get access token
+		accessToken, err := bb.GetAccessToken(ctx)
+		if err != nil {
+			return
+		}
+
+		fullName := fmt.Sprintf("%v/%v", repoOwner, repoName)
+
+		revision, err = bb.GetCommitHash(ctx, accessToken, fullName, ref)
+		if err != nil {
+			return
+		}
+
+		// the manifest gets retrieved the same way as for a push of the commit
+		exists, manifest, err = bb.GetEstafetteManifest(ctx, accessToken, bbcontracts.RepositoryPushEvent{
+			Repository: bbcontracts.Repository{
+				FullName: fullName,
+			},
+			Push: bbcontracts.PushEvent{
+				Changes: []bbcontracts.PushEventChange{
+					bbcontracts.PushEventChange{
+						New: &bbcontracts.PushEventChangeObject{
+							Target: bbcontracts.PushEventChangeObjectTarget{
+								Hash: revision,
+							},
+						},
+					},
+				},
+			},
+		})
+
+		return
+	}
+}
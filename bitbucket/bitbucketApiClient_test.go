@@ -0,0 +1,35 @@
+package bitbucket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCommitURL(t *testing.T) {
+
+	t.Run("EscapesRef", func(t *testing.T) {
+
+		// act
+		commitURL, err := getCommitURL("estafette/estafette-ci-api", "feature/some branch?x=1")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "https://api.bitbucket.org/2.0/repositories/estafette/estafette-ci-api/commit/feature%2Fsome%20branch%3Fx=1", commitURL)
+	})
+
+	t.Run("ReturnsErrorForRefWithDoubleDots", func(t *testing.T) {
+
+		// act
+		_, err := getCommitURL("estafette/estafette-ci-api", "../../orgs/estafette")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForEmptyRef", func(t *testing.T) {
+
+		// act
+		_, err := getCommitURL("estafette/estafette-ci-api", "")
+
+		assert.NotNil(t, err)
+	})
+}
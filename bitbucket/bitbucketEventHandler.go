@@ -5,6 +5,10 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	bbcontracts "github.com/estafette/estafette-ci-api/bitbucket/contracts"
 	"github.com/estafette/estafette-ci-api/estafette"
@@ -25,27 +29,41 @@ type EventHandler interface {
 }
 
 type eventHandlerImpl struct {
-	apiClient                    APIClient
-	pubsubAPIClient              pubsub.APIClient
-	buildService                 estafette.BuildService
-	prometheusInboundEventTotals *prometheus.CounterVec
+	apiClient                        APIClient
+	pubsubAPIClient                  pubsub.APIClient
+	buildService                     estafette.BuildService
+	prometheusInboundEventTotals     *prometheus.CounterVec
+	prometheusWebhookDurationSeconds *prometheus.HistogramVec
+	prometheusWebhookErrorTotals     *prometheus.CounterVec
 }
 
 // NewBitbucketEventHandler returns a new bitbucket.EventHandler
-func NewBitbucketEventHandler(apiClient APIClient, pubsubAPIClient pubsub.APIClient, buildService estafette.BuildService, prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
+func NewBitbucketEventHandler(apiClient APIClient, pubsubAPIClient pubsub.APIClient, buildService estafette.BuildService, prometheusInboundEventTotals *prometheus.CounterVec, prometheusWebhookDurationSeconds *prometheus.HistogramVec, prometheusWebhookErrorTotals *prometheus.CounterVec) EventHandler {
 	return &eventHandlerImpl{
-		apiClient:                    apiClient,
-		pubsubAPIClient:              pubsubAPIClient,
-		buildService:                 buildService,
-		prometheusInboundEventTotals: prometheusInboundEventTotals,
+		apiClient:                        apiClient,
+		pubsubAPIClient:                  pubsubAPIClient,
+		buildService:                     buildService,
+		prometheusInboundEventTotals:     prometheusInboundEventTotals,
+		prometheusWebhookDurationSeconds: prometheusWebhookDurationSeconds,
+		prometheusWebhookErrorTotals:     prometheusWebhookErrorTotals,
 	}
 }
 
 func (h *eventHandlerImpl) Handle(c *gin.Context) {
 
+	start := time.Now()
+
 	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Bitbucket::Handle")
 	defer span.Finish()
 
+	// push events finish processing in the background and get observed once that is done
+	processingInBackground := false
+	defer func() {
+		if !processingInBackground {
+			h.observeWebhook(start, c.Writer.Status())
+		}
+	}()
+
 	// https://confluence.atlassian.com/bitbucket/manage-webhooks-735643732.html
 
 	eventType := c.GetHeader("X-Event-Key")
@@ -69,7 +87,8 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 			return
 		}
 
-		h.CreateJobForBitbucketPush(ctx, pushEvent)
+		processingInBackground = true
+		h.createJobForBitbucketPush(ctx, pushEvent, start)
 
 	case
 		"repo:fork",
@@ -120,10 +139,28 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 }
 
 func (h *eventHandlerImpl) CreateJobForBitbucketPush(ctx context.Context, pushEvent bbcontracts.RepositoryPushEvent) {
+	h.createJobForBitbucketPush(ctx, pushEvent, time.Now())
+}
+
+// createJobForBitbucketPush creates a build for the pushed revision and records the webhook metrics once the git triggers and pubsub subscriptions running in the background are done as well
+func (h *eventHandlerImpl) createJobForBitbucketPush(ctx context.Context, pushEvent bbcontracts.RepositoryPushEvent, start time.Time) {
 
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Bitbucket::CreateJobForBitbucketPush")
 	defer span.Finish()
 
+	var wg sync.WaitGroup
+	var failed int32
+	defer func() {
+		go func() {
+			wg.Wait()
+			code := http.StatusOK
+			if atomic.LoadInt32(&failed) > 0 {
+				code = http.StatusInternalServerError
+			}
+			h.observeWebhook(start, code)
+		}()
+	}()
+
 	// check to see that it's a cloneable event
 	if len(pushEvent.Push.Changes) == 0 || pushEvent.Push.Changes[0].New == nil || pushEvent.Push.Changes[0].New.Type != "branch" || len(pushEvent.Push.Changes[0].New.Target.Hash) == 0 {
 		return
@@ -141,9 +178,12 @@ func (h *eventHandlerImpl) CreateJobForBitbucketPush(ctx context.Context, pushEv
 	}
 
 	// handle git triggers
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		err := h.buildService.FireGitTriggers(ctx, gitEvent)
 		if err != nil {
+			atomic.StoreInt32(&failed, 1)
 			log.Error().Err(err).
 				Interface("gitEvent", gitEvent).
 				Msg("Failed firing git triggers")
@@ -155,6 +195,7 @@ func (h *eventHandlerImpl) CreateJobForBitbucketPush(ctx context.Context, pushEv
 	if err != nil {
 		log.Error().Err(err).
 			Msg("Retrieving Estafettte manifest failed")
+		atomic.StoreInt32(&failed, 1)
 		return
 	}
 
@@ -163,6 +204,7 @@ func (h *eventHandlerImpl) CreateJobForBitbucketPush(ctx context.Context, pushEv
 	if err != nil {
 		log.Error().Err(err).
 			Msg("Retrieving Estafettte manifest failed")
+		atomic.StoreInt32(&failed, 1)
 		return
 	}
 
@@ -202,19 +244,32 @@ func (h *eventHandlerImpl) CreateJobForBitbucketPush(ctx context.Context, pushEv
 	}, false)
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed creating build for pipeline %v/%v/%v with revision %v", pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName(), pushEvent.GetRepoRevision())
+		atomic.StoreInt32(&failed, 1)
 		return
 	}
 
 	log.Info().Msgf("Created build for pipeline %v/%v/%v with revision %v", pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName(), pushEvent.GetRepoRevision())
 
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		err := h.pubsubAPIClient.SubscribeToPubsubTriggers(ctx, manifestString)
 		if err != nil {
+			atomic.StoreInt32(&failed, 1)
 			log.Error().Err(err).Msgf("Failed subscribing to topics for pubsub triggers for build %v/%v/%v revision %v", pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName(), pushEvent.GetRepoRevision())
 		}
 	}()
 }
 
+// observeWebhook records how long processing an inbound webhook took and counts it as failed if it ended with an error code
+func (h *eventHandlerImpl) observeWebhook(start time.Time, code int) {
+	h.prometheusWebhookDurationSeconds.With(prometheus.Labels{"source": "bitbucket"}).Observe(time.Since(start).Seconds())
+
+	if code >= http.StatusBadRequest {
+		h.prometheusWebhookErrorTotals.With(prometheus.Labels{"source": "bitbucket", "code": strconv.Itoa(code)}).Inc()
+	}
+}
+
 func (h *eventHandlerImpl) Rename(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Bitbucket::Rename")
 	defer span.Finish()
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -36,6 +37,25 @@ type DBClient interface {
 	InsertRelease(context.Context, contracts.Release, JobResources) (*contracts.Release, error)
 	UpdateReleaseStatus(context.Context, string, string, string, int, string) error
 	UpdateReleaseResourceUtilization(context.Context, string, string, string, int, JobResources) error
+	UpdateBuildJob(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, jobName, cluster string) error
+	UpdateReleaseJob(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int, jobName, cluster string) error
+	GetJobCluster(ctx context.Context, jobName string) (string, error)
+	UpdateBuildParameters(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, parameters map[string]string) error
+	UpdateReleaseParameters(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int, parameters map[string]string) error
+	GetBuildParameters(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) (map[string]string, error)
+	GetReleaseParameters(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int) (map[string]string, error)
+	UpdateBuildBuilderTrack(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, builderTrack string) error
+	GetBuildBuilderTrack(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) (string, error)
+	UpdateBuildOOMKilled(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) error
+	UpdateReleaseOOMKilled(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int) error
+	UpdateBuildResourceRecommendation(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, recommendation ResourceRecommendation) error
+	UpdateReleaseResourceRecommendation(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int, recommendation ResourceRecommendation) error
+	GetBuildResourceRecommendation(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) (*ResourceRecommendation, error)
+	GetReleaseResourceRecommendation(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int) (*ResourceRecommendation, error)
+	UpdateBuildResourceMetrics(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, metrics JobResourceMetrics) error
+	UpdateReleaseResourceMetrics(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int, metrics JobResourceMetrics) error
+	GetBuildResourceMetrics(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) (*JobResourceMetrics, error)
+	GetReleaseResourceMetrics(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int) (*JobResourceMetrics, error)
 	InsertBuildLog(context.Context, contracts.BuildLog) error
 	InsertReleaseLog(context.Context, contracts.ReleaseLog) error
 
@@ -66,9 +86,16 @@ type DBClient interface {
 	GetPipelineLastReleasesByName(context.Context, string, string, string, string, []string) ([]contracts.Release, error)
 	GetPipelineReleaseLogs(context.Context, string, string, string, int) (*contracts.ReleaseLog, error)
 	GetPipelineReleaseMaxResourceUtilization(context.Context, string, string, string, string, int) (JobResources, int, error)
+	GetPipelineBuildResourceMeasurements(ctx context.Context, repoSource, repoOwner, repoName, repoBranch string, lastNRecords int) ([]*ResourceMeasurement, error)
+	GetPipelineReleaseResourceMeasurements(ctx context.Context, repoSource, repoOwner, repoName, targetName string, lastNRecords int) ([]*ResourceMeasurement, error)
 	GetBuildsCount(context.Context, map[string][]string) (int, error)
 	GetReleasesCount(context.Context, map[string][]string) (int, error)
 	GetBuildsDuration(context.Context, map[string][]string) (time.Duration, error)
+	GetBuildsTimeSeries(ctx context.Context, interval string, filters map[string][]string) ([]*StatsBucket, error)
+	GetReleasesTimeSeries(ctx context.Context, interval string, filters map[string][]string) ([]*StatsBucket, error)
+	GetBuildsResourceUsage(ctx context.Context, filters map[string][]string) ([]*ResourceUsage, error)
+	GetReleasesResourceUsage(ctx context.Context, filters map[string][]string) ([]*ResourceUsage, error)
+	GetPipelineBuildsResourceUsage(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (*ResourceUsage, error)
 	GetFirstBuildTimes(context.Context) ([]time.Time, error)
 	GetFirstReleaseTimes(context.Context) ([]time.Time, error)
 	GetPipelineBuildsDurations(context.Context, string, string, string, map[string][]string) ([]map[string]interface{}, error)
@@ -102,31 +129,84 @@ type DBClient interface {
 	RenameComputedPipelines(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error
 	RenameComputedReleases(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error
 
+	InsertWebhookDelivery(ctx context.Context, delivery WebhookDelivery) (*WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, id string) (*WebhookDelivery, error)
+	GetWebhookDeliveries(ctx context.Context, pageNumber, pageSize int) ([]*WebhookDelivery, error)
+	GetWebhookDeliveriesCount(ctx context.Context) (int, error)
+
+	InsertBuildRetry(ctx context.Context, buildRetry BuildRetry) (*BuildRetry, error)
+	UpdateBuildRetry(ctx context.Context, buildRetry BuildRetry) error
+	GetBuildRetries(ctx context.Context, repoSource, repoOwner, repoName, buildVersion string) ([]*BuildRetry, error)
+
+	GetBuildsByStatus(ctx context.Context, buildStatuses []string, insertedBefore time.Time) ([]*contracts.Build, error)
+	GetReleasesByStatus(ctx context.Context, releaseStatuses []string, insertedBefore time.Time) ([]*contracts.Release, error)
+	GetReleasesWithManifestByStatus(ctx context.Context, releaseStatuses []string, insertedBefore time.Time) ([]*ReleaseWithManifest, error)
+
+	InsertBuildTestCases(ctx context.Context, testCases []TestCase) error
+	GetBuildTestCases(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) ([]*TestCase, error)
+	GetPipelineTestReportSummaries(ctx context.Context, repoSource, repoOwner, repoName string, pageSize int, filters map[string][]string) ([]*TestReportSummary, error)
+	GetPipelineSlowestTestCases(ctx context.Context, repoSource, repoOwner, repoName string, pageSize int, filters map[string][]string) ([]*TestCaseStats, error)
+	GetPipelineMostFailingTestCases(ctx context.Context, repoSource, repoOwner, repoName string, pageSize int, filters map[string][]string) ([]*TestCaseStats, error)
+	GetPipelineFlakyTestCases(ctx context.Context, repoSource, repoOwner, repoName string, pageSize int, filters map[string][]string) ([]*FlakyTestCase, error)
+
+	GetPipelineBuildLogSteps(ctx context.Context, repoSource, repoOwner, repoName string, buildIDs []int) ([]*BuildLogStepOutcome, error)
+	GetStepImagesWithMostFailures(ctx context.Context, pageNumber, pageSize int, filters map[string][]string) ([]*StepImageFailureStats, error)
+	GetStepImagesWithMostFailuresCount(ctx context.Context, filters map[string][]string) (int, error)
+	GetDeploymentRecords(ctx context.Context, releaseNames []string, filters map[string][]string) ([]*DeploymentRecord, error)
+
+	AcquireLease(ctx context.Context, name string, duration time.Duration) (bool, error)
+
 	selectBuildsQuery() sq.SelectBuilder
 	selectPipelinesQuery() sq.SelectBuilder
 	selectReleasesQuery() sq.SelectBuilder
+	selectTimeSeriesQuery(table, statusColumn, interval string) (sq.SelectBuilder, error)
+	selectResourceUsageQuery(table string) sq.SelectBuilder
+	selectResourceMeasurementsQuery(table, repoSource, repoOwner, repoName string, lastNRecords int) sq.SelectBuilder
+	acquireLeaseQuery(name string, duration time.Duration) sq.InsertBuilder
 }
 
 type cockroachDBClientImpl struct {
 	databaseDriver                  string
 	config                          config.DatabaseConfig
 	PrometheusOutboundAPICallTotals *prometheus.CounterVec
+	PrometheusQueryDurationSeconds  *prometheus.HistogramVec
+	PrometheusLogInsertBytes        *prometheus.HistogramVec
 	databaseConnection              *sql.DB
 	tracer                          opentracing.Tracer
+	leaseHolder                     string
 }
 
 // NewCockroachDBClient returns a new cockroach.DBClient
-func NewCockroachDBClient(config config.DatabaseConfig, prometheusOutboundAPICallTotals *prometheus.CounterVec) (cockroachDBClient DBClient) {
+func NewCockroachDBClient(config config.DatabaseConfig, prometheusOutboundAPICallTotals *prometheus.CounterVec, prometheusQueryDurationSeconds, prometheusLogInsertBytes *prometheus.HistogramVec) (cockroachDBClient DBClient) {
 
 	cockroachDBClient = &cockroachDBClientImpl{
 		databaseDriver:                  "postgres",
 		config:                          config,
 		PrometheusOutboundAPICallTotals: prometheusOutboundAPICallTotals,
+		PrometheusQueryDurationSeconds:  prometheusQueryDurationSeconds,
+		PrometheusLogInsertBytes:        prometheusLogInsertBytes,
+		leaseHolder:                     getLeaseHolder(),
 	}
 
 	return
 }
 
+// getLeaseHolder identifies this replica when taking leases; in kubernetes the hostname is the pod name
+func getLeaseHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return fmt.Sprintf("estafette-ci-api-%v", time.Now().UnixNano())
+	}
+
+	return hostname
+}
+
+// observeQueryDuration records the latency of a database client method, named after its span
+func (dbc *cockroachDBClientImpl) observeQueryDuration(operationName string, start time.Time) {
+	dbc.PrometheusQueryDurationSeconds.With(prometheus.Labels{"method": strings.TrimPrefix(operationName, "CockroachDb::")}).Observe(time.Since(start).Seconds())
+}
+
 // Connect sets up a connection with CockroachDB
 func (dbc *cockroachDBClientImpl) Connect() (err error) {
 
@@ -158,6 +238,7 @@ func (dbc *cockroachDBClientImpl) GetAutoIncrement(ctx context.Context, shortRep
 
 	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetAutoIncrement")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetAutoIncrement", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -233,6 +314,7 @@ func (dbc *cockroachDBClientImpl) InsertBuild(ctx context.Context, build contrac
 
 	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::InsertBuild")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::InsertBuild", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -316,7 +398,8 @@ func (dbc *cockroachDBClientImpl) InsertBuild(ctx context.Context, build contrac
 			$17
 		)
 		RETURNING
-			id
+			id,
+			inserted_at
 		`,
 		build.RepoSource,
 		build.RepoOwner,
@@ -339,7 +422,7 @@ func (dbc *cockroachDBClientImpl) InsertBuild(ctx context.Context, build contrac
 
 	insertedBuild = &build
 
-	if err = row.Scan(&insertedBuild.ID); err != nil {
+	if err = row.Scan(&insertedBuild.ID, &insertedBuild.InsertedAt); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -355,6 +438,7 @@ func (dbc *cockroachDBClientImpl) UpdateBuildStatus(ctx context.Context, repoSou
 
 	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateBuildStatus")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateBuildStatus", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -364,13 +448,18 @@ func (dbc *cockroachDBClientImpl) UpdateBuildStatus(ctx context.Context, repoSou
 		allowedBuildStatusesToTransitionFrom = []string{"pending"}
 		break
 	case "succeeded",
-		"failed",
 		"canceling":
 		allowedBuildStatusesToTransitionFrom = []string{"running"}
 		break
+	case "failed":
+		allowedBuildStatusesToTransitionFrom = []string{"pending", "running"}
+		break
 	case "canceled":
 		allowedBuildStatusesToTransitionFrom = []string{"pending", "canceling"}
 		break
+	case "timedout":
+		allowedBuildStatusesToTransitionFrom = []string{"pending", "running"}
+		break
 	}
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
@@ -414,6 +503,7 @@ func (dbc *cockroachDBClientImpl) UpdateBuildResourceUtilization(ctx context.Con
 
 	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateBuildResourceUtilization")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateBuildResourceUtilization", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -443,6 +533,7 @@ func (dbc *cockroachDBClientImpl) InsertRelease(ctx context.Context, release con
 
 	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::InsertRelease")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::InsertRelease", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -488,7 +579,8 @@ func (dbc *cockroachDBClientImpl) InsertRelease(ctx context.Context, release con
 			$12
 		)
 		RETURNING 
-			id
+			id,
+			inserted_at
 		`,
 		release.RepoSource,
 		release.RepoOwner,
@@ -518,7 +610,7 @@ func (dbc *cockroachDBClientImpl) InsertRelease(ctx context.Context, release con
 	}
 
 	insertedRelease = &release
-	if err = rows.Scan(&insertedRelease.ID); err != nil {
+	if err = rows.Scan(&insertedRelease.ID, &insertedRelease.InsertedAt); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -537,6 +629,7 @@ func (dbc *cockroachDBClientImpl) UpdateReleaseStatus(ctx context.Context, repoS
 
 	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateReleaseStatus")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateReleaseStatus", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -546,13 +639,18 @@ func (dbc *cockroachDBClientImpl) UpdateReleaseStatus(ctx context.Context, repoS
 		allowedReleaseStatusesToTransitionFrom = []string{"pending"}
 		break
 	case "succeeded",
-		"failed",
 		"canceling":
 		allowedReleaseStatusesToTransitionFrom = []string{"running"}
 		break
+	case "failed":
+		allowedReleaseStatusesToTransitionFrom = []string{"pending", "running"}
+		break
 	case "canceled":
 		allowedReleaseStatusesToTransitionFrom = []string{"pending", "canceling"}
 		break
+	case "timedout":
+		allowedReleaseStatusesToTransitionFrom = []string{"pending", "running"}
+		break
 	}
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
@@ -598,6 +696,7 @@ func (dbc *cockroachDBClientImpl) UpdateReleaseResourceUtilization(ctx context.C
 
 	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateReleaseResourceUtilization")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateReleaseResourceUtilization", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
@@ -623,361 +722,303 @@ func (dbc *cockroachDBClientImpl) UpdateReleaseResourceUtilization(ctx context.C
 	return
 }
 
-func (dbc *cockroachDBClientImpl) InsertBuildLog(ctx context.Context, buildLog contracts.BuildLog) (err error) {
+func (dbc *cockroachDBClientImpl) UpdateBuildJob(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, jobName, cluster string) (err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::InsertBuildLog")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateBuildJob")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateBuildJob", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	bytes, err := json.Marshal(buildLog.Steps)
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Update("builds").
+		Set("job_name", jobName).
+		Set("cluster", cluster).
+		Where(sq.Eq{"id": buildID}).
+		Where(sq.Eq{"repo_source": repoSource}).
+		Where(sq.Eq{"repo_owner": repoOwner}).
+		Where(sq.Eq{"repo_name": repoName})
+
+	_, err = query.RunWith(dbc.databaseConnection).Exec()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	buildID, err := strconv.Atoi(buildLog.BuildID)
-	if err != nil {
-		// insert logs
-		_, err = dbc.databaseConnection.Exec(
-			`
-			INSERT INTO
-				build_logs
-			(
-				repo_source,
-				repo_owner,
-				repo_name,
-				repo_branch,
-				repo_revision,
-				steps
-			)
-			VALUES
-			(
-				$1,
-				$2,
-				$3,
-				$4,
-				$5,
-				$6
-			)
-			`,
-			buildLog.RepoSource,
-			buildLog.RepoOwner,
-			buildLog.RepoName,
-			buildLog.RepoBranch,
-			buildLog.RepoRevision,
-			bytes,
-		)
+	return
+}
 
-		if err != nil {
-			// log extra detail for filing a ticket regarding 'pq: command is too large: xxx bytes (max: 67108864)' issue
-			nrLines := 0
-			for _, s := range buildLog.Steps {
-				nrLines += len(s.LogLines)
-			}
-			log.Error().Msgf("INSERT INTO build_logs: failed for %v/%v/%v/%v (%v steps, %v lines, %v bytes)", buildLog.RepoSource, buildLog.RepoOwner, buildLog.RepoName, buildLog.RepoRevision, len(buildLog.Steps), nrLines, len(bytes))
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
+func (dbc *cockroachDBClientImpl) UpdateReleaseJob(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int, jobName, cluster string) (err error) {
 
-			return
-		}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateReleaseJob")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateReleaseJob", time.Now())
 
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-		return
-	}
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	// insert logs
-	_, err = dbc.databaseConnection.Exec(
-		`
-		INSERT INTO
-			build_logs
-		(
-			repo_source,
-			repo_owner,
-			repo_name,
-			repo_branch,
-			repo_revision,
-			build_id,
-			steps
-		)
-		VALUES
-		(
-			$1,
-			$2,
-			$3,
-			$4,
-			$5,
-			$6,
-			$7
-		)
-		`,
-		buildLog.RepoSource,
-		buildLog.RepoOwner,
-		buildLog.RepoName,
-		buildLog.RepoBranch,
-		buildLog.RepoRevision,
-		buildID,
-		bytes,
-	)
+	query := psql.
+		Update("releases").
+		Set("job_name", jobName).
+		Set("cluster", cluster).
+		Where(sq.Eq{"id": releaseID}).
+		Where(sq.Eq{"repo_source": repoSource}).
+		Where(sq.Eq{"repo_owner": repoOwner}).
+		Where(sq.Eq{"repo_name": repoName})
 
+	_, err = query.RunWith(dbc.databaseConnection).Exec()
 	if err != nil {
-		// log extra detail for filing a ticket regarding 'pq: command is too large: xxx bytes (max: 67108864)' issue
-		nrLines := 0
-		for _, s := range buildLog.Steps {
-			nrLines += len(s.LogLines)
-		}
-		log.Error().Msgf("INSERT INTO build_logs: failed for %v/%v/%v/%v (%v steps, %v lines, %v bytes)", buildLog.RepoSource, buildLog.RepoOwner, buildLog.RepoName, buildLog.RepoRevision, len(buildLog.Steps), nrLines, len(bytes))
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-
 		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) InsertReleaseLog(ctx context.Context, releaseLog contracts.ReleaseLog) (err error) {
+func (dbc *cockroachDBClientImpl) UpdateBuildParameters(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, parameters map[string]string) (err error) {
+	return dbc.updateParameters(ctx, "CockroachDb::UpdateBuildParameters", "builds", repoSource, repoOwner, repoName, buildID, parameters)
+}
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::InsertReleaseLog")
+func (dbc *cockroachDBClientImpl) UpdateReleaseParameters(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int, parameters map[string]string) (err error) {
+	return dbc.updateParameters(ctx, "CockroachDb::UpdateReleaseParameters", "releases", repoSource, repoOwner, repoName, releaseID, parameters)
+}
+
+func (dbc *cockroachDBClientImpl) GetBuildParameters(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) (parameters map[string]string, err error) {
+	return dbc.getParameters(ctx, "CockroachDb::GetBuildParameters", "builds", repoSource, repoOwner, repoName, buildID)
+}
+
+func (dbc *cockroachDBClientImpl) GetReleaseParameters(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int) (parameters map[string]string, err error) {
+	return dbc.getParameters(ctx, "CockroachDb::GetReleaseParameters", "releases", repoSource, repoOwner, repoName, releaseID)
+}
+
+// updateParameters stores the values of the parameters a build or release was started with manually
+func (dbc *cockroachDBClientImpl) updateParameters(ctx context.Context, operationName, table, repoSource, repoOwner, repoName string, id int, parameters map[string]string) (err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, operationName)
 	defer span.Finish()
+	defer dbc.observeQueryDuration(operationName, time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	bytes, err := json.Marshal(releaseLog.Steps)
+	parametersBytes, err := json.Marshal(parameters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	releaseID, err := strconv.Atoi(releaseLog.ReleaseID)
-	if err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return err
-	}
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	// insert logs
-	_, err = dbc.databaseConnection.Exec(
-		`
-		INSERT INTO
-			release_logs
-		(
-			repo_source,
-			repo_owner,
-			repo_name,
-			release_id,
-			steps
-		)
-		VALUES
-		(
-			$1,
-			$2,
-			$3,
-			$4,
-			$5
-		)
-		`,
-		releaseLog.RepoSource,
-		releaseLog.RepoOwner,
-		releaseLog.RepoName,
-		releaseID,
-		bytes,
-	)
+	query := psql.
+		Update(table).
+		Set("parameters", parametersBytes).
+		Where(sq.Eq{"id": id}).
+		Where(sq.Eq{"repo_source": repoSource}).
+		Where(sq.Eq{"repo_owner": repoOwner}).
+		Where(sq.Eq{"repo_name": repoName})
 
+	_, err = query.RunWith(dbc.databaseConnection).Exec()
 	if err != nil {
-		// log extra detail for filing a ticket regarding 'pq: command is too large: xxx bytes (max: 67108864)' issue
-		nrLines := 0
-		for _, s := range releaseLog.Steps {
-			nrLines += len(s.LogLines)
-		}
-		log.Error().Msgf("INSERT INTO build_logs: failed for %v/%v/%v/%v (%v steps, %v lines, %v bytes)", releaseLog.RepoSource, releaseLog.RepoOwner, releaseLog.RepoName, releaseLog.ReleaseID, len(releaseLog.Steps), nrLines, len(bytes))
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-
 		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) UpsertComputedPipeline(ctx context.Context, repoSource, repoOwner, repoName string) (err error) {
+// getParameters returns the values of the parameters a build or release was started with, or an empty map if it had none
+func (dbc *cockroachDBClientImpl) getParameters(ctx context.Context, operationName, table, repoSource, repoOwner, repoName string, id int) (parameters map[string]string, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpsertComputedPipeline")
+	span, _ := opentracing.StartSpanFromContext(ctx, operationName)
 	defer span.Finish()
+	defer dbc.observeQueryDuration(operationName, time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// get last build
-	lastBuild, err := dbc.GetLastPipelineBuild(ctx, repoSource, repoOwner, repoName, false)
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed getting last build for upserting computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Select("a.parameters").
+		From(table + " a").
+		Where(sq.Eq{"a.id": id}).
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Limit(uint64(1))
+
+	parameters = map[string]string{}
+
+	var parametersData []uint8
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&parametersData); err != nil {
+		if err == sql.ErrNoRows {
+			return parameters, nil
+		}
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-	if lastBuild == nil {
-		log.Error().Msgf("Failed getting last build for upserting computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
-		return
+
+	if len(parametersData) > 0 {
+		if err = json.Unmarshal(parametersData, &parameters); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return
+		}
 	}
 
-	upsertedPipeline := dbc.mapBuildToPipeline(lastBuild)
+	return
+}
 
-	// add releases
-	dbc.enrichPipeline(ctx, upsertedPipeline)
+// UpdateBuildBuilderTrack stores the builder track a build was started with manually, if it overrides the one in the manifest
+func (dbc *cockroachDBClientImpl) UpdateBuildBuilderTrack(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, builderTrack string) (err error) {
 
-	// set LastUpdatedAt from both builds and releases
-	upsertedPipeline.LastUpdatedAt = upsertedPipeline.InsertedAt
-	if upsertedPipeline.UpdatedAt.After(upsertedPipeline.LastUpdatedAt) {
-		upsertedPipeline.LastUpdatedAt = upsertedPipeline.UpdatedAt
-	}
-	for _, rt := range upsertedPipeline.ReleaseTargets {
-		for _, ar := range rt.ActiveReleases {
-			if ar.InsertedAt != nil && ar.InsertedAt.After(upsertedPipeline.LastUpdatedAt) {
-				upsertedPipeline.LastUpdatedAt = *ar.InsertedAt
-			}
-			if ar.UpdatedAt != nil && ar.UpdatedAt.After(upsertedPipeline.LastUpdatedAt) {
-				upsertedPipeline.LastUpdatedAt = *ar.UpdatedAt
-			}
-		}
-	}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateBuildBuilderTrack")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateBuildBuilderTrack", time.Now())
 
-	// sort labels by key
-	sort.Slice(upsertedPipeline.Labels, func(i, j int) bool {
-		return upsertedPipeline.Labels[i].Key < upsertedPipeline.Labels[j].Key
-	})
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	labelsBytes, err := json.Marshal(upsertedPipeline.Labels)
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Update("builds").
+		Set("builder_track", builderTrack).
+		Where(sq.Eq{"id": buildID}).
+		Where(sq.Eq{"repo_source": repoSource}).
+		Where(sq.Eq{"repo_owner": repoOwner}).
+		Where(sq.Eq{"repo_name": repoName})
+
+	_, err = query.RunWith(dbc.databaseConnection).Exec()
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", upsertedPipeline.RepoSource, upsertedPipeline.RepoOwner, upsertedPipeline.RepoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-	releaseTargetsBytes, err := json.Marshal(upsertedPipeline.ReleaseTargets)
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", upsertedPipeline.RepoSource, upsertedPipeline.RepoOwner, upsertedPipeline.RepoName)
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
-	commitsBytes, err := json.Marshal(upsertedPipeline.Commits)
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", upsertedPipeline.RepoSource, upsertedPipeline.RepoOwner, upsertedPipeline.RepoName)
+
+	return
+}
+
+// GetBuildBuilderTrack returns the builder track a build was started with manually, or an empty string if it used the one in the manifest
+func (dbc *cockroachDBClientImpl) GetBuildBuilderTrack(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) (builderTrack string, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetBuildBuilderTrack")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetBuildBuilderTrack", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Select("COALESCE(a.builder_track, '')").
+		From("builds a").
+		Where(sq.Eq{"a.id": buildID}).
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Limit(uint64(1))
+
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&builderTrack); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-	triggersBytes, err := json.Marshal(upsertedPipeline.Triggers)
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) UpdateBuildOOMKilled(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) (err error) {
+	return dbc.updateOOMKilled(ctx, "CockroachDb::UpdateBuildOOMKilled", "builds", repoSource, repoOwner, repoName, buildID)
+}
+
+func (dbc *cockroachDBClientImpl) UpdateReleaseOOMKilled(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int) (err error) {
+	return dbc.updateOOMKilled(ctx, "CockroachDb::UpdateReleaseOOMKilled", "releases", repoSource, repoOwner, repoName, releaseID)
+}
+
+// updateOOMKilled flags a build or release whose job ran out of memory, so the next one gets a higher memory limit
+func (dbc *cockroachDBClientImpl) updateOOMKilled(ctx context.Context, operationName, table, repoSource, repoOwner, repoName string, id int) (err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, operationName)
+	defer span.Finish()
+	defer dbc.observeQueryDuration(operationName, time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Update(table).
+		Set("oom_killed", true).
+		Where(sq.Eq{"id": id}).
+		Where(sq.Eq{"repo_source": repoSource}).
+		Where(sq.Eq{"repo_owner": repoOwner}).
+		Where(sq.Eq{"repo_name": repoName})
+
+	_, err = query.RunWith(dbc.databaseConnection).Exec()
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", upsertedPipeline.RepoSource, upsertedPipeline.RepoOwner, upsertedPipeline.RepoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-	eventsBytes, err := json.Marshal(upsertedPipeline.Events)
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) UpdateBuildResourceRecommendation(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, recommendation ResourceRecommendation) (err error) {
+	return dbc.updateResourceRecommendation(ctx, "CockroachDb::UpdateBuildResourceRecommendation", "builds", repoSource, repoOwner, repoName, buildID, recommendation)
+}
+
+func (dbc *cockroachDBClientImpl) UpdateReleaseResourceRecommendation(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int, recommendation ResourceRecommendation) (err error) {
+	return dbc.updateResourceRecommendation(ctx, "CockroachDb::UpdateReleaseResourceRecommendation", "releases", repoSource, repoOwner, repoName, releaseID, recommendation)
+}
+
+func (dbc *cockroachDBClientImpl) GetBuildResourceRecommendation(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) (recommendation *ResourceRecommendation, err error) {
+	return dbc.getResourceRecommendation(ctx, "CockroachDb::GetBuildResourceRecommendation", "builds", repoSource, repoOwner, repoName, buildID)
+}
+
+func (dbc *cockroachDBClientImpl) GetReleaseResourceRecommendation(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int) (recommendation *ResourceRecommendation, err error) {
+	return dbc.getResourceRecommendation(ctx, "CockroachDb::GetReleaseResourceRecommendation", "releases", repoSource, repoOwner, repoName, releaseID)
+}
+
+// updateResourceRecommendation stores the resources a build or release job got and the reasoning behind them
+func (dbc *cockroachDBClientImpl) updateResourceRecommendation(ctx context.Context, operationName, table, repoSource, repoOwner, repoName string, id int, recommendation ResourceRecommendation) (err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, operationName)
+	defer span.Finish()
+	defer dbc.observeQueryDuration(operationName, time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	recommendationBytes, err := json.Marshal(recommendation)
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", upsertedPipeline.RepoSource, upsertedPipeline.RepoOwner, upsertedPipeline.RepoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	// upsert computed pipeline
-	_, err = dbc.databaseConnection.Exec(
-		`
-		INSERT INTO
-			computed_pipelines
-		(
-			pipeline_id,
-			repo_source,
-			repo_owner,
-			repo_name,
-			repo_branch,
-			repo_revision,
-			build_version,
-			build_status,
-			labels,
-			release_targets,
-			manifest,
-			commits,
-			triggers,
-			inserted_at,
-			first_inserted_at,
-			updated_at,
-			duration,
-			last_updated_at,
-			triggered_by_event
-		)
-		VALUES
-		(
-			$1,
-			$2,
-			$3,
-			$4,
-			$5,
-			$6,
-			$7,
-			$8,
-			$9,
-			$10,
-			$11,
-			$12,
-			$13,
-			$14,
-			$15,
-			$16,
-			AGE($16,$15),
-			$17,
-			$18
-		)
-		ON CONFLICT
-		(
-			repo_source,
-			repo_owner,
-			repo_name
-		)
-		DO UPDATE SET
-			pipeline_id = excluded.pipeline_id,
-			repo_branch = excluded.repo_branch,
-			repo_revision = excluded.repo_revision,
-			build_version = excluded.build_version,
-			build_status = excluded.build_status,
-			labels = excluded.labels,
-			release_targets = excluded.release_targets,
-			manifest = excluded.manifest,
-			commits = excluded.commits,
-			triggers = excluded.triggers,
-			inserted_at = excluded.inserted_at,
-			updated_at = excluded.updated_at,
-			duration = AGE(excluded.updated_at,excluded.inserted_at),
-			last_updated_at = excluded.last_updated_at,
-			triggered_by_event = excluded.triggered_by_event
-		`,
-		upsertedPipeline.ID,
-		upsertedPipeline.RepoSource,
-		upsertedPipeline.RepoOwner,
-		upsertedPipeline.RepoName,
-		upsertedPipeline.RepoBranch,
-		upsertedPipeline.RepoRevision,
-		upsertedPipeline.BuildVersion,
-		upsertedPipeline.BuildStatus,
-		labelsBytes,
-		releaseTargetsBytes,
-		upsertedPipeline.Manifest,
-		commitsBytes,
-		triggersBytes,
-		upsertedPipeline.InsertedAt,
-		upsertedPipeline.InsertedAt,
-		upsertedPipeline.UpdatedAt,
-		upsertedPipeline.LastUpdatedAt,
-		eventsBytes,
-	)
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Update(table).
+		Set("resource_recommendation", recommendationBytes).
+		Where(sq.Eq{"id": id}).
+		Where(sq.Eq{"repo_source": repoSource}).
+		Where(sq.Eq{"repo_owner": repoOwner}).
+		Where(sq.Eq{"repo_name": repoName})
+
+	_, err = query.RunWith(dbc.databaseConnection).Exec()
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -986,511 +1027,543 @@ func (dbc *cockroachDBClientImpl) UpsertComputedPipeline(ctx context.Context, re
 	return
 }
 
-func (dbc *cockroachDBClientImpl) UpdateComputedPipelineFirstInsertedAt(ctx context.Context, repoSource, repoOwner, repoName string) (err error) {
+// getResourceRecommendation returns the resources a build or release job got and why, or nil for jobs started before recommendations were stored
+func (dbc *cockroachDBClientImpl) getResourceRecommendation(ctx context.Context, operationName, table, repoSource, repoOwner, repoName string, id int) (recommendation *ResourceRecommendation, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateComputedPipelineFirstInsertedAt")
+	span, _ := opentracing.StartSpanFromContext(ctx, operationName)
 	defer span.Finish()
+	defer dbc.observeQueryDuration(operationName, time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// get first build
-	firstBuild, err := dbc.GetFirstPipelineBuild(ctx, repoSource, repoOwner, repoName, false)
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed getting first build for updating computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
-	if firstBuild == nil {
-		log.Error().Msgf("Failed getting first build for updating computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
-		return
-	}
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	updatedPipeline := dbc.mapBuildToPipeline(firstBuild)
+	query := psql.
+		Select("a.resource_recommendation").
+		From(table + " a").
+		Where(sq.Eq{"a.id": id}).
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Limit(uint64(1))
 
-	// update computed pipeline
-	_, err = dbc.databaseConnection.Exec(
-		`
-		UPDATE
-			computed_pipelines
-		SET
-			first_inserted_at=$1
-		WHERE
-			repo_source=$2 AND
-			repo_owner=$3 AND
-			repo_name=$4
-		`,
-		updatedPipeline.InsertedAt,
-		updatedPipeline.RepoSource,
-		updatedPipeline.RepoOwner,
-		updatedPipeline.RepoName,
-	)
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed updating computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
+	var recommendationData []uint8
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&recommendationData); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
+	if len(recommendationData) > 0 {
+		recommendation = &ResourceRecommendation{}
+		if err = json.Unmarshal(recommendationData, recommendation); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+	}
+
 	return
 }
 
-func (dbc *cockroachDBClientImpl) UpsertComputedRelease(ctx context.Context, repoSource, repoOwner, repoName, releaseName, releaseAction string) (err error) {
+func (dbc *cockroachDBClientImpl) UpdateBuildResourceMetrics(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, metrics JobResourceMetrics) (err error) {
+	return dbc.updateResourceMetrics(ctx, "CockroachDb::UpdateBuildResourceMetrics", "builds", repoSource, repoOwner, repoName, buildID, metrics)
+}
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpsertComputedRelease")
+func (dbc *cockroachDBClientImpl) UpdateReleaseResourceMetrics(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int, metrics JobResourceMetrics) (err error) {
+	return dbc.updateResourceMetrics(ctx, "CockroachDb::UpdateReleaseResourceMetrics", "releases", repoSource, repoOwner, repoName, releaseID, metrics)
+}
+
+func (dbc *cockroachDBClientImpl) GetBuildResourceMetrics(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) (metrics *JobResourceMetrics, err error) {
+	return dbc.getResourceMetrics(ctx, "CockroachDb::GetBuildResourceMetrics", "builds", repoSource, repoOwner, repoName, buildID)
+}
+
+func (dbc *cockroachDBClientImpl) GetReleaseResourceMetrics(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int) (metrics *JobResourceMetrics, err error) {
+	return dbc.getResourceMetrics(ctx, "CockroachDb::GetReleaseResourceMetrics", "releases", repoSource, repoOwner, repoName, releaseID)
+}
+
+// updateResourceMetrics stores all metrics collected from prometheus for a build or release job, next to the cpu and memory peaks kept in their own columns
+func (dbc *cockroachDBClientImpl) updateResourceMetrics(ctx context.Context, operationName, table, repoSource, repoOwner, repoName string, id int, metrics JobResourceMetrics) (err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, operationName)
 	defer span.Finish()
+	defer dbc.observeQueryDuration(operationName, time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// get last release
-	lastRelease, err := dbc.GetLastPipelineRelease(ctx, repoSource, repoOwner, repoName, releaseName, releaseAction)
+	metricsBytes, err := json.Marshal(metrics)
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed getting last release for upserting computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-	if lastRelease == nil {
-		log.Error().Msgf("Failed getting last release for upserting computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
-		return
-	}
 
-	eventsBytes, err := json.Marshal(lastRelease.Events)
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Update(table).
+		Set("resource_metrics", metricsBytes).
+		Where(sq.Eq{"id": id}).
+		Where(sq.Eq{"repo_source": repoSource}).
+		Where(sq.Eq{"repo_owner": repoOwner}).
+		Where(sq.Eq{"repo_name": repoName})
+
+	_, err = query.RunWith(dbc.databaseConnection).Exec()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	// upsert computed release
-	_, err = dbc.databaseConnection.Exec(
-		`
-		INSERT INTO
-			computed_releases
-		(
-			release_id,
-			repo_source,
-			repo_owner,
-			repo_name,
-			release,
-			release_version,
-			release_status,
-			inserted_at,
-			first_inserted_at,
-			updated_at,
-			duration,
-			release_action,
-			triggered_by_event
-		)
-		VALUES
-		(
-			$1,
-			$2,
-			$3,
-			$4,
-			$5,
-			$6,
-			$7,
-			$8,
-			$8,
-			$9,
-			AGE($9,$8),
-			$10,
-			$11
-		)
-		ON CONFLICT
-		(
-			repo_source,
-			repo_owner,
-			repo_name,
-			release,
-			release_action
-		)
-		DO UPDATE SET
-			release_id = excluded.release_id,
-			release_version = excluded.release_version,
-			release_status = excluded.release_status,
-			inserted_at = excluded.inserted_at,
-			updated_at = excluded.updated_at,
-			duration = AGE(excluded.updated_at,excluded.inserted_at),
-			triggered_by_event = excluded.triggered_by_event
-		`,
-		lastRelease.ID,
-		lastRelease.RepoSource,
-		lastRelease.RepoOwner,
-		lastRelease.RepoName,
-		lastRelease.Name,
-		lastRelease.ReleaseVersion,
-		lastRelease.ReleaseStatus,
-		lastRelease.InsertedAt,
-		lastRelease.UpdatedAt,
-		lastRelease.Action,
-		eventsBytes,
-	)
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed upserting computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
-
 	return
 }
 
-func (dbc *cockroachDBClientImpl) UpdateComputedReleaseFirstInsertedAt(ctx context.Context, repoSource, repoOwner, repoName, releaseName, releaseAction string) (err error) {
+// getResourceMetrics returns the metrics collected for a build or release job, or nil if they haven't been collected (yet)
+func (dbc *cockroachDBClientImpl) getResourceMetrics(ctx context.Context, operationName, table, repoSource, repoOwner, repoName string, id int) (metrics *JobResourceMetrics, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateComputedReleaseFirstInsertedAt")
+	span, _ := opentracing.StartSpanFromContext(ctx, operationName)
 	defer span.Finish()
+	defer dbc.observeQueryDuration(operationName, time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// get first release
-	firstRelease, err := dbc.GetFirstPipelineRelease(ctx, repoSource, repoOwner, repoName, releaseName, releaseAction)
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed getting first release for updating computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Select("a.resource_metrics").
+		From(table + " a").
+		Where(sq.Eq{"a.id": id}).
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Limit(uint64(1))
+
+	var metricsData []uint8
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&metricsData); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-	if firstRelease == nil {
-		log.Error().Msgf("Failed getting first release for updating computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
-		return
-	}
 
-	// update computed release
-	_, err = dbc.databaseConnection.Exec(
-		`
-		UPDATE
-			computed_releases
-		SET
-			first_inserted_at=$1
-		WHERE
-			repo_source=$2 AND
-			repo_owner=$3 AND
-			repo_name=$4 AND
-			release=$5 AND
-			release_action=$6
-		`,
-		firstRelease.InsertedAt,
-		firstRelease.RepoSource,
-		firstRelease.RepoOwner,
-		firstRelease.RepoName,
-		firstRelease.Name,
-		firstRelease.Action,
-	)
-	if err != nil {
-		log.Error().Err(err).Msgf("Failed updating computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
+	if len(metricsData) > 0 {
+		metrics = &JobResourceMetrics{}
+		if err = json.Unmarshal(metricsData, metrics); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelines(ctx context.Context, pageNumber, pageSize int, filters map[string][]string, optimized bool) (pipelines []*contracts.Pipeline, err error) {
+// GetJobCluster returns the cluster the build or release job with this name was dispatched to, or an empty string if unknown
+func (dbc *cockroachDBClientImpl) GetJobCluster(ctx context.Context, jobName string) (cluster string, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelines")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetJobCluster")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetJobCluster", time.Now())
 
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	// generate query
-	query := dbc.selectPipelinesQuery().
-		OrderBy("a.repo_source,a.repo_owner,a.repo_name").
-		Limit(uint64(pageSize)).
-		Offset(uint64((pageNumber - 1) * pageSize))
+	for _, table := range []string{"builds", "releases"} {
 
-	// dynamically set where clauses for filtering
-	query, err = whereClauseGeneratorForAllFilters(query, "a", "last_updated_at", filters)
+		dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+		query := psql.
+			Select("a.cluster").
+			From(table + " a").
+			Where(sq.Eq{"a.job_name": jobName}).
+			Limit(uint64(1))
+
+		var nullableCluster sql.NullString
+		row := query.RunWith(dbc.databaseConnection).QueryRow()
+		err = row.Scan(&nullableCluster)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return
+		}
+
+		return nullableCluster.String, nil
+	}
+
+	return "", nil
+}
+
+func (dbc *cockroachDBClientImpl) InsertBuildLog(ctx context.Context, buildLog contracts.BuildLog) (err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::InsertBuildLog")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::InsertBuildLog", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	bytes, err := json.Marshal(buildLog.Steps)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	// execute query
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	dbc.PrometheusLogInsertBytes.With(prometheus.Labels{"type": "build"}).Observe(float64(len(bytes)))
+
+	buildID, err := strconv.Atoi(buildLog.BuildID)
 	if err != nil {
+		// insert logs
+		_, err = dbc.databaseConnection.Exec(
+			`
+			INSERT INTO
+				build_logs
+			(
+				repo_source,
+				repo_owner,
+				repo_name,
+				repo_branch,
+				repo_revision,
+				steps
+			)
+			VALUES
+			(
+				$1,
+				$2,
+				$3,
+				$4,
+				$5,
+				$6
+			)
+			`,
+			buildLog.RepoSource,
+			buildLog.RepoOwner,
+			buildLog.RepoName,
+			buildLog.RepoBranch,
+			buildLog.RepoRevision,
+			bytes,
+		)
+
+		if err != nil {
+			// log extra detail for filing a ticket regarding 'pq: command is too large: xxx bytes (max: 67108864)' issue
+			nrLines := 0
+			for _, s := range buildLog.Steps {
+				nrLines += len(s.LogLines)
+			}
+			log.Error().Msgf("INSERT INTO build_logs: failed for %v/%v/%v/%v (%v steps, %v lines, %v bytes)", buildLog.RepoSource, buildLog.RepoOwner, buildLog.RepoName, buildLog.RepoRevision, len(buildLog.Steps), nrLines, len(bytes))
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+
+			return
+		}
+
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
+
 		return
 	}
 
-	// read rows
-	if pipelines, err = dbc.scanPipelines(rows, optimized); err != nil {
+	// insert logs
+	_, err = dbc.databaseConnection.Exec(
+		`
+		INSERT INTO
+			build_logs
+		(
+			repo_source,
+			repo_owner,
+			repo_name,
+			repo_branch,
+			repo_revision,
+			build_id,
+			steps
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7
+		)
+		`,
+		buildLog.RepoSource,
+		buildLog.RepoOwner,
+		buildLog.RepoName,
+		buildLog.RepoBranch,
+		buildLog.RepoRevision,
+		buildID,
+		bytes,
+	)
+
+	if err != nil {
+		// log extra detail for filing a ticket regarding 'pq: command is too large: xxx bytes (max: 67108864)' issue
+		nrLines := 0
+		for _, s := range buildLog.Steps {
+			nrLines += len(s.LogLines)
+		}
+		log.Error().Msgf("INSERT INTO build_logs: failed for %v/%v/%v/%v (%v steps, %v lines, %v bytes)", buildLog.RepoSource, buildLog.RepoOwner, buildLog.RepoName, buildLog.RepoRevision, len(buildLog.Steps), nrLines, len(bytes))
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
+
 		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelinesByRepoName(ctx context.Context, repoName string, optimized bool) (pipelines []*contracts.Pipeline, err error) {
+func (dbc *cockroachDBClientImpl) InsertReleaseLog(ctx context.Context, releaseLog contracts.ReleaseLog) (err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelinesByRepoName")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::InsertReleaseLog")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::InsertReleaseLog", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// generate query
-	query := dbc.selectPipelinesQuery().
-		Where(sq.Eq{"a.repo_name": repoName})
-
-	// execute query
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	bytes, err := json.Marshal(releaseLog.Steps)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	// read rows
-	if pipelines, err = dbc.scanPipelines(rows, optimized); err != nil {
+	dbc.PrometheusLogInsertBytes.With(prometheus.Labels{"type": "release"}).Observe(float64(len(bytes)))
+
+	releaseID, err := strconv.Atoi(releaseLog.ReleaseID)
+	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return
+		return err
 	}
 
-	return
-}
-
-func (dbc *cockroachDBClientImpl) GetPipelinesCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelinesCount")
-	defer span.Finish()
-
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
-
-	// generate query
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("COUNT(a.id)").
-			From("computed_pipelines a")
+	// insert logs
+	_, err = dbc.databaseConnection.Exec(
+		`
+		INSERT INTO
+			release_logs
+		(
+			repo_source,
+			repo_owner,
+			repo_name,
+			release_id,
+			steps
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5
+		)
+		`,
+		releaseLog.RepoSource,
+		releaseLog.RepoOwner,
+		releaseLog.RepoName,
+		releaseID,
+		bytes,
+	)
 
-	// dynamically set where clauses for filtering
-	query, err = whereClauseGeneratorForAllFilters(query, "a", "last_updated_at", filters)
 	if err != nil {
+		// log extra detail for filing a ticket regarding 'pq: command is too large: xxx bytes (max: 67108864)' issue
+		nrLines := 0
+		for _, s := range releaseLog.Steps {
+			nrLines += len(s.LogLines)
+		}
+		log.Error().Msgf("INSERT INTO build_logs: failed for %v/%v/%v/%v (%v steps, %v lines, %v bytes)", releaseLog.RepoSource, releaseLog.RepoOwner, releaseLog.RepoName, releaseLog.ReleaseID, len(releaseLog.Steps), nrLines, len(bytes))
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return
-	}
-
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if err = row.Scan(&totalCount); err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
-
-	return
-}
-
-func (dbc *cockroachDBClientImpl) GetPipeline(ctx context.Context, repoSource, repoOwner, repoName string, optimized bool) (pipeline *contracts.Pipeline, err error) {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipeline")
-	defer span.Finish()
-
-	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", repoSource, repoOwner, repoName))
-
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
-
-	// generate query
-	query := dbc.selectPipelinesQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(1))
 
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if pipeline, err = dbc.scanPipeline(row, optimized); err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
 		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineBuilds(ctx context.Context, repoSource, repoOwner, repoName string, pageNumber, pageSize int, filters map[string][]string, optimized bool) (builds []*contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) UpsertComputedPipeline(ctx context.Context, repoSource, repoOwner, repoName string) (err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuilds")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpsertComputedPipeline")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpsertComputedPipeline", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// generate query
-	query := dbc.selectBuildsQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(pageSize)).
-		Offset(uint64((pageNumber - 1) * pageSize))
-
-	// dynamically set where clauses for filtering
-	query, err = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", filters)
-	if err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
-
-	// execute query
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	// get last build
+	lastBuild, err := dbc.GetLastPipelineBuild(ctx, repoSource, repoOwner, repoName, false)
 	if err != nil {
+		log.Error().Err(err).Msgf("Failed getting last build for upserting computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-
-	// read rows
-	if builds, err = dbc.scanBuilds(rows, optimized); err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
+	if lastBuild == nil {
+		log.Error().Msgf("Failed getting last build for upserting computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
 		return
 	}
 
-	return
-}
-
-func (dbc *cockroachDBClientImpl) GetPipelineBuildsCount(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (totalCount int, err error) {
+	upsertedPipeline := dbc.mapBuildToPipeline(lastBuild)
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsCount")
-	defer span.Finish()
+	// add releases
+	dbc.enrichPipeline(ctx, upsertedPipeline)
 
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+	// set LastUpdatedAt from both builds and releases
+	upsertedPipeline.LastUpdatedAt = upsertedPipeline.InsertedAt
+	if upsertedPipeline.UpdatedAt.After(upsertedPipeline.LastUpdatedAt) {
+		upsertedPipeline.LastUpdatedAt = upsertedPipeline.UpdatedAt
+	}
+	for _, rt := range upsertedPipeline.ReleaseTargets {
+		for _, ar := range rt.ActiveReleases {
+			if ar.InsertedAt != nil && ar.InsertedAt.After(upsertedPipeline.LastUpdatedAt) {
+				upsertedPipeline.LastUpdatedAt = *ar.InsertedAt
+			}
+			if ar.UpdatedAt != nil && ar.UpdatedAt.After(upsertedPipeline.LastUpdatedAt) {
+				upsertedPipeline.LastUpdatedAt = *ar.UpdatedAt
+			}
+		}
+	}
 
-	// generate query
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("COUNT(*)").
-			From("builds a").
-			Where(sq.Eq{"a.repo_source": repoSource}).
-			Where(sq.Eq{"a.repo_owner": repoOwner}).
-			Where(sq.Eq{"a.repo_name": repoName})
+	// sort labels by key
+	sort.Slice(upsertedPipeline.Labels, func(i, j int) bool {
+		return upsertedPipeline.Labels[i].Key < upsertedPipeline.Labels[j].Key
+	})
 
-	// dynamically set where clauses for filtering
-	query, err = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", filters)
+	labelsBytes, err := json.Marshal(upsertedPipeline.Labels)
 	if err != nil {
+		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", upsertedPipeline.RepoSource, upsertedPipeline.RepoOwner, upsertedPipeline.RepoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if err = row.Scan(&totalCount); err != nil {
+	releaseTargetsBytes, err := json.Marshal(upsertedPipeline.ReleaseTargets)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", upsertedPipeline.RepoSource, upsertedPipeline.RepoOwner, upsertedPipeline.RepoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-
-	return
-}
-
-func (dbc *cockroachDBClientImpl) GetPipelineBuild(ctx context.Context, repoSource, repoOwner, repoName, repoRevision string, optimized bool) (build *contracts.Build, err error) {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuild")
-	defer span.Finish()
-
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
-
-	// generate query
-	query := dbc.selectBuildsQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		Where(sq.Eq{"a.repo_revision": repoRevision}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(1))
-
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if build, err = dbc.scanBuild(ctx, row, optimized, true); err != nil {
+	commitsBytes, err := json.Marshal(upsertedPipeline.Commits)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", upsertedPipeline.RepoSource, upsertedPipeline.RepoOwner, upsertedPipeline.RepoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-
-	return
-}
-
-func (dbc *cockroachDBClientImpl) GetPipelineBuildByID(ctx context.Context, repoSource, repoOwner, repoName string, id int, optimized bool) (build *contracts.Build, err error) {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildByID")
-	defer span.Finish()
-
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
-
-	// generate query
-	query := dbc.selectBuildsQuery().
-		Where(sq.Eq{"a.id": id}).
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(1))
-
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if build, err = dbc.scanBuild(ctx, row, optimized, true); err != nil {
+	triggersBytes, err := json.Marshal(upsertedPipeline.Triggers)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", upsertedPipeline.RepoSource, upsertedPipeline.RepoOwner, upsertedPipeline.RepoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-
-	return
-}
-
-func (dbc *cockroachDBClientImpl) GetLastPipelineBuild(ctx context.Context, repoSource, repoOwner, repoName string, optimized bool) (build *contracts.Build, err error) {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetLastPipelineBuild")
-	defer span.Finish()
-
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
-
-	// generate query
-	query := dbc.selectBuildsQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(1))
-
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if build, err = dbc.scanBuild(ctx, row, optimized, false); err != nil {
+	eventsBytes, err := json.Marshal(upsertedPipeline.Events)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", upsertedPipeline.RepoSource, upsertedPipeline.RepoOwner, upsertedPipeline.RepoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	return
-}
-
-func (dbc *cockroachDBClientImpl) GetFirstPipelineBuild(ctx context.Context, repoSource, repoOwner, repoName string, optimized bool) (build *contracts.Build, err error) {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFirstPipelineBuild")
-	defer span.Finish()
-
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
-
-	// generate query
-	query := dbc.selectBuildsQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		OrderBy("a.inserted_at ASC").
-		Limit(uint64(1))
-
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if build, err = dbc.scanBuild(ctx, row, optimized, false); err != nil {
+	// upsert computed pipeline
+	_, err = dbc.databaseConnection.Exec(
+		`
+		INSERT INTO
+			computed_pipelines
+		(
+			pipeline_id,
+			repo_source,
+			repo_owner,
+			repo_name,
+			repo_branch,
+			repo_revision,
+			build_version,
+			build_status,
+			labels,
+			release_targets,
+			manifest,
+			commits,
+			triggers,
+			inserted_at,
+			first_inserted_at,
+			updated_at,
+			duration,
+			last_updated_at,
+			triggered_by_event
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$9,
+			$10,
+			$11,
+			$12,
+			$13,
+			$14,
+			$15,
+			$16,
+			AGE($16,$15),
+			$17,
+			$18
+		)
+		ON CONFLICT
+		(
+			repo_source,
+			repo_owner,
+			repo_name
+		)
+		DO UPDATE SET
+			pipeline_id = excluded.pipeline_id,
+			repo_branch = excluded.repo_branch,
+			repo_revision = excluded.repo_revision,
+			build_version = excluded.build_version,
+			build_status = excluded.build_status,
+			labels = excluded.labels,
+			release_targets = excluded.release_targets,
+			manifest = excluded.manifest,
+			commits = excluded.commits,
+			triggers = excluded.triggers,
+			inserted_at = excluded.inserted_at,
+			updated_at = excluded.updated_at,
+			duration = AGE(excluded.updated_at,excluded.inserted_at),
+			last_updated_at = excluded.last_updated_at,
+			triggered_by_event = excluded.triggered_by_event
+		`,
+		upsertedPipeline.ID,
+		upsertedPipeline.RepoSource,
+		upsertedPipeline.RepoOwner,
+		upsertedPipeline.RepoName,
+		upsertedPipeline.RepoBranch,
+		upsertedPipeline.RepoRevision,
+		upsertedPipeline.BuildVersion,
+		upsertedPipeline.BuildStatus,
+		labelsBytes,
+		releaseTargetsBytes,
+		upsertedPipeline.Manifest,
+		commitsBytes,
+		triggersBytes,
+		upsertedPipeline.InsertedAt,
+		upsertedPipeline.InsertedAt,
+		upsertedPipeline.UpdatedAt,
+		upsertedPipeline.LastUpdatedAt,
+		eventsBytes,
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed upserting computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -1499,81 +1572,48 @@ func (dbc *cockroachDBClientImpl) GetFirstPipelineBuild(ctx context.Context, rep
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetLastPipelineBuildForBranch(ctx context.Context, repoSource, repoOwner, repoName, branch string) (build *contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) UpdateComputedPipelineFirstInsertedAt(ctx context.Context, repoSource, repoOwner, repoName string) (err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetLastPipelineBuildForBranch")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateComputedPipelineFirstInsertedAt")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateComputedPipelineFirstInsertedAt", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// generate query
-	query := dbc.selectBuildsQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		Where(sq.Eq{"a.repo_branch": branch}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(1))
-
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if build, err = dbc.scanBuild(ctx, row, false, false); err != nil {
+	// get first build
+	firstBuild, err := dbc.GetFirstPipelineBuild(ctx, repoSource, repoOwner, repoName, false)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed getting first build for updating computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-
-	return
-}
-
-func (dbc *cockroachDBClientImpl) GetLastPipelineRelease(ctx context.Context, repoSource, repoOwner, repoName, releaseName, releaseAction string) (release *contracts.Release, err error) {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetLastPipelineRelease")
-	defer span.Finish()
-
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
-
-	// generate query
-	query := dbc.selectReleasesQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		Where(sq.Eq{"a.release": releaseName}).
-		Where(sq.Eq{"a.release_action": releaseAction}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(1))
-
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if release, err = dbc.scanRelease(row); err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
+	if firstBuild == nil {
+		log.Error().Msgf("Failed getting first build for updating computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
 		return
 	}
 
-	return
-}
-
-func (dbc *cockroachDBClientImpl) GetFirstPipelineRelease(ctx context.Context, repoSource, repoOwner, repoName, releaseName, releaseAction string) (release *contracts.Release, err error) {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFirstPipelineRelease")
-	defer span.Finish()
-
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
-
-	// generate query
-	query := dbc.selectReleasesQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		Where(sq.Eq{"a.release": releaseName}).
-		Where(sq.Eq{"a.release_action": releaseAction}).
-		OrderBy("a.inserted_at ASC").
-		Limit(uint64(1))
+	updatedPipeline := dbc.mapBuildToPipeline(firstBuild)
 
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if release, err = dbc.scanRelease(row); err != nil {
+	// update computed pipeline
+	_, err = dbc.databaseConnection.Exec(
+		`
+		UPDATE
+			computed_pipelines
+		SET
+			first_inserted_at=$1
+		WHERE
+			repo_source=$2 AND
+			repo_owner=$3 AND
+			repo_name=$4
+		`,
+		updatedPipeline.InsertedAt,
+		updatedPipeline.RepoSource,
+		updatedPipeline.RepoOwner,
+		updatedPipeline.RepoName,
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed updating computed pipeline %v/%v/%v", repoSource, repoOwner, repoName)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -1582,135 +1622,193 @@ func (dbc *cockroachDBClientImpl) GetFirstPipelineRelease(ctx context.Context, r
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineBuildsByVersion(ctx context.Context, repoSource, repoOwner, repoName, buildVersion string, optimized bool) (builds []*contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) UpsertComputedRelease(ctx context.Context, repoSource, repoOwner, repoName, releaseName, releaseAction string) (err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsByVersion")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpsertComputedRelease")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpsertComputedRelease", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// generate query
-	query := dbc.selectBuildsQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		Where(sq.Eq{"a.build_version": buildVersion}).
-		OrderBy("a.inserted_at DESC")
-
-	// execute query
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	// get last release
+	lastRelease, err := dbc.GetLastPipelineRelease(ctx, repoSource, repoOwner, repoName, releaseName, releaseAction)
 	if err != nil {
+		log.Error().Err(err).Msgf("Failed getting last release for upserting computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
-
-	// read rows
-	if builds, err = dbc.scanBuilds(rows, optimized); err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
+	if lastRelease == nil {
+		log.Error().Msgf("Failed getting last release for upserting computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
 		return
 	}
 
-	return
-}
-
-func (dbc *cockroachDBClientImpl) GetPipelineBuildLogs(ctx context.Context, repoSource, repoOwner, repoName, repoBranch, repoRevision, buildID string) (buildLog *contracts.BuildLog, err error) {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildLogs")
-	defer span.Finish()
-
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
-
-	buildIDAsInt, err := strconv.Atoi(buildID)
+	eventsBytes, err := json.Marshal(lastRelease.Events)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return nil, err
+		return
 	}
 
-	// generate query
-	query := dbc.selectBuildLogsQuery().
-		Where(sq.Eq{"a.build_id": buildIDAsInt}).
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		Where(sq.Eq{"a.repo_branch": repoBranch}).
-		Where(sq.Eq{"a.repo_revision": repoRevision}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(1))
-
-	buildLog = &contracts.BuildLog{}
-	var stepsData []uint8
-	var rowBuildID sql.NullInt64
-
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if err = row.Scan(&buildLog.ID,
-		&buildLog.RepoSource,
-		&buildLog.RepoOwner,
-		&buildLog.RepoName,
-		&buildLog.RepoBranch,
-		&buildLog.RepoRevision,
-		&rowBuildID,
-		&stepsData,
-		&buildLog.InsertedAt); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
+	// upsert computed release
+	_, err = dbc.databaseConnection.Exec(
+		`
+		INSERT INTO
+			computed_releases
+		(
+			release_id,
+			repo_source,
+			repo_owner,
+			repo_name,
+			release,
+			release_version,
+			release_status,
+			inserted_at,
+			first_inserted_at,
+			updated_at,
+			duration,
+			release_action,
+			triggered_by_event
+		)
+		VALUES
+		(
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8,
+			$8,
+			$9,
+			AGE($9,$8),
+			$10,
+			$11
+		)
+		ON CONFLICT
+		(
+			repo_source,
+			repo_owner,
+			repo_name,
+			release,
+			release_action
+		)
+		DO UPDATE SET
+			release_id = excluded.release_id,
+			release_version = excluded.release_version,
+			release_status = excluded.release_status,
+			inserted_at = excluded.inserted_at,
+			updated_at = excluded.updated_at,
+			duration = AGE(excluded.updated_at,excluded.inserted_at),
+			triggered_by_event = excluded.triggered_by_event
+		`,
+		lastRelease.ID,
+		lastRelease.RepoSource,
+		lastRelease.RepoOwner,
+		lastRelease.RepoName,
+		lastRelease.Name,
+		lastRelease.ReleaseVersion,
+		lastRelease.ReleaseStatus,
+		lastRelease.InsertedAt,
+		lastRelease.UpdatedAt,
+		lastRelease.Action,
+		eventsBytes,
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed upserting computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	if err = json.Unmarshal(stepsData, &buildLog.Steps); err != nil {
+	return
+}
+
+func (dbc *cockroachDBClientImpl) UpdateComputedReleaseFirstInsertedAt(ctx context.Context, repoSource, repoOwner, repoName, releaseName, releaseAction string) (err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateComputedReleaseFirstInsertedAt")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateComputedReleaseFirstInsertedAt", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// get first release
+	firstRelease, err := dbc.GetFirstPipelineRelease(ctx, repoSource, repoOwner, repoName, releaseName, releaseAction)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed getting first release for updating computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
+	if firstRelease == nil {
+		log.Error().Msgf("Failed getting first release for updating computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
+		return
+	}
 
-	if rowBuildID.Valid {
-		buildLog.BuildID = strconv.FormatInt(rowBuildID.Int64, 10)
-
-		// if theses logs have been stored with build_id it could be a rebuild version with multiple logs, so match the supplied build id
-		if buildLog.BuildID == buildID {
-			return
-		}
-
-		// otherwise reset to make sure we don't return the wrong logs if this is still a running build?
-		// buildLog = &contracts.BuildLog{}
+	// update computed release
+	_, err = dbc.databaseConnection.Exec(
+		`
+		UPDATE
+			computed_releases
+		SET
+			first_inserted_at=$1
+		WHERE
+			repo_source=$2 AND
+			repo_owner=$3 AND
+			repo_name=$4 AND
+			release=$5 AND
+			release_action=$6
+		`,
+		firstRelease.InsertedAt,
+		firstRelease.RepoSource,
+		firstRelease.RepoOwner,
+		firstRelease.RepoName,
+		firstRelease.Name,
+		firstRelease.Action,
+	)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed updating computed release %v/%v/%v/%v/%v", repoSource, repoOwner, repoName, releaseName, releaseAction)
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineBuildMaxResourceUtilization(ctx context.Context, repoSource, repoOwner, repoName string, lastNRecords int) (jobResources JobResources, recordCount int, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelines(ctx context.Context, pageNumber, pageSize int, filters map[string][]string, optimized bool) (pipelines []*contracts.Pipeline, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildMaxResourceUtilization")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelines")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelines", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-
-	innerquery := psql.
-		Select("cpu_max_usage, memory_max_usage").
-		From("builds").
-		Where(sq.Eq{"repo_source": repoSource}).
-		Where(sq.Eq{"repo_owner": repoOwner}).
-		Where(sq.Eq{"repo_name": repoName}).
-		Where(sq.NotEq{"cpu_max_usage": nil}).
-		Where(sq.NotEq{"memory_max_usage": nil}).
-		OrderBy("inserted_at DESC").
-		Limit(uint64(lastNRecords))
+	query := dbc.selectPipelinesQuery().
+		OrderBy("a.repo_source,a.repo_owner,a.repo_name").
+		Limit(uint64(pageSize)).
+		Offset(uint64((pageNumber - 1) * pageSize))
 
-	query := psql.Select("COALESCE(MAX(a.cpu_max_usage),0) AS max_cpu_max_usage, COALESCE(MAX(a.memory_max_usage),0) AS max_memory_max_usage, COUNT(a.*) AS nr_records").
-		FromSelect(innerquery, "a")
+	// dynamically set where clauses for filtering
+	query, err = whereClauseGeneratorForAllFilters(query, "a", "last_updated_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
 	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if err = row.Scan(&jobResources.CPUMaxUsage, &jobResources.MemoryMaxUsage, &recordCount); err != nil {
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	// read rows
+	if pipelines, err = dbc.scanPipelines(rows, optimized); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -1719,29 +1817,17 @@ func (dbc *cockroachDBClientImpl) GetPipelineBuildMaxResourceUtilization(ctx con
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineReleases(ctx context.Context, repoSource, repoOwner, repoName string, pageNumber, pageSize int, filters map[string][]string) (releases []*contracts.Release, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelinesByRepoName(ctx context.Context, repoName string, optimized bool) (pipelines []*contracts.Pipeline, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleases")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelinesByRepoName")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelinesByRepoName", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	query := dbc.selectReleasesQuery().
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(pageSize)).
-		Offset(uint64((pageNumber - 1) * pageSize))
-
-	// dynamically set where clauses for filtering
-	query, err = whereClauseGeneratorForAllReleaseFilters(query, "a", "inserted_at", filters)
-	if err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
+	query := dbc.selectPipelinesQuery().
+		Where(sq.Eq{"a.repo_name": repoName})
 
 	// execute query
 	rows, err := query.RunWith(dbc.databaseConnection).Query()
@@ -1752,7 +1838,7 @@ func (dbc *cockroachDBClientImpl) GetPipelineReleases(ctx context.Context, repoS
 	}
 
 	// read rows
-	if releases, err = dbc.scanReleases(rows); err != nil {
+	if pipelines, err = dbc.scanPipelines(rows, optimized); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -1761,24 +1847,22 @@ func (dbc *cockroachDBClientImpl) GetPipelineReleases(ctx context.Context, repoS
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineReleasesCount(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (totalCount int, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelinesCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleasesCount")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelinesCount")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelinesCount", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
 	query :=
 		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("COUNT(*)").
-			From("releases a").
-			Where(sq.Eq{"a.repo_source": repoSource}).
-			Where(sq.Eq{"a.repo_owner": repoOwner}).
-			Where(sq.Eq{"a.repo_name": repoName})
+			Select("COUNT(a.id)").
+			From("computed_pipelines a")
 
 	// dynamically set where clauses for filtering
-	query, err = whereClauseGeneratorForAllReleaseFilters(query, "a", "inserted_at", filters)
+	query, err = whereClauseGeneratorForAllFilters(query, "a", "last_updated_at", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
@@ -1796,16 +1880,18 @@ func (dbc *cockroachDBClientImpl) GetPipelineReleasesCount(ctx context.Context,
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineRelease(ctx context.Context, repoSource, repoOwner, repoName string, id int) (release *contracts.Release, err error) {
+func (dbc *cockroachDBClientImpl) GetPipeline(ctx context.Context, repoSource, repoOwner, repoName string, optimized bool) (pipeline *contracts.Pipeline, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineRelease")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipeline")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipeline", time.Now())
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", repoSource, repoOwner, repoName))
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	query := dbc.selectReleasesQuery().
-		Where(sq.Eq{"a.id": id}).
+	query := dbc.selectPipelinesQuery().
 		Where(sq.Eq{"a.repo_source": repoSource}).
 		Where(sq.Eq{"a.repo_owner": repoOwner}).
 		Where(sq.Eq{"a.repo_name": repoName}).
@@ -1814,7 +1900,7 @@ func (dbc *cockroachDBClientImpl) GetPipelineRelease(ctx context.Context, repoSo
 
 	// execute query
 	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if release, err = dbc.scanRelease(row); err != nil {
+	if pipeline, err = dbc.scanPipeline(row, optimized); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -1823,25 +1909,29 @@ func (dbc *cockroachDBClientImpl) GetPipelineRelease(ctx context.Context, repoSo
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineLastReleasesByName(ctx context.Context, repoSource, repoOwner, repoName, releaseName string, actions []string) (releases []contracts.Release, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuilds(ctx context.Context, repoSource, repoOwner, repoName string, pageNumber, pageSize int, filters map[string][]string, optimized bool) (builds []*contracts.Build, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineLastReleasesByName")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuilds")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuilds", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	query := dbc.selectComputedReleasesQuery().
-		Where(sq.Eq{"a.release": releaseName}).
+	query := dbc.selectBuildsQuery().
 		Where(sq.Eq{"a.repo_source": repoSource}).
 		Where(sq.Eq{"a.repo_owner": repoOwner}).
 		Where(sq.Eq{"a.repo_name": repoName}).
-		OrderBy("a.inserted_at DESC")
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(pageSize)).
+		Offset(uint64((pageNumber - 1) * pageSize))
 
-	if len(actions) > 0 {
-		query = query.Where(sq.Eq{"a.release_action": actions})
-	} else {
-		query = query.Where(sq.Eq{"a.release_action": ""})
+	// dynamically set where clauses for filtering
+	query, err = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
 	}
 
 	// execute query
@@ -1853,63 +1943,43 @@ func (dbc *cockroachDBClientImpl) GetPipelineLastReleasesByName(ctx context.Cont
 	}
 
 	// read rows
-	releasesPointers := []*contracts.Release{}
-	if releasesPointers, err = dbc.scanReleases(rows); err != nil {
+	if builds, err = dbc.scanBuilds(rows, optimized); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return releases, err
-	}
-
-	// copy pointer values
-	releases = make([]contracts.Release, 0)
-	for _, r := range releasesPointers {
-		releases = append(releases, *r)
+		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineReleaseLogs(ctx context.Context, repoSource, repoOwner, repoName string, id int) (releaseLog *contracts.ReleaseLog, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuildsCount(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (totalCount int, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleaseLogs")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsCount")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildsCount", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	query := dbc.selectReleaseLogsQuery().
-		Where(sq.Eq{"a.release_id": id}).
-		Where(sq.Eq{"a.repo_source": repoSource}).
-		Where(sq.Eq{"a.repo_owner": repoOwner}).
-		Where(sq.Eq{"a.repo_name": repoName}).
-		OrderBy("a.inserted_at DESC").
-		Limit(uint64(1))
-
-	releaseLog = &contracts.ReleaseLog{}
-
-	var stepsData []uint8
-	var releaseID int
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("COUNT(*)").
+			From("builds a").
+			Where(sq.Eq{"a.repo_source": repoSource}).
+			Where(sq.Eq{"a.repo_owner": repoOwner}).
+			Where(sq.Eq{"a.repo_name": repoName})
 
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if err = row.Scan(&releaseLog.ID,
-		&releaseLog.RepoSource,
-		&releaseLog.RepoOwner,
-		&releaseLog.RepoName,
-		&releaseID,
-		&stepsData,
-		&releaseLog.InsertedAt); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
+	// dynamically set where clauses for filtering
+	query, err = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", filters)
+	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	releaseLog.ReleaseID = strconv.Itoa(releaseID)
-
-	if err = json.Unmarshal(stepsData, &releaseLog.Steps); err != nil {
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&totalCount); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -1918,34 +1988,26 @@ func (dbc *cockroachDBClientImpl) GetPipelineReleaseLogs(ctx context.Context, re
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineReleaseMaxResourceUtilization(ctx context.Context, repoSource, repoOwner, repoName, targetName string, lastNRecords int) (jobResources JobResources, recordCount int, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuild(ctx context.Context, repoSource, repoOwner, repoName, repoRevision string, optimized bool) (build *contracts.Build, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildMaxResourceUtilization")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuild")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuild", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-
-	innerquery := psql.
-		Select("cpu_max_usage, memory_max_usage").
-		From("releases").
-		Where(sq.Eq{"repo_source": repoSource}).
-		Where(sq.Eq{"repo_owner": repoOwner}).
-		Where(sq.Eq{"repo_name": repoName}).
-		Where(sq.Eq{"release": targetName}).
-		Where(sq.NotEq{"cpu_max_usage": nil}).
-		Where(sq.NotEq{"memory_max_usage": nil}).
-		OrderBy("inserted_at DESC").
-		Limit(uint64(lastNRecords))
-
-	query := psql.Select("COALESCE(MAX(a.cpu_max_usage),0) AS max_cpu_max_usage, COALESCE(MAX(a.memory_max_usage),0) AS max_memory_max_usage, COUNT(a.*) AS nr_records").
-		FromSelect(innerquery, "a")
+	query := dbc.selectBuildsQuery().
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.Eq{"a.repo_revision": repoRevision}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(1))
 
 	// execute query
 	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if err = row.Scan(&jobResources.CPUMaxUsage, &jobResources.MemoryMaxUsage, &recordCount); err != nil {
+	if build, err = dbc.scanBuild(ctx, row, optimized, true); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -1954,30 +2016,26 @@ func (dbc *cockroachDBClientImpl) GetPipelineReleaseMaxResourceUtilization(ctx c
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetBuildsCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuildByID(ctx context.Context, repoSource, repoOwner, repoName string, id int, optimized bool) (build *contracts.Build, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetBuildsCount")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildByID")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildByID", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("COUNT(*)").
-			From("builds a")
-
-	// dynamically set where clauses for filtering
-	query, err = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", filters)
-	if err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
+	query := dbc.selectBuildsQuery().
+		Where(sq.Eq{"a.id": id}).
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(1))
 
 	// execute query
 	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if err = row.Scan(&totalCount); err != nil {
+	if build, err = dbc.scanBuild(ctx, row, optimized, true); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -1986,30 +2044,25 @@ func (dbc *cockroachDBClientImpl) GetBuildsCount(ctx context.Context, filters ma
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetReleasesCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
+func (dbc *cockroachDBClientImpl) GetLastPipelineBuild(ctx context.Context, repoSource, repoOwner, repoName string, optimized bool) (build *contracts.Build, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetReleasesCount")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetLastPipelineBuild")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetLastPipelineBuild", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("COUNT(*)").
-			From("releases a")
-
-	// dynamically set where clauses for filtering
-	query, err = whereClauseGeneratorForAllReleaseFilters(query, "a", "inserted_at", filters)
-	if err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
+	query := dbc.selectBuildsQuery().
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(1))
 
 	// execute query
 	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if err = row.Scan(&totalCount); err != nil {
+	if build, err = dbc.scanBuild(ctx, row, optimized, false); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -2018,40 +2071,53 @@ func (dbc *cockroachDBClientImpl) GetReleasesCount(ctx context.Context, filters
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetBuildsDuration(ctx context.Context, filters map[string][]string) (totalDuration time.Duration, err error) {
+func (dbc *cockroachDBClientImpl) GetFirstPipelineBuild(ctx context.Context, repoSource, repoOwner, repoName string, optimized bool) (build *contracts.Build, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetBuildsDuration")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFirstPipelineBuild")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetFirstPipelineBuild", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("SUM(AGE(updated_at,inserted_at))::string").
-			From("builds a")
+	query := dbc.selectBuildsQuery().
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		OrderBy("a.inserted_at ASC").
+		Limit(uint64(1))
 
-	// dynamically set where clauses for filtering
-	query, err = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", filters)
-	if err != nil {
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if build, err = dbc.scanBuild(ctx, row, optimized, false); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	return
+}
 
-	var totalDurationAsString string
+func (dbc *cockroachDBClientImpl) GetLastPipelineBuildForBranch(ctx context.Context, repoSource, repoOwner, repoName, branch string) (build *contracts.Build, err error) {
 
-	if err = row.Scan(&totalDurationAsString); err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetLastPipelineBuildForBranch")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetLastPipelineBuildForBranch", time.Now())
 
-	totalDuration, err = time.ParseDuration(totalDurationAsString)
-	if err != nil {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query := dbc.selectBuildsQuery().
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.Eq{"a.repo_branch": branch}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(1))
+
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if build, err = dbc.scanBuild(ctx, row, false, false); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
@@ -2060,1300 +2126,3103 @@ func (dbc *cockroachDBClientImpl) GetBuildsDuration(ctx context.Context, filters
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetFirstBuildTimes(ctx context.Context) (buildTimes []time.Time, err error) {
+func (dbc *cockroachDBClientImpl) GetLastPipelineRelease(ctx context.Context, repoSource, repoOwner, repoName, releaseName, releaseAction string) (release *contracts.Release, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFirstBuildTimes")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetLastPipelineRelease")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetLastPipelineRelease", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("a.first_inserted_at").
-			From("computed_pipelines a").
-			OrderBy("a.first_inserted_at")
-
-	buildTimes = make([]time.Time, 0)
+	query := dbc.selectReleasesQuery().
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.Eq{"a.release": releaseName}).
+		Where(sq.Eq{"a.release_action": releaseAction}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(1))
 
 	// execute query
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
-
-	if err != nil {
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if release, err = dbc.scanRelease(row); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	defer rows.Close()
-	for rows.Next() {
+	return
+}
 
-		insertedAt := time.Time{}
+func (dbc *cockroachDBClientImpl) GetFirstPipelineRelease(ctx context.Context, repoSource, repoOwner, repoName, releaseName, releaseAction string) (release *contracts.Release, err error) {
 
-		if err = rows.Scan(
-			&insertedAt); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
-			return
-		}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFirstPipelineRelease")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetFirstPipelineRelease", time.Now())
 
-		buildTimes = append(buildTimes, insertedAt)
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query := dbc.selectReleasesQuery().
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.Eq{"a.release": releaseName}).
+		Where(sq.Eq{"a.release_action": releaseAction}).
+		OrderBy("a.inserted_at ASC").
+		Limit(uint64(1))
+
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if release, err = dbc.scanRelease(row); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetFirstReleaseTimes(ctx context.Context) (releaseTimes []time.Time, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuildsByVersion(ctx context.Context, repoSource, repoOwner, repoName, buildVersion string, optimized bool) (builds []*contracts.Build, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFirstReleaseTimes")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsByVersion")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildsByVersion", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("MIN(a.first_inserted_at)").
-			From("computed_releases a").
-			GroupBy("a.repo_source,a.repo_owner,a.repo_name").
-			OrderBy("MIN(a.first_inserted_at)")
-
-	releaseTimes = make([]time.Time, 0)
+	query := dbc.selectBuildsQuery().
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.Eq{"a.build_version": buildVersion}).
+		OrderBy("a.inserted_at DESC")
 
+	// execute query
 	rows, err := query.RunWith(dbc.databaseConnection).Query()
-
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	defer rows.Close()
-	for rows.Next() {
-
-		insertedAt := time.Time{}
-
-		if err = rows.Scan(
-			&insertedAt); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
-			return
-		}
-
-		releaseTimes = append(releaseTimes, insertedAt)
+	// read rows
+	if builds, err = dbc.scanBuilds(rows, optimized); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineBuildsDurations(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (durations []map[string]interface{}, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuildLogs(ctx context.Context, repoSource, repoOwner, repoName, repoBranch, repoRevision, buildID string) (buildLog *contracts.BuildLog, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsDurations")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildLogs")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildLogs", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// generate query
-	innerquery :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("a.inserted_at, a.duration::INT").
-			From("builds a").
-			Where(sq.Eq{"a.repo_source": repoSource}).
-			Where(sq.Eq{"a.repo_owner": repoOwner}).
-			Where(sq.Eq{"a.repo_name": repoName}).
-			OrderBy("a.inserted_at DESC")
-
-	innerquery, err = whereClauseGeneratorForBuildStatusFilter(innerquery, "a", filters)
+	buildIDAsInt, err := strconv.Atoi(buildID)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return
+		return nil, err
 	}
 
-	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
-	if err != nil {
+	// generate query
+	query := dbc.selectBuildLogsQuery().
+		Where(sq.Eq{"a.build_id": buildIDAsInt}).
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.Eq{"a.repo_branch": repoBranch}).
+		Where(sq.Eq{"a.repo_revision": repoRevision}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(1))
+
+	buildLog = &contracts.BuildLog{}
+	var stepsData []uint8
+	var rowBuildID sql.NullInt64
+
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&buildLog.ID,
+		&buildLog.RepoSource,
+		&buildLog.RepoOwner,
+		&buildLog.RepoName,
+		&buildLog.RepoBranch,
+		&buildLog.RepoRevision,
+		&rowBuildID,
+		&stepsData,
+		&buildLog.InsertedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("*").
-			FromSelect(innerquery, "a").
-			OrderBy("a.duration")
-
-	durations = make([]map[string]interface{}, 0)
-
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
-
-	if err != nil {
+	if err = json.Unmarshal(stepsData, &buildLog.Steps); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	defer rows.Close()
-	for rows.Next() {
-
-		var insertedAt time.Time
-		var seconds int
+	if rowBuildID.Valid {
+		buildLog.BuildID = strconv.FormatInt(rowBuildID.Int64, 10)
 
-		if err = rows.Scan(
-			&insertedAt, &seconds); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
+		// if theses logs have been stored with build_id it could be a rebuild version with multiple logs, so match the supplied build id
+		if buildLog.BuildID == buildID {
 			return
 		}
 
-		duration := time.Duration(seconds) * time.Second
-
-		durations = append(durations, map[string]interface{}{
-			"insertedAt": insertedAt,
-			"duration":   duration,
-		})
+		// otherwise reset to make sure we don't return the wrong logs if this is still a running build?
+		// buildLog = &contracts.BuildLog{}
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineReleasesDurations(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (durations []map[string]interface{}, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineBuildMaxResourceUtilization(ctx context.Context, repoSource, repoOwner, repoName string, lastNRecords int) (jobResources JobResources, recordCount int, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleasesDurations")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildMaxResourceUtilization")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildMaxResourceUtilization", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	innerquery :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("a.inserted_at, a.release, a.release_action, a.duration::INT").
-			From("releases a").
-			Where(sq.Eq{"a.repo_source": repoSource}).
-			Where(sq.Eq{"a.repo_owner": repoOwner}).
-			Where(sq.Eq{"a.repo_name": repoName}).
-			OrderBy("a.inserted_at DESC")
-
-	innerquery, err = whereClauseGeneratorForReleaseStatusFilter(innerquery, "a", filters)
-	if err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
-
-	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
-	if err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
-
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("*").
-			FromSelect(innerquery, "a").
-			OrderBy("a.duration")
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	durations = make([]map[string]interface{}, 0)
+	innerquery := psql.
+		Select("cpu_max_usage, memory_max_usage").
+		From("builds").
+		Where(sq.Eq{"repo_source": repoSource}).
+		Where(sq.Eq{"repo_owner": repoOwner}).
+		Where(sq.Eq{"repo_name": repoName}).
+		Where(sq.NotEq{"cpu_max_usage": nil}).
+		Where(sq.NotEq{"memory_max_usage": nil}).
+		OrderBy("inserted_at DESC").
+		Limit(uint64(lastNRecords))
 
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	query := psql.Select("COALESCE(MAX(a.cpu_max_usage),0) AS max_cpu_max_usage, COALESCE(MAX(a.memory_max_usage),0) AS max_memory_max_usage, COUNT(a.*) AS nr_records").
+		FromSelect(innerquery, "a")
 
-	if err != nil {
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&jobResources.CPUMaxUsage, &jobResources.MemoryMaxUsage, &recordCount); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	defer rows.Close()
-	for rows.Next() {
-
-		var insertedAt time.Time
-		var releaseName, releaseAction string
-		var seconds int
-
-		if err = rows.Scan(
-			&insertedAt, &releaseName, &releaseAction, &seconds); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
-			return
-		}
-
-		duration := time.Duration(seconds) * time.Second
-
-		durations = append(durations, map[string]interface{}{
-			"insertedAt": insertedAt,
-			"name":       releaseName,
-			"action":     releaseAction,
-			"duration":   duration,
-		})
-	}
-
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineBuildsCPUUsageMeasurements(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (measurements []map[string]interface{}, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineReleases(ctx context.Context, repoSource, repoOwner, repoName string, pageNumber, pageSize int, filters map[string][]string) (releases []*contracts.Release, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsCPUUsageMeasurements")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleases")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineReleases", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	innerquery :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("a.inserted_at, a.cpu_max_usage").
-			From("builds a").
-			Where(sq.Eq{"a.repo_source": repoSource}).
-			Where(sq.Eq{"a.repo_owner": repoOwner}).
-			Where(sq.Eq{"a.repo_name": repoName}).
-			Where(sq.NotEq{"a.cpu_max_usage": nil}).
-			OrderBy("a.inserted_at DESC")
+	query := dbc.selectReleasesQuery().
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(pageSize)).
+		Offset(uint64((pageNumber - 1) * pageSize))
 
-	innerquery, err = whereClauseGeneratorForBuildStatusFilter(innerquery, "a", filters)
+	// dynamically set where clauses for filtering
+	query, err = whereClauseGeneratorForAllReleaseFilters(query, "a", "inserted_at", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
+	// execute query
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("*").
-			FromSelect(innerquery, "a").
-			OrderBy("a.inserted_at")
-
-	measurements = make([]map[string]interface{}, 0)
-
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
-
-	if err != nil {
+	// read rows
+	if releases, err = dbc.scanReleases(rows); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	defer rows.Close()
-	for rows.Next() {
-
-		var insertedAt time.Time
-		var cpuMaxUsage float64
-
-		if err = rows.Scan(
-			&insertedAt, &cpuMaxUsage); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
-			return
-		}
-
-		measurements = append(measurements, map[string]interface{}{
-			"insertedAt":  insertedAt,
-			"cpuMaxUsage": cpuMaxUsage,
-		})
-	}
-
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineReleasesCPUUsageMeasurements(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (measurements []map[string]interface{}, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineReleasesCount(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (totalCount int, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleasesCPUUsageMeasurements")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleasesCount")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineReleasesCount", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	innerquery :=
+	query :=
 		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("a.inserted_at, a.release, a.release_action, a.cpu_max_usage").
+			Select("COUNT(*)").
 			From("releases a").
 			Where(sq.Eq{"a.repo_source": repoSource}).
 			Where(sq.Eq{"a.repo_owner": repoOwner}).
-			Where(sq.Eq{"a.repo_name": repoName}).
-			Where(sq.NotEq{"a.cpu_max_usage": nil}).
-			OrderBy("a.inserted_at DESC")
+			Where(sq.Eq{"a.repo_name": repoName})
 
-	innerquery, err = whereClauseGeneratorForReleaseStatusFilter(innerquery, "a", filters)
+	// dynamically set where clauses for filtering
+	query, err = whereClauseGeneratorForAllReleaseFilters(query, "a", "inserted_at", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
-	if err != nil {
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&totalCount); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("*").
-			FromSelect(innerquery, "a").
-			OrderBy("a.inserted_at")
-
-	measurements = make([]map[string]interface{}, 0)
+	return
+}
 
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+func (dbc *cockroachDBClientImpl) GetPipelineRelease(ctx context.Context, repoSource, repoOwner, repoName string, id int) (release *contracts.Release, err error) {
 
-	if err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineRelease")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineRelease", time.Now())
 
-	defer rows.Close()
-	for rows.Next() {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-		var insertedAt time.Time
-		var releaseName, releaseAction string
-		var cpuMaxUsage float64
-
-		if err = rows.Scan(
-			&insertedAt, &releaseName, &releaseAction, &cpuMaxUsage); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
-			return
-		}
+	// generate query
+	query := dbc.selectReleasesQuery().
+		Where(sq.Eq{"a.id": id}).
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(1))
 
-		measurements = append(measurements, map[string]interface{}{
-			"insertedAt":  insertedAt,
-			"name":        releaseName,
-			"action":      releaseAction,
-			"cpuMaxUsage": cpuMaxUsage,
-		})
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if release, err = dbc.scanRelease(row); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineBuildsMemoryUsageMeasurements(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (measurements []map[string]interface{}, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineLastReleasesByName(ctx context.Context, repoSource, repoOwner, repoName, releaseName string, actions []string) (releases []contracts.Release, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsMemoryUsageMeasurements")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineLastReleasesByName")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineLastReleasesByName", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	innerquery :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("a.inserted_at, a.memory_max_usage").
-			From("builds a").
-			Where(sq.Eq{"a.repo_source": repoSource}).
-			Where(sq.Eq{"a.repo_owner": repoOwner}).
-			Where(sq.Eq{"a.repo_name": repoName}).
-			Where(sq.NotEq{"a.memory_max_usage": nil}).
-			OrderBy("a.inserted_at DESC")
+	query := dbc.selectComputedReleasesQuery().
+		Where(sq.Eq{"a.release": releaseName}).
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		OrderBy("a.inserted_at DESC")
 
-	innerquery, err = whereClauseGeneratorForBuildStatusFilter(innerquery, "a", filters)
+	if len(actions) > 0 {
+		query = query.Where(sq.Eq{"a.release_action": actions})
+	} else {
+		query = query.Where(sq.Eq{"a.release_action": ""})
+	}
+
+	// execute query
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
-	if err != nil {
+	// read rows
+	releasesPointers := []*contracts.Release{}
+	if releasesPointers, err = dbc.scanReleases(rows); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return
+		return releases, err
 	}
 
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("*").
-			FromSelect(innerquery, "a").
-			OrderBy("a.inserted_at")
+	// copy pointer values
+	releases = make([]contracts.Release, 0)
+	for _, r := range releasesPointers {
+		releases = append(releases, *r)
+	}
 
-	measurements = make([]map[string]interface{}, 0)
+	return
+}
 
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+func (dbc *cockroachDBClientImpl) GetPipelineReleaseLogs(ctx context.Context, repoSource, repoOwner, repoName string, id int) (releaseLog *contracts.ReleaseLog, err error) {
 
-	if err != nil {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleaseLogs")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineReleaseLogs", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query := dbc.selectReleaseLogsQuery().
+		Where(sq.Eq{"a.release_id": id}).
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(1))
+
+	releaseLog = &contracts.ReleaseLog{}
+
+	var stepsData []uint8
+	var releaseID int
+
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&releaseLog.ID,
+		&releaseLog.RepoSource,
+		&releaseLog.RepoOwner,
+		&releaseLog.RepoName,
+		&releaseID,
+		&stepsData,
+		&releaseLog.InsertedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	defer rows.Close()
-	for rows.Next() {
-
-		var insertedAt time.Time
-		var memoryMaxUsage float64
-
-		if err = rows.Scan(
-			&insertedAt, &memoryMaxUsage); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
-			return
-		}
+	releaseLog.ReleaseID = strconv.Itoa(releaseID)
 
-		measurements = append(measurements, map[string]interface{}{
-			"insertedAt":     insertedAt,
-			"memoryMaxUsage": memoryMaxUsage,
-		})
+	if err = json.Unmarshal(stepsData, &releaseLog.Steps); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelineReleasesMemoryUsageMeasurements(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (measurements []map[string]interface{}, err error) {
+func (dbc *cockroachDBClientImpl) GetPipelineReleaseMaxResourceUtilization(ctx context.Context, repoSource, repoOwner, repoName, targetName string, lastNRecords int) (jobResources JobResources, recordCount int, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleasesMemoryUsageMeasurements")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleaseMaxResourceUtilization")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineReleaseMaxResourceUtilization", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	// generate query
-	innerquery :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("a.inserted_at, a.release, a.release_action, a.memory_max_usage").
-			From("releases a").
-			Where(sq.Eq{"a.repo_source": repoSource}).
-			Where(sq.Eq{"a.repo_owner": repoOwner}).
-			Where(sq.Eq{"a.repo_name": repoName}).
-			Where(sq.NotEq{"a.memory_max_usage": nil}).
-			OrderBy("a.inserted_at DESC")
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	innerquery, err = whereClauseGeneratorForReleaseStatusFilter(innerquery, "a", filters)
-	if err != nil {
+	innerquery := psql.
+		Select("cpu_max_usage, memory_max_usage").
+		From("releases").
+		Where(sq.Eq{"repo_source": repoSource}).
+		Where(sq.Eq{"repo_owner": repoOwner}).
+		Where(sq.Eq{"repo_name": repoName}).
+		Where(sq.Eq{"release": targetName}).
+		Where(sq.NotEq{"cpu_max_usage": nil}).
+		Where(sq.NotEq{"memory_max_usage": nil}).
+		OrderBy("inserted_at DESC").
+		Limit(uint64(lastNRecords))
+
+	query := psql.Select("COALESCE(MAX(a.cpu_max_usage),0) AS max_cpu_max_usage, COALESCE(MAX(a.memory_max_usage),0) AS max_memory_max_usage, COUNT(a.*) AS nr_records").
+		FromSelect(innerquery, "a")
+
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&jobResources.CPUMaxUsage, &jobResources.MemoryMaxUsage, &recordCount); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
-	if err != nil {
+	return
+}
+
+// GetPipelineBuildResourceMeasurements returns the measured resources of the most recent builds for a branch, or for all branches if repoBranch is empty, including builds that got oom-killed before their usage could be measured
+func (dbc *cockroachDBClientImpl) GetPipelineBuildResourceMeasurements(ctx context.Context, repoSource, repoOwner, repoName, repoBranch string, lastNRecords int) (measurements []*ResourceMeasurement, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildResourceMeasurements")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildResourceMeasurements", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	query := dbc.selectResourceMeasurementsQuery("builds", repoSource, repoOwner, repoName, lastNRecords)
+	if repoBranch != "" {
+		query = query.Where(sq.Eq{"a.repo_branch": repoBranch})
+	}
+
+	if measurements, err = dbc.queryResourceMeasurements(query); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("*").
-			FromSelect(innerquery, "a").
-			OrderBy("a.inserted_at")
+	return
+}
 
-	measurements = make([]map[string]interface{}, 0)
+// GetPipelineReleaseResourceMeasurements returns the measured resources of the most recent releases to a target, including releases that got oom-killed before their usage could be measured
+func (dbc *cockroachDBClientImpl) GetPipelineReleaseResourceMeasurements(ctx context.Context, repoSource, repoOwner, repoName, targetName string, lastNRecords int) (measurements []*ResourceMeasurement, err error) {
 
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleaseResourceMeasurements")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineReleaseResourceMeasurements", time.Now())
 
-	if err != nil {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	query := dbc.selectResourceMeasurementsQuery("releases", repoSource, repoOwner, repoName, lastNRecords).
+		Where(sq.Eq{"a.release": targetName})
+
+	if measurements, err = dbc.queryResourceMeasurements(query); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	defer rows.Close()
-	for rows.Next() {
+	return
+}
 
-		var insertedAt time.Time
-		var releaseName, releaseAction string
-		var memoryMaxUsage float64
+func (dbc *cockroachDBClientImpl) selectResourceMeasurementsQuery(table, repoSource, repoOwner, repoName string, lastNRecords int) sq.SelectBuilder {
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	return psql.
+		Select("COALESCE(a.cpu_max_usage, 0), COALESCE(a.memory_max_usage, 0), COALESCE(a.memory_limit, 0), COALESCE(a.oom_killed, false)").
+		From(fmt.Sprintf("%v a", table)).
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.Or{
+			sq.And{sq.NotEq{"a.cpu_max_usage": nil}, sq.NotEq{"a.memory_max_usage": nil}},
+			sq.Eq{"a.oom_killed": true},
+		}).
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(lastNRecords))
+}
+
+func (dbc *cockroachDBClientImpl) queryResourceMeasurements(query sq.SelectBuilder) (measurements []*ResourceMeasurement, err error) {
 
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	measurements = make([]*ResourceMeasurement, 0)
+	for rows.Next() {
+		measurement := &ResourceMeasurement{}
 		if err = rows.Scan(
-			&insertedAt, &releaseName, &releaseAction, &memoryMaxUsage); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
-			return
+			&measurement.CPUMaxUsage,
+			&measurement.MemoryMaxUsage,
+			&measurement.MemoryLimit,
+			&measurement.OOMKilled); err != nil {
+			return nil, err
 		}
-
-		measurements = append(measurements, map[string]interface{}{
-			"insertedAt":     insertedAt,
-			"name":           releaseName,
-			"action":         releaseAction,
-			"memoryMaxUsage": memoryMaxUsage,
-		})
+		measurements = append(measurements, measurement)
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetFrequentLabels(ctx context.Context, pageNumber, pageSize int, filters map[string][]string) (labels []map[string]interface{}, err error) {
+func (dbc *cockroachDBClientImpl) GetBuildsCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFrequentLabels")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetBuildsCount")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetBuildsCount", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// see https://github.com/cockroachdb/cockroach/issues/35848
+	// generate query
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("COUNT(*)").
+			From("builds a")
 
-	// for time being run following query, where the dynamic where clause is in the innermost select query:
+	// dynamically set where clauses for filtering
+	query, err = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-	// SELECT
-	// 		key, value, nr_computed_pipelines
-	// FROM
-	// 		(
-	// 				SELECT
-	// 						key, value, count(DISTINCT id) AS nr_computed_pipelines
-	// 				FROM
-	// 						(
-	// 								SELECT
-	// 										l->>'key' AS key, l->>'value' AS value, id
-	// 								FROM
-	// 										(SELECT id, jsonb_array_elements(labels) AS l FROM computed_pipelines where jsonb_typeof(labels) = 'array')
-	// 						)
-	// 				GROUP BY
-	// 						key, value
-	// 		)
-	// WHERE
-	// 		nr_computed_pipelines > 1
-	// ORDER BY
-	// 		nr_computed_pipelines DESC, key, value
-	// LIMIT 10;
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&totalCount); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-	arrayElementsQuery :=
-		sq.StatementBuilder.
-			Select("a.id, jsonb_array_elements(a.labels) AS l").
-			From("computed_pipelines a").
-			Where("jsonb_typeof(labels) = 'array'")
+	return
+}
 
-	arrayElementsQuery, err = whereClauseGeneratorForSinceFilter(arrayElementsQuery, "a", "last_updated_at", filters)
+func (dbc *cockroachDBClientImpl) GetReleasesCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetReleasesCount")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetReleasesCount", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("COUNT(*)").
+			From("releases a")
+
+	// dynamically set where clauses for filtering
+	query, err = whereClauseGeneratorForAllReleaseFilters(query, "a", "inserted_at", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	arrayElementsQuery, err = whereClauseGeneratorForBuildStatusFilter(arrayElementsQuery, "a", filters)
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&totalCount); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetBuildsDuration(ctx context.Context, filters map[string][]string) (totalDuration time.Duration, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetBuildsDuration")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetBuildsDuration", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("SUM(AGE(updated_at,inserted_at))::string").
+			From("builds a")
+
+	// dynamically set where clauses for filtering
+	query, err = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	arrayElementsQuery, err = whereClauseGeneratorForLabelsFilter(arrayElementsQuery, "a", filters)
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+
+	var totalDurationAsString string
+
+	if err = row.Scan(&totalDurationAsString); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	totalDuration, err = time.ParseDuration(totalDurationAsString)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	selectCountQuery :=
-		sq.StatementBuilder.
-			Select("l->>'key' AS key, l->>'value' AS value, id").
-			FromSelect(arrayElementsQuery, "b")
+	return
+}
+func (dbc *cockroachDBClientImpl) GetBuildsTimeSeries(ctx context.Context, interval string, filters map[string][]string) (buckets []*StatsBucket, err error) {
 
-	groupByQuery :=
-		sq.StatementBuilder.
-			Select("key, value, count(DISTINCT id) AS pipelinesCount").
-			FromSelect(selectCountQuery, "c").
-			GroupBy("key, value")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetBuildsTimeSeries")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetBuildsTimeSeries", time.Now())
 
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("key, value, pipelinesCount").
-			FromSelect(groupByQuery, "d").
-			Where(sq.Gt{"pipelinesCount": 1}).
-			OrderBy("pipelinesCount DESC, key, value").
-			Limit(uint64(pageSize)).
-			Offset(uint64((pageNumber - 1) * pageSize))
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	query, err := dbc.selectTimeSeriesQuery("builds", "build_status", interval)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
+	query, err = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	defer rows.Close()
+	query, err = whereClauseGeneratorForRepoSourceFilter(query, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-	cols, _ := rows.Columns()
-	for rows.Next() {
-		// Create a slice of interface{}'s to represent each column,
-		// and a second slice to contain pointers to each item in the columns slice.
-		columns := make([]interface{}, len(cols))
-		columnPointers := make([]interface{}, len(cols))
-		for i := range columns {
-			columnPointers[i] = &columns[i]
-		}
+	if buckets, err = dbc.queryTimeSeries(query); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-		// Scan the result into the column pointers...
-		if err = rows.Scan(columnPointers...); err != nil {
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetReleasesTimeSeries(ctx context.Context, interval string, filters map[string][]string) (buckets []*StatsBucket, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetReleasesTimeSeries")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetReleasesTimeSeries", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	query, err := dbc.selectTimeSeriesQuery("releases", "release_status", interval)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query, err = whereClauseGeneratorForAllReleaseFilters(query, "a", "inserted_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query, err = whereClauseGeneratorForRepoSourceFilter(query, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	// releases don't have labels themselves, so they're filtered by the labels of their pipeline
+	if labels, ok := filters["labels"]; ok && len(labels) > 0 {
+		query = query.Join("computed_pipelines p ON p.repo_source = a.repo_source AND p.repo_owner = a.repo_owner AND p.repo_name = a.repo_name")
+		query, err = whereClauseGeneratorForLabelsFilter(query, "p", filters)
+		if err != nil {
 			ext.Error.Set(span, true)
 			span.LogFields(otlog.Error(err))
-			return nil, err
+			return
 		}
+	}
 
-		// Create our map, and retrieve the value for each column from the pointers slice,
-		// storing it in the map with the name of the column as the key.
-		m := make(map[string]interface{})
-		for i, colName := range cols {
-			val := columnPointers[i].(*interface{})
-			m[colName] = *val
-		}
+	if buckets, err = dbc.queryTimeSeries(query); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-		labels = append(labels, m)
+	return
+}
+
+func (dbc *cockroachDBClientImpl) selectTimeSeriesQuery(table, statusColumn, interval string) (query sq.SelectBuilder, err error) {
+
+	// the interval ends up in the query itself, so only allow known values
+	if interval != "hour" && interval != "day" && interval != "week" {
+		return query, fmt.Errorf("Interval %v is not supported; use hour, day or week", interval)
 	}
 
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query = psql.
+		Select(fmt.Sprintf("date_trunc('%v', a.inserted_at) AS bucket, count(*), count(*) FILTER (WHERE a.%v = 'succeeded'), count(*) FILTER (WHERE a.%v = 'failed'), COALESCE(avg(a.duration::INT), 0), COALESCE(avg(a.cpu_max_usage), 0), COALESCE(avg(a.memory_max_usage), 0)", interval, statusColumn, statusColumn)).
+		From(fmt.Sprintf("%v a", table)).
+		GroupBy("bucket").
+		OrderBy("bucket")
+
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetFrequentLabelsCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
+func (dbc *cockroachDBClientImpl) queryTimeSeries(query sq.SelectBuilder) (buckets []*StatsBucket, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFrequentLabelsCount")
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	buckets = make([]*StatsBucket, 0)
+	for rows.Next() {
+		bucket := &StatsBucket{}
+		var averageDurationSeconds float64
+		if err = rows.Scan(
+			&bucket.Start,
+			&bucket.Count,
+			&bucket.Succeeded,
+			&bucket.Failed,
+			&averageDurationSeconds,
+			&bucket.AverageCPUMaxUsage,
+			&bucket.AverageMemoryMaxUsage); err != nil {
+			return nil, err
+		}
+		bucket.AverageDuration = time.Duration(averageDurationSeconds * float64(time.Second))
+		if bucket.Succeeded+bucket.Failed > 0 {
+			bucket.SuccessRate = float64(bucket.Succeeded) / float64(bucket.Succeeded+bucket.Failed)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetBuildsResourceUsage(ctx context.Context, filters map[string][]string) (usages []*ResourceUsage, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetBuildsResourceUsage")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetBuildsResourceUsage", time.Now())
 
 	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	// see https://github.com/cockroachdb/cockroach/issues/35848
+	innerquery := dbc.selectResourceUsageQuery("builds")
 
-	// for time being run following query, where the dynamic where clause is in the innermost select query:
+	innerquery, err = whereClauseGeneratorForAllFilters(innerquery, "a", "inserted_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-	// SELECT
-	// 		key, value, nr_computed_pipelines
-	// FROM
-	// 		(
-	// 				SELECT
-	// 						key, value, count(DISTINCT id) AS nr_computed_pipelines
-	// 				FROM
-	// 						(
-	// 								SELECT
-	// 										l->>'key' AS key, l->>'value' AS value, id
-	// 								FROM
-	// 										(SELECT id, jsonb_array_elements(labels) AS l FROM computed_pipelines where jsonb_typeof(labels) = 'array')
-	// 						)
-	// 				GROUP BY
-	// 						key, value
-	// 		)
-	// WHERE
-	// 		nr_computed_pipelines > 1
-	// ORDER BY
-	// 		nr_computed_pipelines DESC, key, value
-	// LIMIT 10;
+	innerquery, err = whereClauseGeneratorForRepoSourceFilter(innerquery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-	arrayElementsQuery :=
-		sq.StatementBuilder.
-			Select("a.id, jsonb_array_elements(a.labels) AS l").
-			From("computed_pipelines a").
-			Where("jsonb_typeof(labels) = 'array'")
+	if usages, err = dbc.queryResourceUsage(dbc.selectResourceUsageWithLabelsQuery(innerquery)); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-	arrayElementsQuery, err = whereClauseGeneratorForSinceFilter(arrayElementsQuery, "a", "last_updated_at", filters)
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetReleasesResourceUsage(ctx context.Context, filters map[string][]string) (usages []*ResourceUsage, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetReleasesResourceUsage")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetReleasesResourceUsage", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	innerquery := dbc.selectResourceUsageQuery("releases")
+
+	innerquery, err = whereClauseGeneratorForReleaseStatusFilter(innerquery, "a", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	arrayElementsQuery, err = whereClauseGeneratorForBuildStatusFilter(arrayElementsQuery, "a", filters)
+	innerquery, err = whereClauseGeneratorForSinceFilter(innerquery, "a", "inserted_at", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	arrayElementsQuery, err = whereClauseGeneratorForLabelsFilter(arrayElementsQuery, "a", filters)
+	innerquery, err = whereClauseGeneratorForRepoSourceFilter(innerquery, "a", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	selectCountQuery :=
-		sq.StatementBuilder.
-			Select("l->>'key' AS key, l->>'value' AS value, id").
-			FromSelect(arrayElementsQuery, "b")
+	// releases don't have labels themselves, so they're filtered by the labels of their pipeline
+	query, err := whereClauseGeneratorForLabelsFilter(dbc.selectResourceUsageWithLabelsQuery(innerquery), "p", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-	groupByQuery :=
-		sq.StatementBuilder.
-			Select("key, value, count(DISTINCT id) AS pipelinesCount").
-			FromSelect(selectCountQuery, "c").
-			GroupBy("key, value")
+	if usages, err = dbc.queryResourceUsage(query); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelineBuildsResourceUsage(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (usage *ResourceUsage, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsResourceUsage")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildsResourceUsage", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	innerquery := dbc.selectResourceUsageQuery("builds").
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName})
+
+	innerquery, err = whereClauseGeneratorForAllFilters(innerquery, "a", "inserted_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	usages, err := dbc.queryResourceUsage(dbc.selectResourceUsageWithLabelsQuery(innerquery))
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	if len(usages) > 0 {
+		usage = usages[0]
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) selectResourceUsageQuery(table string) sq.SelectBuilder {
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	// multiplying the resources by the duration in seconds gives core-seconds and byte-seconds; jobs that haven't finished have no duration yet and don't count
+	// the measured request sums only cover jobs with measured usage, so they can be compared with the usage sums
+	return psql.
+		Select("a.repo_source, a.repo_owner, a.repo_name, count(a.duration) AS jobs, COALESCE(sum(a.duration::INT), 0) AS seconds, COALESCE(sum(a.cpu_request * a.duration::INT), 0) AS cpu_request_seconds, COALESCE(sum(a.memory_request * a.duration::INT), 0) AS memory_request_seconds, COALESCE(sum(a.cpu_max_usage * a.duration::INT), 0) AS cpu_max_usage_seconds, COALESCE(sum(a.memory_max_usage * a.duration::INT), 0) AS memory_max_usage_seconds, COALESCE(sum(a.cpu_request * a.duration::INT) FILTER (WHERE a.cpu_max_usage IS NOT NULL), 0) AS cpu_measured_request_seconds, COALESCE(sum(a.memory_request * a.duration::INT) FILTER (WHERE a.memory_max_usage IS NOT NULL), 0) AS memory_measured_request_seconds").
+		From(fmt.Sprintf("%v a", table)).
+		GroupBy("a.repo_source, a.repo_owner, a.repo_name")
+}
+
+func (dbc *cockroachDBClientImpl) selectResourceUsageWithLabelsQuery(innerquery sq.SelectBuilder) sq.SelectBuilder {
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	return psql.
+		Select("u.repo_source, u.repo_owner, u.repo_name, u.jobs, u.seconds, u.cpu_request_seconds, u.memory_request_seconds, u.cpu_max_usage_seconds, u.memory_max_usage_seconds, u.cpu_measured_request_seconds, u.memory_measured_request_seconds, p.labels").
+		FromSelect(innerquery, "u").
+		LeftJoin("computed_pipelines p ON p.repo_source = u.repo_source AND p.repo_owner = u.repo_owner AND p.repo_name = u.repo_name").
+		OrderBy("u.repo_source, u.repo_owner, u.repo_name")
+}
+
+func (dbc *cockroachDBClientImpl) queryResourceUsage(query sq.SelectBuilder) (usages []*ResourceUsage, err error) {
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	usages = make([]*ResourceUsage, 0)
+	for rows.Next() {
+		usage := &ResourceUsage{}
+		var seconds int64
+		var cpuRequestSeconds, memoryRequestSeconds, cpuMaxUsageSeconds, memoryMaxUsageSeconds, cpuMeasuredRequestSeconds, memoryMeasuredRequestSeconds float64
+		var labelsData []uint8
+
+		if err = rows.Scan(
+			&usage.RepoSource,
+			&usage.RepoOwner,
+			&usage.RepoName,
+			&usage.Jobs,
+			&seconds,
+			&cpuRequestSeconds,
+			&memoryRequestSeconds,
+			&cpuMaxUsageSeconds,
+			&memoryMaxUsageSeconds,
+			&cpuMeasuredRequestSeconds,
+			&memoryMeasuredRequestSeconds,
+			&labelsData); err != nil {
+			return nil, err
+		}
+
+		if len(labelsData) > 0 {
+			if err = json.Unmarshal(labelsData, &usage.Labels); err != nil {
+				return nil, err
+			}
+		}
+
+		usage.Duration = time.Duration(seconds) * time.Second
+		usage.CPURequestCoreHours = cpuRequestSeconds / 3600
+		usage.MemoryRequestGBHours = memoryRequestSeconds / 3600 / bytesPerGB
+		usage.CPUMaxUsageCoreHours = cpuMaxUsageSeconds / 3600
+		usage.MemoryMaxUsageGBHours = memoryMaxUsageSeconds / 3600 / bytesPerGB
+		usage.CPUMeasuredRequestCoreHours = cpuMeasuredRequestSeconds / 3600
+		usage.MemoryMeasuredRequestGBHours = memoryMeasuredRequestSeconds / 3600 / bytesPerGB
+
+		usages = append(usages, usage)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetFirstBuildTimes(ctx context.Context) (buildTimes []time.Time, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFirstBuildTimes")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetFirstBuildTimes", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
+	// generate query
 	query :=
 		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("COUNT(key)").
-			FromSelect(groupByQuery, "d").
-			Where(sq.Gt{"pipelinesCount": 1})
+			Select("a.first_inserted_at").
+			From("computed_pipelines a").
+			OrderBy("a.first_inserted_at")
+
+	buildTimes = make([]time.Time, 0)
+
+	// execute query
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+
+		insertedAt := time.Time{}
+
+		if err = rows.Scan(
+			&insertedAt); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return
+		}
+
+		buildTimes = append(buildTimes, insertedAt)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetFirstReleaseTimes(ctx context.Context) (releaseTimes []time.Time, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFirstReleaseTimes")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetFirstReleaseTimes", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("MIN(a.first_inserted_at)").
+			From("computed_releases a").
+			GroupBy("a.repo_source,a.repo_owner,a.repo_name").
+			OrderBy("MIN(a.first_inserted_at)")
+
+	releaseTimes = make([]time.Time, 0)
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+
+		insertedAt := time.Time{}
+
+		if err = rows.Scan(
+			&insertedAt); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return
+		}
+
+		releaseTimes = append(releaseTimes, insertedAt)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelineBuildsDurations(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (durations []map[string]interface{}, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsDurations")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildsDurations", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	innerquery :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.inserted_at, a.duration::INT").
+			From("builds a").
+			Where(sq.Eq{"a.repo_source": repoSource}).
+			Where(sq.Eq{"a.repo_owner": repoOwner}).
+			Where(sq.Eq{"a.repo_name": repoName}).
+			OrderBy("a.inserted_at DESC")
+
+	innerquery, err = whereClauseGeneratorForBuildStatusFilter(innerquery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("*").
+			FromSelect(innerquery, "a").
+			OrderBy("a.duration")
+
+	durations = make([]map[string]interface{}, 0)
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+
+		var insertedAt time.Time
+		var seconds int
+
+		if err = rows.Scan(
+			&insertedAt, &seconds); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return
+		}
+
+		duration := time.Duration(seconds) * time.Second
+
+		durations = append(durations, map[string]interface{}{
+			"insertedAt": insertedAt,
+			"duration":   duration,
+		})
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelineReleasesDurations(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (durations []map[string]interface{}, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleasesDurations")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineReleasesDurations", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	innerquery :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.inserted_at, a.release, a.release_action, a.duration::INT").
+			From("releases a").
+			Where(sq.Eq{"a.repo_source": repoSource}).
+			Where(sq.Eq{"a.repo_owner": repoOwner}).
+			Where(sq.Eq{"a.repo_name": repoName}).
+			OrderBy("a.inserted_at DESC")
+
+	innerquery, err = whereClauseGeneratorForReleaseStatusFilter(innerquery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("*").
+			FromSelect(innerquery, "a").
+			OrderBy("a.duration")
+
+	durations = make([]map[string]interface{}, 0)
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+
+		var insertedAt time.Time
+		var releaseName, releaseAction string
+		var seconds int
+
+		if err = rows.Scan(
+			&insertedAt, &releaseName, &releaseAction, &seconds); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return
+		}
+
+		duration := time.Duration(seconds) * time.Second
+
+		durations = append(durations, map[string]interface{}{
+			"insertedAt": insertedAt,
+			"name":       releaseName,
+			"action":     releaseAction,
+			"duration":   duration,
+		})
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelineBuildsCPUUsageMeasurements(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (measurements []map[string]interface{}, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsCPUUsageMeasurements")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildsCPUUsageMeasurements", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	innerquery :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.inserted_at, a.cpu_max_usage").
+			From("builds a").
+			Where(sq.Eq{"a.repo_source": repoSource}).
+			Where(sq.Eq{"a.repo_owner": repoOwner}).
+			Where(sq.Eq{"a.repo_name": repoName}).
+			Where(sq.NotEq{"a.cpu_max_usage": nil}).
+			OrderBy("a.inserted_at DESC")
+
+	innerquery, err = whereClauseGeneratorForBuildStatusFilter(innerquery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("*").
+			FromSelect(innerquery, "a").
+			OrderBy("a.inserted_at")
+
+	measurements = make([]map[string]interface{}, 0)
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+
+		var insertedAt time.Time
+		var cpuMaxUsage float64
+
+		if err = rows.Scan(
+			&insertedAt, &cpuMaxUsage); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return
+		}
+
+		measurements = append(measurements, map[string]interface{}{
+			"insertedAt":  insertedAt,
+			"cpuMaxUsage": cpuMaxUsage,
+		})
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelineReleasesCPUUsageMeasurements(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (measurements []map[string]interface{}, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleasesCPUUsageMeasurements")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineReleasesCPUUsageMeasurements", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	innerquery :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.inserted_at, a.release, a.release_action, a.cpu_max_usage").
+			From("releases a").
+			Where(sq.Eq{"a.repo_source": repoSource}).
+			Where(sq.Eq{"a.repo_owner": repoOwner}).
+			Where(sq.Eq{"a.repo_name": repoName}).
+			Where(sq.NotEq{"a.cpu_max_usage": nil}).
+			OrderBy("a.inserted_at DESC")
+
+	innerquery, err = whereClauseGeneratorForReleaseStatusFilter(innerquery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("*").
+			FromSelect(innerquery, "a").
+			OrderBy("a.inserted_at")
+
+	measurements = make([]map[string]interface{}, 0)
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+
+		var insertedAt time.Time
+		var releaseName, releaseAction string
+		var cpuMaxUsage float64
+
+		if err = rows.Scan(
+			&insertedAt, &releaseName, &releaseAction, &cpuMaxUsage); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return
+		}
+
+		measurements = append(measurements, map[string]interface{}{
+			"insertedAt":  insertedAt,
+			"name":        releaseName,
+			"action":      releaseAction,
+			"cpuMaxUsage": cpuMaxUsage,
+		})
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelineBuildsMemoryUsageMeasurements(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (measurements []map[string]interface{}, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildsMemoryUsageMeasurements")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildsMemoryUsageMeasurements", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	innerquery :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.inserted_at, a.memory_max_usage").
+			From("builds a").
+			Where(sq.Eq{"a.repo_source": repoSource}).
+			Where(sq.Eq{"a.repo_owner": repoOwner}).
+			Where(sq.Eq{"a.repo_name": repoName}).
+			Where(sq.NotEq{"a.memory_max_usage": nil}).
+			OrderBy("a.inserted_at DESC")
+
+	innerquery, err = whereClauseGeneratorForBuildStatusFilter(innerquery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("*").
+			FromSelect(innerquery, "a").
+			OrderBy("a.inserted_at")
+
+	measurements = make([]map[string]interface{}, 0)
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+
+		var insertedAt time.Time
+		var memoryMaxUsage float64
+
+		if err = rows.Scan(
+			&insertedAt, &memoryMaxUsage); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return
+		}
+
+		measurements = append(measurements, map[string]interface{}{
+			"insertedAt":     insertedAt,
+			"memoryMaxUsage": memoryMaxUsage,
+		})
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelineReleasesMemoryUsageMeasurements(ctx context.Context, repoSource, repoOwner, repoName string, filters map[string][]string) (measurements []map[string]interface{}, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineReleasesMemoryUsageMeasurements")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineReleasesMemoryUsageMeasurements", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	innerquery :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.inserted_at, a.release, a.release_action, a.memory_max_usage").
+			From("releases a").
+			Where(sq.Eq{"a.repo_source": repoSource}).
+			Where(sq.Eq{"a.repo_owner": repoOwner}).
+			Where(sq.Eq{"a.repo_name": repoName}).
+			Where(sq.NotEq{"a.memory_max_usage": nil}).
+			OrderBy("a.inserted_at DESC")
+
+	innerquery, err = whereClauseGeneratorForReleaseStatusFilter(innerquery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	innerquery, err = limitClauseGeneratorForLastFilter(innerquery, filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("*").
+			FromSelect(innerquery, "a").
+			OrderBy("a.inserted_at")
+
+	measurements = make([]map[string]interface{}, 0)
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+
+		var insertedAt time.Time
+		var releaseName, releaseAction string
+		var memoryMaxUsage float64
+
+		if err = rows.Scan(
+			&insertedAt, &releaseName, &releaseAction, &memoryMaxUsage); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return
+		}
+
+		measurements = append(measurements, map[string]interface{}{
+			"insertedAt":     insertedAt,
+			"name":           releaseName,
+			"action":         releaseAction,
+			"memoryMaxUsage": memoryMaxUsage,
+		})
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetFrequentLabels(ctx context.Context, pageNumber, pageSize int, filters map[string][]string) (labels []map[string]interface{}, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFrequentLabels")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetFrequentLabels", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// see https://github.com/cockroachdb/cockroach/issues/35848
+
+	// for time being run following query, where the dynamic where clause is in the innermost select query:
+
+	// SELECT
+	// 		key, value, nr_computed_pipelines
+	// FROM
+	// 		(
+	// 				SELECT
+	// 						key, value, count(DISTINCT id) AS nr_computed_pipelines
+	// 				FROM
+	// 						(
+	// 								SELECT
+	// 										l->>'key' AS key, l->>'value' AS value, id
+	// 								FROM
+	// 										(SELECT id, jsonb_array_elements(labels) AS l FROM computed_pipelines where jsonb_typeof(labels) = 'array')
+	// 						)
+	// 				GROUP BY
+	// 						key, value
+	// 		)
+	// WHERE
+	// 		nr_computed_pipelines > 1
+	// ORDER BY
+	// 		nr_computed_pipelines DESC, key, value
+	// LIMIT 10;
+
+	arrayElementsQuery :=
+		sq.StatementBuilder.
+			Select("a.id, jsonb_array_elements(a.labels) AS l").
+			From("computed_pipelines a").
+			Where("jsonb_typeof(labels) = 'array'")
+
+	arrayElementsQuery, err = whereClauseGeneratorForSinceFilter(arrayElementsQuery, "a", "last_updated_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	arrayElementsQuery, err = whereClauseGeneratorForBuildStatusFilter(arrayElementsQuery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	arrayElementsQuery, err = whereClauseGeneratorForLabelsFilter(arrayElementsQuery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	selectCountQuery :=
+		sq.StatementBuilder.
+			Select("l->>'key' AS key, l->>'value' AS value, id").
+			FromSelect(arrayElementsQuery, "b")
+
+	groupByQuery :=
+		sq.StatementBuilder.
+			Select("key, value, count(DISTINCT id) AS pipelinesCount").
+			FromSelect(selectCountQuery, "c").
+			GroupBy("key, value")
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("key, value, pipelinesCount").
+			FromSelect(groupByQuery, "d").
+			Where(sq.Gt{"pipelinesCount": 1}).
+			OrderBy("pipelinesCount DESC, key, value").
+			Limit(uint64(pageSize)).
+			Offset(uint64((pageNumber - 1) * pageSize))
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+
+	cols, _ := rows.Columns()
+	for rows.Next() {
+		// Create a slice of interface{}'s to represent each column,
+		// and a second slice to contain pointers to each item in the columns slice.
+		columns := make([]interface{}, len(cols))
+		columnPointers := make([]interface{}, len(cols))
+		for i := range columns {
+			columnPointers[i] = &columns[i]
+		}
+
+		// Scan the result into the column pointers...
+		if err = rows.Scan(columnPointers...); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+
+		// Create our map, and retrieve the value for each column from the pointers slice,
+		// storing it in the map with the name of the column as the key.
+		m := make(map[string]interface{})
+		for i, colName := range cols {
+			val := columnPointers[i].(*interface{})
+			m[colName] = *val
+		}
+
+		labels = append(labels, m)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetFrequentLabelsCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetFrequentLabelsCount")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetFrequentLabelsCount", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// see https://github.com/cockroachdb/cockroach/issues/35848
+
+	// for time being run following query, where the dynamic where clause is in the innermost select query:
+
+	// SELECT
+	// 		key, value, nr_computed_pipelines
+	// FROM
+	// 		(
+	// 				SELECT
+	// 						key, value, count(DISTINCT id) AS nr_computed_pipelines
+	// 				FROM
+	// 						(
+	// 								SELECT
+	// 										l->>'key' AS key, l->>'value' AS value, id
+	// 								FROM
+	// 										(SELECT id, jsonb_array_elements(labels) AS l FROM computed_pipelines where jsonb_typeof(labels) = 'array')
+	// 						)
+	// 				GROUP BY
+	// 						key, value
+	// 		)
+	// WHERE
+	// 		nr_computed_pipelines > 1
+	// ORDER BY
+	// 		nr_computed_pipelines DESC, key, value
+	// LIMIT 10;
+
+	arrayElementsQuery :=
+		sq.StatementBuilder.
+			Select("a.id, jsonb_array_elements(a.labels) AS l").
+			From("computed_pipelines a").
+			Where("jsonb_typeof(labels) = 'array'")
+
+	arrayElementsQuery, err = whereClauseGeneratorForSinceFilter(arrayElementsQuery, "a", "last_updated_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	arrayElementsQuery, err = whereClauseGeneratorForBuildStatusFilter(arrayElementsQuery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	arrayElementsQuery, err = whereClauseGeneratorForLabelsFilter(arrayElementsQuery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	selectCountQuery :=
+		sq.StatementBuilder.
+			Select("l->>'key' AS key, l->>'value' AS value, id").
+			FromSelect(arrayElementsQuery, "b")
+
+	groupByQuery :=
+		sq.StatementBuilder.
+			Select("key, value, count(DISTINCT id) AS pipelinesCount").
+			FromSelect(selectCountQuery, "c").
+			GroupBy("key, value")
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("COUNT(key)").
+			FromSelect(groupByQuery, "d").
+			Where(sq.Gt{"pipelinesCount": 1})
+
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&totalCount); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelinesWithMostBuilds(ctx context.Context, pageNumber, pageSize int, filters map[string][]string) (pipelines []map[string]interface{}, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelinesWithMostBuilds")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelinesWithMostBuilds", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.repo_source, a.repo_owner, a.repo_name, count(a.id) as nr_records").
+			From("builds a").
+			GroupBy("a.repo_source, a.repo_owner, a.repo_name").
+			OrderBy("nr_records DESC, a.repo_source, a.repo_owner, a.repo_name").
+			Limit(uint64(pageSize)).
+			Offset(uint64((pageNumber - 1) * pageSize))
+
+	query, err = whereClauseGeneratorForSinceFilter(query, "a", "inserted_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query, err = whereClauseGeneratorForBuildStatusFilter(query, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query, err = whereClauseGeneratorForLabelsFilter(query, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	pipelines = make([]map[string]interface{}, 0)
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+
+	cols, _ := rows.Columns()
+	for rows.Next() {
+		// Create a slice of interface{}'s to represent each column,
+		// and a second slice to contain pointers to each item in the columns slice.
+		columns := make([]interface{}, len(cols))
+		columnPointers := make([]interface{}, len(cols))
+		for i := range columns {
+			columnPointers[i] = &columns[i]
+		}
+
+		// Scan the result into the column pointers...
+		if err = rows.Scan(columnPointers...); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+
+		// Create our map, and retrieve the value for each column from the pointers slice,
+		// storing it in the map with the name of the column as the key.
+		m := make(map[string]interface{})
+		for i, colName := range cols {
+			val := columnPointers[i].(*interface{})
+			m[colName] = *val
+		}
+
+		pipelines = append(pipelines, m)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelinesWithMostBuildsCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
+	return dbc.GetPipelinesCount(ctx, filters)
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelinesWithMostReleases(ctx context.Context, pageNumber, pageSize int, filters map[string][]string) (pipelines []map[string]interface{}, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelinesWithMostReleases")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelinesWithMostReleases", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.repo_source, a.repo_owner, a.repo_name, count(a.id) as nr_records").
+			From("releases a").
+			GroupBy("a.repo_source, a.repo_owner, a.repo_name").
+			OrderBy("nr_records DESC, a.repo_source, a.repo_owner, a.repo_name").
+			Limit(uint64(pageSize)).
+			Offset(uint64((pageNumber - 1) * pageSize))
+
+	query, err = whereClauseGeneratorForSinceFilter(query, "a", "inserted_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query, err = whereClauseGeneratorForReleaseStatusFilter(query, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	pipelines = make([]map[string]interface{}, 0)
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	defer rows.Close()
+
+	cols, _ := rows.Columns()
+	for rows.Next() {
+		// Create a slice of interface{}'s to represent each column,
+		// and a second slice to contain pointers to each item in the columns slice.
+		columns := make([]interface{}, len(cols))
+		columnPointers := make([]interface{}, len(cols))
+		for i := range columns {
+			columnPointers[i] = &columns[i]
+		}
+
+		// Scan the result into the column pointers...
+		if err = rows.Scan(columnPointers...); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+
+		// Create our map, and retrieve the value for each column from the pointers slice,
+		// storing it in the map with the name of the column as the key.
+		m := make(map[string]interface{})
+		for i, colName := range cols {
+			val := columnPointers[i].(*interface{})
+			m[colName] = *val
+		}
+
+		pipelines = append(pipelines, m)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetPipelinesWithMostReleasesCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelinesWithMostReleasesCount")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelinesWithMostReleasesCount", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	innerquery :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("a.repo_source, a.repo_owner, a.repo_name").
+			From("releases a").
+			GroupBy("a.repo_source, a.repo_owner, a.repo_name")
+
+	innerquery, err = whereClauseGeneratorForSinceFilter(innerquery, "a", "inserted_at", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	innerquery, err = whereClauseGeneratorForReleaseStatusFilter(innerquery, "a", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("count(*)").
+			FromSelect(innerquery, "a")
+
+	// execute query
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&totalCount); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	return
+}
+
+func whereClauseGeneratorForAllFilters(query sq.SelectBuilder, alias, sinceColumn string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	query, err := whereClauseGeneratorForSinceFilter(query, alias, sinceColumn, filters)
+	if err != nil {
+		return query, err
+	}
+	query, err = whereClauseGeneratorForBuildStatusFilter(query, alias, filters)
+	if err != nil {
+		return query, err
+	}
+	query, err = whereClauseGeneratorForLabelsFilter(query, alias, filters)
+	if err != nil {
+		return query, err
+	}
+	query, err = whereClauseGeneratorForSearchFilter(query, alias, filters)
+	if err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+func whereClauseGeneratorForAllReleaseFilters(query sq.SelectBuilder, alias, sinceColumn string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	query, err := whereClauseGeneratorForReleaseStatusFilter(query, alias, filters)
+	if err != nil {
+		return query, err
+	}
+	query, err = whereClauseGeneratorForSinceFilter(query, alias, sinceColumn, filters)
+	if err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+func whereClauseGeneratorForSinceFilter(query sq.SelectBuilder, alias, sinceColumn string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if since, ok := filters["since"]; ok && len(since) > 0 && since[0] != "eternity" {
+		sinceValue := since[0]
+		switch sinceValue {
+		case "1h":
+			query = query.Where(sq.GtOrEq{fmt.Sprintf("%v.%v", alias, sinceColumn): time.Now().Add(time.Duration(-1) * time.Hour)})
+		case "1d":
+			query = query.Where(sq.GtOrEq{fmt.Sprintf("%v.%v", alias, sinceColumn): time.Now().AddDate(0, 0, -1)})
+		case "1w":
+			query = query.Where(sq.GtOrEq{fmt.Sprintf("%v.%v", alias, sinceColumn): time.Now().AddDate(0, 0, -7)})
+		case "1m":
+			query = query.Where(sq.GtOrEq{fmt.Sprintf("%v.%v", alias, sinceColumn): time.Now().AddDate(0, -1, 0)})
+		case "1y":
+			query = query.Where(sq.GtOrEq{fmt.Sprintf("%v.%v", alias, sinceColumn): time.Now().AddDate(-1, 0, 0)})
+		}
+	}
+
+	return query, nil
+}
+
+func whereClauseGeneratorForRepoSourceFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if sources, ok := filters["source"]; ok && len(sources) > 0 {
+		query = query.Where(sq.Eq{fmt.Sprintf("%v.repo_source", alias): sources})
+	}
+
+	return query, nil
+}
+
+func whereClauseGeneratorForSearchFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if search, ok := filters["search"]; ok && len(search) > 0 && search[0] != "" {
+		searchValue := search[0]
+		query = query.Where(sq.Like{fmt.Sprintf("%v.repo_name", alias): fmt.Sprint("%", searchValue, "%")})
+	}
+
+	return query, nil
+}
+
+func whereClauseGeneratorForBuildStatusFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if statuses, ok := filters["status"]; ok && len(statuses) > 0 && statuses[0] != "all" {
+		query = query.Where(sq.Eq{fmt.Sprintf("%v.build_status", alias): statuses})
+	}
+
+	return query, nil
+}
+
+func whereClauseGeneratorForReleaseStatusFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if statuses, ok := filters["status"]; ok && len(statuses) > 0 && statuses[0] != "all" {
+		query = query.Where(sq.Eq{fmt.Sprintf("%v.release_status", alias): statuses})
+	}
+
+	return query, nil
+}
+
+func whereClauseGeneratorForLabelsFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if labels, ok := filters["labels"]; ok && len(labels) > 0 {
+
+		labelsParam := []contracts.Label{}
+
+		for _, label := range labels {
+			keyValuePair := strings.Split(label, "=")
+
+			if len(keyValuePair) == 2 {
+				labelsParam = append(labelsParam, contracts.Label{
+					Key:   keyValuePair[0],
+					Value: keyValuePair[1],
+				})
+			}
+		}
+
+		if len(labelsParam) > 0 {
+			bytes, err := json.Marshal(labelsParam)
+			if err != nil {
+				return query, err
+			}
+
+			query = query.Where(fmt.Sprintf("%v.labels @> ?", alias), string(bytes))
+		}
+	}
+
+	return query, nil
+}
+
+func limitClauseGeneratorForLastFilter(query sq.SelectBuilder, filters map[string][]string) (sq.SelectBuilder, error) {
+
+	if last, ok := filters["last"]; ok && len(last) == 1 {
+		lastValue := last[0]
+		limitSize, err := strconv.ParseUint(lastValue, 10, 64)
+		if err != nil {
+			return query, err
+		}
+
+		query = query.Limit(limitSize)
+	}
+
+	return query, nil
+}
+
+func (dbc *cockroachDBClientImpl) scanBuild(ctx context.Context, row sq.RowScanner, optimized, enriched bool) (build *contracts.Build, err error) {
+
+	build = &contracts.Build{}
+	var labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData []uint8
+	var seconds int
+
+	if err = row.Scan(
+		&build.ID,
+		&build.RepoSource,
+		&build.RepoOwner,
+		&build.RepoName,
+		&build.RepoBranch,
+		&build.RepoRevision,
+		&build.BuildVersion,
+		&build.BuildStatus,
+		&labelsData,
+		&releaseTargetsData,
+		&build.Manifest,
+		&commitsData,
+		&triggersData,
+		&build.InsertedAt,
+		&build.UpdatedAt,
+		&seconds,
+		&triggeredByEventsData); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return
+	}
+
+	build.Duration = time.Duration(seconds) * time.Second
+
+	dbc.setBuildPropertiesFromJSONB(build, labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData, optimized)
+
+	if enriched {
+		dbc.enrichBuild(ctx, build)
+	}
+
+	if optimized {
+		// clear some properties for reduced size and improved performance over the network
+		build.Manifest = ""
+		build.ManifestWithDefaults = ""
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) scanBuilds(rows *sql.Rows, optimized bool) (builds []*contracts.Build, err error) {
+
+	builds = make([]*contracts.Build, 0)
+
+	defer rows.Close()
+	for rows.Next() {
+
+		build := contracts.Build{}
+		var labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData []uint8
+		var seconds int
+
+		if err = rows.Scan(
+			&build.ID,
+			&build.RepoSource,
+			&build.RepoOwner,
+			&build.RepoName,
+			&build.RepoBranch,
+			&build.RepoRevision,
+			&build.BuildVersion,
+			&build.BuildStatus,
+			&labelsData,
+			&releaseTargetsData,
+			&build.Manifest,
+			&commitsData,
+			&triggersData,
+			&build.InsertedAt,
+			&build.UpdatedAt,
+			&seconds,
+			&triggeredByEventsData); err != nil {
+			return
+		}
+
+		build.Duration = time.Duration(seconds) * time.Second
+
+		dbc.setBuildPropertiesFromJSONB(&build, labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData, optimized)
+
+		if optimized {
+			// clear some properties for reduced size and improved performance over the network
+			build.Manifest = ""
+			build.ManifestWithDefaults = ""
+		}
+
+		builds = append(builds, &build)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) scanPipeline(row sq.RowScanner, optimized bool) (pipeline *contracts.Pipeline, err error) {
+
+	pipeline = &contracts.Pipeline{}
+	var labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData []uint8
+	var seconds int
+
+	if err = row.Scan(
+		&pipeline.ID,
+		&pipeline.RepoSource,
+		&pipeline.RepoOwner,
+		&pipeline.RepoName,
+		&pipeline.RepoBranch,
+		&pipeline.RepoRevision,
+		&pipeline.BuildVersion,
+		&pipeline.BuildStatus,
+		&labelsData,
+		&releaseTargetsData,
+		&pipeline.Manifest,
+		&commitsData,
+		&triggersData,
+		&pipeline.InsertedAt,
+		&pipeline.UpdatedAt,
+		&seconds,
+		&pipeline.LastUpdatedAt,
+		&triggeredByEventsData); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return
+	}
+
+	pipeline.Duration = time.Duration(seconds) * time.Second
+
+	dbc.setPipelinePropertiesFromJSONB(pipeline, labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData, optimized)
+
+	if optimized {
+		// clear some properties for reduced size and improved performance over the network
+		pipeline.Manifest = ""
+		pipeline.ManifestWithDefaults = ""
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) scanPipelines(rows *sql.Rows, optimized bool) (pipelines []*contracts.Pipeline, err error) {
+
+	pipelines = make([]*contracts.Pipeline, 0)
+
+	defer rows.Close()
+	for rows.Next() {
+
+		pipeline := contracts.Pipeline{}
+		var labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData []uint8
+		var seconds int
+
+		if err = rows.Scan(
+			&pipeline.ID,
+			&pipeline.RepoSource,
+			&pipeline.RepoOwner,
+			&pipeline.RepoName,
+			&pipeline.RepoBranch,
+			&pipeline.RepoRevision,
+			&pipeline.BuildVersion,
+			&pipeline.BuildStatus,
+			&labelsData,
+			&releaseTargetsData,
+			&pipeline.Manifest,
+			&commitsData,
+			&triggersData,
+			&pipeline.InsertedAt,
+			&pipeline.UpdatedAt,
+			&seconds,
+			&pipeline.LastUpdatedAt,
+			&triggeredByEventsData); err != nil {
+			return
+		}
+
+		pipeline.Duration = time.Duration(seconds) * time.Second
+
+		dbc.setPipelinePropertiesFromJSONB(&pipeline, labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData, optimized)
+
+		if optimized {
+			// clear some properties for reduced size and improved performance over the network
+			pipeline.Manifest = ""
+			pipeline.ManifestWithDefaults = ""
+		}
+
+		pipelines = append(pipelines, &pipeline)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) scanRelease(row sq.RowScanner) (release *contracts.Release, err error) {
+
+	release = &contracts.Release{}
+	var seconds int
+	var id int
+	var triggeredByEventsData []uint8
+
+	if err = row.Scan(
+		&id,
+		&release.RepoSource,
+		&release.RepoOwner,
+		&release.RepoName,
+		&release.Name,
+		&release.Action,
+		&release.ReleaseVersion,
+		&release.ReleaseStatus,
+		&release.InsertedAt,
+		&release.UpdatedAt,
+		&seconds,
+		&triggeredByEventsData); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return
+	}
+
+	duration := time.Duration(seconds) * time.Second
+	release.Duration = &duration
+	release.ID = strconv.Itoa(id)
+
+	if len(triggeredByEventsData) > 0 {
+		if err = json.Unmarshal(triggeredByEventsData, &release.Events); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) scanReleases(rows *sql.Rows) (releases []*contracts.Release, err error) {
+
+	releases = make([]*contracts.Release, 0)
+
+	defer rows.Close()
+	for rows.Next() {
+
+		release := contracts.Release{}
+		var seconds int
+		var id int
+		var triggeredByEventsData []uint8
+
+		if err = rows.Scan(
+			&id,
+			&release.RepoSource,
+			&release.RepoOwner,
+			&release.RepoName,
+			&release.Name,
+			&release.Action,
+			&release.ReleaseVersion,
+			&release.ReleaseStatus,
+			&release.InsertedAt,
+			&release.UpdatedAt,
+			&seconds,
+			&triggeredByEventsData); err != nil {
+			return
+		}
+
+		duration := time.Duration(seconds) * time.Second
+		release.Duration = &duration
+		release.ID = strconv.Itoa(id)
+
+		if len(triggeredByEventsData) > 0 {
+			if err = json.Unmarshal(triggeredByEventsData, &release.Events); err != nil {
+				return
+			}
+		}
+
+		releases = append(releases, &release)
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetTriggers(ctx context.Context, triggerType, identifier, event string) (pipelines []*contracts.Pipeline, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetTriggers")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetTriggers", time.Now())
+	span.SetTag("trigger-type", triggerType)
+
+	// generate query
+	query := dbc.selectPipelinesQuery()
+
+	trigger := manifest.EstafetteTrigger{}
+
+	switch triggerType {
+	case "pipeline":
+
+		trigger.Pipeline = &manifest.EstafettePipelineTrigger{
+			Event: event,
+			Name:  identifier,
+		}
+
+		span.SetTag("trigger-event", event)
+		span.SetTag("trigger-identifier", identifier)
+
+		break
+
+	case "release":
+
+		trigger.Release = &manifest.EstafetteReleaseTrigger{
+			Event: event,
+			Name:  identifier,
+		}
+
+		span.SetTag("trigger-event", event)
+		span.SetTag("trigger-identifier", identifier)
+
+		break
+
+	case "cron":
+
+		trigger.Cron = &manifest.EstafetteCronTrigger{}
+
+		break
+
+	case "git":
+
+		trigger.Git = &manifest.EstafetteGitTrigger{
+			Event:      event,
+			Repository: identifier,
+		}
+
+		span.SetTag("trigger-event", event)
+		span.SetTag("trigger-identifier", identifier)
 
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if err = row.Scan(&totalCount); err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
+		break
 
-	return
-}
+	case "pubsub":
 
-func (dbc *cockroachDBClientImpl) GetPipelinesWithMostBuilds(ctx context.Context, pageNumber, pageSize int, filters map[string][]string) (pipelines []map[string]interface{}, err error) {
+		trigger.PubSub = &manifest.EstafettePubSubTrigger{}
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelinesWithMostBuilds")
-	defer span.Finish()
+		break
 
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+	default:
 
-	// generate query
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("a.repo_source, a.repo_owner, a.repo_name, count(a.id) as nr_records").
-			From("builds a").
-			GroupBy("a.repo_source, a.repo_owner, a.repo_name").
-			OrderBy("nr_records DESC, a.repo_source, a.repo_owner, a.repo_name").
-			Limit(uint64(pageSize)).
-			Offset(uint64((pageNumber - 1) * pageSize))
+		return pipelines, fmt.Errorf("Trigger type %v is not supported", triggerType)
+	}
 
-	query, err = whereClauseGeneratorForSinceFilter(query, "a", "inserted_at", filters)
+	bytes, err := json.Marshal([]manifest.EstafetteTrigger{trigger})
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return
+		return pipelines, err
 	}
 
-	query, err = whereClauseGeneratorForBuildStatusFilter(query, "a", filters)
+	query = query.Where("a.triggers @> ?", string(bytes))
+
+	// execute query
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	query, err = whereClauseGeneratorForLabelsFilter(query, "a", filters)
-	if err != nil {
+	// read rows
+	if pipelines, err = dbc.scanPipelines(rows, false); err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	pipelines = make([]map[string]interface{}, 0)
+	span.SetTag("trigger-pipelines-count", len(pipelines))
 
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	return
+}
 
-	if err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
+func (dbc *cockroachDBClientImpl) GetGitTriggers(ctx context.Context, gitEvent manifest.EstafetteGitEvent) ([]*contracts.Pipeline, error) {
 
-	defer rows.Close()
+	triggerType := "git"
+	name := gitEvent.Repository
+	event := gitEvent.Event
 
-	cols, _ := rows.Columns()
-	for rows.Next() {
-		// Create a slice of interface{}'s to represent each column,
-		// and a second slice to contain pointers to each item in the columns slice.
-		columns := make([]interface{}, len(cols))
-		columnPointers := make([]interface{}, len(cols))
-		for i := range columns {
-			columnPointers[i] = &columns[i]
-		}
+	return dbc.GetTriggers(ctx, triggerType, name, event)
+}
 
-		// Scan the result into the column pointers...
-		if err = rows.Scan(columnPointers...); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
-			return nil, err
-		}
+func (dbc *cockroachDBClientImpl) GetPipelineTriggers(ctx context.Context, build contracts.Build, event string) ([]*contracts.Pipeline, error) {
 
-		// Create our map, and retrieve the value for each column from the pointers slice,
-		// storing it in the map with the name of the column as the key.
-		m := make(map[string]interface{})
-		for i, colName := range cols {
-			val := columnPointers[i].(*interface{})
-			m[colName] = *val
-		}
+	triggerType := "pipeline"
+	name := fmt.Sprintf("%v/%v/%v", build.RepoSource, build.RepoOwner, build.RepoName)
 
-		pipelines = append(pipelines, m)
-	}
+	return dbc.GetTriggers(ctx, triggerType, name, event)
+}
 
-	return
+func (dbc *cockroachDBClientImpl) GetReleaseTriggers(ctx context.Context, release contracts.Release, event string) ([]*contracts.Pipeline, error) {
+
+	triggerType := "release"
+	name := fmt.Sprintf("%v/%v/%v", release.RepoSource, release.RepoOwner, release.RepoName)
+
+	return dbc.GetTriggers(ctx, triggerType, name, event)
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelinesWithMostBuildsCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
-	return dbc.GetPipelinesCount(ctx, filters)
+func (dbc *cockroachDBClientImpl) GetPubSubTriggers(ctx context.Context, pubsubEvent manifest.EstafettePubSubEvent) ([]*contracts.Pipeline, error) {
+
+	triggerType := "pubsub"
+
+	return dbc.GetTriggers(ctx, triggerType, "", "")
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelinesWithMostReleases(ctx context.Context, pageNumber, pageSize int, filters map[string][]string) (pipelines []map[string]interface{}, err error) {
+func (dbc *cockroachDBClientImpl) GetCronTriggers(ctx context.Context) ([]*contracts.Pipeline, error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelinesWithMostReleases")
+	triggerType := "cron"
+
+	return dbc.GetTriggers(ctx, triggerType, "", "")
+}
+
+func (dbc *cockroachDBClientImpl) Rename(ctx context.Context, shortFromRepoSource, fromRepoSource, fromRepoOwner, fromRepoName, shortToRepoSource, toRepoSource, toRepoOwner, toRepoName string) error {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CockroachDb::Rename")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::Rename", time.Now())
 
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+	nrOfQueries := 7
+	var wg sync.WaitGroup
+	wg.Add(nrOfQueries)
 
-	// generate query
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("a.repo_source, a.repo_owner, a.repo_name, count(a.id) as nr_records").
-			From("releases a").
-			GroupBy("a.repo_source, a.repo_owner, a.repo_name").
-			OrderBy("nr_records DESC, a.repo_source, a.repo_owner, a.repo_name").
-			Limit(uint64(pageSize)).
-			Offset(uint64((pageNumber - 1) * pageSize))
+	errors := make(chan error, nrOfQueries)
 
-	query, err = whereClauseGeneratorForSinceFilter(query, "a", "inserted_at", filters)
-	if err != nil {
+	go func(wg *sync.WaitGroup, ctx context.Context, shortFromRepoSource, fromRepoOwner, fromRepoName, shortToRepoSource, toRepoOwner, toRepoName string) {
+		defer wg.Done()
+		err := dbc.RenameBuildVersion(ctx, shortFromRepoSource, fromRepoOwner, fromRepoName, shortToRepoSource, toRepoOwner, toRepoName)
+		if err != nil {
+			errors <- err
+		}
+	}(&wg, ctx, shortFromRepoSource, fromRepoOwner, fromRepoName, shortToRepoSource, toRepoOwner, toRepoName)
+
+	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
+		defer wg.Done()
+		err := dbc.RenameBuilds(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+		if err != nil {
+			errors <- err
+		}
+	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+
+	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
+		defer wg.Done()
+		err := dbc.RenameBuildLogs(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+		if err != nil {
+			errors <- err
+		}
+	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+
+	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
+		defer wg.Done()
+		err := dbc.RenameReleases(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+		if err != nil {
+			errors <- err
+		}
+	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+
+	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
+		defer wg.Done()
+		err := dbc.RenameReleaseLogs(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+		if err != nil {
+			errors <- err
+		}
+	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+
+	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
+		defer wg.Done()
+		err := dbc.RenameComputedPipelines(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+		if err != nil {
+			errors <- err
+		}
+	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+
+	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
+		defer wg.Done()
+		err := dbc.RenameComputedReleases(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+		if err != nil {
+			errors <- err
+		}
+	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+
+	wg.Wait()
+
+	close(errors)
+	for e := range errors {
 		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
+		span.LogFields(otlog.Error(e))
+		return e
 	}
 
-	query, err = whereClauseGeneratorForReleaseStatusFilter(query, "a", filters)
+	// update computed tables
+	err := dbc.UpsertComputedPipeline(ctx, toRepoSource, toRepoOwner, toRepoName)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return
+		return err
 	}
 
-	pipelines = make([]map[string]interface{}, 0)
+	return nil
+}
 
-	rows, err := query.RunWith(dbc.databaseConnection).Query()
+func (dbc *cockroachDBClientImpl) RenameBuildVersion(ctx context.Context, shortFromRepoSource, fromRepoOwner, fromRepoName, shortToRepoSource, toRepoOwner, toRepoName string) error {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameBuildVersion")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::RenameBuildVersion", time.Now())
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
+	query := psql.
+		Update("build_versions").
+		Set("repo_source", shortToRepoSource).
+		Set("repo_full_name", fmt.Sprintf("%v/%v", toRepoOwner, toRepoName)).
+		Where(sq.Eq{"repo_source": shortFromRepoSource}).
+		Where(sq.Eq{"repo_full_name": fmt.Sprintf("%v/%v", fromRepoOwner, fromRepoName)}).
+		Limit(uint64(1))
+
+	_, err := query.RunWith(dbc.databaseConnection).Exec()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return
+		return err
 	}
 
-	defer rows.Close()
+	return nil
+}
 
-	cols, _ := rows.Columns()
-	for rows.Next() {
-		// Create a slice of interface{}'s to represent each column,
-		// and a second slice to contain pointers to each item in the columns slice.
-		columns := make([]interface{}, len(cols))
-		columnPointers := make([]interface{}, len(cols))
-		for i := range columns {
-			columnPointers[i] = &columns[i]
-		}
+func (dbc *cockroachDBClientImpl) RenameBuilds(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
 
-		// Scan the result into the column pointers...
-		if err = rows.Scan(columnPointers...); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(otlog.Error(err))
-			return nil, err
-		}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameBuilds")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::RenameBuilds", time.Now())
 
-		// Create our map, and retrieve the value for each column from the pointers slice,
-		// storing it in the map with the name of the column as the key.
-		m := make(map[string]interface{})
-		for i, colName := range cols {
-			val := columnPointers[i].(*interface{})
-			m[colName] = *val
-		}
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Update("builds").
+		Set("repo_source", toRepoSource).
+		Set("repo_owner", toRepoOwner).
+		Set("repo_name", toRepoName).
+		Where(sq.Eq{"repo_source": fromRepoSource}).
+		Where(sq.Eq{"repo_owner": fromRepoOwner}).
+		Where(sq.Eq{"repo_name": fromRepoName})
 
-		pipelines = append(pipelines, m)
+	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return err
 	}
 
-	return
+	return nil
 }
 
-func (dbc *cockroachDBClientImpl) GetPipelinesWithMostReleasesCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
+func (dbc *cockroachDBClientImpl) RenameBuildLogs(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelinesWithMostReleasesCount")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameBuildLogs")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::RenameBuildLogs", time.Now())
 
-	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	// generate query
-	innerquery :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("a.repo_source, a.repo_owner, a.repo_name").
-			From("releases a").
-			GroupBy("a.repo_source, a.repo_owner, a.repo_name")
+	query := psql.
+		Update("build_logs").
+		Set("repo_source", toRepoSource).
+		Set("repo_owner", toRepoOwner).
+		Set("repo_name", toRepoName).
+		Where(sq.Eq{"repo_source": fromRepoSource}).
+		Where(sq.Eq{"repo_owner": fromRepoOwner}).
+		Where(sq.Eq{"repo_name": fromRepoName})
 
-	innerquery, err = whereClauseGeneratorForSinceFilter(innerquery, "a", "inserted_at", filters)
+	_, err := query.RunWith(dbc.databaseConnection).Exec()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return
+		return err
 	}
 
-	innerquery, err = whereClauseGeneratorForReleaseStatusFilter(innerquery, "a", filters)
-	if err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
-	}
+	return nil
+}
 
-	query :=
-		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
-			Select("count(*)").
-			FromSelect(innerquery, "a")
+func (dbc *cockroachDBClientImpl) RenameReleases(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
 
-	// execute query
-	row := query.RunWith(dbc.databaseConnection).QueryRow()
-	if err = row.Scan(&totalCount); err != nil {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameReleases")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::RenameReleases", time.Now())
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Update("releases").
+		Set("repo_source", toRepoSource).
+		Set("repo_owner", toRepoOwner).
+		Set("repo_name", toRepoName).
+		Where(sq.Eq{"repo_source": fromRepoSource}).
+		Where(sq.Eq{"repo_owner": fromRepoOwner}).
+		Where(sq.Eq{"repo_name": fromRepoName})
+
+	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return
+		return err
 	}
 
-	return
+	return nil
 }
 
-func whereClauseGeneratorForAllFilters(query sq.SelectBuilder, alias, sinceColumn string, filters map[string][]string) (sq.SelectBuilder, error) {
+func (dbc *cockroachDBClientImpl) RenameReleaseLogs(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
 
-	query, err := whereClauseGeneratorForSinceFilter(query, alias, sinceColumn, filters)
-	if err != nil {
-		return query, err
-	}
-	query, err = whereClauseGeneratorForBuildStatusFilter(query, alias, filters)
-	if err != nil {
-		return query, err
-	}
-	query, err = whereClauseGeneratorForLabelsFilter(query, alias, filters)
-	if err != nil {
-		return query, err
-	}
-	query, err = whereClauseGeneratorForSearchFilter(query, alias, filters)
-	if err != nil {
-		return query, err
-	}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameReleaseLogs")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::RenameReleaseLogs", time.Now())
 
-	return query, nil
-}
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-func whereClauseGeneratorForAllReleaseFilters(query sq.SelectBuilder, alias, sinceColumn string, filters map[string][]string) (sq.SelectBuilder, error) {
+	query := psql.
+		Update("release_logs").
+		Set("repo_source", toRepoSource).
+		Set("repo_owner", toRepoOwner).
+		Set("repo_name", toRepoName).
+		Where(sq.Eq{"repo_source": fromRepoSource}).
+		Where(sq.Eq{"repo_owner": fromRepoOwner}).
+		Where(sq.Eq{"repo_name": fromRepoName})
 
-	query, err := whereClauseGeneratorForReleaseStatusFilter(query, alias, filters)
-	if err != nil {
-		return query, err
-	}
-	query, err = whereClauseGeneratorForSinceFilter(query, alias, sinceColumn, filters)
+	_, err := query.RunWith(dbc.databaseConnection).Exec()
 	if err != nil {
-		return query, err
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return err
 	}
 
-	return query, nil
+	return nil
 }
 
-func whereClauseGeneratorForSinceFilter(query sq.SelectBuilder, alias, sinceColumn string, filters map[string][]string) (sq.SelectBuilder, error) {
+func (dbc *cockroachDBClientImpl) RenameComputedPipelines(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
 
-	if since, ok := filters["since"]; ok && len(since) > 0 && since[0] != "eternity" {
-		sinceValue := since[0]
-		switch sinceValue {
-		case "1h":
-			query = query.Where(sq.GtOrEq{fmt.Sprintf("%v.%v", alias, sinceColumn): time.Now().Add(time.Duration(-1) * time.Hour)})
-		case "1d":
-			query = query.Where(sq.GtOrEq{fmt.Sprintf("%v.%v", alias, sinceColumn): time.Now().AddDate(0, 0, -1)})
-		case "1w":
-			query = query.Where(sq.GtOrEq{fmt.Sprintf("%v.%v", alias, sinceColumn): time.Now().AddDate(0, 0, -7)})
-		case "1m":
-			query = query.Where(sq.GtOrEq{fmt.Sprintf("%v.%v", alias, sinceColumn): time.Now().AddDate(0, -1, 0)})
-		case "1y":
-			query = query.Where(sq.GtOrEq{fmt.Sprintf("%v.%v", alias, sinceColumn): time.Now().AddDate(-1, 0, 0)})
-		}
-	}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameComputedPipelines")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::RenameComputedPipelines", time.Now())
 
-	return query, nil
-}
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-func whereClauseGeneratorForSearchFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+	query := psql.
+		Update("computed_pipelines").
+		Set("repo_source", toRepoSource).
+		Set("repo_owner", toRepoOwner).
+		Set("repo_name", toRepoName).
+		Where(sq.Eq{"repo_source": fromRepoSource}).
+		Where(sq.Eq{"repo_owner": fromRepoOwner}).
+		Where(sq.Eq{"repo_name": fromRepoName})
 
-	if search, ok := filters["search"]; ok && len(search) > 0 && search[0] != "" {
-		searchValue := search[0]
-		query = query.Where(sq.Like{fmt.Sprintf("%v.repo_name", alias): fmt.Sprint("%", searchValue, "%")})
+	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return err
 	}
 
-	return query, nil
+	return nil
 }
 
-func whereClauseGeneratorForBuildStatusFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+func (dbc *cockroachDBClientImpl) RenameComputedReleases(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
 
-	if statuses, ok := filters["status"]; ok && len(statuses) > 0 && statuses[0] != "all" {
-		query = query.Where(sq.Eq{fmt.Sprintf("%v.build_status", alias): statuses})
-	}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameComputedReleases")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::RenameComputedReleases", time.Now())
 
-	return query, nil
-}
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-func whereClauseGeneratorForReleaseStatusFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+	query := psql.
+		Update("computed_releases").
+		Set("repo_source", toRepoSource).
+		Set("repo_owner", toRepoOwner).
+		Set("repo_name", toRepoName).
+		Where(sq.Eq{"repo_source": fromRepoSource}).
+		Where(sq.Eq{"repo_owner": fromRepoOwner}).
+		Where(sq.Eq{"repo_name": fromRepoName})
 
-	if statuses, ok := filters["status"]; ok && len(statuses) > 0 && statuses[0] != "all" {
-		query = query.Where(sq.Eq{fmt.Sprintf("%v.release_status", alias): statuses})
+	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return err
 	}
 
-	return query, nil
+	return nil
 }
 
-func whereClauseGeneratorForLabelsFilter(query sq.SelectBuilder, alias string, filters map[string][]string) (sq.SelectBuilder, error) {
+func (dbc *cockroachDBClientImpl) InsertWebhookDelivery(ctx context.Context, delivery WebhookDelivery) (insertedDelivery *WebhookDelivery, err error) {
 
-	if labels, ok := filters["labels"]; ok && len(labels) > 0 {
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::InsertWebhookDelivery")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::InsertWebhookDelivery", time.Now())
 
-		labelsParam := []contracts.Label{}
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-		for _, label := range labels {
-			keyValuePair := strings.Split(label, "=")
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-			if len(keyValuePair) == 2 {
-				labelsParam = append(labelsParam, contracts.Label{
-					Key:   keyValuePair[0],
-					Value: keyValuePair[1],
-				})
-			}
-		}
+	query := psql.
+		Insert("webhook_deliveries").
+		Columns("subscriber_name", "url", "event_id", "event_type", "payload", "status_code", "succeeded", "attempts", "error").
+		Values(delivery.SubscriberName, delivery.URL, delivery.EventID, delivery.EventType, delivery.Payload, delivery.StatusCode, delivery.Succeeded, delivery.Attempts, delivery.Error).
+		Suffix("RETURNING id, inserted_at, updated_at")
 
-		if len(labelsParam) > 0 {
-			bytes, err := json.Marshal(labelsParam)
-			if err != nil {
-				return query, err
-			}
+	insertedDelivery = &delivery
 
-			query = query.Where(fmt.Sprintf("%v.labels @> ?", alias), string(bytes))
-		}
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&insertedDelivery.ID, &insertedDelivery.InsertedAt, &insertedDelivery.UpdatedAt); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return nil, err
 	}
 
-	return query, nil
+	return
 }
 
-func limitClauseGeneratorForLastFilter(query sq.SelectBuilder, filters map[string][]string) (sq.SelectBuilder, error) {
-
-	if last, ok := filters["last"]; ok && len(last) == 1 {
-		lastValue := last[0]
-		limitSize, err := strconv.ParseUint(lastValue, 10, 64)
-		if err != nil {
-			return query, err
-		}
+func (dbc *cockroachDBClientImpl) UpdateWebhookDelivery(ctx context.Context, delivery WebhookDelivery) (err error) {
 
-		query = query.Limit(limitSize)
-	}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateWebhookDelivery")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateWebhookDelivery", time.Now())
 
-	return query, nil
-}
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-func (dbc *cockroachDBClientImpl) scanBuild(ctx context.Context, row sq.RowScanner, optimized, enriched bool) (build *contracts.Build, err error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	build = &contracts.Build{}
-	var labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData []uint8
-	var seconds int
+	query := psql.
+		Update("webhook_deliveries").
+		Set("status_code", delivery.StatusCode).
+		Set("succeeded", delivery.Succeeded).
+		Set("attempts", delivery.Attempts).
+		Set("error", delivery.Error).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": delivery.ID})
 
-	if err = row.Scan(
-		&build.ID,
-		&build.RepoSource,
-		&build.RepoOwner,
-		&build.RepoName,
-		&build.RepoBranch,
-		&build.RepoRevision,
-		&build.BuildVersion,
-		&build.BuildStatus,
-		&labelsData,
-		&releaseTargetsData,
-		&build.Manifest,
-		&commitsData,
-		&triggersData,
-		&build.InsertedAt,
-		&build.UpdatedAt,
-		&seconds,
-		&triggeredByEventsData); err != nil {
+	_, err = query.RunWith(dbc.databaseConnection).Exec()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	return
+}
+
+func (dbc *cockroachDBClientImpl) GetWebhookDelivery(ctx context.Context, id string) (delivery *WebhookDelivery, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetWebhookDelivery")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetWebhookDelivery", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	query := dbc.selectWebhookDeliveriesQuery().
+		Where(sq.Eq{"a.id": id}).
+		Limit(uint64(1))
+
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	delivery, err = dbc.scanWebhookDelivery(row)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	build.Duration = time.Duration(seconds) * time.Second
+	return
+}
 
-	dbc.setBuildPropertiesFromJSONB(build, labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData, optimized)
+func (dbc *cockroachDBClientImpl) GetWebhookDeliveries(ctx context.Context, pageNumber, pageSize int) (deliveries []*WebhookDelivery, err error) {
 
-	if enriched {
-		dbc.enrichBuild(ctx, build)
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetWebhookDeliveries")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetWebhookDeliveries", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	query := dbc.selectWebhookDeliveriesQuery().
+		OrderBy("a.inserted_at DESC").
+		Limit(uint64(pageSize)).
+		Offset(uint64((pageNumber - 1) * pageSize))
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
 	}
+	defer rows.Close()
 
-	if optimized {
-		// clear some properties for reduced size and improved performance over the network
-		build.Manifest = ""
-		build.ManifestWithDefaults = ""
+	deliveries = make([]*WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := dbc.scanWebhookDelivery(rows)
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) scanBuilds(rows *sql.Rows, optimized bool) (builds []*contracts.Build, err error) {
+func (dbc *cockroachDBClientImpl) GetWebhookDeliveriesCount(ctx context.Context) (totalCount int, err error) {
 
-	builds = make([]*contracts.Build, 0)
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetWebhookDeliveriesCount")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetWebhookDeliveriesCount", time.Now())
 
-	defer rows.Close()
-	for rows.Next() {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-		build := contracts.Build{}
-		var labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData []uint8
-		var seconds int
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("COUNT(a.id)").
+			From("webhook_deliveries a")
 
-		if err = rows.Scan(
-			&build.ID,
-			&build.RepoSource,
-			&build.RepoOwner,
-			&build.RepoName,
-			&build.RepoBranch,
-			&build.RepoRevision,
-			&build.BuildVersion,
-			&build.BuildStatus,
-			&labelsData,
-			&releaseTargetsData,
-			&build.Manifest,
-			&commitsData,
-			&triggersData,
-			&build.InsertedAt,
-			&build.UpdatedAt,
-			&seconds,
-			&triggeredByEventsData); err != nil {
-			return
-		}
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&totalCount); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-		build.Duration = time.Duration(seconds) * time.Second
+	return
+}
 
-		dbc.setBuildPropertiesFromJSONB(&build, labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData, optimized)
+func (dbc *cockroachDBClientImpl) selectWebhookDeliveriesQuery() sq.SelectBuilder {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-		if optimized {
-			// clear some properties for reduced size and improved performance over the network
-			build.Manifest = ""
-			build.ManifestWithDefaults = ""
-		}
+	return psql.
+		Select("a.id, a.subscriber_name, a.url, a.event_id, a.event_type, a.payload, a.status_code, a.succeeded, a.attempts, a.error, a.inserted_at, a.updated_at").
+		From("webhook_deliveries a")
+}
 
-		builds = append(builds, &build)
+func (dbc *cockroachDBClientImpl) scanWebhookDelivery(row sq.RowScanner) (delivery *WebhookDelivery, err error) {
+
+	delivery = &WebhookDelivery{}
+
+	if err = row.Scan(
+		&delivery.ID,
+		&delivery.SubscriberName,
+		&delivery.URL,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.StatusCode,
+		&delivery.Succeeded,
+		&delivery.Attempts,
+		&delivery.Error,
+		&delivery.InsertedAt,
+		&delivery.UpdatedAt); err != nil {
+		return nil, err
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) scanPipeline(row sq.RowScanner, optimized bool) (pipeline *contracts.Pipeline, err error) {
+// InsertBuildRetry claims a retry attempt for a build before the retry build gets created; it returns nil if the build or the attempt for its version was claimed already
+func (dbc *cockroachDBClientImpl) InsertBuildRetry(ctx context.Context, buildRetry BuildRetry) (insertedBuildRetry *BuildRetry, err error) {
 
-	pipeline = &contracts.Pipeline{}
-	var labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData []uint8
-	var seconds int
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::InsertBuildRetry")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::InsertBuildRetry", time.Now())
 
-	if err = row.Scan(
-		&pipeline.ID,
-		&pipeline.RepoSource,
-		&pipeline.RepoOwner,
-		&pipeline.RepoName,
-		&pipeline.RepoBranch,
-		&pipeline.RepoRevision,
-		&pipeline.BuildVersion,
-		&pipeline.BuildStatus,
-		&labelsData,
-		&releaseTargetsData,
-		&pipeline.Manifest,
-		&commitsData,
-		&triggersData,
-		&pipeline.InsertedAt,
-		&pipeline.UpdatedAt,
-		&seconds,
-		&pipeline.LastUpdatedAt,
-		&triggeredByEventsData); err != nil {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Insert("build_retries").
+		Columns("repo_source", "repo_owner", "repo_name", "build_version", "build_id", "attempt", "reason").
+		Values(buildRetry.RepoSource, buildRetry.RepoOwner, buildRetry.RepoName, buildRetry.BuildVersion, buildRetry.BuildID, buildRetry.Attempt, buildRetry.Reason).
+		Suffix("ON CONFLICT DO NOTHING RETURNING id, inserted_at")
+
+	insertedBuildRetry = &buildRetry
+
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&insertedBuildRetry.ID, &insertedBuildRetry.InsertedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
-		return
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return nil, err
 	}
 
-	pipeline.Duration = time.Duration(seconds) * time.Second
+	return
+}
 
-	dbc.setPipelinePropertiesFromJSONB(pipeline, labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData, optimized)
+// UpdateBuildRetry links a claimed retry attempt to the build that got created for it
+func (dbc *cockroachDBClientImpl) UpdateBuildRetry(ctx context.Context, buildRetry BuildRetry) (err error) {
 
-	if optimized {
-		// clear some properties for reduced size and improved performance over the network
-		pipeline.Manifest = ""
-		pipeline.ManifestWithDefaults = ""
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::UpdateBuildRetry")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::UpdateBuildRetry", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Update("build_retries").
+		Set("retry_build_id", buildRetry.RetryBuildID).
+		Where(sq.Eq{"id": buildRetry.ID})
+
+	_, err = query.RunWith(dbc.databaseConnection).Exec()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) scanPipelines(rows *sql.Rows, optimized bool) (pipelines []*contracts.Pipeline, err error) {
+func (dbc *cockroachDBClientImpl) GetBuildRetries(ctx context.Context, repoSource, repoOwner, repoName, buildVersion string) (buildRetries []*BuildRetry, err error) {
 
-	pipelines = make([]*contracts.Pipeline, 0)
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetBuildRetries")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetBuildRetries", time.Now())
 
-	defer rows.Close()
-	for rows.Next() {
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-		pipeline := contracts.Pipeline{}
-		var labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData []uint8
-		var seconds int
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query := psql.
+		Select("a.id, a.repo_source, a.repo_owner, a.repo_name, a.build_version, a.build_id, COALESCE(a.retry_build_id::STRING, ''), a.attempt, a.reason, a.inserted_at").
+		From("build_retries a").
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.Eq{"a.build_version": buildVersion}).
+		OrderBy("a.attempt")
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+	defer rows.Close()
 
+	buildRetries = make([]*BuildRetry, 0)
+	for rows.Next() {
+		buildRetry := &BuildRetry{}
 		if err = rows.Scan(
-			&pipeline.ID,
-			&pipeline.RepoSource,
-			&pipeline.RepoOwner,
-			&pipeline.RepoName,
-			&pipeline.RepoBranch,
-			&pipeline.RepoRevision,
-			&pipeline.BuildVersion,
-			&pipeline.BuildStatus,
-			&labelsData,
-			&releaseTargetsData,
-			&pipeline.Manifest,
-			&commitsData,
-			&triggersData,
-			&pipeline.InsertedAt,
-			&pipeline.UpdatedAt,
-			&seconds,
-			&pipeline.LastUpdatedAt,
-			&triggeredByEventsData); err != nil {
-			return
+			&buildRetry.ID,
+			&buildRetry.RepoSource,
+			&buildRetry.RepoOwner,
+			&buildRetry.RepoName,
+			&buildRetry.BuildVersion,
+			&buildRetry.BuildID,
+			&buildRetry.RetryBuildID,
+			&buildRetry.Attempt,
+			&buildRetry.Reason,
+			&buildRetry.InsertedAt); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
 		}
+		buildRetries = append(buildRetries, buildRetry)
+	}
 
-		pipeline.Duration = time.Duration(seconds) * time.Second
+	return
+}
 
-		dbc.setPipelinePropertiesFromJSONB(&pipeline, labelsData, releaseTargetsData, commitsData, triggersData, triggeredByEventsData, optimized)
+func (dbc *cockroachDBClientImpl) GetBuildsByStatus(ctx context.Context, buildStatuses []string, insertedBefore time.Time) (builds []*contracts.Build, err error) {
 
-		if optimized {
-			// clear some properties for reduced size and improved performance over the network
-			pipeline.Manifest = ""
-			pipeline.ManifestWithDefaults = ""
-		}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetBuildsByStatus")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetBuildsByStatus", time.Now())
 
-		pipelines = append(pipelines, &pipeline)
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query := dbc.selectBuildsQuery().
+		Where(sq.Eq{"a.build_status": buildStatuses}).
+		Where(sq.Lt{"a.inserted_at": insertedBefore}).
+		OrderBy("a.inserted_at")
+
+	// execute query
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	// read rows
+	if builds, err = dbc.scanBuilds(rows, false); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) scanRelease(row sq.RowScanner) (release *contracts.Release, err error) {
-
-	release = &contracts.Release{}
-	var seconds int
-	var id int
-	var triggeredByEventsData []uint8
+func (dbc *cockroachDBClientImpl) GetReleasesByStatus(ctx context.Context, releaseStatuses []string, insertedBefore time.Time) (releases []*contracts.Release, err error) {
 
-	if err = row.Scan(
-		&id,
-		&release.RepoSource,
-		&release.RepoOwner,
-		&release.RepoName,
-		&release.Name,
-		&release.Action,
-		&release.ReleaseVersion,
-		&release.ReleaseStatus,
-		&release.InsertedAt,
-		&release.UpdatedAt,
-		&seconds,
-		&triggeredByEventsData); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetReleasesByStatus")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetReleasesByStatus", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query := dbc.selectReleasesQuery().
+		Where(sq.Eq{"a.release_status": releaseStatuses}).
+		Where(sq.Lt{"a.inserted_at": insertedBefore}).
+		OrderBy("a.inserted_at")
+
+	// execute query
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
 		return
 	}
 
-	duration := time.Duration(seconds) * time.Second
-	release.Duration = &duration
-	release.ID = strconv.Itoa(id)
-
-	if len(triggeredByEventsData) > 0 {
-		if err = json.Unmarshal(triggeredByEventsData, &release.Events); err != nil {
-			return
-		}
+	// read rows
+	if releases, err = dbc.scanReleases(rows); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) scanReleases(rows *sql.Rows) (releases []*contracts.Release, err error) {
+// GetReleasesWithManifestByStatus returns releases with one of the statuses along with the manifest of the build they release, loaded in the same query
+func (dbc *cockroachDBClientImpl) GetReleasesWithManifestByStatus(ctx context.Context, releaseStatuses []string, insertedBefore time.Time) (releases []*ReleaseWithManifest, err error) {
 
-	releases = make([]*contracts.Release, 0)
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetReleasesWithManifestByStatus")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetReleasesWithManifestByStatus", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	// generate query
+	query := dbc.selectReleasesQuery().
+		Column("COALESCE((SELECT b.manifest FROM builds b WHERE b.repo_source = a.repo_source AND b.repo_owner = a.repo_owner AND b.repo_name = a.repo_name AND b.build_version = a.release_version AND b.manifest <> '' ORDER BY b.inserted_at DESC LIMIT 1), '')").
+		Where(sq.Eq{"a.release_status": releaseStatuses}).
+		Where(sq.Lt{"a.inserted_at": insertedBefore}).
+		OrderBy("a.inserted_at")
 
+	// execute query
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 	defer rows.Close()
+
+	// read rows
+	releases = make([]*ReleaseWithManifest, 0)
 	for rows.Next() {
 
-		release := contracts.Release{}
+		releaseWithManifest := ReleaseWithManifest{}
+		release := &releaseWithManifest.Release
 		var seconds int
 		var id int
 		var triggeredByEventsData []uint8
@@ -3370,8 +5239,11 @@ func (dbc *cockroachDBClientImpl) scanReleases(rows *sql.Rows) (releases []*cont
 			&release.InsertedAt,
 			&release.UpdatedAt,
 			&seconds,
-			&triggeredByEventsData); err != nil {
-			return
+			&triggeredByEventsData,
+			&releaseWithManifest.Manifest); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
 		}
 
 		duration := time.Duration(seconds) * time.Second
@@ -3380,415 +5252,505 @@ func (dbc *cockroachDBClientImpl) scanReleases(rows *sql.Rows) (releases []*cont
 
 		if len(triggeredByEventsData) > 0 {
 			if err = json.Unmarshal(triggeredByEventsData, &release.Events); err != nil {
-				return
+				ext.Error.Set(span, true)
+				span.LogFields(otlog.Error(err))
+				return nil, err
 			}
 		}
 
-		releases = append(releases, &release)
+		releases = append(releases, &releaseWithManifest)
 	}
 
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetTriggers(ctx context.Context, triggerType, identifier, event string) (pipelines []*contracts.Pipeline, err error) {
+func (dbc *cockroachDBClientImpl) InsertBuildTestCases(ctx context.Context, testCases []TestCase) (err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetTriggers")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::InsertBuildTestCases")
 	defer span.Finish()
-	span.SetTag("trigger-type", triggerType)
-
-	// generate query
-	query := dbc.selectPipelinesQuery()
+	defer dbc.observeQueryDuration("CockroachDb::InsertBuildTestCases", time.Now())
 
-	trigger := manifest.EstafetteTrigger{}
+	if len(testCases) == 0 {
+		return nil
+	}
 
-	switch triggerType {
-	case "pipeline":
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-		trigger.Pipeline = &manifest.EstafettePipelineTrigger{
-			Event: event,
-			Name:  identifier,
-		}
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-		span.SetTag("trigger-event", event)
-		span.SetTag("trigger-identifier", identifier)
+	query := psql.
+		Insert("build_test_cases").
+		Columns("repo_source", "repo_owner", "repo_name", "repo_branch", "build_id", "build_version", "suite_name", "class_name", "name", "status", "duration_seconds", "failure_message")
 
-		break
+	for _, tc := range testCases {
+		query = query.Values(tc.RepoSource, tc.RepoOwner, tc.RepoName, tc.RepoBranch, tc.BuildID, tc.BuildVersion, tc.SuiteName, tc.ClassName, tc.Name, tc.Status, tc.DurationSeconds, tc.FailureMessage)
+	}
 
-	case "release":
+	_, err = query.RunWith(dbc.databaseConnection).Exec()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-		trigger.Release = &manifest.EstafetteReleaseTrigger{
-			Event: event,
-			Name:  identifier,
-		}
+	return
+}
 
-		span.SetTag("trigger-event", event)
-		span.SetTag("trigger-identifier", identifier)
+func (dbc *cockroachDBClientImpl) GetBuildTestCases(ctx context.Context, repoSource, repoOwner, repoName string, buildID int) (testCases []*TestCase, err error) {
 
-		break
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetBuildTestCases")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetBuildTestCases", time.Now())
 
-	case "cron":
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-		trigger.Cron = &manifest.EstafetteCronTrigger{}
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-		break
+	query := psql.
+		Select("a.id, a.repo_source, a.repo_owner, a.repo_name, a.repo_branch, a.build_id, a.build_version, a.suite_name, a.class_name, a.name, a.status, a.duration_seconds, a.failure_message, a.inserted_at").
+		From("build_test_cases a").
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.Eq{"a.build_id": buildID}).
+		OrderBy("a.suite_name, a.class_name, a.name")
 
-	case "git":
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+	defer rows.Close()
 
-		trigger.Git = &manifest.EstafetteGitTrigger{
-			Event:      event,
-			Repository: identifier,
+	testCases = make([]*TestCase, 0)
+	for rows.Next() {
+		testCase := &TestCase{}
+		if err = rows.Scan(
+			&testCase.ID,
+			&testCase.RepoSource,
+			&testCase.RepoOwner,
+			&testCase.RepoName,
+			&testCase.RepoBranch,
+			&testCase.BuildID,
+			&testCase.BuildVersion,
+			&testCase.SuiteName,
+			&testCase.ClassName,
+			&testCase.Name,
+			&testCase.Status,
+			&testCase.DurationSeconds,
+			&testCase.FailureMessage,
+			&testCase.InsertedAt); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
 		}
+		testCases = append(testCases, testCase)
+	}
 
-		span.SetTag("trigger-event", event)
-		span.SetTag("trigger-identifier", identifier)
-
-		break
+	return
+}
 
-	case "pubsub":
+func (dbc *cockroachDBClientImpl) GetPipelineTestReportSummaries(ctx context.Context, repoSource, repoOwner, repoName string, pageSize int, filters map[string][]string) (summaries []*TestReportSummary, err error) {
 
-		trigger.PubSub = &manifest.EstafettePubSubTrigger{}
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineTestReportSummaries")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineTestReportSummaries", time.Now())
 
-		break
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	default:
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-		return pipelines, fmt.Errorf("Trigger type %v is not supported", triggerType)
-	}
+	query := psql.
+		Select("a.build_id, a.build_version, a.repo_branch, count(a.id), count(a.id) FILTER (WHERE a.status = 'failed'), count(a.id) FILTER (WHERE a.status = 'skipped'), sum(a.duration_seconds), max(a.inserted_at)").
+		From("build_test_cases a").
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		GroupBy("a.build_id, a.build_version, a.repo_branch").
+		OrderBy("max(a.inserted_at) DESC").
+		Limit(uint64(pageSize))
 
-	bytes, err := json.Marshal([]manifest.EstafetteTrigger{trigger})
+	query, err = whereClauseGeneratorForSinceFilter(query, "a", "inserted_at", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return pipelines, err
+		return
 	}
 
-	query = query.Where("a.triggers @> ?", string(bytes))
-
-	// execute query
 	rows, err := query.RunWith(dbc.databaseConnection).Query()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
 		return
 	}
+	defer rows.Close()
 
-	// read rows
-	if pipelines, err = dbc.scanPipelines(rows, false); err != nil {
-		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(err))
-		return
+	summaries = make([]*TestReportSummary, 0)
+	for rows.Next() {
+		summary := &TestReportSummary{}
+		if err = rows.Scan(
+			&summary.BuildID,
+			&summary.BuildVersion,
+			&summary.RepoBranch,
+			&summary.Tests,
+			&summary.Failures,
+			&summary.Skipped,
+			&summary.DurationSeconds,
+			&summary.InsertedAt); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+		summaries = append(summaries, summary)
 	}
 
-	span.SetTag("trigger-pipelines-count", len(pipelines))
-
 	return
 }
 
-func (dbc *cockroachDBClientImpl) GetGitTriggers(ctx context.Context, gitEvent manifest.EstafetteGitEvent) ([]*contracts.Pipeline, error) {
-
-	triggerType := "git"
-	name := gitEvent.Repository
-	event := gitEvent.Event
-
-	return dbc.GetTriggers(ctx, triggerType, name, event)
-}
-
-func (dbc *cockroachDBClientImpl) GetPipelineTriggers(ctx context.Context, build contracts.Build, event string) ([]*contracts.Pipeline, error) {
-
-	triggerType := "pipeline"
-	name := fmt.Sprintf("%v/%v/%v", build.RepoSource, build.RepoOwner, build.RepoName)
-
-	return dbc.GetTriggers(ctx, triggerType, name, event)
-}
-
-func (dbc *cockroachDBClientImpl) GetReleaseTriggers(ctx context.Context, release contracts.Release, event string) ([]*contracts.Pipeline, error) {
-
-	triggerType := "release"
-	name := fmt.Sprintf("%v/%v/%v", release.RepoSource, release.RepoOwner, release.RepoName)
-
-	return dbc.GetTriggers(ctx, triggerType, name, event)
-}
-
-func (dbc *cockroachDBClientImpl) GetPubSubTriggers(ctx context.Context, pubsubEvent manifest.EstafettePubSubEvent) ([]*contracts.Pipeline, error) {
-
-	triggerType := "pubsub"
-
-	return dbc.GetTriggers(ctx, triggerType, "", "")
+func (dbc *cockroachDBClientImpl) GetPipelineSlowestTestCases(ctx context.Context, repoSource, repoOwner, repoName string, pageSize int, filters map[string][]string) (stats []*TestCaseStats, err error) {
+	return dbc.getPipelineTestCaseStats(ctx, "CockroachDb::GetPipelineSlowestTestCases", repoSource, repoOwner, repoName, "avg_duration DESC", false, pageSize, filters)
 }
 
-func (dbc *cockroachDBClientImpl) GetCronTriggers(ctx context.Context) ([]*contracts.Pipeline, error) {
-
-	triggerType := "cron"
-
-	return dbc.GetTriggers(ctx, triggerType, "", "")
+func (dbc *cockroachDBClientImpl) GetPipelineMostFailingTestCases(ctx context.Context, repoSource, repoOwner, repoName string, pageSize int, filters map[string][]string) (stats []*TestCaseStats, err error) {
+	return dbc.getPipelineTestCaseStats(ctx, "CockroachDb::GetPipelineMostFailingTestCases", repoSource, repoOwner, repoName, "nr_failures DESC", true, pageSize, filters)
 }
 
-func (dbc *cockroachDBClientImpl) Rename(ctx context.Context, shortFromRepoSource, fromRepoSource, fromRepoOwner, fromRepoName, shortToRepoSource, toRepoSource, toRepoOwner, toRepoName string) error {
+func (dbc *cockroachDBClientImpl) getPipelineTestCaseStats(ctx context.Context, operationName, repoSource, repoOwner, repoName, orderBy string, failedOnly bool, pageSize int, filters map[string][]string) (stats []*TestCaseStats, err error) {
 
-	span, ctx := opentracing.StartSpanFromContext(ctx, "CockroachDb::Rename")
+	span, _ := opentracing.StartSpanFromContext(ctx, operationName)
 	defer span.Finish()
+	defer dbc.observeQueryDuration(operationName, time.Now())
 
-	nrOfQueries := 7
-	var wg sync.WaitGroup
-	wg.Add(nrOfQueries)
-
-	errors := make(chan error, nrOfQueries)
-
-	go func(wg *sync.WaitGroup, ctx context.Context, shortFromRepoSource, fromRepoOwner, fromRepoName, shortToRepoSource, toRepoOwner, toRepoName string) {
-		defer wg.Done()
-		err := dbc.RenameBuildVersion(ctx, shortFromRepoSource, fromRepoOwner, fromRepoName, shortToRepoSource, toRepoOwner, toRepoName)
-		if err != nil {
-			errors <- err
-		}
-	}(&wg, ctx, shortFromRepoSource, fromRepoOwner, fromRepoName, shortToRepoSource, toRepoOwner, toRepoName)
-
-	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
-		defer wg.Done()
-		err := dbc.RenameBuilds(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
-		if err != nil {
-			errors <- err
-		}
-	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
-
-	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
-		defer wg.Done()
-		err := dbc.RenameBuildLogs(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
-		if err != nil {
-			errors <- err
-		}
-	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
-
-	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
-		defer wg.Done()
-		err := dbc.RenameReleases(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
-		if err != nil {
-			errors <- err
-		}
-	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
-
-	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
-		defer wg.Done()
-		err := dbc.RenameReleaseLogs(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
-		if err != nil {
-			errors <- err
-		}
-	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
-		defer wg.Done()
-		err := dbc.RenameComputedPipelines(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
-		if err != nil {
-			errors <- err
-		}
-	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	go func(wg *sync.WaitGroup, ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) {
-		defer wg.Done()
-		err := dbc.RenameComputedReleases(ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
-		if err != nil {
-			errors <- err
-		}
-	}(&wg, ctx, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName)
+	query := psql.
+		Select("a.suite_name, a.class_name, a.name, count(a.id) AS nr_runs, count(a.id) FILTER (WHERE a.status = 'failed') AS nr_failures, avg(a.duration_seconds) AS avg_duration, max(a.duration_seconds), max(a.inserted_at) FILTER (WHERE a.status = 'failed')").
+		From("build_test_cases a").
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		GroupBy("a.suite_name, a.class_name, a.name").
+		OrderBy(orderBy, "a.suite_name, a.class_name, a.name").
+		Limit(uint64(pageSize))
 
-	wg.Wait()
+	if failedOnly {
+		query = query.Having("count(a.id) FILTER (WHERE a.status = 'failed') > 0")
+	}
 
-	close(errors)
-	for e := range errors {
+	query, err = whereClauseGeneratorForSinceFilter(query, "a", "inserted_at", filters)
+	if err != nil {
 		ext.Error.Set(span, true)
-		span.LogFields(otlog.Error(e))
-		return e
+		span.LogFields(otlog.Error(err))
+		return
 	}
 
-	// update computed tables
-	err := dbc.UpsertComputedPipeline(ctx, toRepoSource, toRepoOwner, toRepoName)
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return err
+		return
+	}
+	defer rows.Close()
+
+	stats = make([]*TestCaseStats, 0)
+	for rows.Next() {
+		testCaseStats := &TestCaseStats{}
+		if err = rows.Scan(
+			&testCaseStats.SuiteName,
+			&testCaseStats.ClassName,
+			&testCaseStats.Name,
+			&testCaseStats.Runs,
+			&testCaseStats.Failures,
+			&testCaseStats.AverageDurationSeconds,
+			&testCaseStats.MaxDurationSeconds,
+			&testCaseStats.LastFailedAt); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+		stats = append(stats, testCaseStats)
 	}
 
-	return nil
+	return
 }
+func (dbc *cockroachDBClientImpl) GetPipelineBuildLogSteps(ctx context.Context, repoSource, repoOwner, repoName string, buildIDs []int) (steps []*BuildLogStepOutcome, err error) {
 
-func (dbc *cockroachDBClientImpl) RenameBuildVersion(ctx context.Context, shortFromRepoSource, fromRepoOwner, fromRepoName, shortToRepoSource, toRepoOwner, toRepoName string) error {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameBuildVersion")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineBuildLogSteps")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineBuildLogSteps", time.Now())
+
+	steps = make([]*BuildLogStepOutcome, 0)
+	if len(buildIDs) == 0 {
+		return
+	}
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
+	// extract the steps in the database to avoid transferring all log lines
 	query := psql.
-		Update("build_versions").
-		Set("repo_source", shortToRepoSource).
-		Set("repo_full_name", fmt.Sprintf("%v/%v", toRepoOwner, toRepoName)).
-		Where(sq.Eq{"repo_source": shortFromRepoSource}).
-		Where(sq.Eq{"repo_full_name": fmt.Sprintf("%v/%v", fromRepoOwner, fromRepoName)}).
-		Limit(uint64(1))
+		Select("a.build_id, s->>'step', COALESCE(s->'image'->>'name', ''), COALESCE(s->'image'->>'tag', ''), COALESCE(s->>'status', ''), COALESCE((s->>'exitCode')::INT, 0), COALESCE((s->>'duration')::INT, 0)").
+		From("build_logs a, jsonb_array_elements(a.steps) AS s").
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		Where(sq.Eq{"a.build_id": buildIDs}).
+		OrderBy("a.build_id")
 
-	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return err
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		step := &BuildLogStepOutcome{}
+		var imageName, imageTag string
+		var duration int64
+		if err = rows.Scan(
+			&step.BuildID,
+			&step.Step,
+			&imageName,
+			&imageTag,
+			&step.Status,
+			&step.ExitCode,
+			&duration); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+		if imageName != "" {
+			step.Image = fmt.Sprintf("%v:%v", imageName, imageTag)
+		}
+		step.Duration = time.Duration(duration)
+		steps = append(steps, step)
 	}
 
-	return nil
+	return
 }
+func (dbc *cockroachDBClientImpl) GetStepImagesWithMostFailures(ctx context.Context, pageNumber, pageSize int, filters map[string][]string) (images []*StepImageFailureStats, err error) {
 
-func (dbc *cockroachDBClientImpl) RenameBuilds(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameBuilds")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetStepImagesWithMostFailures")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetStepImagesWithMostFailures", time.Now())
 
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	query := psql.
-		Update("builds").
-		Set("repo_source", toRepoSource).
-		Set("repo_owner", toRepoOwner).
-		Set("repo_name", toRepoName).
-		Where(sq.Eq{"repo_source": fromRepoSource}).
-		Where(sq.Eq{"repo_owner": fromRepoOwner}).
-		Where(sq.Eq{"repo_name": fromRepoName})
+	query, err := dbc.selectStepImageFailuresQuery(filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	query = query.
+		OrderBy("nr_failures DESC, image").
+		Limit(uint64(pageSize)).
+		Offset(uint64((pageNumber - 1) * pageSize))
+
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return err
+		return
 	}
+	defer rows.Close()
 
-	return nil
+	images = make([]*StepImageFailureStats, 0)
+	for rows.Next() {
+		image := &StepImageFailureStats{}
+		if err = rows.Scan(
+			&image.Image,
+			&image.Runs,
+			&image.Failures,
+			&image.Pipelines); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+		if image.Runs > 0 {
+			image.FailureRate = float64(image.Failures) / float64(image.Runs)
+		}
+		images = append(images, image)
+	}
+
+	return
 }
 
-func (dbc *cockroachDBClientImpl) RenameBuildLogs(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
+func (dbc *cockroachDBClientImpl) GetStepImagesWithMostFailuresCount(ctx context.Context, filters map[string][]string) (totalCount int, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameBuildLogs")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetStepImagesWithMostFailuresCount")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetStepImagesWithMostFailuresCount", time.Now())
 
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-
-	query := psql.
-		Update("build_logs").
-		Set("repo_source", toRepoSource).
-		Set("repo_owner", toRepoOwner).
-		Set("repo_name", toRepoName).
-		Where(sq.Eq{"repo_source": fromRepoSource}).
-		Where(sq.Eq{"repo_owner": fromRepoOwner}).
-		Where(sq.Eq{"repo_name": fromRepoName})
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
-	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	innerquery, err := dbc.selectStepImageFailuresQuery(filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return err
+		return
 	}
 
-	return nil
+	query :=
+		sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+			Select("COUNT(*)").
+			FromSelect(innerquery, "a")
+
+	row := query.RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&totalCount); err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	return
 }
 
-func (dbc *cockroachDBClientImpl) RenameReleases(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
+func (dbc *cockroachDBClientImpl) selectStepImageFailuresQuery(filters map[string][]string) (query sq.SelectBuilder, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameReleases")
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	// only steps that ran count, and only images that failed at least once are of interest
+	query = psql.
+		Select("s->'image'->>'name' AS image, count(*) AS nr_runs, count(*) FILTER (WHERE lower(s->>'status') = 'failed') AS nr_failures, count(DISTINCT concat(a.repo_source, '/', a.repo_owner, '/', a.repo_name)) AS nr_pipelines").
+		From("build_logs a, jsonb_array_elements(a.steps) AS s").
+		Where("s->'image'->>'name' IS NOT NULL").
+		Where(sq.Eq{"lower(s->>'status')": []string{"succeeded", "failed"}}).
+		GroupBy("image").
+		Having("count(*) FILTER (WHERE lower(s->>'status') = 'failed') > 0")
+
+	return whereClauseGeneratorForSinceFilter(query, "a", "inserted_at", filters)
+}
+func (dbc *cockroachDBClientImpl) GetDeploymentRecords(ctx context.Context, releaseNames []string, filters map[string][]string) (deployments []*DeploymentRecord, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetDeploymentRecords")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetDeploymentRecords", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
+	// releases don't have labels themselves, so they're filtered by the labels of their pipeline
 	query := psql.
-		Update("releases").
-		Set("repo_source", toRepoSource).
-		Set("repo_owner", toRepoOwner).
-		Set("repo_name", toRepoName).
-		Where(sq.Eq{"repo_source": fromRepoSource}).
-		Where(sq.Eq{"repo_owner": fromRepoOwner}).
-		Where(sq.Eq{"repo_name": fromRepoName})
+		Select("a.repo_source, a.repo_owner, a.repo_name, a.release, a.release_version, a.release_status, a.inserted_at, a.updated_at, (SELECT min(b.inserted_at) FROM builds b WHERE b.repo_source = a.repo_source AND b.repo_owner = a.repo_owner AND b.repo_name = a.repo_name AND b.build_version = a.release_version)").
+		From("releases a").
+		Join("computed_pipelines p ON p.repo_source = a.repo_source AND p.repo_owner = a.repo_owner AND p.repo_name = a.repo_name").
+		Where(sq.Eq{"a.release": releaseNames}).
+		Where(sq.Eq{"a.release_status": []string{"succeeded", "failed"}}).
+		OrderBy("a.inserted_at")
 
-	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	query, err = whereClauseGeneratorForSinceFilter(query, "a", "inserted_at", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return err
+		return
 	}
 
-	return nil
-}
-
-func (dbc *cockroachDBClientImpl) RenameReleaseLogs(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameReleaseLogs")
-	defer span.Finish()
-
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-
-	query := psql.
-		Update("release_logs").
-		Set("repo_source", toRepoSource).
-		Set("repo_owner", toRepoOwner).
-		Set("repo_name", toRepoName).
-		Where(sq.Eq{"repo_source": fromRepoSource}).
-		Where(sq.Eq{"repo_owner": fromRepoOwner}).
-		Where(sq.Eq{"repo_name": fromRepoName})
+	query, err = whereClauseGeneratorForLabelsFilter(query, "p", filters)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
 
-	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return err
+		return
 	}
+	defer rows.Close()
 
-	return nil
+	deployments = make([]*DeploymentRecord, 0)
+	for rows.Next() {
+		deployment := &DeploymentRecord{}
+		if err = rows.Scan(
+			&deployment.RepoSource,
+			&deployment.RepoOwner,
+			&deployment.RepoName,
+			&deployment.ReleaseName,
+			&deployment.ReleaseVersion,
+			&deployment.ReleaseStatus,
+			&deployment.InsertedAt,
+			&deployment.FinishedAt,
+			&deployment.BuildInsertedAt); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+		deployments = append(deployments, deployment)
+	}
+
+	return
 }
 
-func (dbc *cockroachDBClientImpl) RenameComputedPipelines(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
+func (dbc *cockroachDBClientImpl) GetPipelineFlakyTestCases(ctx context.Context, repoSource, repoOwner, repoName string, pageSize int, filters map[string][]string) (testCases []*FlakyTestCase, err error) {
 
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameComputedPipelines")
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::GetPipelineFlakyTestCases")
 	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::GetPipelineFlakyTestCases", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
 
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	query := psql.
-		Update("computed_pipelines").
-		Set("repo_source", toRepoSource).
-		Set("repo_owner", toRepoOwner).
-		Set("repo_name", toRepoName).
-		Where(sq.Eq{"repo_source": fromRepoSource}).
-		Where(sq.Eq{"repo_owner": fromRepoOwner}).
-		Where(sq.Eq{"repo_name": fromRepoName})
+	// tests that both passed and failed in builds of the same revision
+	innerquery := psql.
+		Select("a.suite_name, a.class_name, a.name, b.repo_revision").
+		From("build_test_cases a").
+		Join("builds b ON b.id = a.build_id").
+		Where(sq.Eq{"a.repo_source": repoSource}).
+		Where(sq.Eq{"a.repo_owner": repoOwner}).
+		Where(sq.Eq{"a.repo_name": repoName}).
+		GroupBy("a.suite_name, a.class_name, a.name, b.repo_revision").
+		Having("count(a.id) FILTER (WHERE a.status = 'failed') > 0 AND count(a.id) FILTER (WHERE a.status = 'passed') > 0")
 
-	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	innerquery, err = whereClauseGeneratorForSinceFilter(innerquery, "a", "inserted_at", filters)
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return err
+		return
 	}
 
-	return nil
-}
-
-func (dbc *cockroachDBClientImpl) RenameComputedReleases(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
-
-	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::RenameComputedReleases")
-	defer span.Finish()
-
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-
 	query := psql.
-		Update("computed_releases").
-		Set("repo_source", toRepoSource).
-		Set("repo_owner", toRepoOwner).
-		Set("repo_name", toRepoName).
-		Where(sq.Eq{"repo_source": fromRepoSource}).
-		Where(sq.Eq{"repo_owner": fromRepoOwner}).
-		Where(sq.Eq{"repo_name": fromRepoName})
+		Select("a.suite_name, a.class_name, a.name, count(*) AS nr_revisions").
+		FromSelect(innerquery, "a").
+		GroupBy("a.suite_name, a.class_name, a.name").
+		OrderBy("nr_revisions DESC, a.suite_name, a.class_name, a.name").
+		Limit(uint64(pageSize))
 
-	_, err := query.RunWith(dbc.databaseConnection).Exec()
+	rows, err := query.RunWith(dbc.databaseConnection).Query()
 	if err != nil {
 		ext.Error.Set(span, true)
 		span.LogFields(otlog.Error(err))
-		return err
+		return
 	}
+	defer rows.Close()
 
-	return nil
+	testCases = make([]*FlakyTestCase, 0)
+	for rows.Next() {
+		testCase := &FlakyTestCase{}
+		if err = rows.Scan(
+			&testCase.SuiteName,
+			&testCase.ClassName,
+			&testCase.Name,
+			&testCase.FlakyRevisions); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+			return nil, err
+		}
+		testCases = append(testCases, testCase)
+	}
+
+	return
 }
 
 func (dbc *cockroachDBClientImpl) selectBuildsQuery() sq.SelectBuilder {
@@ -4021,3 +5983,39 @@ func (dbc *cockroachDBClientImpl) mapBuildToPipeline(build *contracts.Build) (pi
 		Events:               build.Events,
 	}
 }
+
+// AcquireLease takes the named lease for this replica if it's free or expired, or extends it if this replica already holds it; it returns whether this replica holds the lease
+func (dbc *cockroachDBClientImpl) AcquireLease(ctx context.Context, name string, duration time.Duration) (acquired bool, err error) {
+
+	span, _ := opentracing.StartSpanFromContext(ctx, "CockroachDb::AcquireLease")
+	defer span.Finish()
+	defer dbc.observeQueryDuration("CockroachDb::AcquireLease", time.Now())
+
+	dbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "cockroachdb"}).Inc()
+
+	var holder string
+	row := dbc.acquireLeaseQuery(name, duration).RunWith(dbc.databaseConnection).QueryRow()
+	if err = row.Scan(&holder); err != nil {
+		if err == sql.ErrNoRows {
+			// another replica holds the lease
+			return false, nil
+		}
+		ext.Error.Set(span, true)
+		span.LogFields(otlog.Error(err))
+		return
+	}
+
+	return holder == dbc.leaseHolder, nil
+}
+
+// acquireLeaseQuery upserts the lease, but only overwrites it if this replica holds it or it expired; otherwise it returns no row
+func (dbc *cockroachDBClientImpl) acquireLeaseQuery(name string, duration time.Duration) sq.InsertBuilder {
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	return psql.
+		Insert("leases").
+		Columns("name", "holder", "expires_at").
+		Values(name, dbc.leaseHolder, sq.Expr("now() + ? * INTERVAL '1 second'", int(duration.Seconds()))).
+		Suffix("ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at WHERE leases.holder = excluded.holder OR leases.expires_at < now() RETURNING holder")
+}
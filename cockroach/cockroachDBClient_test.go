@@ -20,6 +20,18 @@ var (
 			Help: "Total of outgoing api calls.",
 		},
 		[]string{"target"},
+	), prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "estafette_ci_api_database_query_duration_seconds",
+			Help: "Latency of database queries per database client method.",
+		},
+		[]string{"method"},
+	), prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "estafette_ci_api_log_insert_bytes",
+			Help: "Size of inserted build and release logs.",
+		},
+		[]string{"type"},
 	))
 )
 
@@ -162,6 +174,76 @@ func TestQueryBuilder(t *testing.T) {
 		assert.Equal(t, "SELECT key, value, pipelinesCount FROM (SELECT key, value, count(DISTINCT id) AS pipelinesCount FROM (SELECT l->>'key' AS key, l->>'value' AS value, id FROM (SELECT a.id, jsonb_array_elements(a.labels) AS l FROM computed_pipelines a WHERE jsonb_typeof(labels) = 'array' AND a.inserted_at >= $1 AND a.build_status IN ($2) AND a.labels @> $3) AS b) AS c GROUP BY key, value) AS d WHERE pipelinesCount > $4 ORDER BY pipelinesCount DESC, key, value LIMIT 7", sql)
 	})
 
+	t.Run("GeneratesTimeSeriesQueryWithFilters", func(t *testing.T) {
+
+		query, _ := cdbClient.selectTimeSeriesQuery("builds", "build_status", "day")
+
+		query, _ = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", map[string][]string{
+			"status": []string{
+				"succeeded",
+			},
+		})
+		query, _ = whereClauseGeneratorForRepoSourceFilter(query, "a", map[string][]string{
+			"source": []string{
+				"github.com",
+			},
+		})
+
+		// act
+		sql, _, err := query.ToSql()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT date_trunc('day', a.inserted_at) AS bucket, count(*), count(*) FILTER (WHERE a.build_status = 'succeeded'), count(*) FILTER (WHERE a.build_status = 'failed'), COALESCE(avg(a.duration::INT), 0), COALESCE(avg(a.cpu_max_usage), 0), COALESCE(avg(a.memory_max_usage), 0) FROM builds a WHERE a.build_status IN ($1) AND a.repo_source IN ($2) GROUP BY bucket ORDER BY bucket", sql)
+	})
+
+	t.Run("ReturnsErrorForUnsupportedTimeSeriesInterval", func(t *testing.T) {
+
+		// act
+		_, err := cdbClient.selectTimeSeriesQuery("builds", "build_status", "minute'; DROP TABLE builds; --")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("GeneratesResourceUsageQueryWithFilters", func(t *testing.T) {
+
+		query := cdbClient.selectResourceUsageQuery("builds")
+
+		query, _ = whereClauseGeneratorForAllFilters(query, "a", "inserted_at", map[string][]string{
+			"status": []string{
+				"succeeded",
+			},
+		})
+
+		// act
+		sql, _, err := query.ToSql()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT a.repo_source, a.repo_owner, a.repo_name, count(a.duration) AS jobs, COALESCE(sum(a.duration::INT), 0) AS seconds, COALESCE(sum(a.cpu_request * a.duration::INT), 0) AS cpu_request_seconds, COALESCE(sum(a.memory_request * a.duration::INT), 0) AS memory_request_seconds, COALESCE(sum(a.cpu_max_usage * a.duration::INT), 0) AS cpu_max_usage_seconds, COALESCE(sum(a.memory_max_usage * a.duration::INT), 0) AS memory_max_usage_seconds, COALESCE(sum(a.cpu_request * a.duration::INT) FILTER (WHERE a.cpu_max_usage IS NOT NULL), 0) AS cpu_measured_request_seconds, COALESCE(sum(a.memory_request * a.duration::INT) FILTER (WHERE a.memory_max_usage IS NOT NULL), 0) AS memory_measured_request_seconds FROM builds a WHERE a.build_status IN ($1) GROUP BY a.repo_source, a.repo_owner, a.repo_name", sql)
+	})
+
+	t.Run("GeneratesResourceMeasurementsQueryIncludingOOMKilledJobs", func(t *testing.T) {
+
+		query := cdbClient.selectResourceMeasurementsQuery("builds", "github.com", "estafette", "estafette-ci-api", 25)
+
+		// act
+		sql, _, err := query.ToSql()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "SELECT COALESCE(a.cpu_max_usage, 0), COALESCE(a.memory_max_usage, 0), COALESCE(a.memory_limit, 0), COALESCE(a.oom_killed, false) FROM builds a WHERE a.repo_source = $1 AND a.repo_owner = $2 AND a.repo_name = $3 AND ((a.cpu_max_usage IS NOT NULL AND a.memory_max_usage IS NOT NULL) OR a.oom_killed = $4) ORDER BY a.inserted_at DESC LIMIT 25", sql)
+	})
+
+	t.Run("GeneratesAcquireLeaseQuery", func(t *testing.T) {
+
+		query := cdbClient.acquireLeaseQuery("pubsub-subscription-reconciler", 10*time.Minute)
+
+		// act
+		sql, args, err := query.ToSql()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "INSERT INTO leases (name,holder,expires_at) VALUES ($1,$2,now() + $3 * INTERVAL '1 second') ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at WHERE leases.holder = excluded.holder OR leases.expires_at < now() RETURNING holder", sql)
+		assert.Equal(t, 600, args[2])
+	})
+
 	t.Run("GeneratesUpdateBuildStatusQuery", func(t *testing.T) {
 
 		buildStatus := "canceling"
@@ -2,6 +2,8 @@ package cockroach
 
 import (
 	"time"
+
+	contracts "github.com/estafette/estafette-ci-contracts"
 )
 
 // BuildVersionDetail represents a specific build, including version number, repo, branch, revision and manifest
@@ -25,3 +27,182 @@ type JobResources struct {
 	MemoryLimit    float64
 	MemoryMaxUsage float64
 }
+
+// ResourceMeasurement holds the measured peak usage of a build or release job and whether it got oom-killed at its memory limit
+type ResourceMeasurement struct {
+	CPUMaxUsage    float64
+	MemoryMaxUsage float64
+	MemoryLimit    float64
+	OOMKilled      bool
+}
+
+// ResourceRecommendation holds the resources a build or release job gets along with the reasoning behind them
+type ResourceRecommendation struct {
+	CPURequest    float64  `json:"cpuRequest"`
+	CPULimit      float64  `json:"cpuLimit"`
+	MemoryRequest float64  `json:"memoryRequest"`
+	MemoryLimit   float64  `json:"memoryLimit"`
+	Measurements  int      `json:"measurements"`
+	Reasons       []string `json:"reasons"`
+}
+
+// JobResourceMetrics holds the resource usage of a build or release job as measured by prometheus, with the value per metric over the job's lifetime and optionally its usage curve
+type JobResourceMetrics struct {
+	Values map[string]float64               `json:"values"`
+	Series map[string][]ResourceUsageSample `json:"series,omitempty"`
+}
+
+// ResourceUsageSample is a single point of a usage curve
+type ResourceUsageSample struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// WebhookDelivery represents the delivery of a single event to a webhook subscriber, kept for inspection and redelivery
+type WebhookDelivery struct {
+	ID             string    `json:"id"`
+	SubscriberName string    `json:"subscriberName"`
+	URL            string    `json:"url"`
+	EventID        string    `json:"eventID"`
+	EventType      string    `json:"eventType"`
+	Payload        string    `json:"payload"`
+	StatusCode     int       `json:"statusCode"`
+	Succeeded      bool      `json:"succeeded"`
+	Attempts       int       `json:"attempts"`
+	Error          string    `json:"error,omitempty"`
+	InsertedAt     time.Time `json:"insertedAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// BuildRetry links a build lost to node preemption to the build that automatically retried the same version
+type BuildRetry struct {
+	ID           string    `json:"id"`
+	RepoSource   string    `json:"repoSource"`
+	RepoOwner    string    `json:"repoOwner"`
+	RepoName     string    `json:"repoName"`
+	BuildVersion string    `json:"buildVersion"`
+	BuildID      string    `json:"buildID"`
+	RetryBuildID string    `json:"retryBuildID"`
+	Attempt      int       `json:"attempt"`
+	Reason       string    `json:"reason"`
+	InsertedAt   time.Time `json:"insertedAt"`
+}
+
+// TestCase is the result of a single test in a test report posted for a build
+type TestCase struct {
+	ID              string    `json:"id"`
+	RepoSource      string    `json:"repoSource"`
+	RepoOwner       string    `json:"repoOwner"`
+	RepoName        string    `json:"repoName"`
+	RepoBranch      string    `json:"repoBranch"`
+	BuildID         string    `json:"buildID"`
+	BuildVersion    string    `json:"buildVersion"`
+	SuiteName       string    `json:"suiteName"`
+	ClassName       string    `json:"className,omitempty"`
+	Name            string    `json:"name"`
+	Status          string    `json:"status"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	FailureMessage  string    `json:"failureMessage,omitempty"`
+	InsertedAt      time.Time `json:"insertedAt"`
+}
+
+// TestCaseStats aggregates the results of a single test over the builds of a pipeline
+type TestCaseStats struct {
+	SuiteName              string     `json:"suiteName"`
+	ClassName              string     `json:"className,omitempty"`
+	Name                   string     `json:"name"`
+	Runs                   int        `json:"runs"`
+	Failures               int        `json:"failures"`
+	AverageDurationSeconds float64    `json:"averageDurationSeconds"`
+	MaxDurationSeconds     float64    `json:"maxDurationSeconds"`
+	LastFailedAt           *time.Time `json:"lastFailedAt,omitempty"`
+}
+
+// TestReportSummary totals the test results of a build
+type TestReportSummary struct {
+	BuildID         string     `json:"buildID"`
+	BuildVersion    string     `json:"buildVersion"`
+	RepoBranch      string     `json:"repoBranch"`
+	Tests           int        `json:"tests"`
+	Failures        int        `json:"failures"`
+	Skipped         int        `json:"skipped"`
+	DurationSeconds float64    `json:"durationSeconds"`
+	InsertedAt      *time.Time `json:"insertedAt,omitempty"`
+}
+
+// BuildLogStepOutcome is the result of a single step in a build's logs, without its log lines
+type BuildLogStepOutcome struct {
+	BuildID  string        `json:"buildID"`
+	Step     string        `json:"step"`
+	Image    string        `json:"image,omitempty"`
+	Status   string        `json:"status"`
+	ExitCode int64         `json:"exitCode"`
+	Duration time.Duration `json:"duration"`
+}
+
+// FlakyTestCase is a test that both passed and failed for the same revision
+type FlakyTestCase struct {
+	SuiteName      string `json:"suiteName"`
+	ClassName      string `json:"className,omitempty"`
+	Name           string `json:"name"`
+	FlakyRevisions int    `json:"flakyRevisions"`
+}
+
+// StepImageFailureStats aggregates how often stages running a container image fail across all pipelines
+type StepImageFailureStats struct {
+	Image       string  `json:"image"`
+	Runs        int     `json:"runs"`
+	Failures    int     `json:"failures"`
+	FailureRate float64 `json:"failureRate"`
+	Pipelines   int     `json:"pipelines"`
+}
+
+// DeploymentRecord is a finished release along with the time the build it released was started, to compute delivery performance metrics
+type DeploymentRecord struct {
+	RepoSource      string
+	RepoOwner       string
+	RepoName        string
+	ReleaseName     string
+	ReleaseVersion  string
+	ReleaseStatus   string
+	InsertedAt      time.Time
+	FinishedAt      time.Time
+	BuildInsertedAt *time.Time
+}
+
+// ReleaseWithManifest is a release along with the raw manifest of the build it releases, to read settings of its release target
+type ReleaseWithManifest struct {
+	Release  contracts.Release
+	Manifest string
+}
+
+// StatsBucket aggregates the builds or releases started within a time interval
+type StatsBucket struct {
+	Start                 time.Time     `json:"start"`
+	Count                 int           `json:"count"`
+	Succeeded             int           `json:"succeeded"`
+	Failed                int           `json:"failed"`
+	SuccessRate           float64       `json:"successRate"`
+	AverageDuration       time.Duration `json:"averageDuration"`
+	AverageCPUMaxUsage    float64       `json:"averageCPUMaxUsage"`
+	AverageMemoryMaxUsage float64       `json:"averageMemoryMaxUsage"`
+}
+
+// bytesPerGB converts bytes into the GB (2^30 bytes) cloud providers price memory by
+const bytesPerGB = 1024 * 1024 * 1024
+
+// ResourceUsage sums the requested and measured peak resources of the finished builds or releases of a pipeline, multiplied by their duration; the measured request sums only cover the jobs with measured peak usage, to compare requests with usage over the same jobs
+type ResourceUsage struct {
+	RepoSource                   string            `json:"repoSource"`
+	RepoOwner                    string            `json:"repoOwner"`
+	RepoName                     string            `json:"repoName"`
+	Labels                       []contracts.Label `json:"labels,omitempty"`
+	Jobs                         int               `json:"jobs"`
+	Duration                     time.Duration     `json:"duration"`
+	CPURequestCoreHours          float64           `json:"cpuRequestCoreHours"`
+	MemoryRequestGBHours         float64           `json:"memoryRequestGBHours"`
+	CPUMaxUsageCoreHours         float64           `json:"cpuMaxUsageCoreHours"`
+	MemoryMaxUsageGBHours        float64           `json:"memoryMaxUsageGBHours"`
+	CPUMeasuredRequestCoreHours  float64           `json:"cpuMeasuredRequestCoreHours"`
+	MemoryMeasuredRequestGBHours float64           `json:"memoryMeasuredRequestGBHours"`
+}
@@ -38,13 +38,87 @@ type AuthConfig struct {
 
 // JobsConfig configures the lower and upper bounds for automatically setting resources for build/release jobs
 type JobsConfig struct {
-	Namespace          string  `yaml:"namespace"`
-	MinCPUCores        float64 `yaml:"minCPUCores"`
-	MaxCPUCores        float64 `yaml:"maxCPUCores"`
-	CPURequestRatio    float64 `yaml:"cpuRequestRatio"`
-	MinMemoryBytes     float64 `yaml:"minMemoryBytes"`
-	MaxMemoryBytes     float64 `yaml:"maxMemoryBytes"`
-	MemoryRequestRatio float64 `yaml:"memoryRequestRatio"`
+	Namespace             string                      `yaml:"namespace"`
+	MinCPUCores           float64                     `yaml:"minCPUCores"`
+	MaxCPUCores           float64                     `yaml:"maxCPUCores"`
+	CPURequestRatio       float64                     `yaml:"cpuRequestRatio"`
+	MinMemoryBytes        float64                     `yaml:"minMemoryBytes"`
+	MaxMemoryBytes        float64                     `yaml:"maxMemoryBytes"`
+	MemoryRequestRatio    float64                     `yaml:"memoryRequestRatio"`
+	ResourcePercentile    float64                     `yaml:"resourcePercentile"`
+	OOMMemoryLimitRatio   float64                     `yaml:"oomMemoryLimitRatio"`
+	MaxMemoryLimitBytes   float64                     `yaml:"maxMemoryLimitBytes"`
+	PendingTimeoutMinutes int                         `yaml:"pendingTimeoutMinutes"`
+	PreemptionRetries     int                         `yaml:"preemptionRetries"`
+	ReapIntervalMinutes   int                         `yaml:"reapIntervalMinutes"`
+	ReapMinAgeMinutes     int                         `yaml:"reapMinAgeMinutes"`
+	TimeoutMinutes        int                         `yaml:"timeoutMinutes"`
+	TimeoutSweepMinutes   int                         `yaml:"timeoutSweepMinutes"`
+	StalePendingMinutes   int                         `yaml:"stalePendingMinutes"`
+	StaleRunningMinutes   int                         `yaml:"staleRunningMinutes"`
+	StaleCancelingMinutes int                         `yaml:"staleCancelingMinutes"`
+	StaleSweepMinutes     int                         `yaml:"staleSweepMinutes"`
+	CPUCoreHourPrice      float64                     `yaml:"cpuCoreHourPrice"`
+	MemoryGBHourPrice     float64                     `yaml:"memoryGBHourPrice"`
+	PodSpec               *JobPodSpecConfig           `yaml:"podSpec"`
+	PodSpecOverrides      []*JobPodSpecOverrideConfig `yaml:"podSpecOverrides"`
+	Clusters              []*ClusterConfig            `yaml:"clusters"`
+}
+
+// ClusterConfig configures a kubernetes cluster to dispatch build/release jobs to; jobs go to the least loaded cluster whose filters all match, or to the cluster the api runs in if none match
+type ClusterConfig struct {
+	Name             string            `yaml:"name"`
+	KubeConfigPath   string            `yaml:"kubeConfigPath"`
+	Namespace        string            `yaml:"namespace"`
+	OperatingSystems []string          `yaml:"operatingSystems"`
+	Labels           map[string]string `yaml:"labels"`
+	ReleaseTargets   []string          `yaml:"releaseTargets"`
+}
+
+// JobPodSpecConfig configures scheduling and identity of the pods of build/release jobs; without affinity jobs prefer preemptible nodes on GKE
+type JobPodSpecConfig struct {
+	NodeSelector       map[string]string   `yaml:"nodeSelector"`
+	Tolerations        []*TolerationConfig `yaml:"tolerations"`
+	Affinity           *NodeAffinityConfig `yaml:"affinity"`
+	PriorityClassName  string              `yaml:"priorityClassName"`
+	Annotations        map[string]string   `yaml:"annotations"`
+	Labels             map[string]string   `yaml:"labels"`
+	ImagePullSecrets   []string            `yaml:"imagePullSecrets"`
+	ServiceAccountName string              `yaml:"serviceAccountName"`
+}
+
+// JobPodSpecOverrideConfig overrides the pod spec for releases to a target and/or pipelines with matching labels
+type JobPodSpecOverrideConfig struct {
+	ReleaseTarget string            `yaml:"releaseTarget"`
+	Labels        map[string]string `yaml:"labels"`
+	PodSpec       JobPodSpecConfig  `yaml:"podSpec"`
+}
+
+// TolerationConfig configures a toleration for the taints of dedicated ci nodes
+type TolerationConfig struct {
+	Key      string `yaml:"key"`
+	Operator string `yaml:"operator"`
+	Value    string `yaml:"value"`
+	Effect   string `yaml:"effect"`
+}
+
+// NodeAffinityConfig configures the nodes that job pods require or prefer to run on
+type NodeAffinityConfig struct {
+	Required  []*NodeSelectorRequirementConfig `yaml:"required"`
+	Preferred []*PreferredNodeAffinityConfig   `yaml:"preferred"`
+}
+
+// NodeSelectorRequirementConfig matches node labels with an operator like In, NotIn, Exists or DoesNotExist
+type NodeSelectorRequirementConfig struct {
+	Key      string   `yaml:"key"`
+	Operator string   `yaml:"operator"`
+	Values   []string `yaml:"values"`
+}
+
+// PreferredNodeAffinityConfig configures a weighted node preference
+type PreferredNodeAffinityConfig struct {
+	Weight           int32                            `yaml:"weight"`
+	MatchExpressions []*NodeSelectorRequirementConfig `yaml:"matchExpressions"`
 }
 
 // IAPAuthConfig sets iap config in case it's used for authentication and authorization
@@ -72,6 +146,7 @@ type APIConfigIntegrations struct {
 	Pubsub     *PubsubConfig     `yaml:"pubsub,omitempty"`
 	Prometheus *PrometheusConfig `yaml:"prometheus,omitempty"`
 	BigQuery   *BigQueryConfig   `yaml:"bigquery,omitempty"`
+	Webhooks   *WebhooksConfig   `yaml:"webhooks,omitempty"`
 }
 
 // GithubConfig is used to configure github integration
@@ -95,7 +170,26 @@ type SlackConfig struct {
 	ClientID             string `yaml:"clientID"`
 	ClientSecret         string `yaml:"clientSecret"`
 	AppVerificationToken string `yaml:"appVerificationToken"`
+	AppSigningSecret     string `yaml:"appSigningSecret"`
 	AppOAuthAccessToken  string `yaml:"appOAuthAccessToken"`
+	APIBaseURL           string `yaml:"apiBaseURL"`
+
+	Notifications *SlackNotificationsConfig `yaml:"notifications,omitempty"`
+}
+
+// SlackNotificationsConfig configures to which Slack channels build and release outcomes get posted
+type SlackNotificationsConfig struct {
+	Enable                   bool                  `yaml:"enable"`
+	DefaultChannels          []string              `yaml:"defaultChannels"`
+	LabelChannels            []*SlackChannelConfig `yaml:"labelChannels"`
+	NotifyCommitterOnFailure bool                  `yaml:"notifyCommitterOnFailure"`
+	MessagesPerSecond        float64               `yaml:"messagesPerSecond"`
+}
+
+// SlackChannelConfig maps pipelines with all of the specified labels to a Slack channel
+type SlackChannelConfig struct {
+	Labels  map[string]string `yaml:"labels"`
+	Channel string            `yaml:"channel"`
 }
 
 // PubsubConfig is used to be able to subscribe to pub/sub topics for triggering pipelines based on pub/sub events
@@ -106,12 +200,25 @@ type PubsubConfig struct {
 	ServiceAccountEmail            string `yaml:"serviceAccountEmail"`
 	SubscriptionNameSuffix         string `yaml:"subscriptionNameSuffix"`
 	SubscriptionIdleExpirationDays int    `yaml:"subscriptionIdleExpirationDays"`
+	SubscriptionReconcileMinutes   int    `yaml:"subscriptionReconcileMinutes"`
+	PullMode                       bool   `yaml:"pullMode"`
+	EventsProject                  string `yaml:"eventsProject"`
+	EventsTopic                    string `yaml:"eventsTopic"`
 }
 
-// PrometheusConfig configures where to find prometheus for retrieving max cpu and memory consumption of build and release jobs
+// PrometheusConfig configures where to find prometheus for retrieving the resource usage of build and release jobs; queries override the built-in query templates per metric
 type PrometheusConfig struct {
-	ServerURL             string `yaml:"serverURL"`
-	ScrapeIntervalSeconds int    `yaml:"scrapeIntervalSeconds"`
+	ServerURL             string                            `yaml:"serverURL"`
+	ScrapeIntervalSeconds int                               `yaml:"scrapeIntervalSeconds"`
+	RangeStepSeconds      int                               `yaml:"rangeStepSeconds"`
+	CollectRetries        int                               `yaml:"collectRetries"`
+	Queries               map[string]*PrometheusQueryConfig `yaml:"queries"`
+}
+
+// PrometheusQueryConfig holds the templates for a job metric, with {{.PodName}}, {{.Container}} and {{.Window}} available; query returns one value for the lifetime of the job and rangeQuery its value over time, leaving both empty disables the metric
+type PrometheusQueryConfig struct {
+	Query      string `yaml:"query"`
+	RangeQuery string `yaml:"rangeQuery"`
 }
 
 // BigQueryConfig configures the dataset where to send bigquery events
@@ -121,6 +228,25 @@ type BigQueryConfig struct {
 	Dataset   string `yaml:"dataset"`
 }
 
+// WebhooksConfig configures subscribers that receive build and release events as CloudEvents
+type WebhooksConfig struct {
+	Enable      bool                       `yaml:"enable"`
+	MaxRetries  int                        `yaml:"maxRetries"`
+	Workers     int                        `yaml:"workers"`
+	QueueSize   int                        `yaml:"queueSize"`
+	Subscribers []*WebhookSubscriberConfig `yaml:"subscribers"`
+}
+
+// WebhookSubscriberConfig configures a single webhook subscriber; without pipelines and labels it receives events for all pipelines
+type WebhookSubscriberConfig struct {
+	Name      string            `yaml:"name"`
+	URL       string            `yaml:"url"`
+	Secret    string            `yaml:"secret"`
+	Events    []string          `yaml:"events"`
+	Pipelines []string          `yaml:"pipelines"`
+	Labels    map[string]string `yaml:"labels"`
+}
+
 // ConfigReader reads the api config from file
 type ConfigReader interface {
 	ReadConfigFromFile(string, bool) (*APIConfig, error)
@@ -62,7 +62,37 @@ func TestReadConfigFromFile(t *testing.T) {
 		assert.Equal(t, "d9ew90weoijewjke", slackConfig.ClientID)
 		assert.Equal(t, "this is my secret", slackConfig.ClientSecret)
 		assert.Equal(t, "this is my secret", slackConfig.AppVerificationToken)
+		assert.Equal(t, "this is my secret", slackConfig.AppSigningSecret)
 		assert.Equal(t, "this is my secret", slackConfig.AppOAuthAccessToken)
+		assert.True(t, slackConfig.Notifications.Enable)
+		assert.Equal(t, 1, len(slackConfig.Notifications.DefaultChannels))
+		assert.Equal(t, "#estafette-builds", slackConfig.Notifications.DefaultChannels[0])
+		assert.Equal(t, 1, len(slackConfig.Notifications.LabelChannels))
+		assert.Equal(t, "estafette-team", slackConfig.Notifications.LabelChannels[0].Labels["team"])
+		assert.Equal(t, "#estafette-team", slackConfig.Notifications.LabelChannels[0].Channel)
+		assert.True(t, slackConfig.Notifications.NotifyCommitterOnFailure)
+		assert.Equal(t, 1.0, slackConfig.Notifications.MessagesPerSecond)
+	})
+
+	t.Run("ReturnsPubsubConfig", func(t *testing.T) {
+
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp", false))
+
+		// act
+		config, _ := configReader.ReadConfigFromFile("test-config.yaml", true)
+
+		pubsubConfig := config.Integrations.Pubsub
+
+		assert.Equal(t, "my-gcp-project", pubsubConfig.DefaultProject)
+		assert.Equal(t, "https://ci-integrations.estafette.io/api/integrations/pubsub/events", pubsubConfig.Endpoint)
+		assert.Equal(t, "estafette-audience", pubsubConfig.Audience)
+		assert.Equal(t, "estafette@my-gcp-project.iam.gserviceaccount.com", pubsubConfig.ServiceAccountEmail)
+		assert.Equal(t, "~estafette-ci-pubsub-trigger", pubsubConfig.SubscriptionNameSuffix)
+		assert.Equal(t, 365, pubsubConfig.SubscriptionIdleExpirationDays)
+		assert.Equal(t, 15, pubsubConfig.SubscriptionReconcileMinutes)
+		assert.True(t, pubsubConfig.PullMode)
+		assert.Equal(t, "my-events-project", pubsubConfig.EventsProject)
+		assert.Equal(t, "estafette-ci-events", pubsubConfig.EventsTopic)
 	})
 
 	t.Run("ReturnsPrometheusConfig", func(t *testing.T) {
@@ -76,6 +106,12 @@ func TestReadConfigFromFile(t *testing.T) {
 
 		assert.Equal(t, "http://prometheus-server.monitoring.svc.cluster.local", prometheusConfig.ServerURL)
 		assert.Equal(t, 10, prometheusConfig.ScrapeIntervalSeconds)
+		assert.Equal(t, 15, prometheusConfig.RangeStepSeconds)
+		assert.Equal(t, 3, prometheusConfig.CollectRetries)
+		assert.Equal(t, 2, len(prometheusConfig.Queries))
+		assert.Equal(t, "max(max_over_time(container_memory_working_set_bytes{container_name=\"{{.Container}}\",pod_name=\"{{.PodName}}\"}[{{.Window}}]))", prometheusConfig.Queries["memory"].Query)
+		assert.Equal(t, "sum(container_memory_working_set_bytes{container_name=\"{{.Container}}\",pod_name=\"{{.PodName}}\"})", prometheusConfig.Queries["memory"].RangeQuery)
+		assert.Equal(t, "", prometheusConfig.Queries["diskReadBytes"].Query)
 	})
 
 	t.Run("ReturnsBigQueryConfig", func(t *testing.T) {
@@ -92,6 +128,28 @@ func TestReadConfigFromFile(t *testing.T) {
 		assert.Equal(t, "my-dataset", bigqueryConfig.Dataset)
 	})
 
+	t.Run("ReturnsWebhooksConfig", func(t *testing.T) {
+
+		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp", false))
+
+		// act
+		config, _ := configReader.ReadConfigFromFile("test-config.yaml", true)
+
+		webhooksConfig := config.Integrations.Webhooks
+
+		assert.Equal(t, true, webhooksConfig.Enable)
+		assert.Equal(t, 5, webhooksConfig.MaxRetries)
+		assert.Equal(t, 3, webhooksConfig.Workers)
+		assert.Equal(t, 50, webhooksConfig.QueueSize)
+		assert.Equal(t, 2, len(webhooksConfig.Subscribers))
+		assert.Equal(t, "incidents", webhooksConfig.Subscribers[0].Name)
+		assert.Equal(t, "https://incidents.estafette.io/hooks/estafette", webhooksConfig.Subscribers[0].URL)
+		assert.Equal(t, "this is my secret", webhooksConfig.Subscribers[0].Secret)
+		assert.Equal(t, "io.estafette.ci.build.failed", webhooksConfig.Subscribers[0].Events[0])
+		assert.Equal(t, "github.com/estafette/estafette-ci-api", webhooksConfig.Subscribers[1].Pipelines[0])
+		assert.Equal(t, "estafette-team", webhooksConfig.Subscribers[1].Labels["team"])
+	})
+
 	t.Run("ReturnsAPIServerConfig", func(t *testing.T) {
 
 		configReader := NewConfigReader(crypt.NewSecretHelper("SazbwMf3NZxVVbBqQHebPcXCqrVn3DDp", false))
@@ -135,6 +193,46 @@ func TestReadConfigFromFile(t *testing.T) {
 		assert.Equal(t, 64*math.Pow(2, 10)*math.Pow(2, 10), jobsConfig.MinMemoryBytes)                 // 64Mi
 		assert.Equal(t, 12*math.Pow(2, 10)*math.Pow(2, 10)*math.Pow(2, 10), jobsConfig.MaxMemoryBytes) // 12Gi
 		assert.Equal(t, 1.25, jobsConfig.MemoryRequestRatio)
+		assert.Equal(t, 95.0, jobsConfig.ResourcePercentile)
+		assert.Equal(t, 1.5, jobsConfig.OOMMemoryLimitRatio)
+		assert.Equal(t, 24*math.Pow(2, 10)*math.Pow(2, 10)*math.Pow(2, 10), jobsConfig.MaxMemoryLimitBytes) // 24Gi
+		assert.Equal(t, 20, jobsConfig.PendingTimeoutMinutes)
+		assert.Equal(t, 2, jobsConfig.PreemptionRetries)
+		assert.Equal(t, 10, jobsConfig.ReapIntervalMinutes)
+		assert.Equal(t, 180, jobsConfig.ReapMinAgeMinutes)
+		assert.Equal(t, 90, jobsConfig.TimeoutMinutes)
+		assert.Equal(t, 2, jobsConfig.TimeoutSweepMinutes)
+		assert.Equal(t, 30, jobsConfig.StalePendingMinutes)
+		assert.Equal(t, 15, jobsConfig.StaleRunningMinutes)
+		assert.Equal(t, 5, jobsConfig.StaleCancelingMinutes)
+		assert.Equal(t, 5, jobsConfig.StaleSweepMinutes)
+		assert.Equal(t, 0.0332, jobsConfig.CPUCoreHourPrice)
+		assert.Equal(t, 0.0045, jobsConfig.MemoryGBHourPrice)
+		assert.Equal(t, "ci", jobsConfig.PodSpec.NodeSelector["cloud.estafette.io/pool"])
+		assert.Equal(t, 1, len(jobsConfig.PodSpec.Tolerations))
+		assert.Equal(t, "dedicated", jobsConfig.PodSpec.Tolerations[0].Key)
+		assert.Equal(t, "NoSchedule", jobsConfig.PodSpec.Tolerations[0].Effect)
+		assert.Equal(t, "In", jobsConfig.PodSpec.Affinity.Required[0].Operator)
+		assert.Equal(t, []string{"ci"}, jobsConfig.PodSpec.Affinity.Required[0].Values)
+		assert.Equal(t, int32(10), jobsConfig.PodSpec.Affinity.Preferred[0].Weight)
+		assert.Equal(t, "cloud.estafette.io/spot", jobsConfig.PodSpec.Affinity.Preferred[0].MatchExpressions[0].Key)
+		assert.Equal(t, "estafette-ci-jobs", jobsConfig.PodSpec.PriorityClassName)
+		assert.Equal(t, "false", jobsConfig.PodSpec.Annotations["cluster-autoscaler.kubernetes.io/safe-to-evict"])
+		assert.Equal(t, "estafette-team", jobsConfig.PodSpec.Labels["team"])
+		assert.Equal(t, []string{"estafette-registry"}, jobsConfig.PodSpec.ImagePullSecrets)
+		assert.Equal(t, "estafette-ci-builder", jobsConfig.PodSpec.ServiceAccountName)
+		assert.Equal(t, 2, len(jobsConfig.PodSpecOverrides))
+		assert.Equal(t, "production", jobsConfig.PodSpecOverrides[0].ReleaseTarget)
+		assert.Equal(t, "estafette-ci-releases", jobsConfig.PodSpecOverrides[0].PodSpec.PriorityClassName)
+		assert.Equal(t, "gpu-team", jobsConfig.PodSpecOverrides[1].Labels["team"])
+		assert.Equal(t, "estafette-ci-builder-gpu", jobsConfig.PodSpecOverrides[1].PodSpec.ServiceAccountName)
+		assert.Equal(t, 2, len(jobsConfig.Clusters))
+		assert.Equal(t, "windows", jobsConfig.Clusters[0].Name)
+		assert.Equal(t, "/kube-configs/windows.yaml", jobsConfig.Clusters[0].KubeConfigPath)
+		assert.Equal(t, "estafette-ci-windows-jobs", jobsConfig.Clusters[0].Namespace)
+		assert.Equal(t, []string{"windows"}, jobsConfig.Clusters[0].OperatingSystems)
+		assert.Equal(t, []string{"production"}, jobsConfig.Clusters[1].ReleaseTargets)
+		assert.Equal(t, "estafette-team", jobsConfig.Clusters[1].Labels["team"])
 	})
 
 	t.Run("ReturnsDatabaseConfig", func(t *testing.T) {
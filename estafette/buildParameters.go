@@ -0,0 +1,122 @@
+package estafette
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// BuildParameter is a typed parameter a manifest declares for starting its build or a release manually, which the manifest library doesn't know about
+type BuildParameter struct {
+	Name    string   `yaml:"name"`
+	Type    string   `yaml:"type,omitempty"`
+	Default string   `yaml:"default,omitempty"`
+	Choices []string `yaml:"choices,omitempty"`
+}
+
+type rawParametersManifest struct {
+	Parameters []BuildParameter `yaml:"parameters,omitempty"`
+	Releases   yaml.MapSlice    `yaml:"releases,omitempty"`
+}
+
+type rawParametersRelease struct {
+	Parameters []BuildParameter `yaml:"parameters,omitempty"`
+}
+
+// getBuildParameters reads the parameters declared for the build ("" as target) or a release target from the raw manifest
+func getBuildParameters(rawManifest, target string) (parameters []BuildParameter, err error) {
+
+	var raw rawParametersManifest
+	err = yaml.Unmarshal([]byte(rawManifest), &raw)
+	if err != nil {
+		return
+	}
+
+	if target == "" {
+		return raw.Parameters, nil
+	}
+
+	for _, item := range raw.Releases {
+		if name, ok := item.Key.(string); !ok || name != target {
+			continue
+		}
+		releaseBytes, err := yaml.Marshal(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		var release rawParametersRelease
+		err = yaml.Unmarshal(releaseBytes, &release)
+		if err != nil {
+			return nil, err
+		}
+		parameters = release.Parameters
+	}
+
+	return
+}
+
+// ValidateBuildParameters checks the values supplied for a manual build ("" as target) or release against the parameters the manifest declares and returns the values for all of them, with defaults filled in
+func ValidateBuildParameters(rawManifest, target string, values map[string]string) (parameters map[string]string, err error) {
+
+	declared, err := getBuildParameters(rawManifest, target)
+	if err != nil {
+		return nil, fmt.Errorf("Reading parameters from manifest failed: %v", err)
+	}
+
+	declaredNames := map[string]bool{}
+	for _, p := range declared {
+		declaredNames[p.Name] = true
+	}
+	for name := range values {
+		if !declaredNames[name] {
+			return nil, fmt.Errorf("Parameter %v is not declared in the manifest", name)
+		}
+	}
+
+	parameters = map[string]string{}
+	for _, p := range declared {
+		value, supplied := values[p.Name]
+		if !supplied {
+			value = p.Default
+		}
+
+		switch p.Type {
+		case "", "string":
+			if !supplied && value == "" {
+				return nil, fmt.Errorf("Parameter %v has no value and no default", p.Name)
+			}
+
+		case "bool":
+			boolValue, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("Parameter %v needs to be true or false, not '%v'", p.Name, value)
+			}
+			value = strconv.FormatBool(boolValue)
+
+		case "choice":
+			if !stringArrayContains(p.Choices, value) {
+				return nil, fmt.Errorf("Parameter %v needs to be one of %v, not '%v'", p.Name, strings.Join(p.Choices, ", "), value)
+			}
+
+		default:
+			return nil, fmt.Errorf("Parameter %v has unsupported type %v; use string, bool or choice", p.Name, p.Type)
+		}
+
+		parameters[p.Name] = value
+	}
+
+	return
+}
+
+// getBuildParameterEnvironmentVariables makes the parameter values available to the stages of the build or release
+func getBuildParameterEnvironmentVariables(parameters map[string]string) map[string]string {
+
+	envvars := map[string]string{}
+	for name, value := range parameters {
+		envvars["ESTAFETTE_PARAMETER_"+envVarNameSanitize.ReplaceAllString(strings.ToUpper(name), "_")] = value
+	}
+
+	return envvars
+}
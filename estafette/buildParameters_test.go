@@ -0,0 +1,157 @@
+package estafette
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBuildParameters(t *testing.T) {
+
+	rawManifest := `
+parameters:
+- name: skip-tests
+  type: bool
+  default: false
+
+stages:
+  build:
+    image: golang:1.12.7-alpine3.10
+
+releases:
+  development:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+  production:
+    parameters:
+    - name: region
+      type: choice
+      default: europe-west1
+      choices:
+      - europe-west1
+      - us-central1
+    stages:
+      deploy:
+        image: extensions/gke:stable
+`
+
+	t.Run("ReturnsParametersForBuild", func(t *testing.T) {
+
+		// act
+		parameters, err := getBuildParameters(rawManifest, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(parameters))
+		assert.Equal(t, "skip-tests", parameters[0].Name)
+		assert.Equal(t, "bool", parameters[0].Type)
+		assert.Equal(t, "false", parameters[0].Default)
+	})
+
+	t.Run("ReturnsParametersForReleaseTarget", func(t *testing.T) {
+
+		// act
+		parameters, err := getBuildParameters(rawManifest, "production")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(parameters))
+		assert.Equal(t, "region", parameters[0].Name)
+		assert.Equal(t, []string{"europe-west1", "us-central1"}, parameters[0].Choices)
+	})
+
+	t.Run("ReturnsNoParametersForReleaseTargetWithoutParameters", func(t *testing.T) {
+
+		// act
+		parameters, err := getBuildParameters(rawManifest, "development")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(parameters))
+	})
+}
+
+func TestValidateBuildParameters(t *testing.T) {
+
+	rawManifest := `
+parameters:
+- name: skip-tests
+  type: bool
+  default: false
+- name: message
+- name: environment
+  type: choice
+  choices:
+  - staging
+  - production
+  default: staging
+`
+
+	t.Run("FillsInDefaultsForParametersWithoutValue", func(t *testing.T) {
+
+		// act
+		parameters, err := ValidateBuildParameters(rawManifest, "", map[string]string{"message": "hello"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"skip-tests": "false", "message": "hello", "environment": "staging"}, parameters)
+	})
+
+	t.Run("ReturnsErrorForUndeclaredParameter", func(t *testing.T) {
+
+		// act
+		_, err := ValidateBuildParameters(rawManifest, "", map[string]string{"message": "hello", "unknown": "value"})
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForStringParameterWithoutValueOrDefault", func(t *testing.T) {
+
+		// act
+		_, err := ValidateBuildParameters(rawManifest, "", nil)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("NormalizesBoolValues", func(t *testing.T) {
+
+		// act
+		parameters, err := ValidateBuildParameters(rawManifest, "", map[string]string{"message": "hello", "skip-tests": "1"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "true", parameters["skip-tests"])
+	})
+
+	t.Run("ReturnsErrorForInvalidBoolValue", func(t *testing.T) {
+
+		// act
+		_, err := ValidateBuildParameters(rawManifest, "", map[string]string{"message": "hello", "skip-tests": "maybe"})
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForValueNotInChoices", func(t *testing.T) {
+
+		// act
+		_, err := ValidateBuildParameters(rawManifest, "", map[string]string{"message": "hello", "environment": "development"})
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsEmptyParametersIfNoneAreDeclared", func(t *testing.T) {
+
+		// act
+		parameters, err := ValidateBuildParameters("stages: {}", "", nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(parameters))
+	})
+}
+
+func TestGetBuildParameterEnvironmentVariables(t *testing.T) {
+
+	t.Run("PrefixesSanitizedUppercaseName", func(t *testing.T) {
+
+		// act
+		envvars := getBuildParameterEnvironmentVariables(map[string]string{"skip-tests": "true"})
+
+		assert.Equal(t, map[string]string{"ESTAFETTE_PARAMETER_SKIP_TESTS": "true"}, envvars)
+	})
+}
@@ -0,0 +1,149 @@
+package estafette
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+)
+
+// DORAMetrics holds the four key delivery performance metrics for releases to the given targets, in total and per week; LeadTimeFrom tells clients the lead time for changes starts at the build instead of the commit, since commit times aren't stored
+type DORAMetrics struct {
+	Targets            []string     `json:"targets"`
+	DeploymentsPerWeek float64      `json:"deploymentsPerWeek"`
+	LeadTimeFrom       string       `json:"leadTimeFrom"`
+	Total              DORABucket   `json:"total"`
+	Weeks              []DORABucket `json:"weeks"`
+}
+
+// DORABucket holds the delivery performance metrics for a period; the period starts at Start and for weeks at monday 00:00 UTC
+type DORABucket struct {
+	Start               time.Time     `json:"start"`
+	Deployments         int           `json:"deployments"`
+	FailedDeployments   int           `json:"failedDeployments"`
+	ChangeFailureRate   float64       `json:"changeFailureRate"`
+	MedianLeadTime      time.Duration `json:"medianLeadTime"`
+	Restores            int           `json:"restores"`
+	MedianTimeToRestore time.Duration `json:"medianTimeToRestore"`
+	leadTimes           []time.Duration
+	timesToRestore      []time.Duration
+}
+
+// getDORAMetrics computes the metrics from finished releases ordered by time within the requested window, which starts at the first release if it's zero; since commit times aren't stored, the lead time for changes runs from the start of the build to the end of the successful release
+func getDORAMetrics(targets []string, deployments []*cockroach.DeploymentRecord, windowStart, windowEnd time.Time) DORAMetrics {
+
+	metrics := DORAMetrics{
+		Targets:      targets,
+		LeadTimeFrom: "buildStart",
+		Weeks:        []DORABucket{},
+	}
+	weeks := map[time.Time]*DORABucket{}
+
+	bucketFor := func(t time.Time) *DORABucket {
+		start := getWeekStart(t)
+		bucket, ok := weeks[start]
+		if !ok {
+			bucket = &DORABucket{Start: start}
+			weeks[start] = bucket
+		}
+		return bucket
+	}
+
+	// pipeline and target => time of the first failure since the last successful release
+	failingSince := map[string]time.Time{}
+
+	for _, d := range deployments {
+		key := fmt.Sprintf("%v/%v/%v/%v", d.RepoSource, d.RepoOwner, d.RepoName, d.ReleaseName)
+		week := bucketFor(d.FinishedAt)
+
+		switch d.ReleaseStatus {
+		case "succeeded":
+			for _, bucket := range []*DORABucket{&metrics.Total, week} {
+				bucket.Deployments++
+				if d.BuildInsertedAt != nil && d.FinishedAt.After(*d.BuildInsertedAt) {
+					bucket.leadTimes = append(bucket.leadTimes, d.FinishedAt.Sub(*d.BuildInsertedAt))
+				}
+			}
+
+			if failedAt, ok := failingSince[key]; ok {
+				for _, bucket := range []*DORABucket{&metrics.Total, week} {
+					bucket.Restores++
+					bucket.timesToRestore = append(bucket.timesToRestore, d.FinishedAt.Sub(failedAt))
+				}
+				delete(failingSince, key)
+			}
+
+		case "failed":
+			for _, bucket := range []*DORABucket{&metrics.Total, week} {
+				bucket.FailedDeployments++
+			}
+
+			if _, ok := failingSince[key]; !ok {
+				failingSince[key] = d.FinishedAt
+			}
+		}
+	}
+
+	if len(deployments) > 0 {
+		metrics.Total.Start = deployments[0].FinishedAt
+	}
+	finalizeDORABucket(&metrics.Total)
+
+	for _, bucket := range weeks {
+		finalizeDORABucket(bucket)
+		metrics.Weeks = append(metrics.Weeks, *bucket)
+	}
+	sort.Slice(metrics.Weeks, func(i, j int) bool { return metrics.Weeks[i].Start.Before(metrics.Weeks[j].Start) })
+
+	// the rate is over the whole requested window, so weeks without any release count as well
+	if windowStart.IsZero() && len(deployments) > 0 {
+		windowStart = deployments[0].FinishedAt
+	}
+	if nrWeeks := windowEnd.Sub(windowStart).Hours() / 24 / 7; !windowStart.IsZero() && nrWeeks > 0 {
+		metrics.DeploymentsPerWeek = float64(metrics.Total.Deployments) / nrWeeks
+	}
+
+	return metrics
+}
+
+// getSinceTime returns the start of the window for a since filter value, or the zero time for eternity
+func getSinceTime(since string, now time.Time) time.Time {
+
+	switch since {
+	case "1h":
+		return now.Add(time.Duration(-1) * time.Hour)
+	case "1d":
+		return now.AddDate(0, 0, -1)
+	case "1w":
+		return now.AddDate(0, 0, -7)
+	case "1m":
+		return now.AddDate(0, -1, 0)
+	case "1y":
+		return now.AddDate(-1, 0, 0)
+	}
+
+	return time.Time{}
+}
+
+func finalizeDORABucket(bucket *DORABucket) {
+
+	if bucket.Deployments+bucket.FailedDeployments > 0 {
+		bucket.ChangeFailureRate = float64(bucket.FailedDeployments) / float64(bucket.Deployments+bucket.FailedDeployments)
+	}
+
+	sort.Slice(bucket.leadTimes, func(i, j int) bool { return bucket.leadTimes[i] < bucket.leadTimes[j] })
+	bucket.MedianLeadTime = getDurationPercentile(bucket.leadTimes, 50)
+
+	sort.Slice(bucket.timesToRestore, func(i, j int) bool { return bucket.timesToRestore[i] < bucket.timesToRestore[j] })
+	bucket.MedianTimeToRestore = getDurationPercentile(bucket.timesToRestore, 50)
+}
+
+// getWeekStart returns monday 00:00 UTC of the week the time falls in
+func getWeekStart(t time.Time) time.Time {
+
+	t = t.UTC()
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+}
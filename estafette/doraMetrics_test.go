@@ -0,0 +1,92 @@
+package estafette
+
+import (
+	"testing"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDORAMetrics(t *testing.T) {
+
+	// monday 1 july 2019
+	monday := time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+	buildStart := monday.Add(9 * time.Hour)
+
+	deployments := []*cockroach.DeploymentRecord{
+		&cockroach.DeploymentRecord{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", ReleaseName: "production", ReleaseStatus: "succeeded", BuildInsertedAt: &buildStart, FinishedAt: monday.Add(10 * time.Hour)},
+		&cockroach.DeploymentRecord{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", ReleaseName: "production", ReleaseStatus: "failed", FinishedAt: monday.Add(11 * time.Hour)},
+		&cockroach.DeploymentRecord{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", ReleaseName: "production", ReleaseStatus: "failed", FinishedAt: monday.Add(12 * time.Hour)},
+		&cockroach.DeploymentRecord{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", ReleaseName: "production", ReleaseStatus: "succeeded", BuildInsertedAt: &buildStart, FinishedAt: monday.Add(15 * time.Hour)},
+		&cockroach.DeploymentRecord{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-web", ReleaseName: "production", ReleaseStatus: "succeeded", FinishedAt: monday.AddDate(0, 0, 14)},
+	}
+
+	t.Run("CountsDeploymentsAndChangeFailureRate", func(t *testing.T) {
+
+		// act
+		metrics := getDORAMetrics([]string{"production"}, deployments, monday, monday.AddDate(0, 0, 21))
+
+		assert.Equal(t, 3, metrics.Total.Deployments)
+		assert.Equal(t, 2, metrics.Total.FailedDeployments)
+		assert.Equal(t, 0.4, metrics.Total.ChangeFailureRate)
+		assert.Equal(t, 1.0, metrics.DeploymentsPerWeek)
+	})
+
+	t.Run("DividesDeploymentsByWeeksOfRequestedWindow", func(t *testing.T) {
+
+		// act
+		metrics := getDORAMetrics([]string{"production"}, deployments, monday.AddDate(0, 0, -21), monday.AddDate(0, 0, 21))
+
+		assert.Equal(t, 0.5, metrics.DeploymentsPerWeek)
+	})
+
+	t.Run("StartsWindowAtFirstReleaseWithoutSince", func(t *testing.T) {
+
+		// act
+		metrics := getDORAMetrics([]string{"production"}, deployments, time.Time{}, monday.Add(10*time.Hour).AddDate(0, 0, 21))
+
+		assert.Equal(t, 1.0, metrics.DeploymentsPerWeek)
+	})
+
+	t.Run("MeasuresLeadTimeFromBuildStart", func(t *testing.T) {
+
+		// act
+		metrics := getDORAMetrics([]string{"production"}, deployments, monday, monday.AddDate(0, 0, 21))
+
+		assert.Equal(t, 1*time.Hour, metrics.Total.MedianLeadTime)
+		assert.Equal(t, "buildStart", metrics.LeadTimeFrom)
+	})
+
+	t.Run("MeasuresTimeToRestoreFromFirstFailure", func(t *testing.T) {
+
+		// act
+		metrics := getDORAMetrics([]string{"production"}, deployments, monday, monday.AddDate(0, 0, 21))
+
+		assert.Equal(t, 1, metrics.Total.Restores)
+		assert.Equal(t, 4*time.Hour, metrics.Total.MedianTimeToRestore)
+	})
+
+	t.Run("BucketsPerWeek", func(t *testing.T) {
+
+		// act
+		metrics := getDORAMetrics([]string{"production"}, deployments, monday, monday.AddDate(0, 0, 21))
+
+		assert.Equal(t, 2, len(metrics.Weeks))
+		assert.Equal(t, monday, metrics.Weeks[0].Start)
+		assert.Equal(t, 2, metrics.Weeks[0].Deployments)
+		assert.Equal(t, monday.AddDate(0, 0, 14), metrics.Weeks[1].Start)
+		assert.Equal(t, 1, metrics.Weeks[1].Deployments)
+	})
+}
+
+func TestGetWeekStart(t *testing.T) {
+
+	t.Run("ReturnsMondayOfTheWeek", func(t *testing.T) {
+
+		// act
+		weekStart := getWeekStart(time.Date(2019, 7, 7, 23, 59, 0, 0, time.UTC))
+
+		assert.Equal(t, time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC), weekStart)
+	})
+}
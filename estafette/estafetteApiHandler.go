@@ -12,12 +12,14 @@ import (
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
 	"github.com/estafette/estafette-ci-api/auth"
 	"github.com/estafette/estafette-ci-api/cockroach"
 	"github.com/estafette/estafette-ci-api/config"
+	"github.com/estafette/estafette-ci-api/webhooks"
 	contracts "github.com/estafette/estafette-ci-contracts"
 	crypt "github.com/estafette/estafette-ci-crypt"
 	manifest "github.com/estafette/estafette-ci-manifest"
@@ -39,8 +41,18 @@ type APIHandler interface {
 	TailPipelineBuildLogs(*gin.Context)
 	PostPipelineBuildLogs(*gin.Context)
 	GetPipelineBuildWarnings(*gin.Context)
+	GetPipelineBuildRetries(*gin.Context)
+	GetPipelineBuildParameters(*gin.Context)
+	GetPipelineBuildResources(*gin.Context)
+	GetPipelineBuildResourceUsage(*gin.Context)
+	PostPipelineBuildTestReports(*gin.Context)
+	GetPipelineBuildTestReports(*gin.Context)
+	GetPipelineTestReports(*gin.Context)
 	GetPipelineReleases(*gin.Context)
 	GetPipelineRelease(*gin.Context)
+	GetPipelineReleaseParameters(*gin.Context)
+	GetPipelineReleaseResources(*gin.Context)
+	GetPipelineReleaseResourceUsage(*gin.Context)
 	CreatePipelineRelease(*gin.Context)
 	CancelPipelineRelease(*gin.Context)
 	GetPipelineReleaseLogs(*gin.Context)
@@ -55,6 +67,8 @@ type APIHandler interface {
 	GetPipelineStatsBuildsMemoryUsageMeasurements(*gin.Context)
 	GetPipelineStatsReleasesMemoryUsageMeasurements(*gin.Context)
 	GetPipelineWarnings(*gin.Context)
+	GetPipelineStatsFlakiness(*gin.Context)
+	GetPipelineStatsSteps(*gin.Context)
 
 	GetStatsPipelinesCount(*gin.Context)
 	GetStatsBuildsCount(*gin.Context)
@@ -62,6 +76,11 @@ type APIHandler interface {
 
 	GetStatsMostBuilds(*gin.Context)
 	GetStatsMostReleases(*gin.Context)
+	GetStatsMostFailingStepImages(*gin.Context)
+	GetStatsDORA(*gin.Context)
+	GetStatsCosts(*gin.Context)
+	GetStatsBuildsTimeSeries(*gin.Context)
+	GetStatsReleasesTimeSeries(*gin.Context)
 
 	GetStatsBuildsDuration(*gin.Context)
 	GetStatsBuildsAdoption(*gin.Context)
@@ -80,37 +99,46 @@ type APIHandler interface {
 	EncryptSecret(*gin.Context)
 
 	PostCronEvent(*gin.Context)
+
+	GetWebhookDeliveries(*gin.Context)
+	RedeliverWebhookDelivery(*gin.Context)
 }
 
 type apiHandlerImpl struct {
-	configFilePath       string
-	config               config.APIServerConfig
-	authConfig           config.AuthConfig
-	encryptedConfig      config.APIConfig
-	cockroachDBClient    cockroach.DBClient
-	ciBuilderClient      CiBuilderClient
-	buildService         BuildService
-	warningHelper        WarningHelper
-	secretHelper         crypt.SecretHelper
-	githubJobVarsFunc    func(context.Context, string, string, string) (string, string, error)
-	bitbucketJobVarsFunc func(context.Context, string, string, string) (string, string, error)
+	configFilePath        string
+	config                config.APIServerConfig
+	authConfig            config.AuthConfig
+	encryptedConfig       config.APIConfig
+	cockroachDBClient     cockroach.DBClient
+	ciBuilderClient       CiBuilderClient
+	buildService          BuildService
+	warningHelper         WarningHelper
+	secretHelper          crypt.SecretHelper
+	githubJobVarsFunc     func(context.Context, string, string, string) (string, string, error)
+	bitbucketJobVarsFunc  func(context.Context, string, string, string) (string, string, error)
+	githubManifestFunc    func(context.Context, string, string, string, string) (string, bool, string, error)
+	bitbucketManifestFunc func(context.Context, string, string, string, string) (string, bool, string, error)
+	webhookNotifier       webhooks.Notifier
 }
 
 // NewAPIHandler returns a new estafette.APIHandler
-func NewAPIHandler(configFilePath string, config config.APIServerConfig, authConfig config.AuthConfig, encryptedConfig config.APIConfig, cockroachDBClient cockroach.DBClient, ciBuilderClient CiBuilderClient, buildService BuildService, warningHelper WarningHelper, secretHelper crypt.SecretHelper, githubJobVarsFunc func(context.Context, string, string, string) (string, string, error), bitbucketJobVarsFunc func(context.Context, string, string, string) (string, string, error)) (apiHandler APIHandler) {
+func NewAPIHandler(configFilePath string, config config.APIServerConfig, authConfig config.AuthConfig, encryptedConfig config.APIConfig, cockroachDBClient cockroach.DBClient, ciBuilderClient CiBuilderClient, buildService BuildService, warningHelper WarningHelper, secretHelper crypt.SecretHelper, githubJobVarsFunc func(context.Context, string, string, string) (string, string, error), bitbucketJobVarsFunc func(context.Context, string, string, string) (string, string, error), githubManifestFunc func(context.Context, string, string, string, string) (string, bool, string, error), bitbucketManifestFunc func(context.Context, string, string, string, string) (string, bool, string, error), webhookNotifier webhooks.Notifier) (apiHandler APIHandler) {
 
 	apiHandler = &apiHandlerImpl{
-		configFilePath:       configFilePath,
-		config:               config,
-		authConfig:           authConfig,
-		encryptedConfig:      encryptedConfig,
-		cockroachDBClient:    cockroachDBClient,
-		ciBuilderClient:      ciBuilderClient,
-		buildService:         buildService,
-		warningHelper:        warningHelper,
-		secretHelper:         secretHelper,
-		githubJobVarsFunc:    githubJobVarsFunc,
-		bitbucketJobVarsFunc: bitbucketJobVarsFunc,
+		configFilePath:        configFilePath,
+		config:                config,
+		authConfig:            authConfig,
+		encryptedConfig:       encryptedConfig,
+		cockroachDBClient:     cockroachDBClient,
+		ciBuilderClient:       ciBuilderClient,
+		buildService:          buildService,
+		warningHelper:         warningHelper,
+		secretHelper:          secretHelper,
+		githubJobVarsFunc:     githubJobVarsFunc,
+		bitbucketJobVarsFunc:  bitbucketJobVarsFunc,
+		githubManifestFunc:    githubManifestFunc,
+		bitbucketManifestFunc: bitbucketManifestFunc,
+		webhookNotifier:       webhookNotifier,
 	}
 
 	return
@@ -368,7 +396,7 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 
 	user := c.MustGet(gin.AuthUserKey).(auth.User)
 
-	var buildCommand contracts.Build
+	var buildCommand BuildCommand
 	c.BindJSON(&buildCommand)
 
 	// match source, owner, repo with values in binded release
@@ -391,6 +419,19 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 		return
 	}
 
+	if buildCommand.BuilderTrack != "" && !regexp.MustCompile(`^[a-zA-Z0-9._-]+$`).MatchString(buildCommand.BuilderTrack) {
+		errorMessage := fmt.Sprintf("Builder track %v for build command issued by %v is not valid", buildCommand.BuilderTrack, user)
+		log.Error().Msg(errorMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+
+	// without version build a branch or revision from scratch
+	if buildCommand.BuildVersion == "" {
+		h.createPipelineBuildForRef(ctx, c, user, buildCommand)
+		return
+	}
+
 	// check if version exists and is valid to re-run
 	builds, err := h.cockroachDBClient.GetPipelineBuildsByVersion(ctx, buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, buildCommand.BuildVersion, false)
 
@@ -405,7 +446,7 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 	// ensure there's no succeeded or running builds
 	hasNonFailedBuilds := false
 	for _, b := range builds {
-		if b.BuildStatus == "failed" || b.BuildStatus == "canceled" {
+		if b.BuildStatus == "failed" || b.BuildStatus == "canceled" || b.BuildStatus == "timedout" {
 			failedBuild = b
 		} else {
 			hasNonFailedBuilds = true
@@ -434,8 +475,16 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 		},
 	}
 
+	parameters, err := ValidateBuildParameters(failedBuild.Manifest, "", buildCommand.Parameters)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Parameters for build %v/%v/%v version %v for build command issued by %v are not valid: %v", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, buildCommand.BuildVersion, user, err)
+		log.Error().Msg(errorMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+
 	// hand off to build service
-	createdBuild, err := h.buildService.CreateBuild(ctx, *failedBuild, true)
+	createdBuild, err := h.buildService.CreateManualBuild(ctx, *failedBuild, buildCommand.BuilderTrack, parameters)
 	if err != nil {
 		errorMessage := fmt.Sprintf("Failed creating build %v/%v/%v version %v for build command issued by %v", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, buildCommand.BuildVersion, user)
 		log.Error().Err(err).Msg(errorMessage)
@@ -446,6 +495,102 @@ func (h *apiHandlerImpl) CreatePipelineBuild(c *gin.Context) {
 	c.JSON(http.StatusCreated, createdBuild)
 }
 
+// createPipelineBuildForRef starts a new build for the head of a branch or a specific revision, with the manifest at that revision
+func (h *apiHandlerImpl) createPipelineBuildForRef(ctx context.Context, c *gin.Context, user auth.User, buildCommand BuildCommand) {
+
+	if buildCommand.RepoBranch == "" && buildCommand.RepoRevision == "" {
+		errorMessage := fmt.Sprintf("Build command for pipeline %v/%v/%v issued by %v needs a version to re-run or a branch or revision to build", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
+		log.Error().Msg(errorMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+
+	pipeline, err := h.cockroachDBClient.GetPipeline(ctx, buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, false)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving pipeline %v/%v/%v for build command issued by %v", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+	if pipeline == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Pipeline not found"})
+		return
+	}
+
+	// a revision without branch gets built as part of the branch the pipeline last built
+	repoBranch := buildCommand.RepoBranch
+	if repoBranch == "" {
+		repoBranch = pipeline.RepoBranch
+	}
+	ref := buildCommand.RepoRevision
+	if ref == "" {
+		ref = buildCommand.RepoBranch
+	}
+
+	var manifestFunc func(context.Context, string, string, string, string) (string, bool, string, error)
+	switch buildCommand.RepoSource {
+	case "github.com":
+		manifestFunc = h.githubManifestFunc
+	case "bitbucket.org":
+		manifestFunc = h.bitbucketManifestFunc
+	}
+	if manifestFunc == nil {
+		errorMessage := fmt.Sprintf("Source of pipeline %v/%v/%v is not supported for building a branch or revision", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName)
+		log.Error().Msg(errorMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+
+	repoRevision, manifestExists, manifestString, err := manifestFunc(ctx, buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, ref)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving manifest for %v of pipeline %v/%v/%v for build command issued by %v", ref, buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, user)
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+	if !manifestExists {
+		errorMessage := fmt.Sprintf("Revision %v of pipeline %v/%v/%v has no .estafette.yaml manifest", repoRevision, buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName)
+		log.Error().Msg(errorMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+
+	parameters, err := ValidateBuildParameters(manifestString, "", buildCommand.Parameters)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Parameters for build %v/%v/%v revision %v for build command issued by %v are not valid: %v", buildCommand.RepoSource, buildCommand.RepoOwner, buildCommand.RepoName, repoRevision, user, err)
+		log.Error().Msg(errorMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+
+	build := contracts.Build{
+		RepoSource:   buildCommand.RepoSource,
+		RepoOwner:    buildCommand.RepoOwner,
+		RepoName:     buildCommand.RepoName,
+		RepoBranch:   repoBranch,
+		RepoRevision: repoRevision,
+		Manifest:     manifestString,
+		Events: []manifest.EstafetteEvent{
+			manifest.EstafetteEvent{
+				Manual: &manifest.EstafetteManualEvent{
+					UserID: user.Email,
+				},
+			},
+		},
+	}
+
+	// hand off to build service
+	createdBuild, err := h.buildService.CreateManualBuild(ctx, build, buildCommand.BuilderTrack, parameters)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed creating build %v/%v/%v revision %v for build command issued by %v", build.RepoSource, build.RepoOwner, build.RepoName, build.RepoRevision, user)
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	c.JSON(http.StatusCreated, createdBuild)
+}
+
 func (h *apiHandlerImpl) CancelPipelineBuild(c *gin.Context) {
 
 	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::CancelPipelineBuild")
@@ -484,7 +629,7 @@ func (h *apiHandlerImpl) CancelPipelineBuild(c *gin.Context) {
 		// apparently cancel was already clicked, but somehow the job didn't update the status to canceled
 		jobName := h.ciBuilderClient.GetJobName("build", build.RepoOwner, build.RepoName, build.ID)
 		h.ciBuilderClient.CancelCiBuilderJob(ctx, jobName)
-		h.cockroachDBClient.UpdateBuildStatus(ctx, build.RepoSource, build.RepoOwner, build.RepoName, id, "canceled")
+		h.buildService.FinishBuild(ctx, build.RepoSource, build.RepoOwner, build.RepoName, id, "canceled")
 		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Canceled build by user %v", user.Email)})
 		return
 	}
@@ -502,7 +647,7 @@ func (h *apiHandlerImpl) CancelPipelineBuild(c *gin.Context) {
 		// job might not have created a builder yet, so set status to canceled straightaway
 		buildStatus = "canceled"
 	}
-	err = h.cockroachDBClient.UpdateBuildStatus(ctx, build.RepoSource, build.RepoOwner, build.RepoName, id, buildStatus)
+	err = h.buildService.FinishBuild(ctx, build.RepoSource, build.RepoOwner, build.RepoName, id, buildStatus)
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed updating build status for %v/%v/%v/builds/%v in db", source, owner, repo, revisionOrID)
 		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed setting pipeline build status to canceling"})
@@ -512,7 +657,7 @@ func (h *apiHandlerImpl) CancelPipelineBuild(c *gin.Context) {
 	// canceling the job failed because it no longer existed we should set canceled status right after having set it to canceling
 	if cancelErr != nil && build.BuildStatus == "running" {
 		buildStatus = "canceled"
-		err = h.cockroachDBClient.UpdateBuildStatus(ctx, build.RepoSource, build.RepoOwner, build.RepoName, id, buildStatus)
+		err = h.buildService.FinishBuild(ctx, build.RepoSource, build.RepoOwner, build.RepoName, id, buildStatus)
 		if err != nil {
 			log.Error().Err(err).Msgf("Failed updating build status to canceled after setting it to canceling for %v/%v/%v/builds/%v in db", source, owner, repo, revisionOrID)
 			c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed setting pipeline build status to canceled"})
@@ -705,6 +850,301 @@ func (h *apiHandlerImpl) GetPipelineBuildWarnings(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"warnings": warnings})
 }
 
+func (h *apiHandlerImpl) GetPipelineBuildRetries(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineBuildRetries")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	revisionOrID := c.Param("revisionOrId")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+	span.SetTag("build-id", revisionOrID)
+
+	id, err := strconv.Atoi(revisionOrID)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed reading id from path parameter for %v/%v/%v/builds/%v", source, owner, repo, revisionOrID)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Path parameter id is not of type integer"})
+		return
+	}
+
+	build, err := h.cockroachDBClient.GetPipelineBuildByID(ctx, source, owner, repo, id, false)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving build for %v/%v/%v/builds/%v from db", source, owner, repo, id)
+	}
+	if build == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Pipeline build not found"})
+		return
+	}
+
+	// all automatic retries of the version, so each attempt links to the ones before and after it
+	retries, err := h.cockroachDBClient.GetBuildRetries(ctx, source, owner, repo, build.BuildVersion)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving retries for %v/%v/%v/builds/%v from db", source, owner, repo, id)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving build retries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"retries": retries})
+}
+
+func (h *apiHandlerImpl) GetPipelineBuildParameters(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineBuildParameters")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	revisionOrID := c.Param("revisionOrId")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+	span.SetTag("build-id", revisionOrID)
+
+	id, err := strconv.Atoi(revisionOrID)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed reading id from path parameter for %v/%v/%v/builds/%v", source, owner, repo, revisionOrID)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Path parameter id is not of type integer"})
+		return
+	}
+
+	parameters, err := h.cockroachDBClient.GetBuildParameters(ctx, source, owner, repo, id)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving parameters for %v/%v/%v/builds/%v from db", source, owner, repo, id)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving build parameters"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"parameters": parameters})
+}
+
+func (h *apiHandlerImpl) GetPipelineBuildResources(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineBuildResources")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	revisionOrID := c.Param("revisionOrId")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+	span.SetTag("build-id", revisionOrID)
+
+	id, err := strconv.Atoi(revisionOrID)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed reading id from path parameter for %v/%v/%v/builds/%v", source, owner, repo, revisionOrID)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Path parameter id is not of type integer"})
+		return
+	}
+
+	recommendation, err := h.cockroachDBClient.GetBuildResourceRecommendation(ctx, source, owner, repo, id)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving resource recommendation for %v/%v/%v/builds/%v from db", source, owner, repo, id)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving build resources"})
+		return
+	}
+	if recommendation == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Pipeline build resources not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, recommendation)
+}
+
+func (h *apiHandlerImpl) GetPipelineBuildResourceUsage(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineBuildResourceUsage")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	revisionOrID := c.Param("revisionOrId")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+	span.SetTag("build-id", revisionOrID)
+
+	id, err := strconv.Atoi(revisionOrID)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed reading id from path parameter for %v/%v/%v/builds/%v", source, owner, repo, revisionOrID)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Path parameter id is not of type integer"})
+		return
+	}
+
+	metrics, err := h.cockroachDBClient.GetBuildResourceMetrics(ctx, source, owner, repo, id)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving resource metrics for %v/%v/%v/builds/%v from db", source, owner, repo, id)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving build resource usage"})
+		return
+	}
+	if metrics == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Pipeline build resource usage not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+func (h *apiHandlerImpl) PostPipelineBuildTestReports(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::PostPipelineBuildTestReports")
+	defer span.Finish()
+
+	if c.MustGet(gin.AuthUserKey).(string) != "apiKey" {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	revisionOrID := c.Param("revisionOrId")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+	span.SetTag("build-id", revisionOrID)
+
+	id, err := strconv.Atoi(revisionOrID)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed reading id from path parameter for %v/%v/%v/builds/%v", source, owner, repo, revisionOrID)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Path parameter id is not of type integer"})
+		return
+	}
+
+	build, err := h.cockroachDBClient.GetPipelineBuildByID(ctx, source, owner, repo, id, false)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving build for %v/%v/%v/builds/%v from db", source, owner, repo, id)
+	}
+	if build == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Pipeline build not found"})
+		return
+	}
+
+	data, err := c.GetRawData()
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed reading test report for %v/%v/%v/builds/%v", source, owner, repo, id)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Failed reading test report"})
+		return
+	}
+
+	testCases, err := parseJUnitReport(data)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed parsing test report for %v/%v/%v/builds/%v", source, owner, repo, id)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": err.Error()})
+		return
+	}
+
+	testCasePointers := make([]*cockroach.TestCase, len(testCases))
+	for i := range testCases {
+		testCases[i].RepoSource = build.RepoSource
+		testCases[i].RepoOwner = build.RepoOwner
+		testCases[i].RepoName = build.RepoName
+		testCases[i].RepoBranch = build.RepoBranch
+		testCases[i].BuildID = build.ID
+		testCases[i].BuildVersion = build.BuildVersion
+		testCasePointers[i] = &testCases[i]
+	}
+
+	err = h.cockroachDBClient.InsertBuildTestCases(ctx, testCases)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed inserting test report for %v/%v/%v/builds/%v", source, owner, repo, id)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed storing test report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, summarizeTestCases(testCasePointers))
+}
+
+func (h *apiHandlerImpl) GetPipelineBuildTestReports(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineBuildTestReports")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	revisionOrID := c.Param("revisionOrId")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+	span.SetTag("build-id", revisionOrID)
+
+	id, err := strconv.Atoi(revisionOrID)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed reading id from path parameter for %v/%v/%v/builds/%v", source, owner, repo, revisionOrID)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Path parameter id is not of type integer"})
+		return
+	}
+
+	testCases, err := h.cockroachDBClient.GetBuildTestCases(ctx, source, owner, repo, id)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving test cases for %v/%v/%v/builds/%v from db", source, owner, repo, id)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving build test reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"summary": summarizeTestCases(testCases), "testCases": testCases})
+}
+
+func (h *apiHandlerImpl) GetPipelineTestReports(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineTestReports")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+
+	pageSize := h.getPageSize(c)
+	filters := map[string][]string{}
+	filters["since"] = h.getSinceFilter(c)
+
+	builds, err := h.cockroachDBClient.GetPipelineTestReportSummaries(ctx, source, owner, repo, pageSize, filters)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving test report summaries for %v/%v/%v from db", source, owner, repo)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving pipeline test reports"})
+		return
+	}
+
+	slowest, err := h.cockroachDBClient.GetPipelineSlowestTestCases(ctx, source, owner, repo, pageSize, filters)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving slowest test cases for %v/%v/%v from db", source, owner, repo)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving pipeline test reports"})
+		return
+	}
+
+	mostFailing, err := h.cockroachDBClient.GetPipelineMostFailingTestCases(ctx, source, owner, repo, pageSize, filters)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving most failing test cases for %v/%v/%v from db", source, owner, repo)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving pipeline test reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"builds": builds, "slowest": slowest, "mostFailing": mostFailing})
+}
+
 func (h *apiHandlerImpl) GetPipelineReleases(c *gin.Context) {
 
 	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineReleases")
@@ -788,7 +1228,7 @@ func (h *apiHandlerImpl) CreatePipelineRelease(c *gin.Context) {
 
 	user := c.MustGet(gin.AuthUserKey).(auth.User)
 
-	var releaseCommand contracts.Release
+	var releaseCommand ReleaseCommand
 	c.BindJSON(&releaseCommand)
 
 	// match source, owner, repo with values in binded release
@@ -891,8 +1331,16 @@ func (h *apiHandlerImpl) CreatePipelineRelease(c *gin.Context) {
 		return
 	}
 
+	parameters, err := ValidateBuildParameters(build.Manifest, releaseCommand.Name, releaseCommand.Parameters)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Parameters for release %v for pipeline %v/%v/%v version %v for release command are not valid: %v", releaseCommand.Name, releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName, releaseCommand.ReleaseVersion, err)
+		log.Error().Msg(errorMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+
 	// create release object and hand off to build service
-	createdRelease, err := h.buildService.CreateRelease(ctx, contracts.Release{
+	createdRelease, err := h.buildService.CreateManualRelease(ctx, contracts.Release{
 		Name:           releaseCommand.Name,
 		Action:         releaseCommand.Action,
 		RepoSource:     releaseCommand.RepoSource,
@@ -908,7 +1356,7 @@ func (h *apiHandlerImpl) CreatePipelineRelease(c *gin.Context) {
 				},
 			},
 		},
-	}, *build.ManifestObject, build.RepoBranch, build.RepoRevision, true)
+	}, *build.ManifestObject, build.RepoBranch, build.RepoRevision, true, parameters)
 
 	if err != nil {
 		errorMessage := fmt.Sprintf("Failed creating release %v for pipeline %v/%v/%v version %v for release command issued by %v", releaseCommand.Name, releaseCommand.RepoSource, releaseCommand.RepoOwner, releaseCommand.RepoName, releaseCommand.ReleaseVersion, user.Email)
@@ -955,7 +1403,7 @@ func (h *apiHandlerImpl) CancelPipelineRelease(c *gin.Context) {
 	if release.ReleaseStatus == "canceling" {
 		jobName := h.ciBuilderClient.GetJobName("release", release.RepoOwner, release.RepoName, release.ID)
 		h.ciBuilderClient.CancelCiBuilderJob(ctx, jobName)
-		h.cockroachDBClient.UpdateReleaseStatus(ctx, release.RepoSource, release.RepoOwner, release.RepoName, id, "canceled")
+		h.buildService.FinishRelease(ctx, release.RepoSource, release.RepoOwner, release.RepoName, id, "canceled")
 		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Canceled release by user %v", user.Email)})
 		return
 	}
@@ -972,7 +1420,7 @@ func (h *apiHandlerImpl) CancelPipelineRelease(c *gin.Context) {
 		// job might not have created a builder yet, so set status to canceled straightaway
 		releaseStatus = "canceled"
 	}
-	err = h.cockroachDBClient.UpdateReleaseStatus(ctx, release.RepoSource, release.RepoOwner, release.RepoName, id, releaseStatus)
+	err = h.buildService.FinishRelease(ctx, release.RepoSource, release.RepoOwner, release.RepoName, id, releaseStatus)
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed updating release status for %v/%v/%v/builds/%v in db", source, owner, repo, id)
 		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed setting pipeline release status to canceling"})
@@ -982,7 +1430,7 @@ func (h *apiHandlerImpl) CancelPipelineRelease(c *gin.Context) {
 	// canceling the job failed because it no longer existed we should set canceled status right after having set it to canceling
 	if cancelErr != nil && release.ReleaseStatus == "running" {
 		releaseStatus = "canceled"
-		err = h.cockroachDBClient.UpdateReleaseStatus(ctx, release.RepoSource, release.RepoOwner, release.RepoName, id, releaseStatus)
+		err = h.buildService.FinishRelease(ctx, release.RepoSource, release.RepoOwner, release.RepoName, id, releaseStatus)
 		if err != nil {
 			log.Error().Err(err).Msgf("Failed updating release status to canceled after setting it to canceling for %v/%v/%v/builds/%v in db", source, owner, repo, id)
 			c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed setting pipeline release status to canceled"})
@@ -1027,6 +1475,110 @@ func (h *apiHandlerImpl) GetPipelineRelease(c *gin.Context) {
 	c.JSON(http.StatusOK, release)
 }
 
+func (h *apiHandlerImpl) GetPipelineReleaseParameters(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineReleaseParameters")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	idValue := c.Param("id")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+	span.SetTag("release-id", idValue)
+
+	id, err := strconv.Atoi(idValue)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed reading id from path parameter for %v/%v/%v/%v", source, owner, repo, idValue)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Path parameter id is not of type integer"})
+		return
+	}
+
+	parameters, err := h.cockroachDBClient.GetReleaseParameters(ctx, source, owner, repo, id)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving parameters for %v/%v/%v/releases/%v from db", source, owner, repo, id)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving release parameters"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"parameters": parameters})
+}
+
+func (h *apiHandlerImpl) GetPipelineReleaseResources(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineReleaseResources")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	idValue := c.Param("id")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+	span.SetTag("release-id", idValue)
+
+	id, err := strconv.Atoi(idValue)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed reading id from path parameter for %v/%v/%v/%v", source, owner, repo, idValue)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Path parameter id is not of type integer"})
+		return
+	}
+
+	recommendation, err := h.cockroachDBClient.GetReleaseResourceRecommendation(ctx, source, owner, repo, id)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving resource recommendation for %v/%v/%v/releases/%v from db", source, owner, repo, id)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving release resources"})
+		return
+	}
+	if recommendation == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Pipeline release resources not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, recommendation)
+}
+
+func (h *apiHandlerImpl) GetPipelineReleaseResourceUsage(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineReleaseResourceUsage")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+	idValue := c.Param("id")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+	span.SetTag("release-id", idValue)
+
+	id, err := strconv.Atoi(idValue)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed reading id from path parameter for %v/%v/%v/%v", source, owner, repo, idValue)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Path parameter id is not of type integer"})
+		return
+	}
+
+	metrics, err := h.cockroachDBClient.GetReleaseResourceMetrics(ctx, source, owner, repo, id)
+	if err != nil {
+		log.Error().Err(err).
+			Msgf("Failed retrieving resource metrics for %v/%v/%v/releases/%v from db", source, owner, repo, id)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed retrieving release resource usage"})
+		return
+	}
+	if metrics == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Pipeline release resource usage not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
 func (h *apiHandlerImpl) GetPipelineReleaseLogs(c *gin.Context) {
 
 	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineReleaseLogs")
@@ -1442,6 +1994,50 @@ func (h *apiHandlerImpl) GetPipelineWarnings(c *gin.Context) {
 		}
 	}
 
+	// the flakiness warning is a nice-to-have, so failing to compute it doesn't fail the other warnings
+	flakiness, err := h.getPipelineFlakiness(ctx, source, owner, repo, 100, map[string][]string{"since": {"eternity"}})
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed retrieving flakiness from db for pipeline %v/%v/%v warnings, skipping flakiness warning", source, owner, repo)
+	} else if flakiness.Score >= 0.05 {
+		status := "warning"
+		if flakiness.Score >= 0.2 {
+			status = "danger"
+		}
+		flakySteps := []string{}
+		for _, s := range flakiness.Steps {
+			flakySteps = append(flakySteps, s.Step)
+		}
+		message := fmt.Sprintf("Builds of **%v out of %v** recent revisions of this pipeline both [failed and succeeded](/pipelines/%v/%v/%v/statistics), so they're flaky.", len(flakiness.FlakyRevisions), flakiness.Revisions, source, owner, repo)
+		if len(flakySteps) > 0 {
+			message += fmt.Sprintf(" The flaky stages are `%v`;", strings.Join(flakySteps, ", "))
+		}
+		message += " please make your build deterministic so failures can be trusted and don't need a re-run."
+		warnings = append(warnings, contracts.Warning{
+			Status:  status,
+			Message: message,
+		})
+	}
+
+	// like the flakiness warning the cost warning is skipped if it can't be computed
+	buildsUsage, err := h.cockroachDBClient.GetPipelineBuildsResourceUsage(ctx, source, owner, repo, map[string][]string{"since": {"1m"}})
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed retrieving resource usage from db for pipeline %v/%v/%v warnings, skipping resource usage warning", source, owner, repo)
+	} else if cpuRatio, memoryRatio := getOverRequestRatios(buildsUsage); buildsUsage != nil && buildsUsage.Jobs >= 5 && (cpuRatio >= 3 || memoryRatio >= 3) {
+		cpuCoreHourPrice, memoryGBHourPrice := h.getJobPrices()
+		cost := getJobCost(buildsUsage.CPUMeasuredRequestCoreHours, buildsUsage.MemoryMeasuredRequestGBHours, cpuCoreHourPrice, memoryGBHourPrice)
+		maxUsageCost := getJobCost(buildsUsage.CPUMaxUsageCoreHours, buildsUsage.MemoryMaxUsageGBHours, cpuCoreHourPrice, memoryGBHourPrice)
+
+		message := fmt.Sprintf("Builds of this pipeline in the last month requested **%.1fx** the cpu and **%.1fx** the memory they [used at their peak](/pipelines/%v/%v/%v/statistics)", cpuRatio, memoryRatio, source, owner, repo)
+		if cost > 0 {
+			message += fmt.Sprintf(", costing an estimated **%.2f** where **%.2f** would have sufficed", cost, maxUsageCost)
+		}
+		message += "; please check whether the resources your builds request can be lowered."
+		warnings = append(warnings, contracts.Warning{
+			Status:  "warning",
+			Message: message,
+		})
+	}
+
 	manifestWarnings, err := h.warningHelper.GetManifestWarnings(pipeline.ManifestObject, pipeline.RepoOwner)
 	if err != nil {
 		log.Error().Err(err).
@@ -1454,6 +2050,130 @@ func (h *apiHandlerImpl) GetPipelineWarnings(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"warnings": warnings})
 }
 
+func (h *apiHandlerImpl) GetPipelineStatsFlakiness(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineStatsFlakiness")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+
+	// get filters (?filter[last]=100&filter[since]=1w)
+	last, err := strconv.Atoi(h.getLastFilter(c, 100)[0])
+	if err != nil || last <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Filter last is not a positive integer"})
+		return
+	}
+	filters := map[string][]string{}
+	filters["since"] = h.getSinceFilter(c)
+
+	flakiness, err := h.getPipelineFlakiness(ctx, source, owner, repo, last, filters)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving flakiness from db for %v/%v/%v", source, owner, repo)
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	c.JSON(http.StatusOK, flakiness)
+}
+
+func (h *apiHandlerImpl) GetPipelineStatsSteps(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetPipelineStatsSteps")
+	defer span.Finish()
+
+	source := c.Param("source")
+	owner := c.Param("owner")
+	repo := c.Param("repo")
+
+	span.SetTag("git-repo", fmt.Sprintf("%v/%v/%v", source, owner, repo))
+
+	// get filters (?filter[last]=100&filter[since]=1w)
+	last, err := strconv.Atoi(h.getLastFilter(c, 100)[0])
+	if err != nil || last <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": "Filter last is not a positive integer"})
+		return
+	}
+	filters := map[string][]string{}
+	filters["status"] = []string{"succeeded", "failed"}
+	filters["since"] = h.getSinceFilter(c)
+
+	builds, err := h.cockroachDBClient.GetPipelineBuilds(ctx, source, owner, repo, 1, last, filters, true)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving builds from db for %v/%v/%v step stats", source, owner, repo)
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	buildIDs := []int{}
+	for _, b := range builds {
+		buildID, err := strconv.Atoi(b.ID)
+		if err != nil {
+			continue
+		}
+		buildIDs = append(buildIDs, buildID)
+	}
+
+	steps, err := h.cockroachDBClient.GetPipelineBuildLogSteps(ctx, source, owner, repo, buildIDs)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving build log steps from db for %v/%v/%v step stats", source, owner, repo)
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"steps": getStepStats(builds, steps),
+	})
+}
+
+// getPipelineFlakiness detects revisions for which the last builds both failed and succeeded, along with the steps and tests responsible
+func (h *apiHandlerImpl) getPipelineFlakiness(ctx context.Context, source, owner, repo string, last int, filters map[string][]string) (flakiness PipelineFlakiness, err error) {
+
+	buildFilters := map[string][]string{
+		"status": {"succeeded", "failed"},
+		"since":  filters["since"],
+	}
+	builds, err := h.cockroachDBClient.GetPipelineBuilds(ctx, source, owner, repo, 1, last, buildFilters, true)
+	if err != nil {
+		return
+	}
+
+	flakiness.Revisions, flakiness.FlakyRevisions = getFlakyRevisions(builds)
+	flakiness.Score = getFlakinessScore(len(flakiness.FlakyRevisions), flakiness.Revisions)
+
+	// only the steps of builds for flaky revisions can be flaky
+	buildIDs := []int{}
+	for _, b := range builds {
+		if !stringArrayContains(flakiness.FlakyRevisions, b.RepoRevision) {
+			continue
+		}
+		buildID, err := strconv.Atoi(b.ID)
+		if err != nil {
+			continue
+		}
+		buildIDs = append(buildIDs, buildID)
+	}
+
+	steps, err := h.cockroachDBClient.GetPipelineBuildLogSteps(ctx, source, owner, repo, buildIDs)
+	if err != nil {
+		return
+	}
+	flakiness.Steps = getStepFlakiness(builds, steps, flakiness.Revisions)
+
+	flakiness.Tests, err = h.cockroachDBClient.GetPipelineFlakyTestCases(ctx, source, owner, repo, 10, filters)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func (h *apiHandlerImpl) GetStatsPipelinesCount(c *gin.Context) {
 
 	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetStatsPipelinesCount")
@@ -1601,6 +2321,116 @@ func (h *apiHandlerImpl) GetStatsMostReleases(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+func (h *apiHandlerImpl) GetStatsMostFailingStepImages(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetStatsMostFailingStepImages")
+	defer span.Finish()
+
+	pageNumber, pageSize, filters := h.getQueryParameters(c)
+
+	span.SetTag("page-number", pageNumber)
+	span.SetTag("page-size", pageSize)
+
+	images, err := h.cockroachDBClient.GetStepImagesWithMostFailures(ctx, pageNumber, pageSize, filters)
+	if err != nil {
+		errorMessage := "Failed retrieving stage images with most failures from db"
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+	imagesCount, err := h.cockroachDBClient.GetStepImagesWithMostFailuresCount(ctx, filters)
+	if err != nil {
+		errorMessage := "Failed retrieving stage images count from db"
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	response := contracts.ListResponse{
+		Pagination: contracts.Pagination{
+			Page:       pageNumber,
+			Size:       pageSize,
+			TotalItems: imagesCount,
+			TotalPages: int(math.Ceil(float64(imagesCount) / float64(pageSize))),
+		},
+	}
+
+	response.Items = make([]interface{}, len(images))
+	for i := range images {
+		response.Items[i] = images[i]
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *apiHandlerImpl) GetStatsDORA(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetStatsDORA")
+	defer span.Finish()
+
+	// get filters (?filter[target]=production&filter[since]=1m&filter[labels]=team%3Destafette-team)
+	targets, targetsExist := c.GetQueryArray("filter[target]")
+	if !targetsExist {
+		targets = []string{"production"}
+	}
+	filters := map[string][]string{}
+	filters["since"] = h.getSinceFilter(c)
+	filters["labels"] = h.getLabelsFilter(c)
+
+	deployments, err := h.cockroachDBClient.GetDeploymentRecords(ctx, targets, filters)
+	if err != nil {
+		errorMessage := "Failed retrieving releases for dora metrics from db"
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	now := time.Now().UTC()
+	c.JSON(http.StatusOK, getDORAMetrics(targets, deployments, getSinceTime(filters["since"][0], now), now))
+}
+
+func (h *apiHandlerImpl) GetStatsCosts(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetStatsCosts")
+	defer span.Finish()
+
+	// get grouping (?groupBy=label&label=team)
+	groupBy := c.DefaultQuery("groupBy", "pipeline")
+	if !stringArrayContains([]string{"pipeline", "owner", "label"}, groupBy) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": fmt.Sprintf("Grouping by %v is not supported; use pipeline, owner or label", groupBy)})
+		return
+	}
+	labelKey := c.DefaultQuery("label", "team")
+
+	// get filters (?filter[since]=1m&filter[labels]=team%3Destafette-team&filter[source]=github.com)
+	filters := map[string][]string{}
+	filters["since"] = h.getSinceFilter(c)
+	filters["labels"] = h.getLabelsFilter(c)
+	if sources, ok := c.GetQueryArray("filter[source]"); ok {
+		filters["source"] = sources
+	}
+
+	buildUsages, err := h.cockroachDBClient.GetBuildsResourceUsage(ctx, filters)
+	if err != nil {
+		errorMessage := "Failed retrieving builds resource usage from db"
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	releaseUsages, err := h.cockroachDBClient.GetReleasesResourceUsage(ctx, filters)
+	if err != nil {
+		errorMessage := "Failed retrieving releases resource usage from db"
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	cpuCoreHourPrice, memoryGBHourPrice := h.getJobPrices()
+
+	c.JSON(http.StatusOK, getJobCosts(append(buildUsages, releaseUsages...), groupBy, labelKey, cpuCoreHourPrice, memoryGBHourPrice))
+}
+
 func (h *apiHandlerImpl) GetStatsBuildsDuration(c *gin.Context) {
 
 	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetStatsBuildsDuration")
@@ -1622,6 +2452,58 @@ func (h *apiHandlerImpl) GetStatsBuildsDuration(c *gin.Context) {
 	})
 }
 
+func (h *apiHandlerImpl) GetStatsBuildsTimeSeries(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetStatsBuildsTimeSeries")
+	defer span.Finish()
+
+	interval := c.DefaultQuery("interval", "day")
+	if !stringArrayContains([]string{"hour", "day", "week"}, interval) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": fmt.Sprintf("Interval %v is not supported; use hour, day or week", interval)})
+		return
+	}
+	filters := h.getTimeSeriesFilters(c)
+
+	buckets, err := h.cockroachDBClient.GetBuildsTimeSeries(ctx, interval, filters)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving builds time series per %v from db", interval)
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"interval": interval,
+		"buckets":  buckets,
+	})
+}
+
+func (h *apiHandlerImpl) GetStatsReleasesTimeSeries(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetStatsReleasesTimeSeries")
+	defer span.Finish()
+
+	interval := c.DefaultQuery("interval", "day")
+	if !stringArrayContains([]string{"hour", "day", "week"}, interval) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": fmt.Sprintf("Interval %v is not supported; use hour, day or week", interval)})
+		return
+	}
+	filters := h.getTimeSeriesFilters(c)
+
+	buckets, err := h.cockroachDBClient.GetReleasesTimeSeries(ctx, interval, filters)
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed retrieving releases time series per %v from db", interval)
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": errorMessage})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"interval": interval,
+		"buckets":  buckets,
+	})
+}
+
 func (h *apiHandlerImpl) GetStatsBuildsAdoption(c *gin.Context) {
 
 	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetStatsBuildsAdoption")
@@ -2014,6 +2896,71 @@ func (h *apiHandlerImpl) PostCronEvent(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Hey Cron, here's a tock for your tick"})
 }
 
+func (h *apiHandlerImpl) GetWebhookDeliveries(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::GetWebhookDeliveries")
+	defer span.Finish()
+
+	pageNumber := h.getPageNumber(c)
+	pageSize := h.getPageSize(c)
+
+	span.SetTag("page-number", pageNumber)
+	span.SetTag("page-size", pageSize)
+
+	deliveries, err := h.cockroachDBClient.GetWebhookDeliveries(ctx, pageNumber, pageSize)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed retrieving webhook deliveries from db")
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	deliveriesCount, err := h.cockroachDBClient.GetWebhookDeliveriesCount(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed retrieving webhook deliveries count from db")
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+
+	response := contracts.ListResponse{
+		Items: make([]interface{}, len(deliveries)),
+		Pagination: contracts.Pagination{
+			Page:       pageNumber,
+			Size:       pageSize,
+			TotalItems: deliveriesCount,
+			TotalPages: int(math.Ceil(float64(deliveriesCount) / float64(pageSize))),
+		},
+	}
+
+	for i := range deliveries {
+		response.Items[i] = deliveries[i]
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *apiHandlerImpl) RedeliverWebhookDelivery(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Api::RedeliverWebhookDelivery")
+	defer span.Finish()
+
+	user := c.MustGet(gin.AuthUserKey).(auth.User)
+
+	id := c.Param("id")
+
+	delivery, err := h.webhookNotifier.Redeliver(ctx, id)
+	if delivery == nil && err != nil {
+		errorMessage := fmt.Sprintf("Failed redelivering webhook delivery %v for user %v", id, user.Email)
+		log.Error().Err(err).Msg(errorMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"code": http.StatusText(http.StatusBadRequest), "message": errorMessage})
+		return
+	}
+	if err != nil {
+		log.Warn().Err(err).Msgf("Redelivering webhook delivery %v for user %v failed", id, user.Email)
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
 func (h *apiHandlerImpl) getSinceFilter(c *gin.Context) []string {
 
 	filterSinceValues, filterSinceExist := c.GetQueryArray("filter[since]")
@@ -2092,6 +3039,28 @@ func (h *apiHandlerImpl) getFilters(c *gin.Context) map[string][]string {
 	return filters
 }
 
+func (h *apiHandlerImpl) getTimeSeriesFilters(c *gin.Context) map[string][]string {
+	// get filters (?interval=week&filter[status]=succeeded&filter[since]=1y&filter[labels]=team%3Destafette-team&filter[source]=github.com)
+	filters := map[string][]string{}
+	filters["status"] = h.getStatusFilter(c)
+	filters["since"] = h.getSinceFilter(c)
+	filters["labels"] = h.getLabelsFilter(c)
+	if sources, ok := c.GetQueryArray("filter[source]"); ok {
+		filters["source"] = sources
+	}
+
+	return filters
+}
+
+// getJobPrices returns the configured prices per cpu core-hour and per GB-hour of memory, or zero if jobs aren't configured
+func (h *apiHandlerImpl) getJobPrices() (cpuCoreHourPrice, memoryGBHourPrice float64) {
+	if h.encryptedConfig.Jobs == nil {
+		return
+	}
+
+	return h.encryptedConfig.Jobs.CPUCoreHourPrice, h.encryptedConfig.Jobs.MemoryGBHourPrice
+}
+
 func (h *apiHandlerImpl) obfuscateSecrets(input string) (string, error) {
 
 	r, err := regexp.Compile(`estafette\.secret\(([a-zA-Z0-9.=_-]+)\)`)
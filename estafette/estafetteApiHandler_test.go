@@ -0,0 +1,177 @@
+package estafette
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/estafette/estafette-ci-api/auth"
+	"github.com/estafette/estafette-ci-api/cockroach"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDBClient overrides the DBClient methods used by the api handler and build service
+type fakeDBClient struct {
+	cockroach.DBClient
+	pipeline     *contracts.Pipeline
+	build        *contracts.Build
+	buildRetries []*cockroach.BuildRetry
+}
+
+func (f *fakeDBClient) GetBuildRetries(ctx context.Context, repoSource, repoOwner, repoName, buildVersion string) ([]*cockroach.BuildRetry, error) {
+	return f.buildRetries, nil
+}
+
+// InsertBuildRetry claims the attempt unless a retry for the same build or attempt exists already
+func (f *fakeDBClient) InsertBuildRetry(ctx context.Context, buildRetry cockroach.BuildRetry) (*cockroach.BuildRetry, error) {
+	for _, r := range f.buildRetries {
+		if r.BuildID == buildRetry.BuildID || r.Attempt == buildRetry.Attempt {
+			return nil, nil
+		}
+	}
+	f.buildRetries = append(f.buildRetries, &buildRetry)
+	return &buildRetry, nil
+}
+
+func (f *fakeDBClient) GetPipelineBuildByID(ctx context.Context, repoSource, repoOwner, repoName string, id int, optimized bool) (*contracts.Build, error) {
+	return f.build, nil
+}
+
+func (f *fakeDBClient) GetPipeline(ctx context.Context, repoSource, repoOwner, repoName string, optimized bool) (*contracts.Pipeline, error) {
+	return f.pipeline, nil
+}
+
+// fakeBuildService records the builds it gets asked to create
+type fakeBuildService struct {
+	BuildService
+	builds     []contracts.Build
+	parameters []map[string]string
+}
+
+func (f *fakeBuildService) CreateManualBuild(ctx context.Context, build contracts.Build, builderTrack string, parameters map[string]string) (*contracts.Build, error) {
+	f.builds = append(f.builds, build)
+	f.parameters = append(f.parameters, parameters)
+	return &build, nil
+}
+
+func TestCreatePipelineBuildForRef(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	user := auth.User{Authenticated: true, Email: "me@estafette.io"}
+	manifestString := `
+parameters:
+- name: environment
+  default: staging
+
+stages:
+  build:
+    image: golang:1.12.7-alpine3.10
+`
+
+	getHandler := func(dbClient *fakeDBClient, buildService *fakeBuildService, manifestRefs *[]string) *apiHandlerImpl {
+		return &apiHandlerImpl{
+			cockroachDBClient: dbClient,
+			buildService:      buildService,
+			githubManifestFunc: func(ctx context.Context, repoSource, repoOwner, repoName, ref string) (string, bool, string, error) {
+				*manifestRefs = append(*manifestRefs, ref)
+				return "5a2d4c3", true, manifestString, nil
+			},
+		}
+	}
+
+	t.Run("BuildsRevisionOfBranchWithManifestAtThatRevision", func(t *testing.T) {
+
+		buildService := &fakeBuildService{}
+		manifestRefs := []string{}
+		handler := getHandler(&fakeDBClient{pipeline: &contracts.Pipeline{RepoBranch: "master"}}, buildService, &manifestRefs)
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		buildCommand := BuildCommand{Build: contracts.Build{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", RepoBranch: "feature/x"}, Parameters: map[string]string{"environment": "production"}}
+
+		// act
+		handler.createPipelineBuildForRef(context.Background(), c, user, buildCommand)
+
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Equal(t, []string{"feature/x"}, manifestRefs)
+		if assert.Equal(t, 1, len(buildService.builds)) {
+			assert.Equal(t, "feature/x", buildService.builds[0].RepoBranch)
+			assert.Equal(t, "5a2d4c3", buildService.builds[0].RepoRevision)
+			assert.Equal(t, manifestString, buildService.builds[0].Manifest)
+			assert.Equal(t, "me@estafette.io", buildService.builds[0].Events[0].Manual.UserID)
+			assert.Equal(t, "production", buildService.parameters[0]["environment"])
+		}
+	})
+
+	t.Run("BuildsRevisionWithoutBranchAsPartOfLastBuiltBranch", func(t *testing.T) {
+
+		buildService := &fakeBuildService{}
+		manifestRefs := []string{}
+		handler := getHandler(&fakeDBClient{pipeline: &contracts.Pipeline{RepoBranch: "master"}}, buildService, &manifestRefs)
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		buildCommand := BuildCommand{Build: contracts.Build{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", RepoRevision: "5a2d4c3"}}
+
+		// act
+		handler.createPipelineBuildForRef(context.Background(), c, user, buildCommand)
+
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Equal(t, []string{"5a2d4c3"}, manifestRefs)
+		if assert.Equal(t, 1, len(buildService.builds)) {
+			assert.Equal(t, "master", buildService.builds[0].RepoBranch)
+		}
+	})
+
+	t.Run("ReturnsBadRequestWithoutBranchOrRevision", func(t *testing.T) {
+
+		buildService := &fakeBuildService{}
+		manifestRefs := []string{}
+		handler := getHandler(&fakeDBClient{pipeline: &contracts.Pipeline{RepoBranch: "master"}}, buildService, &manifestRefs)
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		buildCommand := BuildCommand{Build: contracts.Build{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api"}}
+
+		// act
+		handler.createPipelineBuildForRef(context.Background(), c, user, buildCommand)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, 0, len(buildService.builds))
+	})
+
+	t.Run("ReturnsNotFoundForUnknownPipeline", func(t *testing.T) {
+
+		buildService := &fakeBuildService{}
+		manifestRefs := []string{}
+		handler := getHandler(&fakeDBClient{}, buildService, &manifestRefs)
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		buildCommand := BuildCommand{Build: contracts.Build{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", RepoBranch: "master"}}
+
+		// act
+		handler.createPipelineBuildForRef(context.Background(), c, user, buildCommand)
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		assert.Equal(t, 0, len(buildService.builds))
+	})
+
+	t.Run("ReturnsBadRequestForUnsupportedSource", func(t *testing.T) {
+
+		buildService := &fakeBuildService{}
+		manifestRefs := []string{}
+		handler := getHandler(&fakeDBClient{pipeline: &contracts.Pipeline{RepoBranch: "master"}}, buildService, &manifestRefs)
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		buildCommand := BuildCommand{Build: contracts.Build{RepoSource: "gitlab.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", RepoBranch: "master"}}
+
+		// act
+		handler.createPipelineBuildForRef(context.Background(), c, user, buildCommand)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), fmt.Sprintf("%v/%v/%v", "gitlab.com", "estafette", "estafette-ci-api"))
+		assert.Equal(t, 0, len(buildService.builds))
+	})
+}
@@ -6,12 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ericchiang/k8s"
@@ -19,6 +19,7 @@ import (
 	corev1 "github.com/ericchiang/k8s/apis/core/v1"
 	metav1 "github.com/ericchiang/k8s/apis/meta/v1"
 	"github.com/ericchiang/k8s/apis/resource"
+	"github.com/estafette/estafette-ci-api/cockroach"
 	"github.com/estafette/estafette-ci-api/config"
 	"github.com/estafette/estafette-ci-api/docker"
 	contracts "github.com/estafette/estafette-ci-contracts"
@@ -27,7 +28,6 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
-	yaml "gopkg.in/yaml.v2"
 )
 
 // CiBuilderClient is the interface for running kubernetes commands specific to this application
@@ -39,19 +39,27 @@ type CiBuilderClient interface {
 	TailCiBuilderJobLogs(context.Context, string, chan contracts.TailLogLine) error
 	GetJobName(string, string, string, string) string
 	GetBuilderConfig(CiBuilderParams, string) contracts.BuilderConfig
+	WatchCiBuilderJobs(<-chan struct{}, *sync.WaitGroup, func(context.Context, FailedJob) error)
+	GetCiBuilderJobs(context.Context) ([]*batchv1.Job, error)
+	GetCiBuilderConfigMaps(context.Context) ([]*corev1.ConfigMap, error)
+	DeleteCiBuilderJob(context.Context, *batchv1.Job) error
+	DeleteCiBuilderConfigMap(context.Context, *corev1.ConfigMap) error
+	GetCiBuilderJob(context.Context, string) (*batchv1.Job, error)
 }
 
 type ciBuilderClientImpl struct {
-	kubeClient                      *k8s.Client
+	clusters                        []*ciBuilderCluster
+	cockroachDBClient               cockroach.DBClient
 	dockerHubClient                 docker.DockerHubAPIClient
 	config                          config.APIConfig
 	encryptedConfig                 config.APIConfig
 	secretHelper                    crypt.SecretHelper
 	PrometheusOutboundAPICallTotals *prometheus.CounterVec
+	PrometheusRunningJobs           *prometheus.GaugeVec
 }
 
 // NewCiBuilderClient returns a new estafette.CiBuilderClient
-func NewCiBuilderClient(config config.APIConfig, encryptedConfig config.APIConfig, secretHelper crypt.SecretHelper, prometheusOutboundAPICallTotals *prometheus.CounterVec) (ciBuilderClient CiBuilderClient, err error) {
+func NewCiBuilderClient(config config.APIConfig, encryptedConfig config.APIConfig, secretHelper crypt.SecretHelper, cockroachDBClient cockroach.DBClient, prometheusOutboundAPICallTotals *prometheus.CounterVec, prometheusRunningJobs *prometheus.GaugeVec) (ciBuilderClient CiBuilderClient, err error) {
 
 	var kubeClient *k8s.Client
 
@@ -67,19 +75,15 @@ func NewCiBuilderClient(config config.APIConfig, encryptedConfig config.APIConfi
 
 		homeDir := os.Getenv("HOME")
 
-		data, err := ioutil.ReadFile(fmt.Sprintf("%v/.kube/config", homeDir))
+		kubeClient, err = newKubeClientFromFile(fmt.Sprintf("%v/.kube/config", homeDir))
 		if err != nil {
-			log.Error().Err(err).Msg("Reading kube config failed")
-			return nil, err
-		}
-
-		var config k8s.Config
-		if err := yaml.Unmarshal(data, &config); err != nil {
-			log.Error().Err(err).Msg("Deserializing kube config failed")
 			return nil, err
 		}
+	}
 
-		kubeClient, err = k8s.NewClient(&config)
+	clusters, err := newCiBuilderClusters(*config.Jobs, kubeClient)
+	if err != nil {
+		return
 	}
 
 	dockerHubClient, err := docker.NewDockerHubAPIClient()
@@ -88,12 +92,14 @@ func NewCiBuilderClient(config config.APIConfig, encryptedConfig config.APIConfi
 	}
 
 	ciBuilderClient = &ciBuilderClientImpl{
-		kubeClient:                      kubeClient,
+		clusters:                        clusters,
+		cockroachDBClient:               cockroachDBClient,
 		dockerHubClient:                 dockerHubClient,
 		config:                          config,
 		encryptedConfig:                 encryptedConfig,
 		secretHelper:                    secretHelper,
 		PrometheusOutboundAPICallTotals: prometheusOutboundAPICallTotals,
+		PrometheusRunningJobs:           prometheusRunningJobs,
 	}
 
 	return
@@ -114,6 +120,22 @@ func (cbc *ciBuilderClientImpl) CreateCiBuilderJob(ctx context.Context, ciBuilde
 	jobName := cbc.GetJobName(ciBuilderParams.JobType, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, id)
 	span.SetTag("job-name", jobName)
 
+	cluster := cbc.selectCluster(ctx, ciBuilderParams)
+	span.SetTag("cluster", cluster.name)
+
+	// annotate job and pod with the build or release they run, so the jobs watcher can fail it if the pod dies
+	annotations := map[string]string{
+		"repoSource": ciBuilderParams.RepoSource,
+		"repoOwner":  ciBuilderParams.RepoOwner,
+		"repoName":   ciBuilderParams.RepoName,
+		"cluster":    cluster.name,
+	}
+	if ciBuilderParams.JobType == "release" {
+		annotations["releaseID"] = id
+	} else {
+		annotations["buildID"] = id
+	}
+
 	log.Info().Msgf("Creating job %v...", jobName)
 
 	// extend builder config to parameterize the builder and replace all other envvars to improve security
@@ -232,18 +254,21 @@ func (cbc *ciBuilderClientImpl) CreateCiBuilderJob(ctx context.Context, ciBuilde
 	configmap := &corev1.ConfigMap{
 		Metadata: &metav1.ObjectMeta{
 			Name:      &builderConfigConfigmapName,
-			Namespace: &cbc.config.Jobs.Namespace,
+			Namespace: &cluster.namespace,
 			Labels: map[string]string{
 				"createdBy": "estafette",
 				"jobType":   ciBuilderParams.JobType,
 			},
+			Annotations: map[string]string{
+				"cluster": cluster.name,
+			},
 		},
 		Data: map[string]string{
 			"builder-config.json": builderConfigValue,
 		},
 	}
 
-	err = cbc.kubeClient.Create(context.Background(), configmap)
+	err = cluster.kubeClient.Create(context.Background(), configmap)
 	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
 
 	if err != nil {
@@ -317,6 +342,27 @@ func (cbc *ciBuilderClientImpl) CreateCiBuilderJob(ctx context.Context, ciBuilde
 		}
 	}
 
+	// apply the configured pod spec, possibly overridden for the release target or pipeline labels
+	podSpecConfig := getJobPodSpecConfig(*cbc.config.Jobs, ciBuilderParams)
+	if podSpecConfig.Affinity != nil {
+		builtInRequirements := []*corev1.NodeSelectorRequirement{
+			&corev1.NodeSelectorRequirement{
+				Key:      &operatingSystemAffinityKey,
+				Operator: &operatingSystemAffinityOperator,
+				Values:   []string{operatingSystemAffinityValue},
+			},
+		}
+		if ciBuilderParams.JobType == "release" {
+			// configured affinity can't move releases onto preemptibles
+			releasePreemptibleAffinityOperator := "DoesNotExist"
+			builtInRequirements = append(builtInRequirements, &corev1.NodeSelectorRequirement{
+				Key:      &preemptibleAffinityKey,
+				Operator: &releasePreemptibleAffinityOperator,
+			})
+		}
+		affinity = getNodeAffinity(*podSpecConfig.Affinity, builtInRequirements)
+	}
+
 	volumes := []*corev1.Volume{
 		&corev1.Volume{
 			Name: &builderConfigVolumeName,
@@ -434,22 +480,69 @@ func (cbc *ciBuilderClientImpl) CreateCiBuilderJob(ctx context.Context, ciBuilde
 		})
 	}
 
+	for _, t := range podSpecConfig.Tolerations {
+		toleration := *t
+		tolerations = append(tolerations, &corev1.Toleration{
+			Key:      &toleration.Key,
+			Operator: &toleration.Operator,
+			Value:    &toleration.Value,
+			Effect:   &toleration.Effect,
+		})
+	}
+
+	// configured labels and annotations can't override the ones estafette relies on
+	podLabels := map[string]string{}
+	for k, v := range podSpecConfig.Labels {
+		podLabels[k] = v
+	}
+	podLabels["createdBy"] = "estafette"
+	podLabels["jobType"] = ciBuilderParams.JobType
+
+	podAnnotations := map[string]string{}
+	for k, v := range podSpecConfig.Annotations {
+		podAnnotations[k] = v
+	}
+	for k, v := range annotations {
+		podAnnotations[k] = v
+	}
+
+	var imagePullSecrets []*corev1.LocalObjectReference
+	for _, s := range podSpecConfig.ImagePullSecrets {
+		secretName := s
+		imagePullSecrets = append(imagePullSecrets, &corev1.LocalObjectReference{Name: &secretName})
+	}
+
+	var priorityClassName, serviceAccountName *string
+	if podSpecConfig.PriorityClassName != "" {
+		priorityClassName = &podSpecConfig.PriorityClassName
+	}
+	if podSpecConfig.ServiceAccountName != "" {
+		serviceAccountName = &podSpecConfig.ServiceAccountName
+	}
+
+	// let kubernetes stop hung jobs; the timeout sweeper marks their build or release as timed out
+	var activeDeadlineSeconds *int64
+	if ciBuilderParams.Timeout > 0 {
+		seconds := int64(getRemainingTimeout(ciBuilderParams.Timeout, ciBuilderParams.InsertedAt, time.Now().UTC()).Seconds())
+		activeDeadlineSeconds = &seconds
+	}
+
 	job = &batchv1.Job{
 		Metadata: &metav1.ObjectMeta{
 			Name:      &jobName,
-			Namespace: &cbc.config.Jobs.Namespace,
+			Namespace: &cluster.namespace,
 			Labels: map[string]string{
 				"createdBy": "estafette",
 				"jobType":   ciBuilderParams.JobType,
 			},
+			Annotations: annotations,
 		},
 		Spec: &batchv1.JobSpec{
+			ActiveDeadlineSeconds: activeDeadlineSeconds,
 			Template: &corev1.PodTemplateSpec{
 				Metadata: &metav1.ObjectMeta{
-					Labels: map[string]string{
-						"createdBy": "estafette",
-						"jobType":   ciBuilderParams.JobType,
-					},
+					Labels:      podLabels,
+					Annotations: podAnnotations,
 				},
 				Spec: &corev1.PodSpec{
 					Containers: []*corev1.Container{
@@ -485,24 +578,153 @@ func (cbc *ciBuilderClientImpl) CreateCiBuilderJob(ctx context.Context, ciBuilde
 					Affinity: affinity,
 
 					Tolerations: tolerations,
+
+					NodeSelector:       podSpecConfig.NodeSelector,
+					PriorityClassName:  priorityClassName,
+					ServiceAccountName: serviceAccountName,
+					ImagePullSecrets:   imagePullSecrets,
 				},
 			},
 		},
 	}
 
 	// "error":"unregistered type *v1.Job",
-	err = cbc.kubeClient.Create(context.Background(), job)
+	err = cluster.kubeClient.Create(context.Background(), job)
 	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
 
 	if err != nil {
 		return
 	}
 
-	log.Info().Msgf("Job %v is created", jobName)
+	log.Info().Msgf("Job %v is created in cluster %v", jobName, cluster.name)
+
+	// store where the job runs, so cancel, tail and clean up go to the same cluster
+	if ciBuilderParams.JobType == "release" {
+		err = cbc.cockroachDBClient.UpdateReleaseJob(ctx, ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.ReleaseID, jobName, cluster.name)
+	} else {
+		err = cbc.cockroachDBClient.UpdateBuildJob(ctx, ciBuilderParams.RepoSource, ciBuilderParams.RepoOwner, ciBuilderParams.RepoName, ciBuilderParams.BuildID, jobName, cluster.name)
+	}
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed storing cluster %v for job %v", cluster.name, jobName)
+		err = nil
+	}
 
 	return
 }
 
+// getJobPodSpecConfig returns the pod spec from the jobs config with all matching overrides applied in order
+func getJobPodSpecConfig(jobsConfig config.JobsConfig, ciBuilderParams CiBuilderParams) (podSpecConfig config.JobPodSpecConfig) {
+
+	if jobsConfig.PodSpec != nil {
+		podSpecConfig = mergeJobPodSpecConfig(podSpecConfig, *jobsConfig.PodSpec)
+	}
+
+	for _, o := range jobsConfig.PodSpecOverrides {
+		if o.ReleaseTarget == "" && len(o.Labels) == 0 {
+			continue
+		}
+		if o.ReleaseTarget != "" && (ciBuilderParams.JobType != "release" || o.ReleaseTarget != ciBuilderParams.ReleaseName) {
+			continue
+		}
+		labelsMatch := true
+		for k, v := range o.Labels {
+			if ciBuilderParams.Manifest.Labels[k] != v {
+				labelsMatch = false
+				break
+			}
+		}
+		if !labelsMatch {
+			continue
+		}
+
+		podSpecConfig = mergeJobPodSpecConfig(podSpecConfig, o.PodSpec)
+	}
+
+	return
+}
+
+// mergeJobPodSpecConfig adds the maps of the override to the base and replaces all other fields set in the override
+func mergeJobPodSpecConfig(base, override config.JobPodSpecConfig) config.JobPodSpecConfig {
+
+	mergeMaps := func(a, b map[string]string) map[string]string {
+		if len(a) == 0 && len(b) == 0 {
+			return nil
+		}
+		merged := map[string]string{}
+		for k, v := range a {
+			merged[k] = v
+		}
+		for k, v := range b {
+			merged[k] = v
+		}
+		return merged
+	}
+
+	base.NodeSelector = mergeMaps(base.NodeSelector, override.NodeSelector)
+	base.Annotations = mergeMaps(base.Annotations, override.Annotations)
+	base.Labels = mergeMaps(base.Labels, override.Labels)
+
+	if len(override.Tolerations) > 0 {
+		base.Tolerations = override.Tolerations
+	}
+	if override.Affinity != nil {
+		base.Affinity = override.Affinity
+	}
+	if override.PriorityClassName != "" {
+		base.PriorityClassName = override.PriorityClassName
+	}
+	if len(override.ImagePullSecrets) > 0 {
+		base.ImagePullSecrets = override.ImagePullSecrets
+	}
+	if override.ServiceAccountName != "" {
+		base.ServiceAccountName = override.ServiceAccountName
+	}
+
+	return base
+}
+
+// getNodeAffinity translates configured node affinity, merging the required terms with the built-in requirements like the operating system of the job
+func getNodeAffinity(affinityConfig config.NodeAffinityConfig, builtInRequirements []*corev1.NodeSelectorRequirement) *corev1.Affinity {
+
+	toRequirements := func(requirementConfigs []*config.NodeSelectorRequirementConfig) (requirements []*corev1.NodeSelectorRequirement) {
+		for _, r := range requirementConfigs {
+			requirement := *r
+			requirements = append(requirements, &corev1.NodeSelectorRequirement{
+				Key:      &requirement.Key,
+				Operator: &requirement.Operator,
+				Values:   requirement.Values,
+			})
+		}
+		return
+	}
+
+	requiredRequirements := append(toRequirements(affinityConfig.Required), builtInRequirements...)
+
+	var preferred []*corev1.PreferredSchedulingTerm
+	for _, p := range affinityConfig.Preferred {
+		weight := p.Weight
+		preferred = append(preferred, &corev1.PreferredSchedulingTerm{
+			Weight: &weight,
+			Preference: &corev1.NodeSelectorTerm{
+				MatchExpressions: toRequirements(p.MatchExpressions),
+			},
+		})
+	}
+
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: preferred,
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []*corev1.NodeSelectorTerm{
+					&corev1.NodeSelectorTerm{
+						MatchExpressions: requiredRequirements,
+					},
+				},
+			},
+		},
+	}
+}
+
 // RemoveCiBuilderJob waits for a job to finish and then removes it
 func (cbc *ciBuilderClientImpl) RemoveCiBuilderJob(ctx context.Context, jobName string) (err error) {
 
@@ -512,9 +734,11 @@ func (cbc *ciBuilderClientImpl) RemoveCiBuilderJob(ctx context.Context, jobName
 
 	log.Info().Msgf("Deleting job %v...", jobName)
 
+	cluster := cbc.getClusterForJob(ctx, jobName)
+
 	// check if job is finished
 	var job batchv1.Job
-	err = cbc.kubeClient.Get(context.Background(), cbc.config.Jobs.Namespace, jobName, &job)
+	err = cluster.kubeClient.Get(context.Background(), cluster.namespace, jobName, &job)
 	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
 	if err != nil {
 		log.Error().Err(err).
@@ -527,7 +751,7 @@ func (cbc *ciBuilderClientImpl) RemoveCiBuilderJob(ctx context.Context, jobName
 
 		// watch for job updates
 		var job batchv1.Job
-		watcher, err := cbc.kubeClient.Watch(context.Background(), cbc.config.Jobs.Namespace, &job, k8s.Timeout(time.Duration(300)*time.Second))
+		watcher, err := cluster.kubeClient.Watch(context.Background(), cluster.namespace, &job, k8s.Timeout(time.Duration(300)*time.Second))
 		defer watcher.Close()
 
 		cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
@@ -553,7 +777,7 @@ func (cbc *ciBuilderClientImpl) RemoveCiBuilderJob(ctx context.Context, jobName
 	}
 
 	// delete job
-	err = cbc.kubeClient.Delete(context.Background(), &job)
+	err = cluster.kubeClient.Delete(context.Background(), &job)
 	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
 	if err != nil {
 		log.Error().Err(err).
@@ -564,16 +788,20 @@ func (cbc *ciBuilderClientImpl) RemoveCiBuilderJob(ctx context.Context, jobName
 
 	log.Info().Msgf("Job %v is deleted", jobName)
 
-	cbc.RemoveCiBuilderConfigMap(ctx, jobName)
+	cbc.removeCiBuilderConfigMap(ctx, cluster, jobName)
 
 	return
 }
 
 func (cbc *ciBuilderClientImpl) RemoveCiBuilderConfigMap(ctx context.Context, configmapName string) (err error) {
+	return cbc.removeCiBuilderConfigMap(ctx, cbc.getClusterForJob(ctx, configmapName), configmapName)
+}
+
+func (cbc *ciBuilderClientImpl) removeCiBuilderConfigMap(ctx context.Context, cluster *ciBuilderCluster, configmapName string) (err error) {
 
 	// check if configmap exists
 	var configmap corev1.ConfigMap
-	err = cbc.kubeClient.Get(context.Background(), cbc.config.Jobs.Namespace, configmapName, &configmap)
+	err = cluster.kubeClient.Get(context.Background(), cluster.namespace, configmapName, &configmap)
 	if err != nil {
 		log.Error().Err(err).
 			Str("configmap", configmapName).
@@ -582,7 +810,7 @@ func (cbc *ciBuilderClientImpl) RemoveCiBuilderConfigMap(ctx context.Context, co
 	}
 
 	// delete configmap
-	err = cbc.kubeClient.Delete(context.Background(), &configmap)
+	err = cluster.kubeClient.Delete(context.Background(), &configmap)
 	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
 	if err != nil {
 		log.Error().Err(err).
@@ -605,9 +833,11 @@ func (cbc *ciBuilderClientImpl) CancelCiBuilderJob(ctx context.Context, jobName
 
 	log.Info().Msgf("Canceling job %v...", jobName)
 
+	cluster := cbc.getClusterForJob(ctx, jobName)
+
 	// check if job is finished
 	var job batchv1.Job
-	err = cbc.kubeClient.Get(context.Background(), cbc.config.Jobs.Namespace, jobName, &job)
+	err = cluster.kubeClient.Get(context.Background(), cluster.namespace, jobName, &job)
 	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
 	if err != nil {
 		log.Error().Err(err).
@@ -617,7 +847,7 @@ func (cbc *ciBuilderClientImpl) CancelCiBuilderJob(ctx context.Context, jobName
 	}
 
 	// delete job
-	err = cbc.kubeClient.Delete(context.Background(), &job)
+	err = cluster.kubeClient.Delete(context.Background(), &job)
 	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
 	if err != nil {
 		log.Error().Err(err).
@@ -628,7 +858,103 @@ func (cbc *ciBuilderClientImpl) CancelCiBuilderJob(ctx context.Context, jobName
 
 	log.Info().Msgf("Job %v is canceled", jobName)
 
-	cbc.RemoveCiBuilderConfigMap(ctx, jobName)
+	cbc.removeCiBuilderConfigMap(ctx, cluster, jobName)
+
+	return
+}
+
+// GetCiBuilderJob returns a build or release job from the cluster it was dispatched to, or nil if it doesn't exist
+func (cbc *ciBuilderClientImpl) GetCiBuilderJob(ctx context.Context, jobName string) (job *batchv1.Job, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "KubernetesApi::GetCiBuilderJob")
+	defer span.Finish()
+	span.SetTag("job-name", jobName)
+
+	cluster := cbc.getClusterForJob(ctx, jobName)
+
+	job = &batchv1.Job{}
+	err = cluster.kubeClient.Get(ctx, cluster.namespace, jobName, job)
+	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
+	if err != nil {
+		if apiErr, ok := err.(*k8s.APIError); ok && apiErr.Code == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return
+}
+
+// GetCiBuilderJobs returns all build and release jobs in the jobs namespace of each cluster
+func (cbc *ciBuilderClientImpl) GetCiBuilderJobs(ctx context.Context) (jobs []*batchv1.Job, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "KubernetesApi::GetCiBuilderJobs")
+	defer span.Finish()
+
+	labels := new(k8s.LabelSelector)
+	labels.Eq("createdBy", "estafette")
+
+	for _, cluster := range cbc.getDistinctClusters() {
+		var jobList batchv1.JobList
+		err = cluster.kubeClient.List(ctx, cluster.namespace, &jobList, labels.Selector())
+		cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
+		if err != nil {
+			return
+		}
+		jobs = append(jobs, jobList.Items...)
+	}
+
+	return jobs, nil
+}
+
+// GetCiBuilderConfigMaps returns all configmaps of build and release jobs in the jobs namespace of each cluster
+func (cbc *ciBuilderClientImpl) GetCiBuilderConfigMaps(ctx context.Context) (configmaps []*corev1.ConfigMap, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "KubernetesApi::GetCiBuilderConfigMaps")
+	defer span.Finish()
+
+	labels := new(k8s.LabelSelector)
+	labels.Eq("createdBy", "estafette")
+
+	for _, cluster := range cbc.getDistinctClusters() {
+		var configmapList corev1.ConfigMapList
+		err = cluster.kubeClient.List(ctx, cluster.namespace, &configmapList, labels.Selector())
+		cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
+		if err != nil {
+			return
+		}
+		configmaps = append(configmaps, configmapList.Items...)
+	}
+
+	return configmaps, nil
+}
+
+// DeleteCiBuilderJob removes a job including its pods without waiting for it to finish
+func (cbc *ciBuilderClientImpl) DeleteCiBuilderJob(ctx context.Context, job *batchv1.Job) (err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "KubernetesApi::DeleteCiBuilderJob")
+	defer span.Finish()
+	span.SetTag("job-name", job.Metadata.GetName())
+
+	cluster := cbc.getCluster(job.Metadata.Annotations["cluster"])
+
+	err = cluster.kubeClient.Delete(ctx, job, k8s.DeletePropagationBackground())
+	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
+
+	return
+}
+
+// DeleteCiBuilderConfigMap removes the configmap of a job
+func (cbc *ciBuilderClientImpl) DeleteCiBuilderConfigMap(ctx context.Context, configmap *corev1.ConfigMap) (err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "KubernetesApi::DeleteCiBuilderConfigMap")
+	defer span.Finish()
+	span.SetTag("configmap", configmap.Metadata.GetName())
+
+	cluster := cbc.getCluster(configmap.Metadata.Annotations["cluster"])
+
+	err = cluster.kubeClient.Delete(ctx, configmap)
+	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
 
 	return
 }
@@ -643,11 +969,13 @@ func (cbc *ciBuilderClientImpl) TailCiBuilderJobLogs(ctx context.Context, jobNam
 	// close channel so api handler can finish it's response
 	defer close(logChannel)
 
+	cluster := cbc.getClusterForJob(ctx, jobName)
+
 	labels := new(k8s.LabelSelector)
 	labels.Eq("job-name", jobName)
 
 	var pods corev1.PodList
-	if err := cbc.kubeClient.List(context.Background(), cbc.config.Jobs.Namespace, &pods, labels.Selector()); err != nil {
+	if err := cluster.kubeClient.List(context.Background(), cluster.namespace, &pods, labels.Selector()); err != nil {
 		return err
 	}
 
@@ -656,7 +984,7 @@ func (cbc *ciBuilderClientImpl) TailCiBuilderJobLogs(ctx context.Context, jobNam
 		if *pod.Status.Phase == "Pending" {
 			// watch for pod to go into Running state (or out of Pending state)
 			var pendingPod corev1.Pod
-			watcher, err := cbc.kubeClient.Watch(context.Background(), cbc.config.Jobs.Namespace, &pendingPod, k8s.Timeout(time.Duration(300)*time.Second))
+			watcher, err := cluster.kubeClient.Watch(context.Background(), cluster.namespace, &pendingPod, k8s.Timeout(time.Duration(300)*time.Second))
 
 			if err != nil {
 				return err
@@ -683,7 +1011,7 @@ func (cbc *ciBuilderClientImpl) TailCiBuilderJobLogs(ctx context.Context, jobNam
 		}
 
 		// follow logs from pod
-		url := fmt.Sprintf("%v/api/v1/namespaces/%v/pods/%v/log?follow=true", cbc.kubeClient.Endpoint, cbc.config.Jobs.Namespace, *pod.Metadata.Name)
+		url := fmt.Sprintf("%v/api/v1/namespaces/%v/pods/%v/log?follow=true", cluster.kubeClient.Endpoint, cluster.namespace, *pod.Metadata.Name)
 
 		ct := "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8"
 
@@ -692,8 +1020,8 @@ func (cbc *ciBuilderClientImpl) TailCiBuilderJobLogs(ctx context.Context, jobNam
 			log.Error().Err(err).Msgf("Failed generating request for retrieving logs from pod %v for job %v", *pod.Metadata.Name, jobName)
 			return err
 		}
-		if cbc.kubeClient.SetHeaders != nil {
-			if err := cbc.kubeClient.SetHeaders(req.Header); err != nil {
+		if cluster.kubeClient.SetHeaders != nil {
+			if err := cluster.kubeClient.SetHeaders(req.Header); err != nil {
 				log.Error().Err(err).Msgf("Failed setting request headers for retrieving logs from pod %v for job %v", *pod.Metadata.Name, jobName)
 				return err
 			}
@@ -702,7 +1030,7 @@ func (cbc *ciBuilderClientImpl) TailCiBuilderJobLogs(ctx context.Context, jobNam
 
 		req.Header.Set("Accept", ct)
 
-		resp, err := cbc.kubeClient.Client.Do(req)
+		resp, err := cluster.kubeClient.Client.Do(req)
 		if err != nil {
 			log.Error().Err(err).Msgf("Failed performing request for retrieving logs from pod %v for job %v", *pod.Metadata.Name, jobName)
 			return err
@@ -740,6 +1068,236 @@ func (cbc *ciBuilderClientImpl) TailCiBuilderJobLogs(ctx context.Context, jobNam
 	return
 }
 
+// WatchCiBuilderJobs watches the pods of build and release jobs for failures the builder can't report itself, hands them to handleFailedJob and then removes the job
+func (cbc *ciBuilderClientImpl) WatchCiBuilderJobs(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup, handleFailedJob func(context.Context, FailedJob) error) {
+
+	pendingTimeout := time.Duration(cbc.config.Jobs.PendingTimeoutMinutes) * time.Minute
+	if pendingTimeout <= 0 {
+		pendingTimeout = 15 * time.Minute
+	}
+
+	// cancel watch calls when shutting down
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		<-stopChannel
+		cancel()
+	}()
+
+	for _, cluster := range cbc.getDistinctClusters() {
+		waitGroup.Add(1)
+		go func(cluster *ciBuilderCluster) {
+			defer waitGroup.Done()
+
+			for {
+				// pods stuck in pending don't trigger watch events, so check all pods in between watches
+				cbc.checkCiBuilderPods(ctx, cluster, pendingTimeout, handleFailedJob)
+				cbc.watchCiBuilderPods(ctx, cluster, pendingTimeout, handleFailedJob)
+
+				select {
+				case <-ctx.Done():
+					log.Debug().Msgf("Stopping jobs watcher for cluster %v...", cluster.name)
+					return
+				default:
+				}
+			}
+		}(cluster)
+	}
+}
+
+func (cbc *ciBuilderClientImpl) checkCiBuilderPods(ctx context.Context, cluster *ciBuilderCluster, pendingTimeout time.Duration, handleFailedJob func(context.Context, FailedJob) error) {
+
+	labels := new(k8s.LabelSelector)
+	labels.Eq("createdBy", "estafette")
+
+	var pods corev1.PodList
+	err := cluster.kubeClient.List(ctx, cluster.namespace, &pods, labels.Selector())
+	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
+	if err != nil {
+		log.Error().Err(err).Msgf("Listing pods in namespace %v of cluster %v failed", cluster.namespace, cluster.name)
+		return
+	}
+
+	// the pods are checked regularly, so this keeps the number of running jobs per cluster up to date as well
+	runningJobs := map[string]float64{"build": 0, "release": 0}
+	for _, pod := range pods.Items {
+		if pod.Status.GetPhase() == "Running" {
+			runningJobs[pod.Metadata.GetLabels()["jobType"]]++
+		}
+		cbc.handleCiBuilderPod(ctx, cluster, pod, pendingTimeout, handleFailedJob)
+	}
+	for jobType, count := range runningJobs {
+		cbc.PrometheusRunningJobs.With(prometheus.Labels{"type": jobType, "cluster": cluster.name}).Set(count)
+	}
+}
+
+func (cbc *ciBuilderClientImpl) watchCiBuilderPods(ctx context.Context, cluster *ciBuilderCluster, pendingTimeout time.Duration, handleFailedJob func(context.Context, FailedJob) error) {
+
+	labels := new(k8s.LabelSelector)
+	labels.Eq("createdBy", "estafette")
+
+	var pod corev1.Pod
+	watcher, err := cluster.kubeClient.Watch(ctx, cluster.namespace, &pod, labels.Selector(), k8s.Timeout(time.Duration(60)*time.Second))
+	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
+	if err != nil {
+		log.Error().Err(err).Msgf("Watcher call for pods in namespace %v of cluster %v failed", cluster.namespace, cluster.name)
+
+		// avoid hammering the kubernetes api if it's unavailable
+		select {
+		case <-ctx.Done():
+		case <-time.After(10 * time.Second):
+		}
+		return
+	}
+	defer watcher.Close()
+
+	for {
+		watchedPod := new(corev1.Pod)
+		event, err := watcher.Next(watchedPod)
+		if err != nil {
+			// the watch times out regularly, after which it gets restarted
+			return
+		}
+
+		if event == k8s.EventAdded || event == k8s.EventModified {
+			cbc.handleCiBuilderPod(ctx, cluster, watchedPod, pendingTimeout, handleFailedJob)
+		}
+	}
+}
+
+func (cbc *ciBuilderClientImpl) handleCiBuilderPod(ctx context.Context, cluster *ciBuilderCluster, pod *corev1.Pod, pendingTimeout time.Duration, handleFailedJob func(context.Context, FailedJob) error) {
+
+	failedJob := getFailedJob(pod, time.Now().UTC(), pendingTimeout)
+	if failedJob == nil {
+		return
+	}
+
+	log.Warn().Interface("failedJob", failedJob).Msgf("Job %v failed with reason %v: %v", failedJob.JobName, failedJob.Reason, failedJob.Message)
+
+	err := handleFailedJob(ctx, *failedJob)
+	if err != nil {
+		// the job is left in place, so it's picked up again during the next check
+		log.Error().Err(err).Msgf("Failed handling failure of job %v", failedJob.JobName)
+		return
+	}
+
+	// removes the job and configmap, the pod is removed explicitly since deleting a job orphans its pods
+	err = cbc.CancelCiBuilderJob(ctx, failedJob.JobName)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed removing job %v after failure", failedJob.JobName)
+	}
+	err = cluster.kubeClient.Delete(ctx, pod)
+	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed removing pod %v after failure", failedJob.PodName)
+	}
+}
+
+// getFailedJob returns the failure for a pod that got oom-killed, evicted, failed otherwise or is stuck in pending; nil if the pod is fine
+func getFailedJob(pod *corev1.Pod, now time.Time, pendingTimeout time.Duration) *FailedJob {
+
+	if pod == nil || pod.Metadata == nil || pod.Status == nil {
+		return nil
+	}
+
+	annotations := pod.Metadata.Annotations
+	if annotations == nil || annotations["repoSource"] == "" {
+		// pods of jobs created before annotating them can't be related to a build or release
+		return nil
+	}
+
+	failedJob := FailedJob{
+		JobName:    pod.Metadata.Labels["job-name"],
+		PodName:    pod.Metadata.GetName(),
+		JobType:    pod.Metadata.Labels["jobType"],
+		RepoSource: annotations["repoSource"],
+		RepoOwner:  annotations["repoOwner"],
+		RepoName:   annotations["repoName"],
+	}
+	failedJob.BuildID, _ = strconv.Atoi(annotations["buildID"])
+	failedJob.ReleaseID, _ = strconv.Atoi(annotations["releaseID"])
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.GetState().GetTerminated().GetReason() == "OOMKilled" {
+			failedJob.Reason = "OOMKilled"
+			failedJob.Message = fmt.Sprintf("Container %v ran out of memory and got killed", cs.GetName())
+			failedJob.ExitCode = int64(cs.GetState().GetTerminated().GetExitCode())
+			return &failedJob
+		}
+	}
+
+	// pods on a preempted node get terminated when the node shuts down or are lost along with the node
+	if isPreemption(pod.Status.GetReason(), pod.Status.GetMessage()) {
+		failedJob.Reason = "Preempted"
+		failedJob.Message = pod.Status.GetMessage()
+		if failedJob.Message == "" {
+			failedJob.Message = fmt.Sprintf("Node running pod %v got preempted", failedJob.PodName)
+		}
+		failedJob.ExitCode = 1
+		failedJob.Preempted = true
+		return &failedJob
+	}
+
+	switch pod.Status.GetPhase() {
+	case "Failed":
+		failedJob.Reason = pod.Status.GetReason()
+		failedJob.Message = pod.Status.GetMessage()
+		failedJob.ExitCode = 1
+		for _, cs := range pod.Status.ContainerStatuses {
+			if terminated := cs.GetState().GetTerminated(); terminated != nil {
+				failedJob.ExitCode = int64(terminated.GetExitCode())
+				if failedJob.Reason == "" {
+					failedJob.Reason = terminated.GetReason()
+					failedJob.Message = terminated.GetMessage()
+				}
+			}
+		}
+		if failedJob.Reason == "" {
+			failedJob.Reason = "Failed"
+		}
+		if failedJob.Message == "" {
+			failedJob.Message = fmt.Sprintf("Pod %v failed", failedJob.PodName)
+		}
+		return &failedJob
+
+	case "Pending":
+		createdAt := time.Unix(pod.Metadata.GetCreationTimestamp().GetSeconds(), 0)
+		if now.Sub(createdAt) < pendingTimeout {
+			return nil
+		}
+
+		failedJob.Reason = "PendingTimeout"
+		failedJob.Message = fmt.Sprintf("Pod %v has been pending for more than %v", failedJob.PodName, pendingTimeout)
+		failedJob.ExitCode = 1
+		for _, c := range pod.Status.Conditions {
+			if c.GetType() == "PodScheduled" && c.GetStatus() == "False" && c.GetReason() != "" {
+				failedJob.Reason = c.GetReason()
+				failedJob.Message = c.GetMessage()
+			}
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if waiting := cs.GetState().GetWaiting(); waiting != nil && waiting.GetReason() != "" && waiting.GetReason() != "ContainerCreating" {
+				failedJob.Reason = waiting.GetReason()
+				failedJob.Message = waiting.GetMessage()
+			}
+		}
+		return &failedJob
+	}
+
+	return nil
+}
+
+func isPreemption(reason, message string) bool {
+	switch reason {
+	case "Terminated", "Shutdown", "NodeShutdown", "NodeLost":
+		return true
+	}
+
+	message = strings.ToLower(message)
+
+	return strings.Contains(message, "preempt") || strings.Contains(message, "node shutdown")
+}
+
 // GetJobName returns the job name for a build or release job
 func (cbc *ciBuilderClientImpl) GetJobName(jobType, repoOwner, repoSource, id string) string {
 
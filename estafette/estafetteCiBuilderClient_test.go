@@ -2,7 +2,12 @@ package estafette
 
 import (
 	"testing"
+	"time"
 
+	corev1 "github.com/ericchiang/k8s/apis/core/v1"
+	metav1 "github.com/ericchiang/k8s/apis/meta/v1"
+	"github.com/estafette/estafette-ci-api/config"
+	manifest "github.com/estafette/estafette-ci-manifest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -52,3 +57,313 @@ func TestGetJobName(t *testing.T) {
 		assert.Equal(t, 63, len(jobName))
 	})
 }
+
+func TestGetFailedJob(t *testing.T) {
+
+	now := time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	newPod := func(phase string, createdAt time.Time) *corev1.Pod {
+		name := "build-estafette-estafette-ci-api-390605593734184965-x7k2p"
+		createdAtSeconds := createdAt.Unix()
+		return &corev1.Pod{
+			Metadata: &metav1.ObjectMeta{
+				Name:              &name,
+				CreationTimestamp: &metav1.Time{Seconds: &createdAtSeconds},
+				Labels: map[string]string{
+					"createdBy": "estafette",
+					"jobType":   "build",
+					"job-name":  "build-estafette-estafette-ci-api-390605593734184965",
+				},
+				Annotations: map[string]string{
+					"repoSource": "github.com",
+					"repoOwner":  "estafette",
+					"repoName":   "estafette-ci-api",
+					"buildID":    "390605593734184965",
+				},
+			},
+			Status: &corev1.PodStatus{
+				Phase: &phase,
+			},
+		}
+	}
+
+	t.Run("ReturnsNilForRunningPod", func(t *testing.T) {
+
+		pod := newPod("Running", now.Add(-1*time.Hour))
+
+		// act
+		failedJob := getFailedJob(pod, now, 15*time.Minute)
+
+		assert.Nil(t, failedJob)
+	})
+
+	t.Run("ReturnsOOMKilledForPodWithOOMKilledContainer", func(t *testing.T) {
+
+		pod := newPod("Running", now.Add(-5*time.Minute))
+		containerName := "estafette-ci-builder"
+		reason := "OOMKilled"
+		exitCode := int32(137)
+		pod.Status.ContainerStatuses = []*corev1.ContainerStatus{
+			&corev1.ContainerStatus{
+				Name: &containerName,
+				State: &corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Reason: &reason, ExitCode: &exitCode},
+				},
+			},
+		}
+
+		// act
+		failedJob := getFailedJob(pod, now, 15*time.Minute)
+
+		if assert.NotNil(t, failedJob) {
+			assert.Equal(t, "OOMKilled", failedJob.Reason)
+			assert.Equal(t, int64(137), failedJob.ExitCode)
+			assert.Equal(t, "build-estafette-estafette-ci-api-390605593734184965", failedJob.JobName)
+			assert.Equal(t, "estafette-ci-api", failedJob.RepoName)
+			assert.Equal(t, 390605593734184965, failedJob.BuildID)
+			assert.Equal(t, 0, failedJob.ReleaseID)
+		}
+	})
+
+	t.Run("ReturnsEvictedForEvictedPod", func(t *testing.T) {
+
+		pod := newPod("Failed", now.Add(-5*time.Minute))
+		reason := "Evicted"
+		message := "The node was low on resource: ephemeral-storage."
+		pod.Status.Reason = &reason
+		pod.Status.Message = &message
+
+		// act
+		failedJob := getFailedJob(pod, now, 15*time.Minute)
+
+		if assert.NotNil(t, failedJob) {
+			assert.Equal(t, "Evicted", failedJob.Reason)
+			assert.Equal(t, message, failedJob.Message)
+		}
+	})
+
+	t.Run("ReturnsPreemptedForPodTerminatedOnNodeShutdown", func(t *testing.T) {
+
+		pod := newPod("Failed", now.Add(-5*time.Minute))
+		reason := "Terminated"
+		message := "Pod was terminated in response to imminent node shutdown."
+		pod.Status.Reason = &reason
+		pod.Status.Message = &message
+
+		// act
+		failedJob := getFailedJob(pod, now, 15*time.Minute)
+
+		if assert.NotNil(t, failedJob) {
+			assert.Equal(t, "Preempted", failedJob.Reason)
+			assert.Equal(t, message, failedJob.Message)
+			assert.True(t, failedJob.Preempted)
+		}
+	})
+
+	t.Run("ReturnsNotPreemptedForEvictedPod", func(t *testing.T) {
+
+		pod := newPod("Failed", now.Add(-5*time.Minute))
+		reason := "Evicted"
+		pod.Status.Reason = &reason
+
+		// act
+		failedJob := getFailedJob(pod, now, 15*time.Minute)
+
+		if assert.NotNil(t, failedJob) {
+			assert.False(t, failedJob.Preempted)
+		}
+	})
+
+	t.Run("ReturnsNilForPodPendingShorterThanTimeout", func(t *testing.T) {
+
+		pod := newPod("Pending", now.Add(-5*time.Minute))
+
+		// act
+		failedJob := getFailedJob(pod, now, 15*time.Minute)
+
+		assert.Nil(t, failedJob)
+	})
+
+	t.Run("ReturnsWaitingReasonForPodPendingLongerThanTimeout", func(t *testing.T) {
+
+		pod := newPod("Pending", now.Add(-20*time.Minute))
+		reason := "ImagePullBackOff"
+		pod.Status.ContainerStatuses = []*corev1.ContainerStatus{
+			&corev1.ContainerStatus{
+				State: &corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: &reason},
+				},
+			},
+		}
+
+		// act
+		failedJob := getFailedJob(pod, now, 15*time.Minute)
+
+		if assert.NotNil(t, failedJob) {
+			assert.Equal(t, "ImagePullBackOff", failedJob.Reason)
+		}
+	})
+
+	t.Run("ReturnsUnschedulableForPodThatCannotBeScheduled", func(t *testing.T) {
+
+		pod := newPod("Pending", now.Add(-20*time.Minute))
+		conditionType := "PodScheduled"
+		conditionStatus := "False"
+		reason := "Unschedulable"
+		pod.Status.Conditions = []*corev1.PodCondition{
+			&corev1.PodCondition{Type: &conditionType, Status: &conditionStatus, Reason: &reason},
+		}
+
+		// act
+		failedJob := getFailedJob(pod, now, 15*time.Minute)
+
+		if assert.NotNil(t, failedJob) {
+			assert.Equal(t, "Unschedulable", failedJob.Reason)
+		}
+	})
+
+	t.Run("ReturnsNilForPodWithoutAnnotations", func(t *testing.T) {
+
+		pod := newPod("Failed", now.Add(-5*time.Minute))
+		pod.Metadata.Annotations = nil
+
+		// act
+		failedJob := getFailedJob(pod, now, 15*time.Minute)
+
+		assert.Nil(t, failedJob)
+	})
+}
+
+func TestGetJobPodSpecConfig(t *testing.T) {
+
+	jobsConfig := config.JobsConfig{
+		PodSpec: &config.JobPodSpecConfig{
+			NodeSelector:       map[string]string{"pool": "ci"},
+			PriorityClassName:  "estafette-ci-jobs",
+			ServiceAccountName: "estafette-ci-builder",
+		},
+		PodSpecOverrides: []*config.JobPodSpecOverrideConfig{
+			&config.JobPodSpecOverrideConfig{
+				ReleaseTarget: "production",
+				PodSpec: config.JobPodSpecConfig{
+					NodeSelector:      map[string]string{"pool": "ci-production"},
+					PriorityClassName: "estafette-ci-releases",
+				},
+			},
+			&config.JobPodSpecOverrideConfig{
+				Labels: map[string]string{"team": "gpu-team"},
+				PodSpec: config.JobPodSpecConfig{
+					NodeSelector:       map[string]string{"gpu": "true"},
+					ServiceAccountName: "estafette-ci-builder-gpu",
+				},
+			},
+		},
+	}
+
+	t.Run("ReturnsPodSpecWithoutOverridesForBuild", func(t *testing.T) {
+
+		// act
+		podSpecConfig := getJobPodSpecConfig(jobsConfig, CiBuilderParams{JobType: "build"})
+
+		assert.Equal(t, map[string]string{"pool": "ci"}, podSpecConfig.NodeSelector)
+		assert.Equal(t, "estafette-ci-jobs", podSpecConfig.PriorityClassName)
+		assert.Equal(t, "estafette-ci-builder", podSpecConfig.ServiceAccountName)
+	})
+
+	t.Run("AppliesOverrideForReleaseTarget", func(t *testing.T) {
+
+		// act
+		podSpecConfig := getJobPodSpecConfig(jobsConfig, CiBuilderParams{JobType: "release", ReleaseName: "production"})
+
+		assert.Equal(t, map[string]string{"pool": "ci-production"}, podSpecConfig.NodeSelector)
+		assert.Equal(t, "estafette-ci-releases", podSpecConfig.PriorityClassName)
+		assert.Equal(t, "estafette-ci-builder", podSpecConfig.ServiceAccountName)
+	})
+
+	t.Run("DoesNotApplyReleaseTargetOverrideToBuild", func(t *testing.T) {
+
+		// act
+		podSpecConfig := getJobPodSpecConfig(jobsConfig, CiBuilderParams{JobType: "build", ReleaseName: "production"})
+
+		assert.Equal(t, "estafette-ci-jobs", podSpecConfig.PriorityClassName)
+	})
+
+	t.Run("AppliesOverrideForMatchingPipelineLabels", func(t *testing.T) {
+
+		// act
+		podSpecConfig := getJobPodSpecConfig(jobsConfig, CiBuilderParams{
+			JobType:  "build",
+			Manifest: manifest.EstafetteManifest{Labels: map[string]string{"team": "gpu-team"}},
+		})
+
+		assert.Equal(t, map[string]string{"pool": "ci", "gpu": "true"}, podSpecConfig.NodeSelector)
+		assert.Equal(t, "estafette-ci-builder-gpu", podSpecConfig.ServiceAccountName)
+		assert.Equal(t, "estafette-ci-jobs", podSpecConfig.PriorityClassName)
+	})
+}
+
+func TestGetNodeAffinity(t *testing.T) {
+
+	operatingSystemKey := "beta.kubernetes.io/os"
+	operatingSystemOperator := "In"
+	operatingSystemRequirement := &corev1.NodeSelectorRequirement{
+		Key:      &operatingSystemKey,
+		Operator: &operatingSystemOperator,
+		Values:   []string{"linux"},
+	}
+
+	t.Run("AddsOperatingSystemToRequiredTerm", func(t *testing.T) {
+
+		affinityConfig := config.NodeAffinityConfig{
+			Required: []*config.NodeSelectorRequirementConfig{
+				&config.NodeSelectorRequirementConfig{Key: "pool", Operator: "In", Values: []string{"ci"}},
+			},
+			Preferred: []*config.PreferredNodeAffinityConfig{
+				&config.PreferredNodeAffinityConfig{
+					Weight: 10,
+					MatchExpressions: []*config.NodeSelectorRequirementConfig{
+						&config.NodeSelectorRequirementConfig{Key: "spot", Operator: "Exists"},
+					},
+				},
+			},
+		}
+
+		// act
+		affinity := getNodeAffinity(affinityConfig, []*corev1.NodeSelectorRequirement{operatingSystemRequirement})
+
+		requirements := affinity.GetNodeAffinity().GetRequiredDuringSchedulingIgnoredDuringExecution().GetNodeSelectorTerms()[0].GetMatchExpressions()
+		if assert.Equal(t, 2, len(requirements)) {
+			assert.Equal(t, "pool", requirements[0].GetKey())
+			assert.Equal(t, "beta.kubernetes.io/os", requirements[1].GetKey())
+			assert.Equal(t, []string{"linux"}, requirements[1].GetValues())
+		}
+		preferred := affinity.GetNodeAffinity().GetPreferredDuringSchedulingIgnoredDuringExecution()
+		if assert.Equal(t, 1, len(preferred)) {
+			assert.Equal(t, int32(10), preferred[0].GetWeight())
+			assert.Equal(t, "spot", preferred[0].GetPreference().GetMatchExpressions()[0].GetKey())
+		}
+	})
+	t.Run("KeepsReleasesOffPreemptiblesWithConfiguredAffinity", func(t *testing.T) {
+
+		affinityConfig := config.NodeAffinityConfig{
+			Required: []*config.NodeSelectorRequirementConfig{
+				&config.NodeSelectorRequirementConfig{Key: "pool", Operator: "In", Values: []string{"ci"}},
+			},
+		}
+		preemptibleKey := "cloud.google.com/gke-preemptible"
+		preemptibleOperator := "DoesNotExist"
+
+		// act
+		affinity := getNodeAffinity(affinityConfig, []*corev1.NodeSelectorRequirement{
+			operatingSystemRequirement,
+			&corev1.NodeSelectorRequirement{Key: &preemptibleKey, Operator: &preemptibleOperator},
+		})
+
+		terms := affinity.GetNodeAffinity().GetRequiredDuringSchedulingIgnoredDuringExecution().GetNodeSelectorTerms()
+		if assert.Equal(t, 1, len(terms)) && assert.Equal(t, 3, len(terms[0].GetMatchExpressions())) {
+			assert.Equal(t, "pool", terms[0].GetMatchExpressions()[0].GetKey())
+			assert.Equal(t, "cloud.google.com/gke-preemptible", terms[0].GetMatchExpressions()[2].GetKey())
+			assert.Equal(t, "DoesNotExist", terms[0].GetMatchExpressions()[2].GetOperator())
+		}
+	})
+}
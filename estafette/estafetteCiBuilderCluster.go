@@ -0,0 +1,205 @@
+package estafette
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ericchiang/k8s"
+	batchv1 "github.com/ericchiang/k8s/apis/batch/v1"
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const defaultClusterName = "default"
+
+// ciBuilderCluster is a kubernetes cluster that build and release jobs get dispatched to
+type ciBuilderCluster struct {
+	name       string
+	kubeClient *k8s.Client
+	namespace  string
+	config     *config.ClusterConfig
+}
+
+// newCiBuilderClusters returns the cluster the api runs in followed by all configured clusters
+func newCiBuilderClusters(jobsConfig config.JobsConfig, defaultKubeClient *k8s.Client) (clusters []*ciBuilderCluster, err error) {
+
+	clusters = []*ciBuilderCluster{
+		&ciBuilderCluster{
+			name:       defaultClusterName,
+			kubeClient: defaultKubeClient,
+			namespace:  jobsConfig.Namespace,
+		},
+	}
+
+	for _, c := range jobsConfig.Clusters {
+		cluster := &ciBuilderCluster{
+			name:       c.Name,
+			kubeClient: defaultKubeClient,
+			namespace:  c.Namespace,
+			config:     c,
+		}
+		if cluster.namespace == "" {
+			cluster.namespace = jobsConfig.Namespace
+		}
+
+		// without kube config the cluster the api runs in gets used, so it can be part of the routing as well
+		if c.KubeConfigPath != "" {
+			cluster.kubeClient, err = newKubeClientFromFile(c.KubeConfigPath)
+			if err != nil {
+				return nil, fmt.Errorf("Creating kube client for cluster %v failed: %v", c.Name, err)
+			}
+		}
+
+		clusters = append(clusters, cluster)
+	}
+
+	return
+}
+
+func newKubeClientFromFile(kubeConfigPath string) (*k8s.Client, error) {
+
+	data, err := ioutil.ReadFile(kubeConfigPath)
+	if err != nil {
+		log.Error().Err(err).Msg("Reading kube config failed")
+		return nil, err
+	}
+
+	var kubeConfig k8s.Config
+	if err := yaml.Unmarshal(data, &kubeConfig); err != nil {
+		log.Error().Err(err).Msg("Deserializing kube config failed")
+		return nil, err
+	}
+
+	return k8s.NewClient(&kubeConfig)
+}
+
+// getCluster returns the cluster with the name, or the default cluster for unknown or empty names
+func (cbc *ciBuilderClientImpl) getCluster(name string) *ciBuilderCluster {
+	for _, c := range cbc.clusters {
+		if c.name == name {
+			return c
+		}
+	}
+
+	return cbc.clusters[0]
+}
+
+// getDistinctClusters returns the clusters to list and watch jobs in, skipping clusters that share the kube client and namespace of another one
+func (cbc *ciBuilderClientImpl) getDistinctClusters() (clusters []*ciBuilderCluster) {
+	for _, c := range cbc.clusters {
+		isDuplicate := false
+		for _, d := range clusters {
+			if d.kubeClient == c.kubeClient && d.namespace == c.namespace {
+				isDuplicate = true
+				break
+			}
+		}
+		if !isDuplicate {
+			clusters = append(clusters, c)
+		}
+	}
+
+	return
+}
+
+// getClusterForJob returns the cluster a job was dispatched to, as stored with its build or release
+func (cbc *ciBuilderClientImpl) getClusterForJob(ctx context.Context, jobName string) *ciBuilderCluster {
+
+	if len(cbc.clusters) == 1 {
+		return cbc.clusters[0]
+	}
+
+	clusterName, err := cbc.cockroachDBClient.GetJobCluster(ctx, jobName)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed retrieving cluster for job %v, using default cluster", jobName)
+	}
+
+	return cbc.getCluster(clusterName)
+}
+
+// selectCluster routes a job to the least loaded of the matching clusters
+func (cbc *ciBuilderClientImpl) selectCluster(ctx context.Context, ciBuilderParams CiBuilderParams) *ciBuilderCluster {
+
+	candidates := getClusterCandidates(cbc.clusters, ciBuilderParams)
+	if len(candidates) == 0 {
+		return cbc.clusters[0]
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	var selected *ciBuilderCluster
+	minActiveJobs := -1
+	for _, c := range candidates {
+		activeJobs, err := cbc.getActiveJobsCount(ctx, c)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed retrieving active jobs for cluster %v, skipping it", c.name)
+			continue
+		}
+		if minActiveJobs == -1 || activeJobs < minActiveJobs {
+			selected = c
+			minActiveJobs = activeJobs
+		}
+	}
+
+	if selected == nil {
+		return candidates[0]
+	}
+
+	return selected
+}
+
+func (cbc *ciBuilderClientImpl) getActiveJobsCount(ctx context.Context, cluster *ciBuilderCluster) (activeJobs int, err error) {
+
+	labels := new(k8s.LabelSelector)
+	labels.Eq("createdBy", "estafette")
+
+	var jobList batchv1.JobList
+	err = cluster.kubeClient.List(ctx, cluster.namespace, &jobList, labels.Selector())
+	cbc.PrometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "kubernetes"}).Inc()
+	if err != nil {
+		return
+	}
+
+	for _, j := range jobList.Items {
+		if j.GetStatus().GetActive() > 0 {
+			activeJobs++
+		}
+	}
+
+	return
+}
+
+// getClusterCandidates returns the default cluster, which accepts any job, and the configured clusters for which all set filters match the job
+func getClusterCandidates(clusters []*ciBuilderCluster, ciBuilderParams CiBuilderParams) (candidates []*ciBuilderCluster) {
+
+	for _, c := range clusters {
+		if c.config == nil {
+			candidates = append(candidates, c)
+			continue
+		}
+		if len(c.config.OperatingSystems) > 0 && !stringArrayContains(c.config.OperatingSystems, ciBuilderParams.OperatingSystem) {
+			continue
+		}
+		if len(c.config.ReleaseTargets) > 0 && (ciBuilderParams.JobType != "release" || !stringArrayContains(c.config.ReleaseTargets, ciBuilderParams.ReleaseName)) {
+			continue
+		}
+		labelsMatch := true
+		for k, v := range c.config.Labels {
+			if ciBuilderParams.Manifest.Labels[k] != v {
+				labelsMatch = false
+				break
+			}
+		}
+		if !labelsMatch {
+			continue
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	return
+}
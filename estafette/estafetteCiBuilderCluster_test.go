@@ -0,0 +1,97 @@
+package estafette
+
+import (
+	"testing"
+
+	"github.com/ericchiang/k8s"
+	"github.com/estafette/estafette-ci-api/config"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetClusterCandidates(t *testing.T) {
+
+	clusters := []*ciBuilderCluster{
+		&ciBuilderCluster{name: "default"},
+		&ciBuilderCluster{name: "windows", config: &config.ClusterConfig{Name: "windows", OperatingSystems: []string{"windows"}}},
+		&ciBuilderCluster{name: "production", config: &config.ClusterConfig{Name: "production", ReleaseTargets: []string{"production"}}},
+		&ciBuilderCluster{name: "gpu", config: &config.ClusterConfig{Name: "gpu", Labels: map[string]string{"team": "gpu-team"}}},
+		&ciBuilderCluster{name: "linux-gpu", config: &config.ClusterConfig{Name: "linux-gpu", OperatingSystems: []string{"linux"}, Labels: map[string]string{"team": "gpu-team"}}},
+	}
+
+	t.Run("ReturnsOnlyDefaultClusterIfNoFiltersMatch", func(t *testing.T) {
+
+		// act
+		candidates := getClusterCandidates(clusters, CiBuilderParams{JobType: "build", OperatingSystem: "linux"})
+
+		if assert.Equal(t, 1, len(candidates)) {
+			assert.Equal(t, "default", candidates[0].name)
+		}
+	})
+
+	t.Run("ReturnsClusterMatchingOperatingSystem", func(t *testing.T) {
+
+		// act
+		candidates := getClusterCandidates(clusters, CiBuilderParams{JobType: "build", OperatingSystem: "windows"})
+
+		if assert.Equal(t, 2, len(candidates)) {
+			assert.Equal(t, "default", candidates[0].name)
+			assert.Equal(t, "windows", candidates[1].name)
+		}
+	})
+
+	t.Run("ReturnsClusterMatchingReleaseTargetOnlyForReleases", func(t *testing.T) {
+
+		// act
+		releaseCandidates := getClusterCandidates(clusters, CiBuilderParams{JobType: "release", OperatingSystem: "linux", ReleaseName: "production"})
+		buildCandidates := getClusterCandidates(clusters, CiBuilderParams{JobType: "build", OperatingSystem: "linux", ReleaseName: "production"})
+
+		if assert.Equal(t, 2, len(releaseCandidates)) {
+			assert.Equal(t, "production", releaseCandidates[1].name)
+		}
+		assert.Equal(t, 1, len(buildCandidates))
+	})
+
+	t.Run("ReturnsAllClustersMatchingLabelsForLoadBasedRouting", func(t *testing.T) {
+
+		// act
+		candidates := getClusterCandidates(clusters, CiBuilderParams{
+			JobType:         "build",
+			OperatingSystem: "linux",
+			Manifest:        manifest.EstafetteManifest{Labels: map[string]string{"team": "gpu-team"}},
+		})
+
+		if assert.Equal(t, 3, len(candidates)) {
+			assert.Equal(t, "default", candidates[0].name)
+			assert.Equal(t, "gpu", candidates[1].name)
+			assert.Equal(t, "linux-gpu", candidates[2].name)
+		}
+	})
+}
+
+func TestGetDistinctClusters(t *testing.T) {
+
+	t.Run("SkipsClustersSharingKubeClientAndNamespace", func(t *testing.T) {
+
+		defaultKubeClient := &k8s.Client{}
+		otherKubeClient := &k8s.Client{}
+
+		ciBuilderClient := &ciBuilderClientImpl{
+			clusters: []*ciBuilderCluster{
+				&ciBuilderCluster{name: "default", kubeClient: defaultKubeClient, namespace: "estafette-ci-jobs"},
+				&ciBuilderCluster{name: "local", kubeClient: defaultKubeClient, namespace: "estafette-ci-jobs"},
+				&ciBuilderCluster{name: "local-windows", kubeClient: defaultKubeClient, namespace: "estafette-ci-windows-jobs"},
+				&ciBuilderCluster{name: "remote", kubeClient: otherKubeClient, namespace: "estafette-ci-jobs"},
+			},
+		}
+
+		// act
+		clusters := ciBuilderClient.getDistinctClusters()
+
+		if assert.Equal(t, 3, len(clusters)) {
+			assert.Equal(t, "default", clusters[0].name)
+			assert.Equal(t, "local-windows", clusters[1].name)
+			assert.Equal(t, "remote", clusters[2].name)
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package estafette
 
 import (
+	"time"
+
 	"github.com/estafette/estafette-ci-api/cockroach"
 	contracts "github.com/estafette/estafette-ci-contracts"
 	manifest "github.com/estafette/estafette-ci-manifest"
@@ -50,6 +52,38 @@ type CiBuilderParams struct {
 	BuildID            int
 	TriggeredByEvents  []manifest.EstafetteEvent
 	JobResources       cockroach.JobResources
+	Timeout            time.Duration
+	// InsertedAt of the build or release is when the timeout starts counting, for both the job and the timeout sweeper
+	InsertedAt time.Time
+}
+
+// BuildCommand is the body for starting a build through the api; with a version it re-runs that version if all its builds failed, otherwise it builds a branch or revision
+type BuildCommand struct {
+	contracts.Build
+	BuilderTrack string            `json:"builderTrack,omitempty"`
+	Parameters   map[string]string `json:"parameters,omitempty"`
+}
+
+// ReleaseCommand is the body for starting a release through the api, with values for the parameters the release target declares
+type ReleaseCommand struct {
+	contracts.Release
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// FailedJob describes a build or release job that failed without the builder being able to report it, for example because its pod got oom-killed or evicted
+type FailedJob struct {
+	JobName    string
+	PodName    string
+	JobType    string
+	RepoSource string
+	RepoOwner  string
+	RepoName   string
+	BuildID    int
+	ReleaseID  int
+	Reason     string
+	Message    string
+	ExitCode   int64
+	Preempted  bool
 }
 
 type zeroLogLine struct {
@@ -7,10 +7,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/estafette/estafette-ci-api/cockroach"
 	"github.com/estafette/estafette-ci-api/config"
-	prom "github.com/estafette/estafette-ci-api/prometheus"
+	contracts "github.com/estafette/estafette-ci-contracts"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
@@ -21,23 +22,24 @@ type EventHandler interface {
 	Handle(*gin.Context)
 	UpdateBuildStatus(context.Context, CiBuilderEvent) error
 	UpdateJobResources(context.Context, CiBuilderEvent) error
+	HandleFailedJob(context.Context, FailedJob) error
 }
 
 type eventHandlerImpl struct {
 	config                       config.APIServerConfig
 	ciBuilderClient              CiBuilderClient
-	prometheusClient             prom.PrometheusClient
+	resourceUsageCollector       ResourceUsageCollector
 	buildService                 BuildService
 	cockroachDBClient            cockroach.DBClient
 	prometheusInboundEventTotals *prometheus.CounterVec
 }
 
 // NewEstafetteEventHandler returns a new estafette.EventHandler
-func NewEstafetteEventHandler(config config.APIServerConfig, ciBuilderClient CiBuilderClient, prometheusClient prom.PrometheusClient, buildService BuildService, cockroachDBClient cockroach.DBClient, prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
+func NewEstafetteEventHandler(config config.APIServerConfig, ciBuilderClient CiBuilderClient, resourceUsageCollector ResourceUsageCollector, buildService BuildService, cockroachDBClient cockroach.DBClient, prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
 	return &eventHandlerImpl{
 		config:                       config,
 		ciBuilderClient:              ciBuilderClient,
-		prometheusClient:             prometheusClient,
+		resourceUsageCollector:       resourceUsageCollector,
 		buildService:                 buildService,
 		cockroachDBClient:            cockroachDBClient,
 		prometheusInboundEventTotals: prometheusInboundEventTotals,
@@ -120,12 +122,10 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 			log.Info().Msgf("Job %v is already removed by cancellation, no need to remove for event %v", eventJobname, eventType)
 		}
 
-		go func(ctx context.Context, ciBuilderEvent CiBuilderEvent) {
-			err := h.UpdateJobResources(ctx, ciBuilderEvent)
-			if err != nil {
-				log.Error().Err(err).Msgf("Failed updating max cpu and memory from prometheus for pod %v", ciBuilderEvent.PodName)
-			}
-		}(c.Request.Context(), ciBuilderEvent)
+		err = h.UpdateJobResources(ctx, ciBuilderEvent)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed updating max cpu and memory from prometheus for pod %v", ciBuilderEvent.PodName)
+		}
 
 	default:
 		log.Warn().Str("event", eventType).Msgf("Unsupported Estafette event of type '%v'", eventType)
@@ -178,57 +178,124 @@ func (h *eventHandlerImpl) UpdateBuildStatus(ctx context.Context, ciBuilderEvent
 	return fmt.Errorf("CiBuilderEvent has invalid state, not updating build status")
 }
 
+// UpdateJobResources queues collecting the resource usage of the job's pod, which happens in the background once prometheus has scraped its last samples
 func (h *eventHandlerImpl) UpdateJobResources(ctx context.Context, ciBuilderEvent CiBuilderEvent) (err error) {
 
 	log.Info().Msgf("Updating job resources for pod %v", ciBuilderEvent.PodName)
 
-	if ciBuilderEvent.PodName != "" {
+	h.resourceUsageCollector.Enqueue(ciBuilderEvent)
+
+	return nil
+}
+
+// HandleFailedJob marks the build or release of a job that died without reporting back as failed, or timed out if it exceeded its deadline, and logs the reason
+func (h *eventHandlerImpl) HandleFailedJob(ctx context.Context, failedJob FailedJob) (err error) {
+
+	logStep := contracts.BuildLogStep{
+		Step:         "estafette-ci-builder",
+		ExitCode:     failedJob.ExitCode,
+		Status:       "FAILED",
+		AutoInjected: true,
+		RunIndex:     0,
+		LogLines: []contracts.BuildLogLine{
+			contracts.BuildLogLine{
+				LineNumber: 1,
+				Timestamp:  time.Now().UTC(),
+				StreamType: "stderr",
+				Text:       fmt.Sprintf("%v: %v", failedJob.Reason, failedJob.Message),
+			},
+		},
+	}
 
-		h.prometheusClient.AwaitScrapeInterval()
+	if failedJob.ReleaseID > 0 {
 
-		maxCPU, err := h.prometheusClient.GetMaxCPUByPodName(ciBuilderEvent.PodName)
+		release, err := h.cockroachDBClient.GetPipelineRelease(ctx, failedJob.RepoSource, failedJob.RepoOwner, failedJob.RepoName, failedJob.ReleaseID)
 		if err != nil {
 			return err
 		}
+		if release == nil || (release.ReleaseStatus != "pending" && release.ReleaseStatus != "running") {
+			// already finished or removed, nothing to update
+			return nil
+		}
 
-		log.Info().Msgf("Max cpu usage for pod %v is %v", ciBuilderEvent.PodName, maxCPU)
+		// remember the oom kill so the next release to this target gets a higher memory limit
+		if failedJob.Reason == "OOMKilled" {
+			err = h.cockroachDBClient.UpdateReleaseOOMKilled(ctx, failedJob.RepoSource, failedJob.RepoOwner, failedJob.RepoName, failedJob.ReleaseID)
+			if err != nil {
+				log.Warn().Err(err).Msgf("Failed marking release for failed job %v as oom-killed", failedJob.JobName)
+			}
+		}
 
-		maxMemory, err := h.prometheusClient.GetMaxMemoryByPodName(ciBuilderEvent.PodName)
+		err = h.cockroachDBClient.InsertReleaseLog(ctx, contracts.ReleaseLog{
+			RepoSource: failedJob.RepoSource,
+			RepoOwner:  failedJob.RepoOwner,
+			RepoName:   failedJob.RepoName,
+			ReleaseID:  strconv.Itoa(failedJob.ReleaseID),
+			Steps:      []contracts.BuildLogStep{logStep},
+		})
 		if err != nil {
-			return err
+			log.Warn().Err(err).Msgf("Failed inserting release log for failed job %v", failedJob.JobName)
 		}
 
-		log.Info().Msgf("Max memory usage for pod %v is %v", ciBuilderEvent.PodName, maxMemory)
+		return h.buildService.FinishRelease(ctx, failedJob.RepoSource, failedJob.RepoOwner, failedJob.RepoName, failedJob.ReleaseID, getFailedJobStatus(failedJob.Reason))
 
-		jobResources := cockroach.JobResources{
-			CPUMaxUsage:    maxCPU,
-			MemoryMaxUsage: maxMemory,
-		}
+	} else if failedJob.BuildID > 0 {
 
-		if ciBuilderEvent.BuildStatus != "" && ciBuilderEvent.ReleaseID != "" {
+		build, err := h.cockroachDBClient.GetPipelineBuildByID(ctx, failedJob.RepoSource, failedJob.RepoOwner, failedJob.RepoName, failedJob.BuildID, false)
+		if err != nil {
+			return err
+		}
+		if build == nil || (build.BuildStatus != "pending" && build.BuildStatus != "running") {
+			// already finished or removed, nothing to update
+			return nil
+		}
 
-			releaseID, err := strconv.Atoi(ciBuilderEvent.ReleaseID)
+		// remember the oom kill so the next build gets a higher memory limit
+		if failedJob.Reason == "OOMKilled" {
+			err = h.cockroachDBClient.UpdateBuildOOMKilled(ctx, failedJob.RepoSource, failedJob.RepoOwner, failedJob.RepoName, failedJob.BuildID)
 			if err != nil {
-				return err
+				log.Warn().Err(err).Msgf("Failed marking build for failed job %v as oom-killed", failedJob.JobName)
 			}
+		}
 
-			err = h.cockroachDBClient.UpdateReleaseResourceUtilization(ctx, ciBuilderEvent.RepoSource, ciBuilderEvent.RepoOwner, ciBuilderEvent.RepoName, releaseID, jobResources)
-			if err != nil {
-				return err
-			}
-		} else if ciBuilderEvent.BuildStatus != "" && ciBuilderEvent.BuildID != "" {
+		err = h.buildService.FinishBuild(ctx, failedJob.RepoSource, failedJob.RepoOwner, failedJob.RepoName, failedJob.BuildID, getFailedJobStatus(failedJob.Reason))
+		if err != nil {
+			return err
+		}
 
-			buildID, err := strconv.Atoi(ciBuilderEvent.BuildID)
+		// a preempted build isn't at fault itself, so it's run again on another node
+		if failedJob.Preempted {
+			buildRetry, err := h.buildService.RetryPreemptedBuild(ctx, *build, failedJob.Message)
+			retryText := "Not retrying build, because the maximum number of retries for this version is reached"
 			if err != nil {
-				return err
+				log.Error().Err(err).Msgf("Failed retrying preempted build %v/%v/%v version %v", failedJob.RepoSource, failedJob.RepoOwner, failedJob.RepoName, build.BuildVersion)
+				retryText = "Retrying build failed"
+			} else if buildRetry != nil {
+				retryText = fmt.Sprintf("Retrying build as build %v (attempt %v)", buildRetry.RetryBuildID, buildRetry.Attempt)
 			}
+			logStep.LogLines = append(logStep.LogLines, contracts.BuildLogLine{
+				LineNumber: 2,
+				Timestamp:  time.Now().UTC(),
+				StreamType: "stderr",
+				Text:       retryText,
+			})
+		}
 
-			err = h.cockroachDBClient.UpdateBuildResourceUtilization(ctx, ciBuilderEvent.RepoSource, ciBuilderEvent.RepoOwner, ciBuilderEvent.RepoName, buildID, jobResources)
-			if err != nil {
-				return err
-			}
+		err = h.cockroachDBClient.InsertBuildLog(ctx, contracts.BuildLog{
+			RepoSource:   failedJob.RepoSource,
+			RepoOwner:    failedJob.RepoOwner,
+			RepoName:     failedJob.RepoName,
+			RepoBranch:   build.RepoBranch,
+			RepoRevision: build.RepoRevision,
+			BuildID:      strconv.Itoa(failedJob.BuildID),
+			Steps:        []contracts.BuildLogStep{logStep},
+		})
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed inserting build log for failed job %v", failedJob.JobName)
 		}
+
+		return nil
 	}
 
-	return nil
+	return fmt.Errorf("FailedJob %v has no build or release id, not updating status", failedJob.JobName)
 }
@@ -0,0 +1,251 @@
+package estafette
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	batchv1 "github.com/ericchiang/k8s/apis/batch/v1"
+	corev1 "github.com/ericchiang/k8s/apis/core/v1"
+	metav1 "github.com/ericchiang/k8s/apis/meta/v1"
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// JobReaper removes build and release jobs and configmaps left behind when the builder's clean-up callback got lost
+type JobReaper interface {
+	Reap(ctx context.Context) error
+	Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
+}
+
+type jobReaperImpl struct {
+	jobsConfig             config.JobsConfig
+	ciBuilderClient        CiBuilderClient
+	cockroachDBClient      cockroach.DBClient
+	prometheusReapedTotals *prometheus.CounterVec
+}
+
+// NewJobReaper returns a new estafette.JobReaper
+func NewJobReaper(jobsConfig config.JobsConfig, ciBuilderClient CiBuilderClient, cockroachDBClient cockroach.DBClient, prometheusReapedTotals *prometheus.CounterVec) JobReaper {
+	return &jobReaperImpl{
+		jobsConfig:             jobsConfig,
+		ciBuilderClient:        ciBuilderClient,
+		cockroachDBClient:      cockroachDBClient,
+		prometheusReapedTotals: prometheusReapedTotals,
+	}
+}
+
+// Reap removes finished and orphaned jobs and their configmaps once they're older than the configured minimum age
+func (r *jobReaperImpl) Reap(ctx context.Context) (err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "JobReaper::Reap")
+	defer span.Finish()
+
+	jobs, err := r.ciBuilderClient.GetCiBuilderJobs(ctx)
+	if err != nil {
+		return
+	}
+
+	configmaps, err := r.ciBuilderClient.GetCiBuilderConfigMaps(ctx)
+	if err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	minAge := r.getMinAge()
+
+	// configmaps are named after their job
+	remainingJobs := map[string]bool{}
+	reapedJobs := map[string]string{}
+
+	for _, job := range jobs {
+		jobName := job.Metadata.GetName()
+
+		hasRecord, status, err := r.getStatus(ctx, job.Metadata.Annotations)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed retrieving status for job %v, skipping it", jobName)
+			remainingJobs[jobName] = true
+			continue
+		}
+
+		reason := getJobReapReason(job, hasRecord, status, now, minAge)
+		if reason == "" {
+			remainingJobs[jobName] = true
+			continue
+		}
+
+		log.Info().Msgf("Reaping %v job %v...", reason, jobName)
+		err = r.ciBuilderClient.DeleteCiBuilderJob(ctx, job)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed reaping job %v", jobName)
+			remainingJobs[jobName] = true
+			continue
+		}
+		reapedJobs[jobName] = reason
+		r.prometheusReapedTotals.With(prometheus.Labels{"kind": "job", "reason": reason}).Inc()
+	}
+
+	for _, configmap := range configmaps {
+		configmapName := configmap.Metadata.GetName()
+
+		reason, ok := reapedJobs[configmapName]
+		if !ok {
+			reason = getConfigMapReapReason(configmap, remainingJobs[configmapName], now, minAge)
+		}
+		if reason == "" {
+			continue
+		}
+
+		log.Info().Msgf("Reaping %v configmap %v...", reason, configmapName)
+		err = r.ciBuilderClient.DeleteCiBuilderConfigMap(ctx, configmap)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed reaping configmap %v", configmapName)
+			continue
+		}
+		r.prometheusReapedTotals.With(prometheus.Labels{"kind": "configmap", "reason": reason}).Inc()
+	}
+
+	return nil
+}
+
+// Run reaps at the configured interval on the replica holding the reaper lease until the stop channel gets closed
+func (r *jobReaperImpl) Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) {
+
+	interval := time.Duration(r.jobsConfig.ReapIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+
+		for {
+			select {
+			case <-time.After(interval):
+				// only one replica reaps, so they don't race on deleting the same jobs and configmaps
+				acquired, err := r.cockroachDBClient.AcquireLease(context.Background(), "job-reaper", 2*interval)
+				if err != nil {
+					log.Error().Err(err).Msg("Failed acquiring lease for reaping build and release jobs")
+					continue
+				}
+				if !acquired {
+					continue
+				}
+				err = r.Reap(context.Background())
+				if err != nil {
+					log.Error().Err(err).Msg("Failed reaping build and release jobs")
+				}
+			case <-stopChannel:
+				log.Debug().Msg("Stopping job reaper...")
+				return
+			}
+		}
+	}()
+}
+
+func (r *jobReaperImpl) getMinAge() time.Duration {
+	if r.jobsConfig.ReapMinAgeMinutes <= 0 {
+		return 2 * time.Hour
+	}
+
+	return time.Duration(r.jobsConfig.ReapMinAgeMinutes) * time.Minute
+}
+
+// getStatus looks up the build or release a job runs for; jobs without annotations can't be related to either and return no record
+func (r *jobReaperImpl) getStatus(ctx context.Context, annotations map[string]string) (hasRecord bool, status string, err error) {
+
+	if annotations == nil || annotations["repoSource"] == "" {
+		return
+	}
+
+	repoSource := annotations["repoSource"]
+	repoOwner := annotations["repoOwner"]
+	repoName := annotations["repoName"]
+
+	if annotations["releaseID"] != "" {
+		releaseID, err := strconv.Atoi(annotations["releaseID"])
+		if err != nil {
+			return false, "", err
+		}
+		release, err := r.cockroachDBClient.GetPipelineRelease(ctx, repoSource, repoOwner, repoName, releaseID)
+		if err != nil || release == nil {
+			return false, "", err
+		}
+		return true, release.ReleaseStatus, nil
+	}
+
+	buildID, err := strconv.Atoi(annotations["buildID"])
+	if err != nil {
+		return
+	}
+	build, err := r.cockroachDBClient.GetPipelineBuildByID(ctx, repoSource, repoOwner, repoName, buildID, false)
+	if err != nil || build == nil {
+		return false, "", err
+	}
+
+	return true, build.BuildStatus, nil
+}
+
+// getJobReapReason returns why a job should be removed, either 'finished' or 'orphaned', or an empty string if it should be kept
+func getJobReapReason(job *batchv1.Job, hasRecord bool, status string, now time.Time, minAge time.Duration) string {
+
+	if job == nil || job.Metadata == nil || isYoungerThan(job.Metadata, now, minAge) {
+		return ""
+	}
+
+	if isJobFinished(job) {
+		return "finished"
+	}
+
+	annotations := job.Metadata.Annotations
+	if annotations == nil || annotations["repoSource"] == "" {
+		// an active job without annotations can't be related to a build or release, so leave it alone
+		return ""
+	}
+
+	if !hasRecord {
+		return "orphaned"
+	}
+
+	switch status {
+	case "pending", "running", "canceling":
+		return ""
+	}
+
+	return "finished"
+}
+
+// getConfigMapReapReason returns 'orphaned' for configmaps without job, or an empty string if it should be kept
+func getConfigMapReapReason(configmap *corev1.ConfigMap, hasJob bool, now time.Time, minAge time.Duration) string {
+
+	if configmap == nil || configmap.Metadata == nil || isYoungerThan(configmap.Metadata, now, minAge) || hasJob {
+		return ""
+	}
+
+	return "orphaned"
+}
+
+// isJobFinished returns true if a job completed or failed, for example because it exceeded its active deadline
+func isJobFinished(job *batchv1.Job) bool {
+	if job.GetStatus().GetCompletionTime() != nil || job.GetStatus().GetSucceeded() > 0 {
+		return true
+	}
+	for _, c := range job.GetStatus().GetConditions() {
+		if c.GetType() == "Failed" && c.GetStatus() == "True" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isYoungerThan(metadata *metav1.ObjectMeta, now time.Time, minAge time.Duration) bool {
+	createdAt := time.Unix(metadata.GetCreationTimestamp().GetSeconds(), 0)
+
+	return now.Sub(createdAt) < minAge
+}
@@ -0,0 +1,141 @@
+package estafette
+
+import (
+	"testing"
+	"time"
+
+	batchv1 "github.com/ericchiang/k8s/apis/batch/v1"
+	corev1 "github.com/ericchiang/k8s/apis/core/v1"
+	metav1 "github.com/ericchiang/k8s/apis/meta/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetJobReapReason(t *testing.T) {
+
+	now := time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+	minAge := 2 * time.Hour
+
+	newJob := func(createdAt time.Time, annotated bool) *batchv1.Job {
+		name := "build-estafette-estafette-ci-api-390605593734184965"
+		createdAtSeconds := createdAt.Unix()
+		job := &batchv1.Job{
+			Metadata: &metav1.ObjectMeta{
+				Name:              &name,
+				CreationTimestamp: &metav1.Time{Seconds: &createdAtSeconds},
+			},
+			Status: &batchv1.JobStatus{},
+		}
+		if annotated {
+			job.Metadata.Annotations = map[string]string{
+				"repoSource": "github.com",
+				"repoOwner":  "estafette",
+				"repoName":   "estafette-ci-api",
+				"buildID":    "390605593734184965",
+			}
+		}
+		return job
+	}
+
+	t.Run("ReturnsEmptyReasonForJobYoungerThanMinAge", func(t *testing.T) {
+
+		job := newJob(now.Add(-1*time.Hour), true)
+
+		// act
+		reason := getJobReapReason(job, false, "", now, minAge)
+
+		assert.Equal(t, "", reason)
+	})
+
+	t.Run("ReturnsFinishedForSucceededJob", func(t *testing.T) {
+
+		job := newJob(now.Add(-3*time.Hour), false)
+		succeeded := int32(1)
+		job.Status.Succeeded = &succeeded
+
+		// act
+		reason := getJobReapReason(job, false, "", now, minAge)
+
+		assert.Equal(t, "finished", reason)
+	})
+
+	t.Run("ReturnsFinishedForActiveJobOfFinishedBuild", func(t *testing.T) {
+
+		job := newJob(now.Add(-3*time.Hour), true)
+
+		// act
+		reason := getJobReapReason(job, true, "failed", now, minAge)
+
+		assert.Equal(t, "finished", reason)
+	})
+
+	t.Run("ReturnsEmptyReasonForActiveJobOfRunningBuild", func(t *testing.T) {
+
+		job := newJob(now.Add(-3*time.Hour), true)
+
+		// act
+		reason := getJobReapReason(job, true, "running", now, minAge)
+
+		assert.Equal(t, "", reason)
+	})
+
+	t.Run("ReturnsOrphanedForActiveJobWithoutBuild", func(t *testing.T) {
+
+		job := newJob(now.Add(-3*time.Hour), true)
+
+		// act
+		reason := getJobReapReason(job, false, "", now, minAge)
+
+		assert.Equal(t, "orphaned", reason)
+	})
+
+	t.Run("ReturnsEmptyReasonForActiveJobWithoutAnnotations", func(t *testing.T) {
+
+		job := newJob(now.Add(-3*time.Hour), false)
+
+		// act
+		reason := getJobReapReason(job, false, "", now, minAge)
+
+		assert.Equal(t, "", reason)
+	})
+}
+
+func TestGetConfigMapReapReason(t *testing.T) {
+
+	now := time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+	minAge := 2 * time.Hour
+
+	newConfigMap := func(createdAt time.Time) *corev1.ConfigMap {
+		name := "build-estafette-estafette-ci-api-390605593734184965"
+		createdAtSeconds := createdAt.Unix()
+		return &corev1.ConfigMap{
+			Metadata: &metav1.ObjectMeta{
+				Name:              &name,
+				CreationTimestamp: &metav1.Time{Seconds: &createdAtSeconds},
+			},
+		}
+	}
+
+	t.Run("ReturnsOrphanedForOldConfigMapWithoutJob", func(t *testing.T) {
+
+		// act
+		reason := getConfigMapReapReason(newConfigMap(now.Add(-3*time.Hour)), false, now, minAge)
+
+		assert.Equal(t, "orphaned", reason)
+	})
+
+	t.Run("ReturnsEmptyReasonForConfigMapWithJob", func(t *testing.T) {
+
+		// act
+		reason := getConfigMapReapReason(newConfigMap(now.Add(-3*time.Hour)), true, now, minAge)
+
+		assert.Equal(t, "", reason)
+	})
+
+	t.Run("ReturnsEmptyReasonForConfigMapYoungerThanMinAge", func(t *testing.T) {
+
+		// act
+		reason := getConfigMapReapReason(newConfigMap(now.Add(-1*time.Hour)), false, now, minAge)
+
+		assert.Equal(t, "", reason)
+	})
+}
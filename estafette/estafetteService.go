@@ -10,50 +10,116 @@ import (
 
 	"github.com/estafette/estafette-ci-api/cockroach"
 	"github.com/estafette/estafette-ci-api/config"
+	"github.com/estafette/estafette-ci-api/webhooks"
 	contracts "github.com/estafette/estafette-ci-contracts"
 	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 )
 
 // BuildService encapsulates build and release creation and re-triggering
 type BuildService interface {
 	CreateBuild(ctx context.Context, build contracts.Build, waitForJobToStart bool) (*contracts.Build, error)
+	CreateManualBuild(ctx context.Context, build contracts.Build, builderTrack string, parameters map[string]string) (*contracts.Build, error)
 	FinishBuild(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, buildStatus string) error
+	RetryPreemptedBuild(ctx context.Context, build contracts.Build, reason string) (*cockroach.BuildRetry, error)
 	CreateRelease(ctx context.Context, release contracts.Release, mft manifest.EstafetteManifest, repoBranch, repoRevision string, waitForJobToStart bool) (*contracts.Release, error)
+	CreateManualRelease(ctx context.Context, release contracts.Release, mft manifest.EstafetteManifest, repoBranch, repoRevision string, waitForJobToStart bool, parameters map[string]string) (*contracts.Release, error)
 	FinishRelease(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int, releaseStatus string) error
 
 	FireGitTriggers(ctx context.Context, gitEvent manifest.EstafetteGitEvent) error
 	FirePipelineTriggers(ctx context.Context, build contracts.Build, event string) error
 	FireReleaseTriggers(ctx context.Context, release contracts.Release, event string) error
-	FirePubSubTriggers(ctx context.Context, pubsubEvent manifest.EstafettePubSubEvent) error
+	FirePubSubTriggers(ctx context.Context, pubsubEvent manifest.EstafettePubSubEvent, message PubSubMessage) error
 	FireCronTriggers(ctx context.Context) error
 
 	Rename(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error
 }
 
 type buildServiceImpl struct {
-	jobsConfig           config.JobsConfig
-	cockroachDBClient    cockroach.DBClient
-	ciBuilderClient      CiBuilderClient
-	githubJobVarsFunc    func(context.Context, string, string, string) (string, string, error)
-	bitbucketJobVarsFunc func(context.Context, string, string, string) (string, string, error)
+	jobsConfig               config.JobsConfig
+	cockroachDBClient        cockroach.DBClient
+	ciBuilderClient          CiBuilderClient
+	resourceRecommender      ResourceRecommender
+	githubJobVarsFunc        func(context.Context, string, string, string) (string, string, error)
+	bitbucketJobVarsFunc     func(context.Context, string, string, string) (string, string, error)
+	slackBuildNotifyFunc     func(context.Context, contracts.Build) error
+	slackReleaseNotifyFunc   func(context.Context, contracts.Release) error
+	webhookNotifier          webhooks.Notifier
+	pubsubBuildPublishFunc   func(context.Context, contracts.Build, string) error
+	pubsubReleasePublishFunc func(context.Context, contracts.Release, []contracts.Label, string) error
+	jobDurationSeconds       *prometheus.HistogramVec
+	jobPendingSeconds        *prometheus.HistogramVec
+	triggerFiringTotals      *prometheus.CounterVec
 }
 
 // NewBuildService returns a new estafette.BuildService
-func NewBuildService(jobsConfig config.JobsConfig, cockroachDBClient cockroach.DBClient, ciBuilderClient CiBuilderClient, githubJobVarsFunc func(context.Context, string, string, string) (string, string, error), bitbucketJobVarsFunc func(context.Context, string, string, string) (string, string, error)) (buildService BuildService) {
+func NewBuildService(jobsConfig config.JobsConfig, cockroachDBClient cockroach.DBClient, ciBuilderClient CiBuilderClient, resourceRecommender ResourceRecommender, githubJobVarsFunc func(context.Context, string, string, string) (string, string, error), bitbucketJobVarsFunc func(context.Context, string, string, string) (string, string, error), slackBuildNotifyFunc func(context.Context, contracts.Build) error, slackReleaseNotifyFunc func(context.Context, contracts.Release) error, webhookNotifier webhooks.Notifier, pubsubBuildPublishFunc func(context.Context, contracts.Build, string) error, pubsubReleasePublishFunc func(context.Context, contracts.Release, []contracts.Label, string) error, jobDurationSeconds, jobPendingSeconds *prometheus.HistogramVec, triggerFiringTotals *prometheus.CounterVec) (buildService BuildService) {
 
 	buildService = &buildServiceImpl{
-		jobsConfig:           jobsConfig,
-		cockroachDBClient:    cockroachDBClient,
-		ciBuilderClient:      ciBuilderClient,
-		githubJobVarsFunc:    githubJobVarsFunc,
-		bitbucketJobVarsFunc: bitbucketJobVarsFunc,
+		jobsConfig:               jobsConfig,
+		cockroachDBClient:        cockroachDBClient,
+		ciBuilderClient:          ciBuilderClient,
+		resourceRecommender:      resourceRecommender,
+		githubJobVarsFunc:        githubJobVarsFunc,
+		bitbucketJobVarsFunc:     bitbucketJobVarsFunc,
+		slackBuildNotifyFunc:     slackBuildNotifyFunc,
+		slackReleaseNotifyFunc:   slackReleaseNotifyFunc,
+		webhookNotifier:          webhookNotifier,
+		pubsubBuildPublishFunc:   pubsubBuildPublishFunc,
+		pubsubReleasePublishFunc: pubsubReleasePublishFunc,
+		jobDurationSeconds:       jobDurationSeconds,
+		jobPendingSeconds:        jobPendingSeconds,
+		triggerFiringTotals:      triggerFiringTotals,
 	}
 
 	return
 }
 
 func (s *buildServiceImpl) CreateBuild(ctx context.Context, build contracts.Build, waitForJobToStart bool) (createdBuild *contracts.Build, err error) {
+	return s.createBuild(ctx, build, waitForJobToStart, nil, "")
+}
+
+// CreateManualBuild creates a build started through the api, optionally running it with another builder track than the manifest specifies; the validated parameter values get passed to the stages and stored with the build
+func (s *buildServiceImpl) CreateManualBuild(ctx context.Context, build contracts.Build, builderTrack string, parameters map[string]string) (createdBuild *contracts.Build, err error) {
+	return s.createBuildWithParameters(ctx, build, true, builderTrack, parameters)
+}
+
+// createBuildWithParameters creates a build with parameter values and an optional builder track overriding the manifest, and stores both so the build can be rerun the same way
+func (s *buildServiceImpl) createBuildWithParameters(ctx context.Context, build contracts.Build, waitForJobToStart bool, builderTrack string, parameters map[string]string) (createdBuild *contracts.Build, err error) {
+
+	createdBuild, err = s.createBuild(ctx, build, waitForJobToStart, getBuildParameterEnvironmentVariables(parameters), builderTrack)
+	if err != nil || (len(parameters) == 0 && builderTrack == "") {
+		return
+	}
+
+	buildID, err := strconv.Atoi(createdBuild.ID)
+	if err != nil {
+		return
+	}
+
+	if len(parameters) > 0 {
+		err = s.cockroachDBClient.UpdateBuildParameters(ctx, createdBuild.RepoSource, createdBuild.RepoOwner, createdBuild.RepoName, buildID, parameters)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed storing parameters for build %v/%v/%v id %v", createdBuild.RepoSource, createdBuild.RepoOwner, createdBuild.RepoName, createdBuild.ID)
+			err = nil
+		}
+	}
+
+	if builderTrack != "" {
+		err = s.cockroachDBClient.UpdateBuildBuilderTrack(ctx, createdBuild.RepoSource, createdBuild.RepoOwner, createdBuild.RepoName, buildID, builderTrack)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed storing builder track for build %v/%v/%v id %v", createdBuild.RepoSource, createdBuild.RepoOwner, createdBuild.RepoName, createdBuild.ID)
+			err = nil
+		}
+	}
+
+	return
+}
+
+// createBuild creates a build with additional environment variables for all stages, for builds fired by triggers carrying a payload, and with an optional builder track overriding the manifest
+func (s *buildServiceImpl) createBuild(ctx context.Context, build contracts.Build, waitForJobToStart bool, envvars map[string]string, builderTrackOverride string) (createdBuild *contracts.Build, err error) {
 
 	// validate manifest
 	hasValidManifest := false
@@ -77,6 +143,9 @@ func (s *buildServiceImpl) CreateBuild(ctx context.Context, build contracts.Buil
 		builderTrack = mft.Builder.Track
 		builderOperatingSystem = mft.Builder.OperatingSystem
 	}
+	if builderTrackOverride != "" {
+		builderTrack = builderTrackOverride
+	}
 
 	// get short version of repo source
 	shortRepoSource := s.getShortRepoSource(build.RepoSource)
@@ -201,43 +270,13 @@ func (s *buildServiceImpl) CreateBuild(ctx context.Context, build contracts.Buil
 		return
 	}
 
-	// define resource request and limit values to fit reasonably well inside a n1-standard-8 (8 vCPUs, 30 GB memory) machine
+	// base resource requests and limits on the usage of previous builds
+	recommendation := s.resourceRecommender.RecommendBuildResources(ctx, build.RepoSource, build.RepoOwner, build.RepoName, build.RepoBranch)
 	jobResources := cockroach.JobResources{
-		CPURequest:    s.jobsConfig.MaxCPUCores,
-		CPULimit:      s.jobsConfig.MaxCPUCores,
-		MemoryRequest: s.jobsConfig.MaxMemoryBytes,
-		MemoryLimit:   s.jobsConfig.MaxMemoryBytes,
-	}
-
-	// get max usage from previous builds
-	measuredResources, nrRecords, err := s.cockroachDBClient.GetPipelineBuildMaxResourceUtilization(ctx, build.RepoSource, build.RepoOwner, build.RepoName, 25)
-	if err != nil {
-		log.Warn().Err(err).Msgf("Failed retrieving max resource utilization for recent builds of %v/%v/%v, using defaults...", build.RepoSource, build.RepoOwner, build.RepoName)
-	} else if nrRecords < 5 {
-		log.Info().Msgf("Retrieved max resource utilization for recent builds of %v/%v/%v only has %v records, using defaults...", build.RepoSource, build.RepoOwner, build.RepoName, nrRecords)
-	} else {
-		log.Info().Msgf("Retrieved max resource utilization for recent builds of %v/%v/%v, checking if they are within lower and upper bound...", build.RepoSource, build.RepoOwner, build.RepoName)
-
-		// only override cpu and memory request values if measured values are within min and max
-		if measuredResources.CPUMaxUsage > 0 {
-			if measuredResources.CPUMaxUsage*s.jobsConfig.CPURequestRatio <= s.jobsConfig.MinCPUCores {
-				jobResources.CPURequest = s.jobsConfig.MinCPUCores
-			} else if measuredResources.CPUMaxUsage*s.jobsConfig.CPURequestRatio >= s.jobsConfig.MaxCPUCores {
-				jobResources.CPURequest = s.jobsConfig.MaxCPUCores
-			} else {
-				jobResources.CPURequest = measuredResources.CPUMaxUsage * s.jobsConfig.CPURequestRatio
-			}
-		}
-
-		if measuredResources.MemoryMaxUsage > 0 {
-			if measuredResources.MemoryMaxUsage*s.jobsConfig.MemoryRequestRatio <= s.jobsConfig.MinMemoryBytes {
-				jobResources.MemoryRequest = s.jobsConfig.MinMemoryBytes
-			} else if measuredResources.MemoryMaxUsage*s.jobsConfig.MemoryRequestRatio >= s.jobsConfig.MaxMemoryBytes {
-				jobResources.MemoryRequest = s.jobsConfig.MaxMemoryBytes
-			} else {
-				jobResources.MemoryRequest = measuredResources.MemoryMaxUsage * s.jobsConfig.MemoryRequestRatio
-			}
-		}
+		CPURequest:    recommendation.CPURequest,
+		CPULimit:      recommendation.CPULimit,
+		MemoryRequest: recommendation.MemoryRequest,
+		MemoryLimit:   recommendation.MemoryLimit,
 	}
 
 	// store build in db
@@ -265,6 +304,17 @@ func (s *buildServiceImpl) CreateBuild(ctx context.Context, build contracts.Buil
 		return
 	}
 
+	err = s.cockroachDBClient.UpdateBuildResourceRecommendation(ctx, createdBuild.RepoSource, createdBuild.RepoOwner, createdBuild.RepoName, buildID, recommendation)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed storing resource recommendation for build %v/%v/%v id %v", createdBuild.RepoSource, createdBuild.RepoOwner, createdBuild.RepoName, buildID)
+		err = nil
+	}
+
+	// make parameters and trigger payloads available to the builder as well
+	for key, value := range envvars {
+		environmentVariableWithToken[key] = value
+	}
+
 	// define ci builder params
 	ciBuilderParams := CiBuilderParams{
 		JobType:              "build",
@@ -279,10 +329,12 @@ func (s *buildServiceImpl) CreateBuild(ctx context.Context, build contracts.Buil
 		OperatingSystem:      builderOperatingSystem,
 		AutoIncrement:        autoincrement,
 		VersionNumber:        build.BuildVersion,
-		Manifest:             mft,
+		Manifest:             addGlobalEnvVars(mft, envvars),
 		BuildID:              buildID,
 		TriggeredByEvents:    build.Events,
 		JobResources:         jobResources,
+		Timeout:              getJobTimeout(s.jobsConfig, build.Manifest, ""),
+		InsertedAt:           createdBuild.InsertedAt,
 	}
 
 	// create ci builder job
@@ -310,6 +362,14 @@ func (s *buildServiceImpl) CreateBuild(ctx context.Context, build contracts.Buil
 				log.Error().Err(err).Msgf("Failed firing pipeline triggers for build %v/%v/%v revision %v", build.RepoSource, build.RepoOwner, build.RepoName, build.RepoRevision)
 			}
 		}()
+
+		// publish lifecycle event, without waiting for it and thus with a context that outlives the request
+		go func(ctx context.Context, createdBuild contracts.Build) {
+			err := s.pubsubBuildPublishFunc(ctx, createdBuild, "started")
+			if err != nil {
+				log.Error().Err(err).Msgf("Failed publishing started event for build %v/%v/%v id %v", createdBuild.RepoSource, createdBuild.RepoOwner, createdBuild.RepoName, createdBuild.ID)
+			}
+		}(getDetachedContext(ctx), *createdBuild)
 	} else if manifestError != nil {
 		log.Debug().Msgf("Pipeline %v/%v/%v revision %v with build id %v has invalid manifest, storing log...", build.RepoSource, build.RepoOwner, build.RepoName, build.RepoRevision, build.ID)
 		// store log with manifest unmarshalling error
@@ -355,24 +415,143 @@ func (s *buildServiceImpl) FinishBuild(ctx context.Context, repoSource, repoOwne
 		return err
 	}
 
-	// handle triggers
-	go func() {
-		build, err := s.cockroachDBClient.GetPipelineBuildByID(ctx, repoSource, repoOwner, repoName, buildID, false)
+	// handle triggers, notifications and lifecycle events without holding up the caller, whose context may get canceled before this is done
+	go s.handleFinishedBuild(getDetachedContext(ctx), repoSource, repoOwner, repoName, buildID, buildStatus)
+
+	return nil
+}
+
+// handleFinishedBuild fires triggers, sends notifications and publishes lifecycle events for a build status that took effect
+func (s *buildServiceImpl) handleFinishedBuild(ctx context.Context, repoSource, repoOwner, repoName string, buildID int, buildStatus string) {
+
+	build, err := s.cockroachDBClient.GetPipelineBuildByID(ctx, repoSource, repoOwner, repoName, buildID, false)
+	if err != nil || build == nil {
+		return
+	}
+
+	// the status doesn't change if the transition isn't allowed, don't act on those
+	if build.BuildStatus != buildStatus {
+		return
+	}
+
+	s.observeJobDuration("build", build.RepoOwner, build.BuildStatus, build.Duration)
+
+	// running and canceling are intermediate statuses that also pass through here
+	if !isIntermediateStatus(build.BuildStatus) {
+		err = s.FirePipelineTriggers(ctx, *build, "finished")
 		if err != nil {
-			return
+			log.Error().Err(err).Msgf("Failed firing pipeline triggers for build %v/%v/%v id %v", repoSource, repoOwner, repoName, buildID)
 		}
-		if build != nil {
-			err = s.FirePipelineTriggers(ctx, *build, "finished")
-			if err != nil {
-				log.Error().Err(err).Msgf("Failed firing pipeline triggers for build %v/%v/%v id %v", repoSource, repoOwner, repoName, buildID)
-			}
+	}
+
+	if s.slackBuildNotifyFunc != nil {
+		err = s.slackBuildNotifyFunc(ctx, *build)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed sending Slack notifications for build %v/%v/%v id %v", repoSource, repoOwner, repoName, buildID)
 		}
-	}()
+	}
 
-	return nil
+	err = s.webhookNotifier.NotifyBuild(ctx, *build)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed sending webhook notifications for build %v/%v/%v id %v", repoSource, repoOwner, repoName, buildID)
+	}
+
+	if !isIntermediateStatus(build.BuildStatus) {
+		err = s.pubsubBuildPublishFunc(ctx, *build, "finished")
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed publishing finished event for build %v/%v/%v id %v", repoSource, repoOwner, repoName, buildID)
+		}
+	}
+}
+
+// RetryPreemptedBuild reruns the version of a build lost to node preemption, unless the configured number of retries for that version is used up; then it returns nil
+func (s *buildServiceImpl) RetryPreemptedBuild(ctx context.Context, build contracts.Build, reason string) (buildRetry *cockroach.BuildRetry, err error) {
+
+	buildRetries, err := s.cockroachDBClient.GetBuildRetries(ctx, build.RepoSource, build.RepoOwner, build.RepoName, build.BuildVersion)
+	if err != nil {
+		return
+	}
+
+	if len(buildRetries) >= s.jobsConfig.PreemptionRetries {
+		log.Info().Msgf("Build %v/%v/%v version %v has been retried %v times already, not retrying again", build.RepoSource, build.RepoOwner, build.RepoName, build.BuildVersion, len(buildRetries))
+		return nil, nil
+	}
+
+	// the same preemption gets reported more than once and by every replica, so the attempt is claimed before creating the retry
+	// build; only the first claim for this build and attempt succeeds, a retry build that fails to get created still uses up the attempt
+	buildRetry, err = s.cockroachDBClient.InsertBuildRetry(ctx, cockroach.BuildRetry{
+		RepoSource:   build.RepoSource,
+		RepoOwner:    build.RepoOwner,
+		RepoName:     build.RepoName,
+		BuildVersion: build.BuildVersion,
+		BuildID:      build.ID,
+		Attempt:      len(buildRetries) + 1,
+		Reason:       reason,
+	})
+	if err != nil {
+		return
+	}
+	if buildRetry == nil {
+		log.Info().Msgf("Build %v/%v/%v id %v is being retried already", build.RepoSource, build.RepoOwner, build.RepoName, build.ID)
+		return nil, nil
+	}
+
+	buildID, err := strconv.Atoi(build.ID)
+	if err != nil {
+		return nil, err
+	}
+	parameters, err := s.cockroachDBClient.GetBuildParameters(ctx, build.RepoSource, build.RepoOwner, build.RepoName, buildID)
+	if err != nil {
+		return nil, err
+	}
+	builderTrack, err := s.cockroachDBClient.GetBuildBuilderTrack(ctx, build.RepoSource, build.RepoOwner, build.RepoName, buildID)
+	if err != nil {
+		return nil, err
+	}
+
+	// rerun the same version with the original trigger events, builder track and parameters
+	retryBuild, err := s.createBuildWithParameters(ctx, build, false, builderTrack, parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	buildRetry.RetryBuildID = retryBuild.ID
+	err = s.cockroachDBClient.UpdateBuildRetry(ctx, *buildRetry)
+	if err != nil {
+		return nil, err
+	}
+
+	return
 }
 
 func (s *buildServiceImpl) CreateRelease(ctx context.Context, release contracts.Release, mft manifest.EstafetteManifest, repoBranch, repoRevision string, waitForJobToStart bool) (createdRelease *contracts.Release, err error) {
+	return s.createRelease(ctx, release, mft, repoBranch, repoRevision, waitForJobToStart, nil)
+}
+
+// CreateManualRelease creates a release started through the api or slack; the validated parameter values get passed to the stages and stored with the release
+func (s *buildServiceImpl) CreateManualRelease(ctx context.Context, release contracts.Release, mft manifest.EstafetteManifest, repoBranch, repoRevision string, waitForJobToStart bool, parameters map[string]string) (createdRelease *contracts.Release, err error) {
+
+	createdRelease, err = s.createRelease(ctx, release, mft, repoBranch, repoRevision, waitForJobToStart, getBuildParameterEnvironmentVariables(parameters))
+	if err != nil || len(parameters) == 0 {
+		return
+	}
+
+	releaseID, err := strconv.Atoi(createdRelease.ID)
+	if err != nil {
+		return
+	}
+
+	err = s.cockroachDBClient.UpdateReleaseParameters(ctx, createdRelease.RepoSource, createdRelease.RepoOwner, createdRelease.RepoName, releaseID, parameters)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed storing parameters for release %v/%v/%v id %v", createdRelease.RepoSource, createdRelease.RepoOwner, createdRelease.RepoName, createdRelease.ID)
+		err = nil
+	}
+
+	return
+}
+
+// createRelease creates a release with additional environment variables for all stages, for releases fired by triggers carrying a payload
+func (s *buildServiceImpl) createRelease(ctx context.Context, release contracts.Release, mft manifest.EstafetteManifest, repoBranch, repoRevision string, waitForJobToStart bool, envvars map[string]string) (createdRelease *contracts.Release, err error) {
 
 	// set builder track
 	builderTrack := mft.Builder.Track
@@ -425,43 +604,13 @@ func (s *buildServiceImpl) CreateRelease(ctx context.Context, release contracts.
 		return
 	}
 
-	// define resource request and limit values to fit reasonably well inside a n1-standard-8 (8 vCPUs, 30 GB memory) machine
+	// base resource requests and limits on the usage of previous releases to the same target
+	recommendation := s.resourceRecommender.RecommendReleaseResources(ctx, release.RepoSource, release.RepoOwner, release.RepoName, release.Name)
 	jobResources := cockroach.JobResources{
-		CPURequest:    s.jobsConfig.MaxCPUCores,
-		CPULimit:      s.jobsConfig.MaxCPUCores,
-		MemoryRequest: s.jobsConfig.MaxMemoryBytes,
-		MemoryLimit:   s.jobsConfig.MaxMemoryBytes,
-	}
-
-	// get max usage from previous releases
-	measuredResources, nrRecords, err := s.cockroachDBClient.GetPipelineReleaseMaxResourceUtilization(ctx, release.RepoSource, release.RepoOwner, release.RepoName, release.Name, 25)
-	if err != nil {
-		log.Warn().Err(err).Msgf("Failed retrieving max resource utilization for recent releases of %v/%v/%v target %v, using defaults...", release.RepoSource, release.RepoOwner, release.RepoName, release.Name)
-	} else if nrRecords < 5 {
-		log.Info().Msgf("Retrieved max resource utilization for recent releases of %v/%v/%v target %v only has %v records, using defaults...", release.RepoSource, release.RepoOwner, release.RepoName, release.Name, nrRecords)
-	} else {
-		log.Info().Msgf("Retrieved max resource utilization for recent releases of %v/%v/%v target %v, checking if they are within lower and upper bound...", release.RepoSource, release.RepoOwner, release.RepoName, release.Name)
-
-		// only override cpu and memory request values if measured values are within min and max
-		if measuredResources.CPUMaxUsage > 0 {
-			if measuredResources.CPUMaxUsage*s.jobsConfig.CPURequestRatio <= s.jobsConfig.MinCPUCores {
-				jobResources.CPURequest = s.jobsConfig.MinCPUCores
-			} else if measuredResources.CPUMaxUsage*s.jobsConfig.CPURequestRatio >= s.jobsConfig.MaxCPUCores {
-				jobResources.CPURequest = s.jobsConfig.MaxCPUCores
-			} else {
-				jobResources.CPURequest = measuredResources.CPUMaxUsage * s.jobsConfig.CPURequestRatio
-			}
-		}
-
-		if measuredResources.MemoryMaxUsage > 0 {
-			if measuredResources.MemoryMaxUsage*s.jobsConfig.MemoryRequestRatio <= s.jobsConfig.MinMemoryBytes {
-				jobResources.MemoryRequest = s.jobsConfig.MinMemoryBytes
-			} else if measuredResources.MemoryMaxUsage*s.jobsConfig.MemoryRequestRatio >= s.jobsConfig.MaxMemoryBytes {
-				jobResources.MemoryRequest = s.jobsConfig.MaxMemoryBytes
-			} else {
-				jobResources.MemoryRequest = measuredResources.MemoryMaxUsage * s.jobsConfig.MemoryRequestRatio
-			}
-		}
+		CPURequest:    recommendation.CPURequest,
+		CPULimit:      recommendation.CPULimit,
+		MemoryRequest: recommendation.MemoryRequest,
+		MemoryLimit:   recommendation.MemoryLimit,
 	}
 
 	// create release in database
@@ -484,6 +633,12 @@ func (s *buildServiceImpl) CreateRelease(ctx context.Context, release contracts.
 		return
 	}
 
+	err = s.cockroachDBClient.UpdateReleaseResourceRecommendation(ctx, createdRelease.RepoSource, createdRelease.RepoOwner, createdRelease.RepoName, insertedReleaseID, recommendation)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed storing resource recommendation for release %v/%v/%v id %v", createdRelease.RepoSource, createdRelease.RepoOwner, createdRelease.RepoName, insertedReleaseID)
+		err = nil
+	}
+
 	// get triggered by from events
 	triggeredBy := ""
 	if len(release.Events) > 0 {
@@ -494,6 +649,11 @@ func (s *buildServiceImpl) CreateRelease(ctx context.Context, release contracts.
 		}
 	}
 
+	// make parameters and trigger payloads available to the builder as well
+	for key, value := range envvars {
+		environmentVariableWithToken[key] = value
+	}
+
 	// define ci builder params
 	ciBuilderParams := CiBuilderParams{
 		JobType:              "release",
@@ -508,13 +668,17 @@ func (s *buildServiceImpl) CreateRelease(ctx context.Context, release contracts.
 		OperatingSystem:      builderOperatingSystem,
 		AutoIncrement:        autoincrement,
 		VersionNumber:        release.ReleaseVersion,
-		Manifest:             mft,
+		Manifest:             addGlobalEnvVars(mft, envvars),
 		ReleaseID:            insertedReleaseID,
 		ReleaseName:          release.Name,
 		ReleaseAction:        release.Action,
 		ReleaseTriggeredBy:   triggeredBy,
 		TriggeredByEvents:    release.Events,
 		JobResources:         jobResources,
+		Timeout:              getReleaseTimeout(ctx, s.jobsConfig, s.cockroachDBClient, release),
+	}
+	if createdRelease.InsertedAt != nil {
+		ciBuilderParams.InsertedAt = *createdRelease.InsertedAt
 	}
 
 	// create ci release job
@@ -540,6 +704,18 @@ func (s *buildServiceImpl) CreateRelease(ctx context.Context, release contracts.
 		}
 	}()
 
+	// publish lifecycle event, without waiting for it and thus with a context that outlives the request
+	go func(ctx context.Context, createdRelease contracts.Release) {
+		labels := []contracts.Label{}
+		for key, value := range mft.Labels {
+			labels = append(labels, contracts.Label{Key: key, Value: value})
+		}
+		err := s.pubsubReleasePublishFunc(ctx, createdRelease, labels, "started")
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed publishing started event for release %v/%v/%v id %v", createdRelease.RepoSource, createdRelease.RepoOwner, createdRelease.RepoName, createdRelease.ID)
+		}
+	}(getDetachedContext(ctx), *createdRelease)
+
 	return
 }
 
@@ -549,21 +725,60 @@ func (s *buildServiceImpl) FinishRelease(ctx context.Context, repoSource, repoOw
 		return err
 	}
 
-	// handle triggers
-	go func() {
-		release, err := s.cockroachDBClient.GetPipelineRelease(ctx, repoSource, repoOwner, repoName, releaseID)
+	// handle triggers, notifications and lifecycle events without holding up the caller, whose context may get canceled before this is done
+	go s.handleFinishedRelease(getDetachedContext(ctx), repoSource, repoOwner, repoName, releaseID, releaseStatus)
+
+	return nil
+}
+
+// handleFinishedRelease fires triggers, sends notifications and publishes lifecycle events for a release status that took effect
+func (s *buildServiceImpl) handleFinishedRelease(ctx context.Context, repoSource, repoOwner, repoName string, releaseID int, releaseStatus string) {
+
+	release, err := s.cockroachDBClient.GetPipelineRelease(ctx, repoSource, repoOwner, repoName, releaseID)
+	if err != nil || release == nil {
+		return
+	}
+
+	// the status doesn't change if the transition isn't allowed, don't act on those
+	if release.ReleaseStatus != releaseStatus {
+		return
+	}
+
+	if release.Duration != nil {
+		s.observeJobDuration("release", release.RepoOwner, release.ReleaseStatus, *release.Duration)
+	}
+
+	// running and canceling are intermediate statuses that also pass through here
+	if !isIntermediateStatus(release.ReleaseStatus) {
+		err = s.FireReleaseTriggers(ctx, *release, "finished")
 		if err != nil {
-			return
+			log.Error().Err(err).Msgf("Failed firing release triggers for %v/%v/%v id %v", repoSource, repoOwner, repoName, releaseID)
 		}
-		if release != nil {
-			err = s.FireReleaseTriggers(ctx, *release, "finished")
-			if err != nil {
-				log.Error().Err(err).Msgf("Failed firing release triggers for %v/%v/%v id %v", repoSource, repoOwner, repoName, releaseID)
-			}
+	}
+
+	if s.slackReleaseNotifyFunc != nil {
+		err = s.slackReleaseNotifyFunc(ctx, *release)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed sending Slack notifications for release %v/%v/%v id %v", repoSource, repoOwner, repoName, releaseID)
 		}
-	}()
+	}
 
-	return nil
+	err = s.webhookNotifier.NotifyRelease(ctx, *release)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed sending webhook notifications for release %v/%v/%v id %v", repoSource, repoOwner, repoName, releaseID)
+	}
+
+	if !isIntermediateStatus(release.ReleaseStatus) {
+		var labels []contracts.Label
+		pipeline, err := s.cockroachDBClient.GetPipeline(ctx, repoSource, repoOwner, repoName, true)
+		if err == nil && pipeline != nil {
+			labels = pipeline.Labels
+		}
+		err = s.pubsubReleasePublishFunc(ctx, *release, labels, "finished")
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed publishing finished event for release %v/%v/%v id %v", repoSource, repoOwner, repoName, releaseID)
+		}
+	}
 }
 
 func (s *buildServiceImpl) FireGitTriggers(ctx context.Context, gitEvent manifest.EstafetteGitEvent) error {
@@ -602,13 +817,13 @@ func (s *buildServiceImpl) FireGitTriggers(ctx context.Context, gitEvent manifes
 				// create new build for t.Run
 				if t.BuildAction != nil {
 					log.Info().Msgf("[trigger:git(%v-%v:%v)] Firing build action '%v/%v/%v', branch '%v'...", gitEvent.Repository, gitEvent.Branch, gitEvent.Event, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
-					err := s.fireBuild(ctx, *p, t, e)
+					err := s.fireBuild(ctx, *p, t, e, nil)
 					if err != nil {
 						log.Error().Err(err).Msgf("[trigger:git(%v-%v:%v)] Failed starting build action'%v/%v/%v', branch '%v'", gitEvent.Repository, gitEvent.Branch, gitEvent.Event, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
 					}
 				} else if t.ReleaseAction != nil {
 					log.Info().Msgf("[trigger:git(%v-%v:%v)] Firing release action '%v/%v/%v', target '%v', action '%v'...", gitEvent.Repository, gitEvent.Branch, gitEvent.Event, p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action)
-					err := s.fireRelease(ctx, *p, t, e)
+					err := s.fireRelease(ctx, *p, t, e, nil)
 					if err != nil {
 						log.Error().Err(err).Msgf("[trigger:git(%v-%v:%v)] Failed starting release action '%v/%v/%v', target '%v', action '%v'", gitEvent.Repository, gitEvent.Branch, gitEvent.Event, p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action)
 					}
@@ -668,13 +883,13 @@ func (s *buildServiceImpl) FirePipelineTriggers(ctx context.Context, build contr
 				// create new build for t.Run
 				if t.BuildAction != nil {
 					log.Info().Msgf("[trigger:pipeline(%v/%v/%v:%v)] Firing build action '%v/%v/%v', branch '%v'...", build.RepoSource, build.RepoOwner, build.RepoName, event, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
-					err := s.fireBuild(ctx, *p, t, e)
+					err := s.fireBuild(ctx, *p, t, e, nil)
 					if err != nil {
 						log.Error().Err(err).Msgf("[trigger:pipeline(%v/%v/%v:%v)] Failed starting build action'%v/%v/%v', branch '%v'", build.RepoSource, build.RepoOwner, build.RepoName, event, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
 					}
 				} else if t.ReleaseAction != nil {
 					log.Info().Msgf("[trigger:pipeline(%v/%v/%v:%v)] Firing release action '%v/%v/%v', target '%v', action '%v'...", build.RepoSource, build.RepoOwner, build.RepoName, event, p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action)
-					err := s.fireRelease(ctx, *p, t, e)
+					err := s.fireRelease(ctx, *p, t, e, nil)
 					if err != nil {
 						log.Error().Err(err).Msgf("[trigger:pipeline(%v/%v/%v:%v)] Failed starting release action '%v/%v/%v', target '%v', action '%v'", build.RepoSource, build.RepoOwner, build.RepoName, event, p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action)
 					}
@@ -732,13 +947,13 @@ func (s *buildServiceImpl) FireReleaseTriggers(ctx context.Context, release cont
 
 				if t.BuildAction != nil {
 					log.Info().Msgf("[trigger:release(%v/%v/%v-%v:%v)] Firing build action '%v/%v/%v', branch '%v'...", release.RepoSource, release.RepoOwner, release.RepoName, release.Name, event, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
-					err := s.fireBuild(ctx, *p, t, e)
+					err := s.fireBuild(ctx, *p, t, e, nil)
 					if err != nil {
 						log.Error().Err(err).Msgf("[trigger:release(%v/%v/%v-%v:%v)] Failed starting build action '%v/%v/%v', branch '%v'", release.RepoSource, release.RepoOwner, release.RepoName, release.Name, event, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
 					}
 				} else if t.ReleaseAction != nil {
 					log.Info().Msgf("[trigger:release(%v/%v/%v-%v:%v)] Firing release action '%v/%v/%v', target '%v', action '%v'...", release.RepoSource, release.RepoOwner, release.RepoName, release.Name, event, p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action)
-					err := s.fireRelease(ctx, *p, t, e)
+					err := s.fireRelease(ctx, *p, t, e, nil)
 					if err != nil {
 						log.Error().Err(err).Msgf("[trigger:release(%v/%v/%v-%v:%v)] Failed starting release action '%v/%v/%v', target '%v', action '%v'", release.RepoSource, release.RepoOwner, release.RepoName, release.Name, event, p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action)
 					}
@@ -752,7 +967,7 @@ func (s *buildServiceImpl) FireReleaseTriggers(ctx context.Context, release cont
 	return nil
 }
 
-func (s *buildServiceImpl) FirePubSubTriggers(ctx context.Context, pubsubEvent manifest.EstafettePubSubEvent) error {
+func (s *buildServiceImpl) FirePubSubTriggers(ctx context.Context, pubsubEvent manifest.EstafettePubSubEvent, message PubSubMessage) error {
 
 	log.Info().Msgf("[trigger:pubsub(projects/%v/topics/%v)] Checking if triggers need to be fired...", pubsubEvent.Project, pubsubEvent.Topic)
 
@@ -766,11 +981,20 @@ func (s *buildServiceImpl) FirePubSubTriggers(ctx context.Context, pubsubEvent m
 		PubSub: &pubsubEvent,
 	}
 
+	envvars := getPubSubEnvironmentVariables(pubsubEvent, message)
+
 	triggerCount := 0
 	firedTriggerCount := 0
 
+	// failures are collected while other matching triggers still fire
+	failedTriggers := []string{}
+
 	// check for each trigger whether it should fire
 	for _, p := range pipelines {
+
+		// keeps track of triggers for the same project and topic per build or release target, to find their filter in the raw manifest
+		occurrences := map[string]int{}
+
 		for _, t := range p.Triggers {
 
 			log.Debug().Interface("event", pubsubEvent).Interface("trigger", t).Msgf("[trigger:pubsub(projects/%v/topics/%v)] Checking if pipeline '%v/%v/%v' trigger should fire...", pubsubEvent.Project, pubsubEvent.Topic, p.RepoSource, p.RepoOwner, p.RepoName)
@@ -781,22 +1005,47 @@ func (s *buildServiceImpl) FirePubSubTriggers(ctx context.Context, pubsubEvent m
 
 			triggerCount++
 
+			target := ""
+			if t.ReleaseAction != nil {
+				target = t.ReleaseAction.Target
+			}
+			occurrenceKey := strings.ToLower(fmt.Sprintf("%v/%v/%v", target, t.PubSub.Project, t.PubSub.Topic))
+			occurrence := occurrences[occurrenceKey]
+			occurrences[occurrenceKey]++
+
 			if t.PubSub.Fires(&pubsubEvent) {
 
+				filter, err := getPubSubTriggerFilter(p.Manifest, target, *t.PubSub, occurrence)
+				if err != nil {
+					log.Warn().Err(err).Msgf("[trigger:pubsub(projects/%v/topics/%v)] Failed reading trigger filter for pipeline '%v/%v/%v', skipping trigger", pubsubEvent.Project, pubsubEvent.Topic, p.RepoSource, p.RepoOwner, p.RepoName)
+					continue
+				}
+				matches, err := matchesPubSubFilter(filter, message)
+				if err != nil {
+					log.Warn().Err(err).Msgf("[trigger:pubsub(projects/%v/topics/%v)] Failed evaluating trigger filter for pipeline '%v/%v/%v', skipping trigger", pubsubEvent.Project, pubsubEvent.Topic, p.RepoSource, p.RepoOwner, p.RepoName)
+					continue
+				}
+				if !matches {
+					log.Debug().Msgf("[trigger:pubsub(projects/%v/topics/%v)] Message doesn't match filter '%v' for pipeline '%v/%v/%v', skipping trigger", pubsubEvent.Project, pubsubEvent.Topic, filter, p.RepoSource, p.RepoOwner, p.RepoName)
+					continue
+				}
+
 				firedTriggerCount++
 
 				// create new build for t.Run
 				if t.BuildAction != nil {
 					log.Info().Msgf("[trigger:pubsub(projects/%v/topics/%v)] Firing build action '%v/%v/%v', branch '%v'...", pubsubEvent.Project, pubsubEvent.Topic, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
-					err := s.fireBuild(ctx, *p, t, e)
+					err := s.fireBuild(ctx, *p, t, e, envvars)
 					if err != nil {
 						log.Error().Err(err).Msgf("[trigger:pubsub(projects/%v/topics/%v)] Failed starting build action'%v/%v/%v', branch '%v'", pubsubEvent.Project, pubsubEvent.Topic, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
+						failedTriggers = append(failedTriggers, fmt.Sprintf("build action '%v/%v/%v', branch '%v': %v", p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch, err))
 					}
 				} else if t.ReleaseAction != nil {
 					log.Info().Msgf("[trigger:pubsub(projects/%v/topics/%v)] Firing release action '%v/%v/%v', target '%v', action '%v'...", pubsubEvent.Project, pubsubEvent.Topic, p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action)
-					err := s.fireRelease(ctx, *p, t, e)
+					err := s.fireRelease(ctx, *p, t, e, envvars)
 					if err != nil {
 						log.Error().Err(err).Msgf("[trigger:pubsub(projects/%v/topics/%v)] Failed starting release action '%v/%v/%v', target '%v', action '%v'", pubsubEvent.Project, pubsubEvent.Topic, p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action)
+						failedTriggers = append(failedTriggers, fmt.Sprintf("release action '%v/%v/%v', target '%v', action '%v': %v", p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action, err))
 					}
 				}
 			}
@@ -805,7 +1054,23 @@ func (s *buildServiceImpl) FirePubSubTriggers(ctx context.Context, pubsubEvent m
 
 	log.Info().Msgf("[trigger:pubsub(projects/%v/topics/%v)] Fired %v out of %v triggers for %v pipelines", pubsubEvent.Project, pubsubEvent.Topic, firedTriggerCount, triggerCount, len(pipelines))
 
-	return nil
+	return getPubSubTriggersError(pubsubEvent, firedTriggerCount, failedTriggers)
+}
+
+// getPubSubTriggersError returns an error to have the message redelivered only if none of the matching triggers fired; after a partial
+// failure a redelivery would fire the triggers that succeeded again, so the failed ones only get logged
+func getPubSubTriggersError(pubsubEvent manifest.EstafettePubSubEvent, firedTriggerCount int, failedTriggers []string) error {
+
+	if len(failedTriggers) == 0 {
+		return nil
+	}
+
+	if len(failedTriggers) < firedTriggerCount {
+		log.Error().Msgf("[trigger:pubsub(projects/%v/topics/%v)] Failed firing %v out of %v triggers, not redelivering the message since the other triggers fired: %v", pubsubEvent.Project, pubsubEvent.Topic, len(failedTriggers), firedTriggerCount, strings.Join(failedTriggers, "; "))
+		return nil
+	}
+
+	return fmt.Errorf("[trigger:pubsub(projects/%v/topics/%v)] Failed firing %v out of %v triggers: %v", pubsubEvent.Project, pubsubEvent.Topic, len(failedTriggers), firedTriggerCount, strings.Join(failedTriggers, "; "))
 }
 
 func (s *buildServiceImpl) FireCronTriggers(ctx context.Context) error {
@@ -847,13 +1112,13 @@ func (s *buildServiceImpl) FireCronTriggers(ctx context.Context) error {
 				// create new build for t.Run
 				if t.BuildAction != nil {
 					log.Info().Msgf("[trigger:cron(%v)] Firing build action '%v/%v/%v', branch '%v'...", ce.Time, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
-					err := s.fireBuild(ctx, *p, t, e)
+					err := s.fireBuild(ctx, *p, t, e, nil)
 					if err != nil {
 						log.Error().Err(err).Msgf("[trigger:cron(%v)] Failed starting build action'%v/%v/%v', branch '%v'", ce.Time, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
 					}
 				} else if t.ReleaseAction != nil {
 					log.Info().Msgf("[trigger:cron(%v)] Firing release action '%v/%v/%v', target '%v', action '%v'...", ce.Time, p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action)
-					err := s.fireRelease(ctx, *p, t, e)
+					err := s.fireRelease(ctx, *p, t, e, nil)
 					if err != nil {
 						log.Error().Err(err).Msgf("[trigger:cron(%v)] Failed starting release action '%v/%v/%v', target '%v', action '%v'", ce.Time, p.RepoSource, p.RepoOwner, p.RepoName, t.ReleaseAction.Target, t.ReleaseAction.Action)
 					}
@@ -867,11 +1132,13 @@ func (s *buildServiceImpl) FireCronTriggers(ctx context.Context) error {
 	return nil
 }
 
-func (s *buildServiceImpl) fireBuild(ctx context.Context, p contracts.Pipeline, t manifest.EstafetteTrigger, e manifest.EstafetteEvent) error {
+func (s *buildServiceImpl) fireBuild(ctx context.Context, p contracts.Pipeline, t manifest.EstafetteTrigger, e manifest.EstafetteEvent, envvars map[string]string) error {
 	if t.BuildAction == nil {
 		return fmt.Errorf("Trigger to fire does not have a 'builds' property, shouldn't get to here")
 	}
 
+	s.triggerFiringTotals.With(prometheus.Labels{"trigger": getEventType(e), "action": "build"}).Inc()
+
 	// get last build for branch defined in 'builds' section
 	lastBuildForBranch, err := s.cockroachDBClient.GetLastPipelineBuildForBranch(ctx, p.RepoSource, p.RepoOwner, p.RepoName, t.BuildAction.Branch)
 
@@ -885,18 +1152,20 @@ func (s *buildServiceImpl) fireBuild(ctx context.Context, p contracts.Pipeline,
 	// set event that triggers the build
 	lastBuildForBranch.Events = []manifest.EstafetteEvent{e}
 
-	_, err = s.CreateBuild(ctx, *lastBuildForBranch, true)
+	_, err = s.createBuild(ctx, *lastBuildForBranch, true, envvars, "")
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *buildServiceImpl) fireRelease(ctx context.Context, p contracts.Pipeline, t manifest.EstafetteTrigger, e manifest.EstafetteEvent) error {
+func (s *buildServiceImpl) fireRelease(ctx context.Context, p contracts.Pipeline, t manifest.EstafetteTrigger, e manifest.EstafetteEvent, envvars map[string]string) error {
 	if t.ReleaseAction == nil {
 		return fmt.Errorf("Trigger to fire does not have a 'releases' property, shouldn't get to here")
 	}
 
+	s.triggerFiringTotals.With(prometheus.Labels{"trigger": getEventType(e), "action": "release"}).Inc()
+
 	// determine version to release
 	versionToRelease := p.BuildVersion
 
@@ -924,7 +1193,7 @@ func (s *buildServiceImpl) fireRelease(ctx context.Context, p contracts.Pipeline
 		versionToRelease = t.ReleaseAction.Version
 	}
 
-	_, err := s.CreateRelease(ctx, contracts.Release{
+	_, err := s.createRelease(ctx, contracts.Release{
 		Name:           t.ReleaseAction.Target,
 		Action:         t.ReleaseAction.Action,
 		RepoSource:     p.RepoSource,
@@ -932,13 +1201,56 @@ func (s *buildServiceImpl) fireRelease(ctx context.Context, p contracts.Pipeline
 		RepoName:       p.RepoName,
 		ReleaseVersion: versionToRelease,
 		Events:         []manifest.EstafetteEvent{e},
-	}, *p.ManifestObject, p.RepoBranch, p.RepoRevision, true)
+	}, *p.ManifestObject, p.RepoBranch, p.RepoRevision, true, envvars)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// observeJobDuration records how long a build or release waited for its job to start running, or how long it took in total once it's finished
+func (s *buildServiceImpl) observeJobDuration(jobType, repoOwner, status string, duration time.Duration) {
+	switch status {
+	case "running":
+		// at the transition to running the duration is the time spent pending
+		s.jobPendingSeconds.With(prometheus.Labels{"type": jobType}).Observe(duration.Seconds())
+	case "succeeded", "failed", "canceled", "timedout":
+		s.jobDurationSeconds.With(prometheus.Labels{"type": jobType, "status": status, "repo_owner": repoOwner}).Observe(duration.Seconds())
+	}
+}
+
+// getDetachedContext returns a context that keeps the span of ctx, but isn't canceled along with it, for work that continues after a request has been handled
+func getDetachedContext(ctx context.Context) context.Context {
+	return opentracing.ContextWithSpan(context.Background(), opentracing.SpanFromContext(ctx))
+}
+
+// isIntermediateStatus returns true for the statuses a build or release passes through before it's finished
+func isIntermediateStatus(status string) bool {
+	return status == "pending" || status == "running" || status == "canceling"
+}
+
+// getEventType returns the type of event firing a trigger
+func getEventType(e manifest.EstafetteEvent) string {
+	switch {
+	case e.Pipeline != nil:
+		return "pipeline"
+	case e.Release != nil:
+		return "release"
+	case e.Git != nil:
+		return "git"
+	case e.Docker != nil:
+		return "docker"
+	case e.Cron != nil:
+		return "cron"
+	case e.PubSub != nil:
+		return "pubsub"
+	case e.Manual != nil:
+		return "manual"
+	}
+
+	return "unknown"
+}
+
 func (s *buildServiceImpl) getShortRepoSource(repoSource string) string {
 
 	repoSourceArray := strings.Split(repoSource, ".")
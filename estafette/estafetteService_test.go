@@ -0,0 +1,170 @@
+package estafette
+
+import (
+	"context"
+	"testing"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/estafette/estafette-ci-api/webhooks"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEventType(t *testing.T) {
+
+	t.Run("ReturnsTypeOfSetEvent", func(t *testing.T) {
+
+		// act
+		eventTypes := []string{
+			getEventType(manifest.EstafetteEvent{Pipeline: &manifest.EstafettePipelineEvent{}}),
+			getEventType(manifest.EstafetteEvent{Release: &manifest.EstafetteReleaseEvent{}}),
+			getEventType(manifest.EstafetteEvent{Git: &manifest.EstafetteGitEvent{}}),
+			getEventType(manifest.EstafetteEvent{Cron: &manifest.EstafetteCronEvent{}}),
+			getEventType(manifest.EstafetteEvent{PubSub: &manifest.EstafettePubSubEvent{}}),
+		}
+
+		assert.Equal(t, []string{"pipeline", "release", "git", "cron", "pubsub"}, eventTypes)
+	})
+
+	t.Run("ReturnsUnknownWithoutEvent", func(t *testing.T) {
+
+		// act
+		eventType := getEventType(manifest.EstafetteEvent{})
+
+		assert.Equal(t, "unknown", eventType)
+	})
+}
+
+func TestGetDetachedContext(t *testing.T) {
+
+	t.Run("IsNotCanceledWithParentContext", func(t *testing.T) {
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// act
+		detachedCtx := getDetachedContext(ctx)
+		cancel()
+
+		assert.NotNil(t, ctx.Err())
+		assert.Nil(t, detachedCtx.Err())
+	})
+}
+
+// fakeWebhookNotifier records the builds it gets asked to notify subscribers about
+type fakeWebhookNotifier struct {
+	webhooks.Notifier
+	builds []contracts.Build
+}
+
+func (f *fakeWebhookNotifier) NotifyBuild(ctx context.Context, build contracts.Build) error {
+	f.builds = append(f.builds, build)
+	return nil
+}
+
+func TestHandleFinishedBuild(t *testing.T) {
+
+	getService := func(build *contracts.Build, webhookNotifier *fakeWebhookNotifier, publishedEvents *[]string) *buildServiceImpl {
+		return &buildServiceImpl{
+			cockroachDBClient: &fakeDBClient{build: build},
+			webhookNotifier:   webhookNotifier,
+			pubsubBuildPublishFunc: func(ctx context.Context, build contracts.Build, event string) error {
+				*publishedEvents = append(*publishedEvents, event)
+				return nil
+			},
+		}
+	}
+
+	t.Run("SkipsNotificationsWhenStatusTransitionWasRejected", func(t *testing.T) {
+
+		webhookNotifier := &fakeWebhookNotifier{}
+		publishedEvents := []string{}
+		service := getService(&contracts.Build{ID: "15", BuildStatus: "succeeded"}, webhookNotifier, &publishedEvents)
+
+		// act
+		service.handleFinishedBuild(context.Background(), "github.com", "estafette", "estafette-ci-api", 15, "canceling")
+
+		assert.Equal(t, 0, len(webhookNotifier.builds))
+		assert.Equal(t, 0, len(publishedEvents))
+	})
+
+	t.Run("SkipsFinishedTriggersAndEventForCanceling", func(t *testing.T) {
+
+		webhookNotifier := &fakeWebhookNotifier{}
+		publishedEvents := []string{}
+		service := getService(&contracts.Build{ID: "15", BuildStatus: "canceling"}, webhookNotifier, &publishedEvents)
+
+		// act
+		service.handleFinishedBuild(context.Background(), "github.com", "estafette", "estafette-ci-api", 15, "canceling")
+
+		if assert.Equal(t, 1, len(webhookNotifier.builds)) {
+			assert.Equal(t, "canceling", webhookNotifier.builds[0].BuildStatus)
+		}
+		assert.Equal(t, 0, len(publishedEvents))
+	})
+}
+
+func TestGetPubSubTriggersError(t *testing.T) {
+
+	pubsubEvent := manifest.EstafettePubSubEvent{Project: "my-project", Topic: "my-topic"}
+
+	t.Run("ReturnsNilIfAllTriggersFired", func(t *testing.T) {
+
+		// act
+		err := getPubSubTriggersError(pubsubEvent, 2, []string{})
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsNilIfSomeTriggersFailedAfterOthersFired", func(t *testing.T) {
+
+		// act
+		err := getPubSubTriggersError(pubsubEvent, 3, []string{"build action 'github.com/estafette/estafette-ci-api', branch 'master': failed"})
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfAllTriggersFailed", func(t *testing.T) {
+
+		// act
+		err := getPubSubTriggersError(pubsubEvent, 2, []string{"build action 'github.com/estafette/estafette-ci-api', branch 'master': failed", "release action 'github.com/estafette/estafette-ci-api', target 'production', action '': failed"})
+
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "Failed firing 2 out of 2 triggers")
+		}
+	})
+}
+
+func TestRetryPreemptedBuild(t *testing.T) {
+
+	build := contracts.Build{ID: "15", RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", BuildVersion: "1.0.3"}
+
+	t.Run("SkipsRetryWhenBuildIsBeingRetriedAlready", func(t *testing.T) {
+
+		dbClient := &fakeDBClient{}
+		service := &buildServiceImpl{jobsConfig: config.JobsConfig{PreemptionRetries: 3}, cockroachDBClient: dbClient}
+		// another report of the same preemption claimed the attempt after this one counted the retries
+		dbClient.buildRetries = []*cockroach.BuildRetry{&cockroach.BuildRetry{BuildID: "15", Attempt: 1}}
+
+		// act
+		buildRetry, err := service.RetryPreemptedBuild(context.Background(), build, "node preempted")
+
+		assert.Nil(t, err)
+		assert.Nil(t, buildRetry)
+		assert.Equal(t, 1, len(dbClient.buildRetries))
+	})
+
+	t.Run("SkipsRetryWhenRetriesAreUsedUp", func(t *testing.T) {
+
+		dbClient := &fakeDBClient{buildRetries: []*cockroach.BuildRetry{&cockroach.BuildRetry{BuildID: "14", Attempt: 1}}}
+		service := &buildServiceImpl{jobsConfig: config.JobsConfig{PreemptionRetries: 1}, cockroachDBClient: dbClient}
+
+		// act
+		buildRetry, err := service.RetryPreemptedBuild(context.Background(), build, "node preempted")
+
+		assert.Nil(t, err)
+		assert.Nil(t, buildRetry)
+		assert.Equal(t, 1, len(dbClient.buildRetries))
+	})
+}
@@ -0,0 +1,208 @@
+package estafette
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	batchv1 "github.com/ericchiang/k8s/apis/batch/v1"
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+// StaleStatusSweeper reconciles the status of builds and releases stuck in pending, running or canceling with the state of their job
+type StaleStatusSweeper interface {
+	Sweep(ctx context.Context) error
+	Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
+}
+
+type staleStatusSweeperImpl struct {
+	jobsConfig        config.JobsConfig
+	buildService      BuildService
+	ciBuilderClient   CiBuilderClient
+	cockroachDBClient cockroach.DBClient
+}
+
+// NewStaleStatusSweeper returns a new estafette.StaleStatusSweeper
+func NewStaleStatusSweeper(jobsConfig config.JobsConfig, buildService BuildService, ciBuilderClient CiBuilderClient, cockroachDBClient cockroach.DBClient) StaleStatusSweeper {
+	return &staleStatusSweeperImpl{
+		jobsConfig:        jobsConfig,
+		buildService:      buildService,
+		ciBuilderClient:   ciBuilderClient,
+		cockroachDBClient: cockroachDBClient,
+	}
+}
+
+// Sweep checks the job of each build and release that has had its status for longer than the threshold for that status and updates the status if the job no longer runs
+func (s *staleStatusSweeperImpl) Sweep(ctx context.Context) (err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "StaleStatusSweeper::Sweep")
+	defer span.Finish()
+
+	now := time.Now().UTC()
+
+	for _, status := range []string{"pending", "running", "canceling"} {
+		threshold := getStaleThreshold(s.jobsConfig, status)
+
+		// the status can't have been set before the build or release got inserted
+		builds, err := s.cockroachDBClient.GetBuildsByStatus(ctx, []string{status}, now.Add(-threshold))
+		if err != nil {
+			return err
+		}
+
+		for _, b := range builds {
+			if now.Sub(b.UpdatedAt) < threshold {
+				continue
+			}
+
+			buildID, err := strconv.Atoi(b.ID)
+			if err != nil {
+				log.Warn().Err(err).Msgf("Failed parsing id %v of build %v/%v/%v", b.ID, b.RepoSource, b.RepoOwner, b.RepoName)
+				continue
+			}
+
+			jobName := s.ciBuilderClient.GetJobName("build", b.RepoOwner, b.RepoName, b.ID)
+			reconciledStatus, err := s.reconcileJob(ctx, jobName, status)
+			if err != nil || reconciledStatus == "" {
+				continue
+			}
+
+			log.Info().Msgf("Build %v/%v/%v id %v has been %v for over %v, setting status %v to match job %v", b.RepoSource, b.RepoOwner, b.RepoName, b.ID, status, threshold, reconciledStatus, jobName)
+			err = s.buildService.FinishBuild(ctx, b.RepoSource, b.RepoOwner, b.RepoName, buildID, reconciledStatus)
+			if err != nil {
+				log.Warn().Err(err).Msgf("Failed setting status %v for build %v/%v/%v id %v", reconciledStatus, b.RepoSource, b.RepoOwner, b.RepoName, b.ID)
+			}
+		}
+
+		releases, err := s.cockroachDBClient.GetReleasesByStatus(ctx, []string{status}, now.Add(-threshold))
+		if err != nil {
+			return err
+		}
+
+		for _, r := range releases {
+			if r.UpdatedAt != nil && now.Sub(*r.UpdatedAt) < threshold {
+				continue
+			}
+
+			releaseID, err := strconv.Atoi(r.ID)
+			if err != nil {
+				log.Warn().Err(err).Msgf("Failed parsing id %v of release %v/%v/%v", r.ID, r.RepoSource, r.RepoOwner, r.RepoName)
+				continue
+			}
+
+			jobName := s.ciBuilderClient.GetJobName("release", r.RepoOwner, r.RepoName, r.ID)
+			reconciledStatus, err := s.reconcileJob(ctx, jobName, status)
+			if err != nil || reconciledStatus == "" {
+				continue
+			}
+
+			log.Info().Msgf("Release %v/%v/%v id %v to %v has been %v for over %v, setting status %v to match job %v", r.RepoSource, r.RepoOwner, r.RepoName, r.ID, r.Name, status, threshold, reconciledStatus, jobName)
+			err = s.buildService.FinishRelease(ctx, r.RepoSource, r.RepoOwner, r.RepoName, releaseID, reconciledStatus)
+			if err != nil {
+				log.Warn().Err(err).Msgf("Failed setting status %v for release %v/%v/%v id %v", reconciledStatus, r.RepoSource, r.RepoOwner, r.RepoName, r.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run sweeps at the configured interval on the replica holding the stale status sweeper lease until the stop channel gets closed
+func (s *staleStatusSweeperImpl) Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) {
+
+	interval := time.Duration(s.jobsConfig.StaleSweepMinutes) * time.Minute
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+
+		for {
+			select {
+			case <-time.After(interval):
+				// only one replica sweeps, so the same jobs don't get canceled from every replica
+				acquired, err := s.cockroachDBClient.AcquireLease(context.Background(), "stale-status-sweeper", 2*interval)
+				if err != nil {
+					log.Error().Err(err).Msg("Failed acquiring lease for sweeping builds and releases with stale status")
+					continue
+				}
+				if !acquired {
+					continue
+				}
+				err = s.Sweep(context.Background())
+				if err != nil {
+					log.Error().Err(err).Msg("Failed sweeping builds and releases with stale status")
+				}
+			case <-stopChannel:
+				log.Debug().Msg("Stopping stale status sweeper...")
+				return
+			}
+		}
+	}()
+}
+
+// reconcileJob returns the status matching the state of the job, canceling the job if it still runs for a build or release that's being canceled
+func (s *staleStatusSweeperImpl) reconcileJob(ctx context.Context, jobName, status string) (reconciledStatus string, err error) {
+
+	job, err := s.ciBuilderClient.GetCiBuilderJob(ctx, jobName)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed retrieving job %v", jobName)
+		return
+	}
+
+	reconciledStatus = getReconciledStatus(status, job)
+
+	if reconciledStatus == "canceled" && job != nil && !isJobFinished(job) {
+		err = s.ciBuilderClient.CancelCiBuilderJob(ctx, jobName)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed canceling job %v", jobName)
+			return "", err
+		}
+	}
+
+	return
+}
+
+// getStaleThreshold returns how long a build or release can have a status before its job gets checked
+func getStaleThreshold(jobsConfig config.JobsConfig, status string) time.Duration {
+
+	switch status {
+	case "pending":
+		if jobsConfig.StalePendingMinutes > 0 {
+			return time.Duration(jobsConfig.StalePendingMinutes) * time.Minute
+		}
+		return 30 * time.Minute
+	case "running":
+		if jobsConfig.StaleRunningMinutes > 0 {
+			return time.Duration(jobsConfig.StaleRunningMinutes) * time.Minute
+		}
+		return 15 * time.Minute
+	}
+
+	if jobsConfig.StaleCancelingMinutes > 0 {
+		return time.Duration(jobsConfig.StaleCancelingMinutes) * time.Minute
+	}
+
+	return 5 * time.Minute
+}
+
+// getReconciledStatus returns the status a stale build or release should get given its job, nil if the job doesn't exist, or an empty string if it should be left alone
+func getReconciledStatus(status string, job *batchv1.Job) string {
+
+	// the builder didn't report cancellation, the job gets canceled again if it still runs
+	if status == "canceling" {
+		return "canceled"
+	}
+
+	// the outcome of a job that is gone or finished without its builder reporting it is unknown, so it can't count as succeeded
+	if job == nil || isJobFinished(job) {
+		return "failed"
+	}
+
+	return ""
+}
@@ -0,0 +1,89 @@
+package estafette
+
+import (
+	"testing"
+	"time"
+
+	batchv1 "github.com/ericchiang/k8s/apis/batch/v1"
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReconciledStatus(t *testing.T) {
+
+	activeJob := func() *batchv1.Job {
+		active := int32(1)
+		return &batchv1.Job{Status: &batchv1.JobStatus{Active: &active}}
+	}
+
+	failedJob := func() *batchv1.Job {
+		conditionType := "Failed"
+		conditionStatus := "True"
+		return &batchv1.Job{Status: &batchv1.JobStatus{Conditions: []*batchv1.JobCondition{&batchv1.JobCondition{Type: &conditionType, Status: &conditionStatus}}}}
+	}
+
+	t.Run("ReturnsCanceledForCancelingWithActiveJob", func(t *testing.T) {
+
+		// act
+		status := getReconciledStatus("canceling", activeJob())
+
+		assert.Equal(t, "canceled", status)
+	})
+
+	t.Run("ReturnsCanceledForCancelingWithoutJob", func(t *testing.T) {
+
+		// act
+		status := getReconciledStatus("canceling", nil)
+
+		assert.Equal(t, "canceled", status)
+	})
+
+	t.Run("ReturnsFailedForPendingWithoutJob", func(t *testing.T) {
+
+		// act
+		status := getReconciledStatus("pending", nil)
+
+		assert.Equal(t, "failed", status)
+	})
+
+	t.Run("ReturnsFailedForRunningWithFailedJob", func(t *testing.T) {
+
+		// act
+		status := getReconciledStatus("running", failedJob())
+
+		assert.Equal(t, "failed", status)
+	})
+
+	t.Run("ReturnsEmptyStatusForRunningWithActiveJob", func(t *testing.T) {
+
+		// act
+		status := getReconciledStatus("running", activeJob())
+
+		assert.Equal(t, "", status)
+	})
+}
+
+func TestGetStaleThreshold(t *testing.T) {
+
+	t.Run("ReturnsConfiguredThresholdForStatus", func(t *testing.T) {
+
+		jobsConfig := config.JobsConfig{StalePendingMinutes: 45, StaleRunningMinutes: 20, StaleCancelingMinutes: 3}
+
+		// act
+		pending := getStaleThreshold(jobsConfig, "pending")
+		running := getStaleThreshold(jobsConfig, "running")
+		canceling := getStaleThreshold(jobsConfig, "canceling")
+
+		assert.Equal(t, 45*time.Minute, pending)
+		assert.Equal(t, 20*time.Minute, running)
+		assert.Equal(t, 3*time.Minute, canceling)
+	})
+
+	t.Run("ReturnsDefaultThresholdIfNotConfigured", func(t *testing.T) {
+
+		// act
+		canceling := getStaleThreshold(config.JobsConfig{}, "canceling")
+
+		assert.Equal(t, 5*time.Minute, canceling)
+	})
+}
@@ -0,0 +1,155 @@
+package estafette
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+// TimeoutSweeper marks builds and releases that run longer than their timeout as timed out and cancels their jobs
+type TimeoutSweeper interface {
+	Sweep(ctx context.Context) error
+	Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
+}
+
+type timeoutSweeperImpl struct {
+	jobsConfig        config.JobsConfig
+	buildService      BuildService
+	ciBuilderClient   CiBuilderClient
+	cockroachDBClient cockroach.DBClient
+}
+
+// NewTimeoutSweeper returns a new estafette.TimeoutSweeper
+func NewTimeoutSweeper(jobsConfig config.JobsConfig, buildService BuildService, ciBuilderClient CiBuilderClient, cockroachDBClient cockroach.DBClient) TimeoutSweeper {
+	return &timeoutSweeperImpl{
+		jobsConfig:        jobsConfig,
+		buildService:      buildService,
+		ciBuilderClient:   ciBuilderClient,
+		cockroachDBClient: cockroachDBClient,
+	}
+}
+
+// Sweep sets status timedout for all pending and running builds and releases that exceeded their timeout, which fires their triggers and notifications, and cancels their jobs
+func (s *timeoutSweeperImpl) Sweep(ctx context.Context) (err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "TimeoutSweeper::Sweep")
+	defer span.Finish()
+
+	now := time.Now().UTC()
+
+	builds, err := s.cockroachDBClient.GetBuildsByStatus(ctx, []string{"pending", "running"}, now)
+	if err != nil {
+		return
+	}
+
+	for _, b := range builds {
+		timeout := getJobTimeout(s.jobsConfig, b.Manifest, "")
+		if !isOverdue(b.InsertedAt, now, timeout) {
+			continue
+		}
+
+		buildID, err := strconv.Atoi(b.ID)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed parsing id %v of build %v/%v/%v", b.ID, b.RepoSource, b.RepoOwner, b.RepoName)
+			continue
+		}
+
+		log.Info().Msgf("Build %v/%v/%v id %v exceeded its timeout of %v, marking it as timed out...", b.RepoSource, b.RepoOwner, b.RepoName, b.ID, timeout)
+		err = s.buildService.FinishBuild(ctx, b.RepoSource, b.RepoOwner, b.RepoName, buildID, "timedout")
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed marking build %v/%v/%v id %v as timed out", b.RepoSource, b.RepoOwner, b.RepoName, b.ID)
+			continue
+		}
+
+		// the job might already be removed by kubernetes for exceeding its active deadline
+		jobName := s.ciBuilderClient.GetJobName("build", b.RepoOwner, b.RepoName, b.ID)
+		err = s.ciBuilderClient.CancelCiBuilderJob(ctx, jobName)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed canceling job %v for timed out build", jobName)
+		}
+	}
+
+	// the manifests of the released builds come along, so timeouts don't need a lookup per release
+	releases, err := s.cockroachDBClient.GetReleasesWithManifestByStatus(ctx, []string{"pending", "running"}, now)
+	if err != nil {
+		return
+	}
+
+	for _, rm := range releases {
+		r := rm.Release
+		if r.InsertedAt == nil {
+			continue
+		}
+		timeout := getJobTimeout(s.jobsConfig, rm.Manifest, r.Name)
+		if !isOverdue(*r.InsertedAt, now, timeout) {
+			continue
+		}
+
+		releaseID, err := strconv.Atoi(r.ID)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed parsing id %v of release %v/%v/%v", r.ID, r.RepoSource, r.RepoOwner, r.RepoName)
+			continue
+		}
+
+		log.Info().Msgf("Release %v/%v/%v id %v to %v exceeded its timeout of %v, marking it as timed out...", r.RepoSource, r.RepoOwner, r.RepoName, r.ID, r.Name, timeout)
+		err = s.buildService.FinishRelease(ctx, r.RepoSource, r.RepoOwner, r.RepoName, releaseID, "timedout")
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed marking release %v/%v/%v id %v as timed out", r.RepoSource, r.RepoOwner, r.RepoName, r.ID)
+			continue
+		}
+
+		jobName := s.ciBuilderClient.GetJobName("release", r.RepoOwner, r.RepoName, r.ID)
+		err = s.ciBuilderClient.CancelCiBuilderJob(ctx, jobName)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed canceling job %v for timed out release", jobName)
+		}
+	}
+
+	return nil
+}
+
+// Run sweeps at the configured interval on the replica holding the timeout sweeper lease until the stop channel gets closed
+func (s *timeoutSweeperImpl) Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) {
+
+	interval := time.Duration(s.jobsConfig.TimeoutSweepMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+
+		for {
+			select {
+			case <-time.After(interval):
+				// only one replica sweeps, so timeouts get handled and notified once
+				acquired, err := s.cockroachDBClient.AcquireLease(context.Background(), "timeout-sweeper", 2*interval)
+				if err != nil {
+					log.Error().Err(err).Msg("Failed acquiring lease for sweeping timed out builds and releases")
+					continue
+				}
+				if !acquired {
+					continue
+				}
+				err = s.Sweep(context.Background())
+				if err != nil {
+					log.Error().Err(err).Msg("Failed sweeping timed out builds and releases")
+				}
+			case <-stopChannel:
+				log.Debug().Msg("Stopping timeout sweeper...")
+				return
+			}
+		}
+	}()
+}
+
+func isOverdue(insertedAt, now time.Time, timeout time.Duration) bool {
+	return now.Sub(insertedAt) > timeout
+}
@@ -0,0 +1,121 @@
+package estafette
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	contracts "github.com/estafette/estafette-ci-contracts"
+)
+
+// PipelineFlakiness tells how often builds and their steps and tests both failed and succeeded for the same revision
+type PipelineFlakiness struct {
+	Revisions      int                        `json:"revisions"`
+	FlakyRevisions []string                   `json:"flakyRevisions"`
+	Score          float64                    `json:"score"`
+	Steps          []StepFlakiness            `json:"steps"`
+	Tests          []*cockroach.FlakyTestCase `json:"tests"`
+}
+
+// StepFlakiness tells for how many revisions a step both failed and succeeded
+type StepFlakiness struct {
+	Step           string  `json:"step"`
+	FlakyRevisions int     `json:"flakyRevisions"`
+	Score          float64 `json:"score"`
+}
+
+// getFlakyRevisions returns the number of revisions built and the revisions with both failed and succeeded builds, in order of the builds
+func getFlakyRevisions(builds []*contracts.Build) (revisions int, flakyRevisions []string) {
+
+	failed := map[string]bool{}
+	succeeded := map[string]bool{}
+	order := []string{}
+
+	for _, b := range builds {
+		if !failed[b.RepoRevision] && !succeeded[b.RepoRevision] {
+			order = append(order, b.RepoRevision)
+		}
+		switch b.BuildStatus {
+		case "failed":
+			failed[b.RepoRevision] = true
+		case "succeeded":
+			succeeded[b.RepoRevision] = true
+		}
+	}
+
+	flakyRevisions = []string{}
+	for _, r := range order {
+		if failed[r] && succeeded[r] {
+			flakyRevisions = append(flakyRevisions, r)
+		}
+	}
+
+	return len(order), flakyRevisions
+}
+
+// getStepFlakiness returns the steps that both failed and succeeded for the same revision, most flaky first
+func getStepFlakiness(builds []*contracts.Build, steps []*cockroach.BuildLogStepOutcome, revisions int) []StepFlakiness {
+
+	revisionByBuildID := map[string]string{}
+	for _, b := range builds {
+		revisionByBuildID[b.ID] = b.RepoRevision
+	}
+
+	// step name => revision => outcome
+	failed := map[string]map[string]bool{}
+	succeeded := map[string]map[string]bool{}
+	for _, s := range steps {
+		revision, ok := revisionByBuildID[s.BuildID]
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(s.Status) {
+		case "failed":
+			if failed[s.Step] == nil {
+				failed[s.Step] = map[string]bool{}
+			}
+			failed[s.Step][revision] = true
+		case "succeeded":
+			if succeeded[s.Step] == nil {
+				succeeded[s.Step] = map[string]bool{}
+			}
+			succeeded[s.Step][revision] = true
+		}
+	}
+
+	stepFlakiness := []StepFlakiness{}
+	for step, failedRevisions := range failed {
+		flakyRevisions := 0
+		for revision := range failedRevisions {
+			if succeeded[step][revision] {
+				flakyRevisions++
+			}
+		}
+		if flakyRevisions == 0 {
+			continue
+		}
+		stepFlakiness = append(stepFlakiness, StepFlakiness{
+			Step:           step,
+			FlakyRevisions: flakyRevisions,
+			Score:          getFlakinessScore(flakyRevisions, revisions),
+		})
+	}
+
+	sort.Slice(stepFlakiness, func(i, j int) bool {
+		if stepFlakiness[i].FlakyRevisions != stepFlakiness[j].FlakyRevisions {
+			return stepFlakiness[i].FlakyRevisions > stepFlakiness[j].FlakyRevisions
+		}
+		return stepFlakiness[i].Step < stepFlakiness[j].Step
+	})
+
+	return stepFlakiness
+}
+
+// getFlakinessScore returns the share of revisions that were flaky
+func getFlakinessScore(flakyRevisions, revisions int) float64 {
+	if revisions == 0 {
+		return 0
+	}
+
+	return float64(flakyRevisions) / float64(revisions)
+}
@@ -0,0 +1,78 @@
+package estafette
+
+import (
+	"testing"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFlakyRevisions(t *testing.T) {
+
+	t.Run("ReturnsRevisionsWithBothFailedAndSucceededBuilds", func(t *testing.T) {
+
+		builds := []*contracts.Build{
+			&contracts.Build{ID: "4", RepoRevision: "c", BuildStatus: "succeeded"},
+			&contracts.Build{ID: "3", RepoRevision: "b", BuildStatus: "succeeded"},
+			&contracts.Build{ID: "2", RepoRevision: "b", BuildStatus: "failed"},
+			&contracts.Build{ID: "1", RepoRevision: "a", BuildStatus: "failed"},
+		}
+
+		// act
+		revisions, flakyRevisions := getFlakyRevisions(builds)
+
+		assert.Equal(t, 3, revisions)
+		assert.Equal(t, []string{"b"}, flakyRevisions)
+	})
+
+	t.Run("ReturnsNoRevisionsIfEachRevisionHasOneOutcome", func(t *testing.T) {
+
+		builds := []*contracts.Build{
+			&contracts.Build{ID: "2", RepoRevision: "b", BuildStatus: "failed"},
+			&contracts.Build{ID: "1", RepoRevision: "a", BuildStatus: "succeeded"},
+		}
+
+		// act
+		revisions, flakyRevisions := getFlakyRevisions(builds)
+
+		assert.Equal(t, 2, revisions)
+		assert.Equal(t, 0, len(flakyRevisions))
+	})
+}
+
+func TestGetStepFlakiness(t *testing.T) {
+
+	t.Run("ReturnsStepsThatFailedAndSucceededForTheSameRevision", func(t *testing.T) {
+
+		builds := []*contracts.Build{
+			&contracts.Build{ID: "3", RepoRevision: "b", BuildStatus: "succeeded"},
+			&contracts.Build{ID: "2", RepoRevision: "b", BuildStatus: "failed"},
+		}
+		steps := []*cockroach.BuildLogStepOutcome{
+			&cockroach.BuildLogStepOutcome{BuildID: "3", Step: "build", Status: "SUCCEEDED"},
+			&cockroach.BuildLogStepOutcome{BuildID: "3", Step: "test", Status: "SUCCEEDED"},
+			&cockroach.BuildLogStepOutcome{BuildID: "2", Step: "build", Status: "SUCCEEDED"},
+			&cockroach.BuildLogStepOutcome{BuildID: "2", Step: "test", Status: "FAILED"},
+		}
+
+		// act
+		stepFlakiness := getStepFlakiness(builds, steps, 4)
+
+		assert.Equal(t, 1, len(stepFlakiness))
+		assert.Equal(t, "test", stepFlakiness[0].Step)
+		assert.Equal(t, 1, stepFlakiness[0].FlakyRevisions)
+		assert.Equal(t, 0.25, stepFlakiness[0].Score)
+	})
+}
+
+func TestGetFlakinessScore(t *testing.T) {
+
+	t.Run("ReturnsZeroWithoutRevisions", func(t *testing.T) {
+
+		// act
+		score := getFlakinessScore(0, 0)
+
+		assert.Equal(t, 0.0, score)
+	})
+}
@@ -0,0 +1,124 @@
+package estafette
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+)
+
+// JobCosts holds the estimated cost of builds and releases grouped by pipeline, owner or the value of a label
+type JobCosts struct {
+	GroupBy           string            `json:"groupBy"`
+	LabelKey          string            `json:"labelKey,omitempty"`
+	CPUCoreHourPrice  float64           `json:"cpuCoreHourPrice"`
+	MemoryGBHourPrice float64           `json:"memoryGBHourPrice"`
+	Total             JobCostSummary    `json:"total"`
+	Items             []*JobCostSummary `json:"items"`
+}
+
+// JobCostSummary holds the cost of the requested resources and the cost the measured peak usage would have had; an efficiency far below 1 means jobs request much more than they use
+type JobCostSummary struct {
+	Key                   string        `json:"key"`
+	Jobs                  int           `json:"jobs"`
+	Duration              time.Duration `json:"duration"`
+	CPURequestCoreHours   float64       `json:"cpuRequestCoreHours"`
+	MemoryRequestGBHours  float64       `json:"memoryRequestGBHours"`
+	CPUMaxUsageCoreHours  float64       `json:"cpuMaxUsageCoreHours"`
+	MemoryMaxUsageGBHours float64       `json:"memoryMaxUsageGBHours"`
+	Cost                  float64       `json:"cost"`
+	MaxUsageCost          float64       `json:"maxUsageCost"`
+	Efficiency            float64       `json:"efficiency"`
+
+	cpuMeasuredRequestCoreHours  float64
+	memoryMeasuredRequestGBHours float64
+}
+
+// getJobCosts prices the resource usage of builds and releases and sums it per group; groupBy is pipeline, owner or label, in which case pipelines without the label end up in a group with an empty key
+func getJobCosts(usages []*cockroach.ResourceUsage, groupBy, labelKey string, cpuCoreHourPrice, memoryGBHourPrice float64) JobCosts {
+
+	costs := JobCosts{
+		GroupBy:           groupBy,
+		CPUCoreHourPrice:  cpuCoreHourPrice,
+		MemoryGBHourPrice: memoryGBHourPrice,
+		Items:             []*JobCostSummary{},
+	}
+	if groupBy == "label" {
+		costs.LabelKey = labelKey
+	}
+
+	summariesByKey := map[string]*JobCostSummary{}
+	for _, u := range usages {
+		key := getJobCostKey(u, groupBy, labelKey)
+
+		summary, ok := summariesByKey[key]
+		if !ok {
+			summary = &JobCostSummary{Key: key}
+			summariesByKey[key] = summary
+			costs.Items = append(costs.Items, summary)
+		}
+
+		for _, s := range []*JobCostSummary{&costs.Total, summary} {
+			s.Jobs += u.Jobs
+			s.Duration += u.Duration
+			s.CPURequestCoreHours += u.CPURequestCoreHours
+			s.MemoryRequestGBHours += u.MemoryRequestGBHours
+			s.CPUMaxUsageCoreHours += u.CPUMaxUsageCoreHours
+			s.MemoryMaxUsageGBHours += u.MemoryMaxUsageGBHours
+			s.cpuMeasuredRequestCoreHours += u.CPUMeasuredRequestCoreHours
+			s.memoryMeasuredRequestGBHours += u.MemoryMeasuredRequestGBHours
+		}
+	}
+
+	for _, s := range append(costs.Items, &costs.Total) {
+		s.Cost = getJobCost(s.CPURequestCoreHours, s.MemoryRequestGBHours, cpuCoreHourPrice, memoryGBHourPrice)
+		s.MaxUsageCost = getJobCost(s.CPUMaxUsageCoreHours, s.MemoryMaxUsageGBHours, cpuCoreHourPrice, memoryGBHourPrice)
+		// efficiency only compares jobs with measured usage, otherwise jobs without measurements would look wasteful
+		if measuredCost := getJobCost(s.cpuMeasuredRequestCoreHours, s.memoryMeasuredRequestGBHours, cpuCoreHourPrice, memoryGBHourPrice); measuredCost > 0 {
+			s.Efficiency = s.MaxUsageCost / measuredCost
+		}
+	}
+
+	sort.SliceStable(costs.Items, func(i, j int) bool { return costs.Items[i].Cost > costs.Items[j].Cost })
+
+	return costs
+}
+
+func getJobCostKey(usage *cockroach.ResourceUsage, groupBy, labelKey string) string {
+
+	switch groupBy {
+	case "owner":
+		return fmt.Sprintf("%v/%v", usage.RepoSource, usage.RepoOwner)
+	case "label":
+		for _, l := range usage.Labels {
+			if l.Key == labelKey {
+				return l.Value
+			}
+		}
+		return ""
+	}
+
+	return fmt.Sprintf("%v/%v/%v", usage.RepoSource, usage.RepoOwner, usage.RepoName)
+}
+
+// getJobCost returns the price of the cpu and memory reserved for jobs
+func getJobCost(cpuCoreHours, memoryGBHours, cpuCoreHourPrice, memoryGBHourPrice float64) float64 {
+	return cpuCoreHours*cpuCoreHourPrice + memoryGBHours*memoryGBHourPrice
+}
+
+// getOverRequestRatios returns how many times more cpu and memory jobs requested than they used at their peak; zero if usage wasn't measured
+func getOverRequestRatios(usage *cockroach.ResourceUsage) (cpuRatio, memoryRatio float64) {
+
+	if usage == nil {
+		return
+	}
+	if usage.CPUMaxUsageCoreHours > 0 {
+		cpuRatio = usage.CPUMeasuredRequestCoreHours / usage.CPUMaxUsageCoreHours
+	}
+	if usage.MemoryMaxUsageGBHours > 0 {
+		memoryRatio = usage.MemoryMeasuredRequestGBHours / usage.MemoryMaxUsageGBHours
+	}
+
+	return
+}
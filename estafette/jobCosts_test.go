@@ -0,0 +1,93 @@
+package estafette
+
+import (
+	"testing"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetJobCosts(t *testing.T) {
+
+	usages := []*cockroach.ResourceUsage{
+		&cockroach.ResourceUsage{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", Labels: []contracts.Label{{Key: "team", Value: "estafette-team"}}, Jobs: 10, Duration: 5 * time.Hour, CPURequestCoreHours: 10, MemoryRequestGBHours: 20, CPUMaxUsageCoreHours: 5, MemoryMaxUsageGBHours: 10, CPUMeasuredRequestCoreHours: 10, MemoryMeasuredRequestGBHours: 20},
+		&cockroach.ResourceUsage{RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-web", Labels: []contracts.Label{{Key: "team", Value: "estafette-team"}}, Jobs: 2, Duration: 1 * time.Hour, CPURequestCoreHours: 1, MemoryRequestGBHours: 2, CPUMaxUsageCoreHours: 1, MemoryMaxUsageGBHours: 2, CPUMeasuredRequestCoreHours: 1, MemoryMeasuredRequestGBHours: 2},
+		&cockroach.ResourceUsage{RepoSource: "github.com", RepoOwner: "other", RepoName: "website", Jobs: 4, Duration: 2 * time.Hour, CPURequestCoreHours: 20, MemoryRequestGBHours: 40, CPUMaxUsageCoreHours: 2, MemoryMaxUsageGBHours: 4, CPUMeasuredRequestCoreHours: 20, MemoryMeasuredRequestGBHours: 40},
+	}
+
+	t.Run("GroupsByPipelineOrderedByCost", func(t *testing.T) {
+
+		// act
+		costs := getJobCosts(usages, "pipeline", "", 0.5, 0.1)
+
+		assert.Equal(t, 3, len(costs.Items))
+		assert.Equal(t, "github.com/other/website", costs.Items[0].Key)
+		assert.InDelta(t, 14.0, costs.Items[0].Cost, 0.0001)
+		assert.InDelta(t, 0.1, costs.Items[0].Efficiency, 0.0001)
+		assert.Equal(t, "github.com/estafette/estafette-ci-api", costs.Items[1].Key)
+		assert.InDelta(t, 7.0, costs.Items[1].Cost, 0.0001)
+		assert.Equal(t, 16, costs.Total.Jobs)
+		assert.InDelta(t, 21.7, costs.Total.Cost, 0.0001)
+	})
+
+	t.Run("GroupsByOwner", func(t *testing.T) {
+
+		// act
+		costs := getJobCosts(usages, "owner", "", 0.5, 0.1)
+
+		assert.Equal(t, 2, len(costs.Items))
+		assert.Equal(t, "github.com/other", costs.Items[0].Key)
+		assert.Equal(t, "github.com/estafette", costs.Items[1].Key)
+		assert.Equal(t, 12, costs.Items[1].Jobs)
+		assert.Equal(t, 6*time.Hour, costs.Items[1].Duration)
+	})
+
+	t.Run("GroupsByLabelValueWithEmptyKeyForPipelinesWithoutLabel", func(t *testing.T) {
+
+		// act
+		costs := getJobCosts(usages, "label", "team", 0.5, 0.1)
+
+		assert.Equal(t, "team", costs.LabelKey)
+		assert.Equal(t, 2, len(costs.Items))
+		assert.Equal(t, "", costs.Items[0].Key)
+		assert.Equal(t, "estafette-team", costs.Items[1].Key)
+		assert.InDelta(t, 7.7, costs.Items[1].Cost, 0.0001)
+	})
+}
+
+func TestGetOverRequestRatios(t *testing.T) {
+
+	t.Run("ReturnsRequestedOverMeasuredUsage", func(t *testing.T) {
+
+		usage := &cockroach.ResourceUsage{CPURequestCoreHours: 8, MemoryRequestGBHours: 4, CPUMaxUsageCoreHours: 2, MemoryMaxUsageGBHours: 4, CPUMeasuredRequestCoreHours: 8, MemoryMeasuredRequestGBHours: 4}
+
+		// act
+		cpuRatio, memoryRatio := getOverRequestRatios(usage)
+
+		assert.Equal(t, 4.0, cpuRatio)
+		assert.Equal(t, 1.0, memoryRatio)
+	})
+
+	t.Run("ReturnsZeroIfUsageIsNotMeasured", func(t *testing.T) {
+
+		usage := &cockroach.ResourceUsage{CPURequestCoreHours: 8, MemoryRequestGBHours: 4}
+
+		// act
+		cpuRatio, memoryRatio := getOverRequestRatios(usage)
+
+		assert.Equal(t, 0.0, cpuRatio)
+		assert.Equal(t, 0.0, memoryRatio)
+	})
+	t.Run("ComparesUsageWithRequestsOfMeasuredJobsOnly", func(t *testing.T) {
+
+		usage := &cockroach.ResourceUsage{CPURequestCoreHours: 80, MemoryRequestGBHours: 40, CPUMaxUsageCoreHours: 2, MemoryMaxUsageGBHours: 4, CPUMeasuredRequestCoreHours: 2, MemoryMeasuredRequestGBHours: 4}
+
+		// act
+		cpuRatio, memoryRatio := getOverRequestRatios(usage)
+
+		assert.Equal(t, 1.0, cpuRatio)
+		assert.Equal(t, 1.0, memoryRatio)
+	})
+}
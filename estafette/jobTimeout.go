@@ -0,0 +1,127 @@
+package estafette
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/rs/zerolog/log"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// rawTimeoutManifest holds the timeouts of the build and release targets, which the manifest library doesn't know about
+type rawTimeoutManifest struct {
+	Timeout  string        `yaml:"timeout,omitempty"`
+	Releases yaml.MapSlice `yaml:"releases,omitempty"`
+}
+
+type rawTimeoutRelease struct {
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// getManifestTimeout reads the timeout of the build ("" as target) or a release target from the raw manifest, returning 0 if it isn't set
+func getManifestTimeout(rawManifest, target string) (timeout time.Duration, err error) {
+
+	var raw rawTimeoutManifest
+	err = yaml.Unmarshal([]byte(rawManifest), &raw)
+	if err != nil {
+		return
+	}
+
+	value := raw.Timeout
+	if target != "" {
+		value = ""
+		for _, item := range raw.Releases {
+			if name, ok := item.Key.(string); !ok || name != target {
+				continue
+			}
+			releaseBytes, err := yaml.Marshal(item.Value)
+			if err != nil {
+				return 0, err
+			}
+			var release rawTimeoutRelease
+			err = yaml.Unmarshal(releaseBytes, &release)
+			if err != nil {
+				return 0, err
+			}
+			value = release.Timeout
+		}
+	}
+
+	if value == "" {
+		return 0, nil
+	}
+
+	timeout, err = time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("Timeout %v is not a positive duration", value)
+	}
+
+	return
+}
+
+// getJobTimeout returns the timeout set in the manifest for the build ("" as target) or a release target, or the configured default if it doesn't set a valid one
+func getJobTimeout(jobsConfig config.JobsConfig, rawManifest, target string) time.Duration {
+
+	timeout, err := getManifestTimeout(rawManifest, target)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed reading timeout for target '%v' from manifest, using default", target)
+	}
+	if timeout > 0 {
+		return timeout
+	}
+
+	if jobsConfig.TimeoutMinutes > 0 {
+		return time.Duration(jobsConfig.TimeoutMinutes) * time.Minute
+	}
+
+	return 2 * time.Hour
+}
+
+// getRemainingTimeout returns what's left of the timeout counting from the insertion of the build or release, like the timeout sweeper does, with at least a second for a valid job deadline
+func getRemainingTimeout(timeout time.Duration, insertedAt, now time.Time) time.Duration {
+
+	if insertedAt.IsZero() {
+		return timeout
+	}
+
+	remaining := timeout - now.Sub(insertedAt)
+	if remaining < time.Second {
+		return time.Second
+	}
+
+	return remaining
+}
+
+// getFailedJobStatus returns the status for the build or release of a failed job; kubernetes stopping a job at its active deadline is the same timeout the sweeper enforces
+func getFailedJobStatus(reason string) string {
+	if reason == "DeadlineExceeded" {
+		return "timedout"
+	}
+
+	return "failed"
+}
+
+// getReleaseTimeout returns the timeout for a release, as set in the manifest of the build it releases
+func getReleaseTimeout(ctx context.Context, jobsConfig config.JobsConfig, cockroachDBClient cockroach.DBClient, release contracts.Release) time.Duration {
+
+	rawManifest := ""
+	builds, err := cockroachDBClient.GetPipelineBuildsByVersion(ctx, release.RepoSource, release.RepoOwner, release.RepoName, release.ReleaseVersion, false)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed retrieving builds for release %v/%v/%v version %v, using default timeout", release.RepoSource, release.RepoOwner, release.RepoName, release.ReleaseVersion)
+	}
+	for _, b := range builds {
+		if b.Manifest != "" {
+			rawManifest = b.Manifest
+			break
+		}
+	}
+
+	return getJobTimeout(jobsConfig, rawManifest, release.Name)
+}
@@ -0,0 +1,141 @@
+package estafette
+
+import (
+	"testing"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetManifestTimeout(t *testing.T) {
+
+	rawManifest := `
+timeout: 45m
+
+stages:
+  build:
+    image: golang:1.12.7-alpine3.10
+
+releases:
+  development:
+    stages:
+      deploy:
+        image: extensions/gke:stable
+  production:
+    timeout: 1h30m
+    stages:
+      deploy:
+        image: extensions/gke:stable
+`
+
+	t.Run("ReturnsTimeoutForBuild", func(t *testing.T) {
+
+		// act
+		timeout, err := getManifestTimeout(rawManifest, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 45*time.Minute, timeout)
+	})
+
+	t.Run("ReturnsTimeoutForReleaseTarget", func(t *testing.T) {
+
+		// act
+		timeout, err := getManifestTimeout(rawManifest, "production")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 90*time.Minute, timeout)
+	})
+
+	t.Run("ReturnsZeroForReleaseTargetWithoutTimeout", func(t *testing.T) {
+
+		// act
+		timeout, err := getManifestTimeout(rawManifest, "development")
+
+		assert.Nil(t, err)
+		assert.Equal(t, time.Duration(0), timeout)
+	})
+
+	t.Run("ReturnsErrorForInvalidTimeout", func(t *testing.T) {
+
+		// act
+		_, err := getManifestTimeout("timeout: forever", "")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetJobTimeout(t *testing.T) {
+
+	t.Run("ReturnsManifestTimeoutIfSet", func(t *testing.T) {
+
+		// act
+		timeout := getJobTimeout(config.JobsConfig{TimeoutMinutes: 90}, "timeout: 10m", "")
+
+		assert.Equal(t, 10*time.Minute, timeout)
+	})
+
+	t.Run("ReturnsConfiguredTimeoutIfManifestTimeoutIsInvalid", func(t *testing.T) {
+
+		// act
+		timeout := getJobTimeout(config.JobsConfig{TimeoutMinutes: 90}, "timeout: -10m", "")
+
+		assert.Equal(t, 90*time.Minute, timeout)
+	})
+
+	t.Run("ReturnsTwoHoursIfNothingIsConfigured", func(t *testing.T) {
+
+		// act
+		timeout := getJobTimeout(config.JobsConfig{}, "", "")
+
+		assert.Equal(t, 2*time.Hour, timeout)
+	})
+}
+
+func TestGetRemainingTimeout(t *testing.T) {
+
+	insertedAt := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("SubtractsTimeSinceInsertion", func(t *testing.T) {
+
+		// act
+		remaining := getRemainingTimeout(time.Hour, insertedAt, insertedAt.Add(5*time.Minute))
+
+		assert.Equal(t, 55*time.Minute, remaining)
+	})
+
+	t.Run("ReturnsAtLeastASecondIfAlreadyOverdue", func(t *testing.T) {
+
+		// act
+		remaining := getRemainingTimeout(time.Hour, insertedAt, insertedAt.Add(2*time.Hour))
+
+		assert.Equal(t, time.Second, remaining)
+	})
+
+	t.Run("ReturnsFullTimeoutWithoutInsertionTime", func(t *testing.T) {
+
+		// act
+		remaining := getRemainingTimeout(time.Hour, time.Time{}, insertedAt)
+
+		assert.Equal(t, time.Hour, remaining)
+	})
+}
+
+func TestGetFailedJobStatus(t *testing.T) {
+
+	t.Run("ReturnsTimedOutForExceededDeadline", func(t *testing.T) {
+
+		// act
+		status := getFailedJobStatus("DeadlineExceeded")
+
+		assert.Equal(t, "timedout", status)
+	})
+
+	t.Run("ReturnsFailedForOtherReasons", func(t *testing.T) {
+
+		// act
+		status := getFailedJobStatus("OOMKilled")
+
+		assert.Equal(t, "failed", status)
+	})
+}
@@ -0,0 +1,220 @@
+package estafette
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	manifest "github.com/estafette/estafette-ci-manifest"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// PubSubMessage contains the decoded payload and attributes of the pubsub message that fires triggers
+type PubSubMessage struct {
+	Data       string
+	Attributes map[string]string
+}
+
+// pubsubTriggerFilter holds the filter expression of a pubsub trigger, which the manifest library doesn't know about
+type pubsubTriggerFilter struct {
+	Project string `yaml:"project,omitempty"`
+	Topic   string `yaml:"topic,omitempty"`
+	Filter  string `yaml:"filter,omitempty"`
+}
+
+type rawTrigger struct {
+	PubSub *pubsubTriggerFilter `yaml:"pubsub,omitempty"`
+}
+
+type rawPubSubTriggersManifest struct {
+	Triggers []rawTrigger  `yaml:"triggers,omitempty"`
+	Releases yaml.MapSlice `yaml:"releases,omitempty"`
+}
+
+type rawRelease struct {
+	Triggers []rawTrigger `yaml:"triggers,omitempty"`
+}
+
+var (
+	filterClauseRegex  = regexp.MustCompile(`^\s*(attributes|data)((?:\.[A-Za-z0-9_\-]+)+)\s*(==|!=)\s*(.+?)\s*$`)
+	envVarNameSanitize = regexp.MustCompile(`[^A-Z0-9_]`)
+)
+
+// getPubSubTriggerFilters reads the filter expressions for the pubsub triggers of the build ("" as target) or a release target from the raw manifest, in order of appearance
+func getPubSubTriggerFilters(rawManifest, target string) (filters []pubsubTriggerFilter, err error) {
+
+	var raw rawPubSubTriggersManifest
+	err = yaml.Unmarshal([]byte(rawManifest), &raw)
+	if err != nil {
+		return
+	}
+
+	triggers := raw.Triggers
+	if target != "" {
+		triggers = nil
+		for _, item := range raw.Releases {
+			if name, ok := item.Key.(string); !ok || name != target {
+				continue
+			}
+			releaseBytes, err := yaml.Marshal(item.Value)
+			if err != nil {
+				return filters, err
+			}
+			var release rawRelease
+			err = yaml.Unmarshal(releaseBytes, &release)
+			if err != nil {
+				return filters, err
+			}
+			triggers = release.Triggers
+		}
+	}
+
+	for _, t := range triggers {
+		if t.PubSub != nil {
+			filters = append(filters, *t.PubSub)
+		}
+	}
+
+	return
+}
+
+// getPubSubTriggerFilter returns the filter expression for the nth pubsub trigger for the same project and topic of a build or release target
+func getPubSubTriggerFilter(rawManifest, target string, trigger manifest.EstafettePubSubTrigger, occurrence int) (string, error) {
+
+	filters, err := getPubSubTriggerFilters(rawManifest, target)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range filters {
+		if strings.EqualFold(f.Project, trigger.Project) && strings.EqualFold(f.Topic, trigger.Topic) {
+			if occurrence == 0 {
+				return f.Filter, nil
+			}
+			occurrence--
+		}
+	}
+
+	return "", nil
+}
+
+// matchesPubSubFilter evaluates a filter like 'attributes.env == "prod" && data.status != "failed"' against a pubsub message;
+// attributes refer to message attributes, data to fields in the json payload; an empty filter matches every message
+func matchesPubSubFilter(filter string, message PubSubMessage) (bool, error) {
+
+	if strings.TrimSpace(filter) == "" {
+		return true, nil
+	}
+
+	var payload interface{}
+	payloadIsJSON := json.Unmarshal([]byte(message.Data), &payload) == nil
+
+	for _, clause := range strings.Split(filter, "&&") {
+		match := filterClauseRegex.FindStringSubmatch(clause)
+		if len(match) != 5 {
+			return false, fmt.Errorf("Pubsub trigger filter clause '%v' is invalid, use '<attributes|data>.<field> <==|!=> <value>'", strings.TrimSpace(clause))
+		}
+
+		source, path, operator, expected := match[1], strings.Split(strings.TrimPrefix(match[2], "."), "."), match[3], unquoteFilterValue(match[4])
+
+		actual, found := "", false
+		switch source {
+		case "attributes":
+			if len(path) == 1 && message.Attributes != nil {
+				actual, found = message.Attributes[path[0]]
+			}
+		case "data":
+			if payloadIsJSON {
+				actual, found = getJSONField(payload, path)
+			}
+		}
+
+		equal := found && actual == expected
+		if (operator == "==" && !equal) || (operator == "!=" && equal) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func unquoteFilterValue(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+func getJSONField(payload interface{}, path []string) (string, bool) {
+
+	value := payload
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		value, ok = object[key]
+		if !ok {
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nil:
+		return "null", true
+	}
+
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+
+	return string(bytes), true
+}
+
+// getPubSubEnvironmentVariables makes the pubsub message available to the stages of the build or release it fired
+func getPubSubEnvironmentVariables(pubsubEvent manifest.EstafettePubSubEvent, message PubSubMessage) map[string]string {
+
+	envvars := map[string]string{
+		"ESTAFETTE_PUBSUB_PROJECT": pubsubEvent.Project,
+		"ESTAFETTE_PUBSUB_TOPIC":   pubsubEvent.Topic,
+		"ESTAFETTE_PUBSUB_DATA":    message.Data,
+	}
+
+	for key, value := range message.Attributes {
+		envvars["ESTAFETTE_PUBSUB_ATTRIBUTE_"+envVarNameSanitize.ReplaceAllString(strings.ToUpper(key), "_")] = value
+	}
+
+	return envvars
+}
+
+// addGlobalEnvVars returns a copy of the manifest with the environment variables added to its global env vars
+func addGlobalEnvVars(mft manifest.EstafetteManifest, envvars map[string]string) manifest.EstafetteManifest {
+
+	if len(envvars) == 0 {
+		return mft
+	}
+
+	globalEnvVars := map[string]string{}
+	for key, value := range mft.GlobalEnvVars {
+		globalEnvVars[key] = value
+	}
+	for key, value := range envvars {
+		globalEnvVars[key] = value
+	}
+	mft.GlobalEnvVars = globalEnvVars
+
+	return mft
+}
@@ -0,0 +1,138 @@
+package estafette
+
+import (
+	"testing"
+
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesPubSubFilter(t *testing.T) {
+
+	message := PubSubMessage{
+		Data:       `{"status":"succeeded","image":{"tag":"1.0.3"},"replicas":3}`,
+		Attributes: map[string]string{"env": "prod"},
+	}
+
+	t.Run("ReturnsTrueForEmptyFilter", func(t *testing.T) {
+
+		// act
+		matches, err := matchesPubSubFilter("", message)
+
+		assert.Nil(t, err)
+		assert.True(t, matches)
+	})
+
+	t.Run("ReturnsTrueIfAttributeEqualsValue", func(t *testing.T) {
+
+		// act
+		matches, err := matchesPubSubFilter(`attributes.env == "prod"`, message)
+
+		assert.Nil(t, err)
+		assert.True(t, matches)
+	})
+
+	t.Run("ReturnsFalseIfAttributeDoesNotEqualValue", func(t *testing.T) {
+
+		// act
+		matches, err := matchesPubSubFilter(`attributes.env == "dev"`, message)
+
+		assert.Nil(t, err)
+		assert.False(t, matches)
+	})
+
+	t.Run("ReturnsTrueIfAllClausesMatchNestedPayloadFields", func(t *testing.T) {
+
+		// act
+		matches, err := matchesPubSubFilter(`attributes.env != 'dev' && data.image.tag == "1.0.3" && data.replicas == 3`, message)
+
+		assert.Nil(t, err)
+		assert.True(t, matches)
+	})
+
+	t.Run("ReturnsFalseIfPayloadFieldIsMissing", func(t *testing.T) {
+
+		// act
+		matches, err := matchesPubSubFilter(`data.image.digest == "sha256:abc"`, message)
+
+		assert.Nil(t, err)
+		assert.False(t, matches)
+	})
+
+	t.Run("ReturnsErrorForInvalidClause", func(t *testing.T) {
+
+		// act
+		_, err := matchesPubSubFilter(`env = prod`, message)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetPubSubTriggerFilter(t *testing.T) {
+
+	rawManifest := `
+triggers:
+- pubsub:
+    project: my-project
+    topic: my-topic
+    filter: attributes.env == "dev"
+  builds:
+    branch: master
+
+releases:
+  production:
+    triggers:
+    - pubsub:
+        project: my-project
+        topic: my-topic
+        filter: attributes.env == "prod"
+    - pubsub:
+        project: my-project
+        topic: my-topic
+        filter: attributes.env == "prod-eu"
+`
+
+	t.Run("ReturnsFilterForBuildTrigger", func(t *testing.T) {
+
+		// act
+		filter, err := getPubSubTriggerFilter(rawManifest, "", manifest.EstafettePubSubTrigger{Project: "my-project", Topic: "my-topic"}, 0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, `attributes.env == "dev"`, filter)
+	})
+
+	t.Run("ReturnsFilterForNthReleaseTriggerWithSameTopic", func(t *testing.T) {
+
+		// act
+		filter, err := getPubSubTriggerFilter(rawManifest, "production", manifest.EstafettePubSubTrigger{Project: "my-project", Topic: "my-topic"}, 1)
+
+		assert.Nil(t, err)
+		assert.Equal(t, `attributes.env == "prod-eu"`, filter)
+	})
+
+	t.Run("ReturnsEmptyFilterForUnknownTopic", func(t *testing.T) {
+
+		// act
+		filter, err := getPubSubTriggerFilter(rawManifest, "production", manifest.EstafettePubSubTrigger{Project: "my-project", Topic: "other-topic"}, 0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "", filter)
+	})
+}
+
+func TestGetPubSubEnvironmentVariables(t *testing.T) {
+
+	t.Run("ReturnsDataAndSanitizedAttributeEnvvars", func(t *testing.T) {
+
+		// act
+		envvars := getPubSubEnvironmentVariables(manifest.EstafettePubSubEvent{Project: "my-project", Topic: "my-topic"}, PubSubMessage{
+			Data:       `{"status":"succeeded"}`,
+			Attributes: map[string]string{"image-tag": "1.0.3"},
+		})
+
+		assert.Equal(t, "my-project", envvars["ESTAFETTE_PUBSUB_PROJECT"])
+		assert.Equal(t, "my-topic", envvars["ESTAFETTE_PUBSUB_TOPIC"])
+		assert.Equal(t, `{"status":"succeeded"}`, envvars["ESTAFETTE_PUBSUB_DATA"])
+		assert.Equal(t, "1.0.3", envvars["ESTAFETTE_PUBSUB_ATTRIBUTE_IMAGE_TAG"])
+	})
+}
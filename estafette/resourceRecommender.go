@@ -0,0 +1,189 @@
+package estafette
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// number of recent jobs to base a recommendation on and the minimum needed to deviate from the defaults
+	resourceMeasurementsWindow  = 25
+	resourceMeasurementsMinimum = 5
+)
+
+// ResourceRecommender recommends the cpu and memory requests and limits for build and release jobs based on the usage of previous jobs
+type ResourceRecommender interface {
+	RecommendBuildResources(ctx context.Context, repoSource, repoOwner, repoName, repoBranch string) cockroach.ResourceRecommendation
+	RecommendReleaseResources(ctx context.Context, repoSource, repoOwner, repoName, releaseName string) cockroach.ResourceRecommendation
+}
+
+type resourceRecommenderImpl struct {
+	jobsConfig        config.JobsConfig
+	cockroachDBClient cockroach.DBClient
+}
+
+// NewResourceRecommender returns a new estafette.ResourceRecommender
+func NewResourceRecommender(jobsConfig config.JobsConfig, cockroachDBClient cockroach.DBClient) (resourceRecommender ResourceRecommender) {
+
+	resourceRecommender = &resourceRecommenderImpl{
+		jobsConfig:        jobsConfig,
+		cockroachDBClient: cockroachDBClient,
+	}
+
+	return
+}
+
+// RecommendBuildResources bases the recommendation on recent builds of the same branch, since branches can build quite differently, falling back to builds of all branches if the branch hasn't built often enough
+func (r *resourceRecommenderImpl) RecommendBuildResources(ctx context.Context, repoSource, repoOwner, repoName, repoBranch string) cockroach.ResourceRecommendation {
+
+	reasons := []string{}
+
+	if repoBranch != "" {
+		measurements, err := r.cockroachDBClient.GetPipelineBuildResourceMeasurements(ctx, repoSource, repoOwner, repoName, repoBranch, resourceMeasurementsWindow)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed retrieving resource measurements for recent builds of %v/%v/%v branch %v, trying all branches...", repoSource, repoOwner, repoName, repoBranch)
+		} else if len(measurements) >= resourceMeasurementsMinimum {
+			return recommendResources(r.jobsConfig, measurements, fmt.Sprintf("Based on the last %v builds of branch %v.", len(measurements), repoBranch))
+		} else {
+			reasons = append(reasons, fmt.Sprintf("Branch %v has only %v measured builds, so builds of all branches are used.", repoBranch, len(measurements)))
+		}
+	}
+
+	measurements, err := r.cockroachDBClient.GetPipelineBuildResourceMeasurements(ctx, repoSource, repoOwner, repoName, "", resourceMeasurementsWindow)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed retrieving resource measurements for recent builds of %v/%v/%v, using defaults...", repoSource, repoOwner, repoName)
+		measurements = nil
+	}
+
+	recommendation := recommendResources(r.jobsConfig, measurements, fmt.Sprintf("Based on the last %v builds of all branches.", len(measurements)))
+	recommendation.Reasons = append(reasons, recommendation.Reasons...)
+
+	return recommendation
+}
+
+// RecommendReleaseResources bases the recommendation on recent releases to the same target
+func (r *resourceRecommenderImpl) RecommendReleaseResources(ctx context.Context, repoSource, repoOwner, repoName, releaseName string) cockroach.ResourceRecommendation {
+
+	measurements, err := r.cockroachDBClient.GetPipelineReleaseResourceMeasurements(ctx, repoSource, repoOwner, repoName, releaseName, resourceMeasurementsWindow)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed retrieving resource measurements for recent releases of %v/%v/%v target %v, using defaults...", repoSource, repoOwner, repoName, releaseName)
+		measurements = nil
+	}
+
+	return recommendResources(r.jobsConfig, measurements, fmt.Sprintf("Based on the last %v releases to target %v.", len(measurements), releaseName))
+}
+
+// recommendResources requests a percentile of the measured peak usage plus headroom within the configured bounds; limits stay at the maximum, except for the memory limit which gets raised if recent jobs got oom-killed
+func recommendResources(jobsConfig config.JobsConfig, measurements []*cockroach.ResourceMeasurement, scope string) cockroach.ResourceRecommendation {
+
+	// define resource request and limit values to fit reasonably well inside a n1-standard-8 (8 vCPUs, 30 GB memory) machine
+	recommendation := cockroach.ResourceRecommendation{
+		CPURequest:    jobsConfig.MaxCPUCores,
+		CPULimit:      jobsConfig.MaxCPUCores,
+		MemoryRequest: jobsConfig.MaxMemoryBytes,
+		MemoryLimit:   jobsConfig.MaxMemoryBytes,
+		Measurements:  len(measurements),
+		Reasons:       []string{scope},
+	}
+
+	percentile := jobsConfig.ResourcePercentile
+	if percentile <= 0 || percentile > 100 {
+		percentile = 95
+	}
+
+	cpuUsages := []float64{}
+	memoryUsages := []float64{}
+	oomKills := 0
+	oomKilledAtLimit := 0.0
+	for _, m := range measurements {
+		if m.OOMKilled {
+			oomKills++
+			limit := m.MemoryLimit
+			if limit <= 0 {
+				limit = jobsConfig.MaxMemoryBytes
+			}
+			oomKilledAtLimit = math.Max(oomKilledAtLimit, limit)
+			continue
+		}
+		if m.CPUMaxUsage > 0 {
+			cpuUsages = append(cpuUsages, m.CPUMaxUsage)
+		}
+		if m.MemoryMaxUsage > 0 {
+			memoryUsages = append(memoryUsages, m.MemoryMaxUsage)
+		}
+	}
+
+	if len(cpuUsages) >= resourceMeasurementsMinimum {
+		sort.Float64s(cpuUsages)
+		usage := getFloatPercentile(cpuUsages, percentile)
+		recommendation.CPURequest = clampResource(usage*jobsConfig.CPURequestRatio, jobsConfig.MinCPUCores, jobsConfig.MaxCPUCores)
+		recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf("Requesting %.2f cpu: the p%v of %v measured peaks is %.2f cpu, times %v headroom, within %v and %v cpu.", recommendation.CPURequest, percentile, len(cpuUsages), usage, jobsConfig.CPURequestRatio, jobsConfig.MinCPUCores, jobsConfig.MaxCPUCores))
+	} else {
+		recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf("Requesting the maximum of %v cpu, because only %v peaks were measured.", jobsConfig.MaxCPUCores, len(cpuUsages)))
+	}
+
+	if len(memoryUsages) >= resourceMeasurementsMinimum {
+		sort.Float64s(memoryUsages)
+		usage := getFloatPercentile(memoryUsages, percentile)
+		recommendation.MemoryRequest = clampResource(usage*jobsConfig.MemoryRequestRatio, jobsConfig.MinMemoryBytes, jobsConfig.MaxMemoryBytes)
+		recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf("Requesting %v memory: the p%v of %v measured peaks is %v, times %v headroom, within %v and %v.", formatMemory(recommendation.MemoryRequest), percentile, len(memoryUsages), formatMemory(usage), jobsConfig.MemoryRequestRatio, formatMemory(jobsConfig.MinMemoryBytes), formatMemory(jobsConfig.MaxMemoryBytes)))
+	} else {
+		recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf("Requesting the maximum of %v memory, because only %v peaks were measured.", formatMemory(jobsConfig.MaxMemoryBytes), len(memoryUsages)))
+	}
+
+	if oomKills > 0 {
+		// a job that got killed at its limit needs more than that, so request at least the limit and raise the limit itself
+		maxMemoryLimit := math.Max(jobsConfig.MaxMemoryLimitBytes, jobsConfig.MaxMemoryBytes)
+		ratio := jobsConfig.OOMMemoryLimitRatio
+		if ratio <= 1 {
+			ratio = 1.5
+		}
+
+		recommendation.MemoryLimit = clampResource(oomKilledAtLimit*ratio, recommendation.MemoryLimit, maxMemoryLimit)
+		recommendation.MemoryRequest = math.Max(recommendation.MemoryRequest, math.Min(oomKilledAtLimit, jobsConfig.MaxMemoryBytes))
+
+		if recommendation.MemoryLimit > oomKilledAtLimit {
+			recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf("Raising the memory limit to %v and requesting %v, because %v recent jobs got oom-killed at a limit of %v.", formatMemory(recommendation.MemoryLimit), formatMemory(recommendation.MemoryRequest), oomKills, formatMemory(oomKilledAtLimit)))
+		} else {
+			recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf("%v recent jobs got oom-killed at a limit of %v, but the memory limit can't be raised above %v.", oomKills, formatMemory(oomKilledAtLimit), formatMemory(maxMemoryLimit)))
+		}
+	}
+
+	// a request above the limit isn't accepted by kubernetes
+	recommendation.CPURequest = math.Min(recommendation.CPURequest, recommendation.CPULimit)
+	recommendation.MemoryRequest = math.Min(recommendation.MemoryRequest, recommendation.MemoryLimit)
+
+	return recommendation
+}
+
+// getFloatPercentile returns the nearest-rank percentile of sorted values
+func getFloatPercentile(sortedValues []float64, percentile float64) float64 {
+
+	if len(sortedValues) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(percentile / 100 * float64(len(sortedValues))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sortedValues) {
+		rank = len(sortedValues)
+	}
+
+	return sortedValues[rank-1]
+}
+
+func clampResource(value, min, max float64) float64 {
+	return math.Min(math.Max(value, min), max)
+}
+
+func formatMemory(bytes float64) string {
+	return fmt.Sprintf("%.0fMi", bytes/1024/1024)
+}
@@ -0,0 +1,123 @@
+package estafette
+
+import (
+	"math"
+	"testing"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecommendResources(t *testing.T) {
+
+	mi := math.Pow(2, 20)
+	gi := math.Pow(2, 30)
+
+	jobsConfig := config.JobsConfig{
+		MinCPUCores:         0.2,
+		MaxCPUCores:         4.0,
+		CPURequestRatio:     1.25,
+		MinMemoryBytes:      64 * mi,
+		MaxMemoryBytes:      12 * gi,
+		MemoryRequestRatio:  1.25,
+		ResourcePercentile:  95,
+		OOMMemoryLimitRatio: 1.5,
+		MaxMemoryLimitBytes: 24 * gi,
+	}
+
+	t.Run("UsesMaximumWithTooFewMeasurements", func(t *testing.T) {
+
+		measurements := []*cockroach.ResourceMeasurement{
+			&cockroach.ResourceMeasurement{CPUMaxUsage: 0.5, MemoryMaxUsage: 256 * mi},
+		}
+
+		// act
+		recommendation := recommendResources(jobsConfig, measurements, "Based on the last 1 builds of all branches.")
+
+		assert.Equal(t, 4.0, recommendation.CPURequest)
+		assert.Equal(t, 4.0, recommendation.CPULimit)
+		assert.Equal(t, 12*gi, recommendation.MemoryRequest)
+		assert.Equal(t, 12*gi, recommendation.MemoryLimit)
+		assert.Equal(t, 3, len(recommendation.Reasons))
+	})
+
+	t.Run("RequestsPercentileWithHeadroom", func(t *testing.T) {
+
+		measurements := []*cockroach.ResourceMeasurement{}
+		for i := 1; i <= 20; i++ {
+			measurements = append(measurements, &cockroach.ResourceMeasurement{CPUMaxUsage: 0.1 * float64(i), MemoryMaxUsage: 100 * mi * float64(i)})
+		}
+
+		// act
+		recommendation := recommendResources(jobsConfig, measurements, "")
+
+		// the p95 of 20 measurements is the 19th
+		assert.InDelta(t, 1.9*1.25, recommendation.CPURequest, 0.0001)
+		assert.Equal(t, 4.0, recommendation.CPULimit)
+		assert.InDelta(t, 1900*mi*1.25, recommendation.MemoryRequest, 1)
+		assert.Equal(t, 12*gi, recommendation.MemoryLimit)
+	})
+
+	t.Run("ClampsRequestsToMinimum", func(t *testing.T) {
+
+		measurements := []*cockroach.ResourceMeasurement{}
+		for i := 1; i <= 5; i++ {
+			measurements = append(measurements, &cockroach.ResourceMeasurement{CPUMaxUsage: 0.01, MemoryMaxUsage: 1 * mi})
+		}
+
+		// act
+		recommendation := recommendResources(jobsConfig, measurements, "")
+
+		assert.Equal(t, 0.2, recommendation.CPURequest)
+		assert.Equal(t, 64*mi, recommendation.MemoryRequest)
+	})
+
+	t.Run("RaisesMemoryLimitAfterOOMKill", func(t *testing.T) {
+
+		measurements := []*cockroach.ResourceMeasurement{
+			&cockroach.ResourceMeasurement{OOMKilled: true, MemoryLimit: 12 * gi},
+		}
+		for i := 1; i <= 5; i++ {
+			measurements = append(measurements, &cockroach.ResourceMeasurement{CPUMaxUsage: 1, MemoryMaxUsage: 1 * gi})
+		}
+
+		// act
+		recommendation := recommendResources(jobsConfig, measurements, "")
+
+		assert.Equal(t, 18*gi, recommendation.MemoryLimit)
+		assert.Equal(t, 12*gi, recommendation.MemoryRequest)
+		assert.Contains(t, recommendation.Reasons[len(recommendation.Reasons)-1], "oom-killed")
+	})
+
+	t.Run("DoesNotRaiseMemoryLimitAboveMaximum", func(t *testing.T) {
+
+		measurements := []*cockroach.ResourceMeasurement{
+			&cockroach.ResourceMeasurement{OOMKilled: true, MemoryLimit: 20 * gi},
+		}
+
+		// act
+		recommendation := recommendResources(jobsConfig, measurements, "")
+
+		assert.Equal(t, 24*gi, recommendation.MemoryLimit)
+	})
+}
+
+func TestGetFloatPercentile(t *testing.T) {
+
+	t.Run("ReturnsNearestRankPercentile", func(t *testing.T) {
+
+		// act
+		value := getFloatPercentile([]float64{1, 2, 3, 4}, 50)
+
+		assert.Equal(t, 2.0, value)
+	})
+
+	t.Run("ReturnsZeroWithoutValues", func(t *testing.T) {
+
+		// act
+		value := getFloatPercentile([]float64{}, 95)
+
+		assert.Equal(t, 0.0, value)
+	})
+}
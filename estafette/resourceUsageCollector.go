@@ -0,0 +1,230 @@
+package estafette
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	prom "github.com/estafette/estafette-ci-api/prometheus"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// number of finished jobs that can wait for their resource usage to be collected
+	resourceUsageQueueSize = 1000
+)
+
+// ResourceUsageCollector collects the resource usage of finished build and release jobs from prometheus in the background, once prometheus has scraped the last samples of the job's pod
+type ResourceUsageCollector interface {
+	Enqueue(ciBuilderEvent CiBuilderEvent)
+	Collect(ctx context.Context, ciBuilderEvent CiBuilderEvent, finishedAt time.Time) error
+	Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
+}
+
+type resourceUsageCollectorImpl struct {
+	prometheusConfig  config.PrometheusConfig
+	prometheusClient  prom.PrometheusClient
+	cockroachDBClient cockroach.DBClient
+	queue             chan *resourceUsageRequest
+}
+
+type resourceUsageRequest struct {
+	ciBuilderEvent CiBuilderEvent
+	finishedAt     time.Time
+	dueAt          time.Time
+	attempt        int
+}
+
+// NewResourceUsageCollector returns a new estafette.ResourceUsageCollector
+func NewResourceUsageCollector(prometheusConfig config.PrometheusConfig, prometheusClient prom.PrometheusClient, cockroachDBClient cockroach.DBClient) ResourceUsageCollector {
+	return &resourceUsageCollectorImpl{
+		prometheusConfig:  prometheusConfig,
+		prometheusClient:  prometheusClient,
+		cockroachDBClient: cockroachDBClient,
+		queue:             make(chan *resourceUsageRequest, resourceUsageQueueSize),
+	}
+}
+
+// Enqueue schedules collection of the resource usage of a finished job after the scrape interval; it never blocks, so if the queue is full the job's usage doesn't get collected
+func (c *resourceUsageCollectorImpl) Enqueue(ciBuilderEvent CiBuilderEvent) {
+
+	if ciBuilderEvent.PodName == "" {
+		return
+	}
+
+	finishedAt := time.Now().UTC()
+
+	select {
+	case c.queue <- &resourceUsageRequest{ciBuilderEvent: ciBuilderEvent, finishedAt: finishedAt, dueAt: finishedAt.Add(c.getScrapeInterval())}:
+		log.Debug().Msgf("Queued collecting resource usage for pod %v", ciBuilderEvent.PodName)
+	default:
+		log.Warn().Msgf("Resource usage collection queue is full, not collecting resource usage for pod %v", ciBuilderEvent.PodName)
+	}
+}
+
+// Collect retrieves all configured metrics for the lifetime of a job's pod and stores them with its build or release; the cpu and memory peaks are required, the other metrics and usage curves are collected on a best effort basis
+func (c *resourceUsageCollectorImpl) Collect(ctx context.Context, ciBuilderEvent CiBuilderEvent, finishedAt time.Time) (err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ResourceUsageCollector::Collect")
+	defer span.Finish()
+
+	if ciBuilderEvent.PodName == "" || ciBuilderEvent.BuildStatus == "" {
+		return nil
+	}
+
+	// without the time the build or release got created fall back to the window previously used for all jobs
+	startedAt := finishedAt.Add(-3 * time.Hour)
+
+	var buildID, releaseID int
+	if ciBuilderEvent.ReleaseID != "" {
+		releaseID, err = strconv.Atoi(ciBuilderEvent.ReleaseID)
+		if err != nil {
+			return err
+		}
+		release, err := c.cockroachDBClient.GetPipelineRelease(ctx, ciBuilderEvent.RepoSource, ciBuilderEvent.RepoOwner, ciBuilderEvent.RepoName, releaseID)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed retrieving release %v for pod %v, using default window", releaseID, ciBuilderEvent.PodName)
+		} else if release != nil && release.InsertedAt != nil {
+			startedAt = *release.InsertedAt
+		}
+	} else if ciBuilderEvent.BuildID != "" {
+		buildID, err = strconv.Atoi(ciBuilderEvent.BuildID)
+		if err != nil {
+			return err
+		}
+		build, err := c.cockroachDBClient.GetPipelineBuildByID(ctx, ciBuilderEvent.RepoSource, ciBuilderEvent.RepoOwner, ciBuilderEvent.RepoName, buildID, true)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed retrieving build %v for pod %v, using default window", buildID, ciBuilderEvent.PodName)
+		} else if build != nil {
+			startedAt = build.InsertedAt
+		}
+	} else {
+		return nil
+	}
+
+	metrics := cockroach.JobResourceMetrics{
+		Values: map[string]float64{},
+		Series: map[string][]cockroach.ResourceUsageSample{},
+	}
+
+	for _, name := range c.prometheusClient.GetMetricNames() {
+		value, err := c.prometheusClient.GetJobMetric(name, ciBuilderEvent.PodName, startedAt, finishedAt)
+		if err != nil {
+			if name == "cpu" || name == "memory" {
+				return err
+			}
+			log.Warn().Err(err).Msgf("Failed retrieving %v metric for pod %v", name, ciBuilderEvent.PodName)
+			continue
+		}
+		metrics.Values[name] = value
+
+		samples, err := c.prometheusClient.GetJobMetricRange(name, ciBuilderEvent.PodName, startedAt, finishedAt)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed retrieving %v usage curve for pod %v", name, ciBuilderEvent.PodName)
+			continue
+		}
+		series := make([]cockroach.ResourceUsageSample, 0, len(samples))
+		for _, s := range samples {
+			series = append(series, cockroach.ResourceUsageSample{Time: s.Time, Value: s.Value})
+		}
+		metrics.Series[name] = series
+	}
+
+	log.Info().Msgf("Max cpu usage for pod %v is %v, max memory usage is %v", ciBuilderEvent.PodName, metrics.Values["cpu"], metrics.Values["memory"])
+
+	jobResources := cockroach.JobResources{
+		CPUMaxUsage:    metrics.Values["cpu"],
+		MemoryMaxUsage: metrics.Values["memory"],
+	}
+
+	if releaseID > 0 {
+		err = c.cockroachDBClient.UpdateReleaseResourceUtilization(ctx, ciBuilderEvent.RepoSource, ciBuilderEvent.RepoOwner, ciBuilderEvent.RepoName, releaseID, jobResources)
+		if err != nil {
+			return err
+		}
+		return c.cockroachDBClient.UpdateReleaseResourceMetrics(ctx, ciBuilderEvent.RepoSource, ciBuilderEvent.RepoOwner, ciBuilderEvent.RepoName, releaseID, metrics)
+	}
+
+	err = c.cockroachDBClient.UpdateBuildResourceUtilization(ctx, ciBuilderEvent.RepoSource, ciBuilderEvent.RepoOwner, ciBuilderEvent.RepoName, buildID, jobResources)
+	if err != nil {
+		return err
+	}
+	return c.cockroachDBClient.UpdateBuildResourceMetrics(ctx, ciBuilderEvent.RepoSource, ciBuilderEvent.RepoOwner, ciBuilderEvent.RepoName, buildID, metrics)
+}
+
+// Run collects the resource usage of queued jobs once they're due, retrying with backoff, until the stop channel gets closed
+func (c *resourceUsageCollectorImpl) Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) {
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		pending := []*resourceUsageRequest{}
+
+		for {
+			select {
+			case request := <-c.queue:
+				pending = append(pending, request)
+			case <-ticker.C:
+				pending = c.collectDue(pending, time.Now().UTC())
+			case <-stopChannel:
+				log.Debug().Msgf("Stopping resource usage collector with %v pending jobs...", len(pending))
+				return
+			}
+		}
+	}()
+}
+
+// collectDue collects the requests that are due and returns the ones still pending, including the failed ones that get another attempt
+func (c *resourceUsageCollectorImpl) collectDue(pending []*resourceUsageRequest, now time.Time) []*resourceUsageRequest {
+
+	retries := c.prometheusConfig.CollectRetries
+	if retries <= 0 {
+		retries = 3
+	}
+
+	stillPending := pending[:0]
+	for _, r := range pending {
+		if r.dueAt.After(now) {
+			stillPending = append(stillPending, r)
+			continue
+		}
+
+		err := c.Collect(context.Background(), r.ciBuilderEvent, r.finishedAt)
+		if err == nil {
+			continue
+		}
+
+		if r.attempt >= retries {
+			log.Error().Err(err).Msgf("Failed collecting resource usage for pod %v after %v attempts, giving up", r.ciBuilderEvent.PodName, r.attempt+1)
+			continue
+		}
+
+		r.attempt++
+		r.dueAt = now.Add(getCollectRetryDelay(c.getScrapeInterval(), r.attempt))
+		log.Warn().Err(err).Msgf("Failed collecting resource usage for pod %v, retrying at %v", r.ciBuilderEvent.PodName, r.dueAt)
+		stillPending = append(stillPending, r)
+	}
+
+	return stillPending
+}
+
+func (c *resourceUsageCollectorImpl) getScrapeInterval() time.Duration {
+	if c.prometheusConfig.ScrapeIntervalSeconds <= 0 {
+		return 15 * time.Second
+	}
+	return time.Duration(c.prometheusConfig.ScrapeIntervalSeconds) * time.Second
+}
+
+// getCollectRetryDelay doubles the delay with each attempt, since prometheus failing once tends to fail for a while
+func getCollectRetryDelay(scrapeInterval time.Duration, attempt int) time.Duration {
+	return time.Duration(math.Pow(2, float64(attempt))) * scrapeInterval
+}
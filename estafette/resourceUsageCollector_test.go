@@ -0,0 +1,23 @@
+package estafette
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCollectRetryDelay(t *testing.T) {
+
+	t.Run("DoublesDelayWithEachAttempt", func(t *testing.T) {
+
+		// act
+		delays := []time.Duration{
+			getCollectRetryDelay(15*time.Second, 1),
+			getCollectRetryDelay(15*time.Second, 2),
+			getCollectRetryDelay(15*time.Second, 3),
+		}
+
+		assert.Equal(t, []time.Duration{30 * time.Second, 60 * time.Second, 120 * time.Second}, delays)
+	})
+}
@@ -0,0 +1,118 @@
+package estafette
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	contracts "github.com/estafette/estafette-ci-contracts"
+)
+
+// StepStats aggregates the duration and outcome of a step over the builds of a pipeline
+type StepStats struct {
+	Step           string        `json:"step"`
+	Image          string        `json:"image,omitempty"`
+	Runs           int           `json:"runs"`
+	Failures       int           `json:"failures"`
+	FailureRate    float64       `json:"failureRate"`
+	MedianDuration time.Duration `json:"medianDuration"`
+	P95Duration    time.Duration `json:"p95Duration"`
+	LastFailure    *StepFailure  `json:"lastFailure,omitempty"`
+}
+
+// StepFailure identifies the build in which a step failed
+type StepFailure struct {
+	BuildID      string    `json:"buildID"`
+	BuildVersion string    `json:"buildVersion"`
+	ExitCode     int64     `json:"exitCode"`
+	FailedAt     time.Time `json:"failedAt"`
+}
+
+// getStepStats aggregates the steps that ran in the builds, in order of first appearance; skipped or canceled steps don't count as runs
+func getStepStats(builds []*contracts.Build, steps []*cockroach.BuildLogStepOutcome) []*StepStats {
+
+	buildsByID := map[string]*contracts.Build{}
+	for _, b := range builds {
+		buildsByID[b.ID] = b
+	}
+
+	statsByStep := map[string]*StepStats{}
+	durationsByStep := map[string][]time.Duration{}
+	imageSeenAt := map[string]time.Time{}
+	order := []string{}
+
+	for _, s := range steps {
+		build, ok := buildsByID[s.BuildID]
+		if !ok {
+			continue
+		}
+
+		status := strings.ToLower(s.Status)
+		if status != "succeeded" && status != "failed" {
+			continue
+		}
+
+		stats, ok := statsByStep[s.Step]
+		if !ok {
+			stats = &StepStats{Step: s.Step}
+			statsByStep[s.Step] = stats
+			order = append(order, s.Step)
+		}
+
+		stats.Runs++
+		durationsByStep[s.Step] = append(durationsByStep[s.Step], s.Duration)
+
+		// keep the image of the most recent build
+		if seenAt, ok := imageSeenAt[s.Step]; !ok || build.InsertedAt.After(seenAt) {
+			stats.Image = s.Image
+			imageSeenAt[s.Step] = build.InsertedAt
+		}
+
+		if status == "failed" {
+			stats.Failures++
+			if stats.LastFailure == nil || build.InsertedAt.After(stats.LastFailure.FailedAt) {
+				stats.LastFailure = &StepFailure{
+					BuildID:      build.ID,
+					BuildVersion: build.BuildVersion,
+					ExitCode:     s.ExitCode,
+					FailedAt:     build.InsertedAt,
+				}
+			}
+		}
+	}
+
+	stepStats := make([]*StepStats, 0, len(order))
+	for _, step := range order {
+		stats := statsByStep[step]
+		durations := durationsByStep[step]
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+		stats.FailureRate = float64(stats.Failures) / float64(stats.Runs)
+		stats.MedianDuration = getDurationPercentile(durations, 50)
+		stats.P95Duration = getDurationPercentile(durations, 95)
+
+		stepStats = append(stepStats, stats)
+	}
+
+	return stepStats
+}
+
+// getDurationPercentile returns the nearest-rank percentile of sorted durations
+func getDurationPercentile(sortedDurations []time.Duration, percentile float64) time.Duration {
+
+	if len(sortedDurations) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(percentile / 100 * float64(len(sortedDurations))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sortedDurations) {
+		rank = len(sortedDurations)
+	}
+
+	return sortedDurations[rank-1]
+}
@@ -0,0 +1,78 @@
+package estafette
+
+import (
+	"testing"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStepStats(t *testing.T) {
+
+	t.Run("AggregatesDurationsFailuresAndLastFailurePerStep", func(t *testing.T) {
+
+		builds := []*contracts.Build{
+			&contracts.Build{ID: "3", BuildVersion: "1.0.3", InsertedAt: time.Date(2019, 7, 3, 0, 0, 0, 0, time.UTC)},
+			&contracts.Build{ID: "2", BuildVersion: "1.0.2", InsertedAt: time.Date(2019, 7, 2, 0, 0, 0, 0, time.UTC)},
+			&contracts.Build{ID: "1", BuildVersion: "1.0.1", InsertedAt: time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)},
+		}
+		steps := []*cockroach.BuildLogStepOutcome{
+			&cockroach.BuildLogStepOutcome{BuildID: "1", Step: "build", Image: "golang:1.12", Status: "SUCCEEDED", Duration: 30 * time.Second},
+			&cockroach.BuildLogStepOutcome{BuildID: "1", Step: "test", Image: "golang:1.12", Status: "FAILED", ExitCode: 1, Duration: 10 * time.Second},
+			&cockroach.BuildLogStepOutcome{BuildID: "2", Step: "build", Image: "golang:1.12", Status: "SUCCEEDED", Duration: 10 * time.Second},
+			&cockroach.BuildLogStepOutcome{BuildID: "2", Step: "test", Image: "golang:1.12", Status: "FAILED", ExitCode: 2, Duration: 20 * time.Second},
+			&cockroach.BuildLogStepOutcome{BuildID: "3", Step: "build", Image: "golang:1.13", Status: "SUCCEEDED", Duration: 20 * time.Second},
+			&cockroach.BuildLogStepOutcome{BuildID: "3", Step: "test", Image: "golang:1.13", Status: "SKIPPED"},
+		}
+
+		// act
+		stepStats := getStepStats(builds, steps)
+
+		assert.Equal(t, 2, len(stepStats))
+
+		assert.Equal(t, "build", stepStats[0].Step)
+		assert.Equal(t, "golang:1.13", stepStats[0].Image)
+		assert.Equal(t, 3, stepStats[0].Runs)
+		assert.Equal(t, 0, stepStats[0].Failures)
+		assert.Equal(t, 20*time.Second, stepStats[0].MedianDuration)
+		assert.Equal(t, 30*time.Second, stepStats[0].P95Duration)
+		assert.Nil(t, stepStats[0].LastFailure)
+
+		assert.Equal(t, "test", stepStats[1].Step)
+		assert.Equal(t, 2, stepStats[1].Runs)
+		assert.Equal(t, 1.0, stepStats[1].FailureRate)
+		assert.Equal(t, "2", stepStats[1].LastFailure.BuildID)
+		assert.Equal(t, int64(2), stepStats[1].LastFailure.ExitCode)
+	})
+}
+
+func TestGetDurationPercentile(t *testing.T) {
+
+	durations := []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}
+
+	t.Run("ReturnsNearestRankMedian", func(t *testing.T) {
+
+		// act
+		duration := getDurationPercentile(durations, 50)
+
+		assert.Equal(t, 2*time.Second, duration)
+	})
+
+	t.Run("ReturnsMaximumForP95OfSmallSample", func(t *testing.T) {
+
+		// act
+		duration := getDurationPercentile(durations, 95)
+
+		assert.Equal(t, 4*time.Second, duration)
+	})
+
+	t.Run("ReturnsZeroWithoutDurations", func(t *testing.T) {
+
+		// act
+		duration := getDurationPercentile([]time.Duration{}, 95)
+
+		assert.Equal(t, time.Duration(0), duration)
+	})
+}
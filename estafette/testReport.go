@@ -0,0 +1,133 @@
+package estafette
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+)
+
+// junitTestSuite maps both a <testsuites> and a <testsuite> element, so a report with either as root element can be read
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Suites    []junitTestSuite `xml:"testsuite"`
+	TestCases []junitTestCase  `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	Error     *junitFailure `xml:"error"`
+	Skipped   *junitFailure `xml:"skipped"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// parseJUnitReport reads the test cases from a JUnit xml report
+func parseJUnitReport(data []byte) (testCases []cockroach.TestCase, err error) {
+
+	var root junitTestSuite
+	err = xml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, fmt.Errorf("Reading JUnit report failed: %v", err)
+	}
+
+	testCases = flattenJUnitTestSuite(root, "")
+
+	return testCases, nil
+}
+
+// parseJUnitDuration reads a duration in seconds; some tools write them with a thousands separator next to the decimal point, others with a decimal comma
+func parseJUnitDuration(value string) (float64, error) {
+	if strings.Contains(value, ".") {
+		value = strings.Replace(value, ",", "", -1)
+	} else {
+		value = strings.Replace(value, ",", ".", 1)
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+func flattenJUnitTestSuite(suite junitTestSuite, parentSuiteName string) (testCases []cockroach.TestCase) {
+
+	suiteName := suite.Name
+	if suiteName == "" {
+		suiteName = parentSuiteName
+	}
+
+	for _, tc := range suite.TestCases {
+		testCase := cockroach.TestCase{
+			SuiteName: suiteName,
+			ClassName: tc.ClassName,
+			Name:      tc.Name,
+			Status:    "passed",
+		}
+
+		duration, err := parseJUnitDuration(tc.Time)
+		if err == nil {
+			testCase.DurationSeconds = duration
+		}
+
+		switch {
+		case tc.Failure != nil:
+			testCase.Status = "failed"
+			testCase.FailureMessage = getJUnitFailureMessage(*tc.Failure)
+		case tc.Error != nil:
+			testCase.Status = "failed"
+			testCase.FailureMessage = getJUnitFailureMessage(*tc.Error)
+		case tc.Skipped != nil:
+			testCase.Status = "skipped"
+		}
+
+		testCases = append(testCases, testCase)
+	}
+
+	for _, s := range suite.Suites {
+		testCases = append(testCases, flattenJUnitTestSuite(s, suiteName)...)
+	}
+
+	return
+}
+
+func getJUnitFailureMessage(failure junitFailure) string {
+
+	message := strings.TrimSpace(failure.Message)
+	text := strings.TrimSpace(failure.Text)
+
+	if message == "" {
+		return text
+	}
+	if text == "" || strings.HasPrefix(text, message) {
+		return message
+	}
+
+	return message + "\n" + text
+}
+
+// summarizeTestCases totals the test results of a build
+func summarizeTestCases(testCases []*cockroach.TestCase) (summary cockroach.TestReportSummary) {
+
+	for _, tc := range testCases {
+		summary.BuildID = tc.BuildID
+		summary.BuildVersion = tc.BuildVersion
+		summary.RepoBranch = tc.RepoBranch
+
+		summary.Tests++
+		summary.DurationSeconds += tc.DurationSeconds
+		switch tc.Status {
+		case "failed":
+			summary.Failures++
+		case "skipped":
+			summary.Skipped++
+		}
+	}
+
+	return
+}
@@ -0,0 +1,126 @@
+package estafette
+
+import (
+	"testing"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJUnitReport(t *testing.T) {
+
+	t.Run("ReadsTestCasesFromTestSuitesRoot", func(t *testing.T) {
+
+		data := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="estafette" tests="3" failures="1" skipped="1" time="1.5">
+    <testcase classname="estafette" name="TestGetJobTimeout" time="0.25"></testcase>
+    <testcase classname="estafette" name="TestParseJUnitReport" time="1.25">
+      <failure message="expected 3, got 2">estafetteTestReport_test.go:42: expected 3, got 2</failure>
+    </testcase>
+    <testcase classname="estafette" name="TestSkipped" time="0">
+      <skipped/>
+    </testcase>
+  </testsuite>
+</testsuites>`)
+
+		// act
+		testCases, err := parseJUnitReport(data)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(testCases))
+		assert.Equal(t, "estafette", testCases[0].SuiteName)
+		assert.Equal(t, "TestGetJobTimeout", testCases[0].Name)
+		assert.Equal(t, "passed", testCases[0].Status)
+		assert.Equal(t, 0.25, testCases[0].DurationSeconds)
+		assert.Equal(t, "failed", testCases[1].Status)
+		assert.Equal(t, "expected 3, got 2\nestafetteTestReport_test.go:42: expected 3, got 2", testCases[1].FailureMessage)
+		assert.Equal(t, "skipped", testCases[2].Status)
+	})
+
+	t.Run("ReadsTestCasesFromTestSuiteRoot", func(t *testing.T) {
+
+		data := []byte(`<testsuite name="api"><testcase name="returns 200" time="1,000.5"><error message="timeout"/></testcase></testsuite>`)
+
+		// act
+		testCases, err := parseJUnitReport(data)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(testCases))
+		assert.Equal(t, "api", testCases[0].SuiteName)
+		assert.Equal(t, "failed", testCases[0].Status)
+		assert.Equal(t, "timeout", testCases[0].FailureMessage)
+		assert.Equal(t, 1000.5, testCases[0].DurationSeconds)
+	})
+
+	t.Run("UsesParentSuiteNameForNestedSuiteWithoutName", func(t *testing.T) {
+
+		data := []byte(`<testsuite name="outer"><testsuite><testcase name="inner"/></testsuite></testsuite>`)
+
+		// act
+		testCases, err := parseJUnitReport(data)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(testCases))
+		assert.Equal(t, "outer", testCases[0].SuiteName)
+	})
+
+	t.Run("ReturnsErrorForInvalidXML", func(t *testing.T) {
+
+		// act
+		_, err := parseJUnitReport([]byte("not xml"))
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSummarizeTestCases(t *testing.T) {
+
+	t.Run("TotalsTestsFailuresSkippedAndDuration", func(t *testing.T) {
+
+		testCases := []*cockroach.TestCase{
+			&cockroach.TestCase{BuildID: "15", Status: "passed", DurationSeconds: 1.5},
+			&cockroach.TestCase{BuildID: "15", Status: "failed", DurationSeconds: 2},
+			&cockroach.TestCase{BuildID: "15", Status: "skipped"},
+		}
+
+		// act
+		summary := summarizeTestCases(testCases)
+
+		assert.Equal(t, "15", summary.BuildID)
+		assert.Equal(t, 3, summary.Tests)
+		assert.Equal(t, 1, summary.Failures)
+		assert.Equal(t, 1, summary.Skipped)
+		assert.Equal(t, 3.5, summary.DurationSeconds)
+	})
+}
+
+func TestParseJUnitDuration(t *testing.T) {
+
+	t.Run("StripsThousandsSeparatorNextToDecimalPoint", func(t *testing.T) {
+
+		// act
+		duration, err := parseJUnitDuration("1,000.5")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1000.5, duration)
+	})
+
+	t.Run("ReadsCommaWithoutDecimalPointAsDecimalComma", func(t *testing.T) {
+
+		// act
+		duration, err := parseJUnitDuration("0,123")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0.123, duration)
+	})
+
+	t.Run("ReadsPlainDecimal", func(t *testing.T) {
+
+		// act
+		duration, err := parseJUnitDuration("2.5")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2.5, duration)
+	})
+}
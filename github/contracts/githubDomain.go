@@ -66,6 +66,11 @@ type RepositoryContent struct {
 	Sha      string `json:"sha"`
 }
 
+// RepositoryCommit represents a commit as returned by the Github commits api
+type RepositoryCommit struct {
+	Sha string `json:"sha"`
+}
+
 // GetRepoSource returns the repository source
 func (pe *PushEvent) GetRepoSource() string {
 	return "github.com"
@@ -9,6 +9,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -29,9 +30,11 @@ type APIClient interface {
 	GetInstallationToken(context.Context, int) (ghcontracts.AccessToken, error)
 	GetAuthenticatedRepositoryURL(ghcontracts.AccessToken, string) (string, error)
 	GetEstafetteManifest(context.Context, ghcontracts.AccessToken, ghcontracts.PushEvent) (bool, string, error)
+	GetCommitSha(context.Context, ghcontracts.AccessToken, string, string) (string, error)
 	callGithubAPI(opentracing.Span, string, string, interface{}, string, string) (int, []byte, error)
 
 	JobVarsFunc() func(context.Context, string, string, string) (string, string, error)
+	ManifestFunc() func(context.Context, string, string, string, string) (string, bool, string, error)
 }
 
 type apiClientImpl struct {
@@ -195,6 +198,48 @@ func (gh *apiClientImpl) GetEstafetteManifest(ctx context.Context, accessToken g
 	return
 }
 
+// GetCommitSha returns the sha of the commit a branch, tag or sha refers to
+func (gh *apiClientImpl) GetCommitSha(ctx context.Context, accessToken ghcontracts.AccessToken, fullName, ref string) (sha string, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "GithubApi::GetCommitSha")
+	defer span.Finish()
+
+	// https://developer.github.com/v3/repos/commits/#get-a-single-commit
+
+	commitURL, err := getCommitURL(fullName, ref)
+	if err != nil {
+		return
+	}
+
+	statusCode, body, err := gh.callGithubAPI(span, "GET", commitURL, nil, "token", accessToken.Token)
+	if err != nil {
+		return
+	}
+
+	if statusCode != http.StatusOK {
+		return "", fmt.Errorf("Retrieving commit for ref %v of repository %v failed with status code %v", ref, fullName, statusCode)
+	}
+
+	var commit ghcontracts.RepositoryCommit
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &commit)
+	if err != nil {
+		return
+	}
+
+	return commit.Sha, nil
+}
+
+// getCommitURL returns the api url for the commit a branch, tag or sha points to; the ref is user input, so it gets escaped and can't traverse to other paths
+func getCommitURL(fullName, ref string) (string, error) {
+	if ref == "" || strings.Contains(ref, "..") {
+		return "", fmt.Errorf("Ref %v is not valid", ref)
+	}
+
+	return fmt.Sprintf("https://api.github.com/repos/%v/commits/%v", fullName, url.PathEscape(ref)), nil
+}
+
 // JobVarsFunc returns a function that can get an access token and authenticated url for a repository
 func (gh *apiClientImpl) JobVarsFunc() func(context.Context, string, string, string) (string, string, error) {
 	return func(ctx context.Context, repoSource, repoOwner, repoName string) (token string, url string, err error) {
@@ -220,6 +265,40 @@ func (gh *apiClientImpl) JobVarsFunc() func(context.Context, string, string, str
 	}
 }
 
+// ManifestFunc returns a function that resolves a branch, tag or sha of a repository to a commit sha and gets the manifest at that commit
+func (gh *apiClientImpl) ManifestFunc() func(context.Context, string, string, string, string) (string, bool, string, error) {
+	return func(ctx context.Context, repoSource, repoOwner, repoName, ref string) (revision string, exists bool, manifest string, err error) {
+		// get installation id with just the repo owner
+		installationID, err := gh.GetInstallationID(ctx, repoOwner)
+		if err != nil {
+			return
+		}
+
+		// get access token
+		accessToken, err := gh.GetInstallationToken(ctx, installationID)
+		if err != nil {
+			return
+		}
+
+		fullName := fmt.Sprintf("%v/%v", repoOwner, repoName)
+
+		revision, err = gh.GetCommitSha(ctx, accessToken, fullName, ref)
+		if err != nil {
+			return
+		}
+
+		// the manifest gets retrieved the same way as for a push of the commit
+		exists, manifest, err = gh.GetEstafetteManifest(ctx, accessToken, ghcontracts.PushEvent{
+			After: revision,
+			Repository: ghcontracts.Repository{
+				FullName: fullName,
+			},
+		})
+
+		return
+	}
+}
+
 func (gh *apiClientImpl) callGithubAPI(span opentracing.Span, method, url string, params interface{}, authorizationType, token string) (statusCode int, body []byte, err error) {
 
 	// track call via prometheus
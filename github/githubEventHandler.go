@@ -9,7 +9,11 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/estafette/estafette-ci-api/config"
 	"github.com/estafette/estafette-ci-api/estafette"
@@ -32,29 +36,43 @@ type EventHandler interface {
 }
 
 type eventHandlerImpl struct {
-	apiClient                    APIClient
-	pubsubAPIClient              pubsub.APIClient
-	buildService                 estafette.BuildService
-	config                       config.GithubConfig
-	prometheusInboundEventTotals *prometheus.CounterVec
+	apiClient                        APIClient
+	pubsubAPIClient                  pubsub.APIClient
+	buildService                     estafette.BuildService
+	config                           config.GithubConfig
+	prometheusInboundEventTotals     *prometheus.CounterVec
+	prometheusWebhookDurationSeconds *prometheus.HistogramVec
+	prometheusWebhookErrorTotals     *prometheus.CounterVec
 }
 
 // NewGithubEventHandler returns a github.EventHandler to handle incoming webhook events
-func NewGithubEventHandler(apiClient APIClient, pubsubAPIClient pubsub.APIClient, buildService estafette.BuildService, config config.GithubConfig, prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
+func NewGithubEventHandler(apiClient APIClient, pubsubAPIClient pubsub.APIClient, buildService estafette.BuildService, config config.GithubConfig, prometheusInboundEventTotals *prometheus.CounterVec, prometheusWebhookDurationSeconds *prometheus.HistogramVec, prometheusWebhookErrorTotals *prometheus.CounterVec) EventHandler {
 	return &eventHandlerImpl{
-		apiClient:                    apiClient,
-		pubsubAPIClient:              pubsubAPIClient,
-		buildService:                 buildService,
-		config:                       config,
-		prometheusInboundEventTotals: prometheusInboundEventTotals,
+		apiClient:                        apiClient,
+		pubsubAPIClient:                  pubsubAPIClient,
+		buildService:                     buildService,
+		config:                           config,
+		prometheusInboundEventTotals:     prometheusInboundEventTotals,
+		prometheusWebhookDurationSeconds: prometheusWebhookDurationSeconds,
+		prometheusWebhookErrorTotals:     prometheusWebhookErrorTotals,
 	}
 }
 
 func (h *eventHandlerImpl) Handle(c *gin.Context) {
 
+	start := time.Now()
+
 	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Github::Handle")
 	defer span.Finish()
 
+	// push events finish processing in the background and get observed once that is done
+	processingInBackground := false
+	defer func() {
+		if !processingInBackground {
+			h.observeWebhook(start, c.Writer.Status())
+		}
+	}()
+
 	// https://developer.github.com/webhooks/
 	eventType := c.GetHeader("X-Github-Event")
 	h.prometheusInboundEventTotals.With(prometheus.Labels{"event": eventType, "source": "github"}).Inc()
@@ -90,7 +108,8 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 			return
 		}
 
-		h.CreateJobForGithubPush(ctx, pushEvent)
+		processingInBackground = true
+		h.createJobForGithubPush(ctx, pushEvent, start)
 
 	case
 		"commit_comment",                        // Any time a Commit is commented on.
@@ -154,10 +173,28 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 }
 
 func (h *eventHandlerImpl) CreateJobForGithubPush(ctx context.Context, pushEvent ghcontracts.PushEvent) {
+	h.createJobForGithubPush(ctx, pushEvent, time.Now())
+}
+
+// createJobForGithubPush creates a build for the pushed revision and records the webhook metrics once the git triggers and pubsub subscriptions running in the background are done as well
+func (h *eventHandlerImpl) createJobForGithubPush(ctx context.Context, pushEvent ghcontracts.PushEvent, start time.Time) {
 
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Github::CreateJobForGithubPush")
 	defer span.Finish()
 
+	var wg sync.WaitGroup
+	var failed int32
+	defer func() {
+		go func() {
+			wg.Wait()
+			code := http.StatusOK
+			if atomic.LoadInt32(&failed) > 0 {
+				code = http.StatusInternalServerError
+			}
+			h.observeWebhook(start, code)
+		}()
+	}()
+
 	// check to see that it's a cloneable event
 	if !strings.HasPrefix(pushEvent.Ref, "refs/heads/") {
 		return
@@ -175,9 +212,12 @@ func (h *eventHandlerImpl) CreateJobForGithubPush(ctx context.Context, pushEvent
 	}
 
 	// handle git triggers
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		err := h.buildService.FireGitTriggers(ctx, gitEvent)
 		if err != nil {
+			atomic.StoreInt32(&failed, 1)
 			log.Error().Err(err).
 				Interface("gitEvent", gitEvent).
 				Msg("Failed firing git triggers")
@@ -189,6 +229,7 @@ func (h *eventHandlerImpl) CreateJobForGithubPush(ctx context.Context, pushEvent
 	if err != nil {
 		log.Error().Err(err).
 			Msg("Retrieving access token failed")
+		atomic.StoreInt32(&failed, 1)
 		return
 	}
 
@@ -197,6 +238,7 @@ func (h *eventHandlerImpl) CreateJobForGithubPush(ctx context.Context, pushEvent
 	if err != nil {
 		log.Error().Err(err).
 			Msg("Retrieving Estafettte manifest failed")
+		atomic.StoreInt32(&failed, 1)
 		return
 	}
 
@@ -235,14 +277,18 @@ func (h *eventHandlerImpl) CreateJobForGithubPush(ctx context.Context, pushEvent
 
 	if err != nil {
 		log.Error().Err(err).Msgf("Failed creating build for pipeline %v/%v/%v with revision %v", pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName(), pushEvent.GetRepoRevision())
+		atomic.StoreInt32(&failed, 1)
 		return
 	}
 
 	log.Info().Msgf("Created build for pipeline %v/%v/%v with revision %v", pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName(), pushEvent.GetRepoRevision())
 
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		err := h.pubsubAPIClient.SubscribeToPubsubTriggers(ctx, manifestString)
 		if err != nil {
+			atomic.StoreInt32(&failed, 1)
 			log.Error().Err(err).Msgf("Failed subscribing to topics for pubsub triggers for build %v/%v/%v revision %v", pushEvent.GetRepoSource(), pushEvent.GetRepoOwner(), pushEvent.GetRepoName(), pushEvent.GetRepoRevision())
 		}
 	}()
@@ -275,6 +321,15 @@ func (h *eventHandlerImpl) HasValidSignature(body []byte, signatureHeader string
 	return false, nil
 }
 
+// observeWebhook records how long processing an inbound webhook took and counts it as failed if it ended with an error code
+func (h *eventHandlerImpl) observeWebhook(start time.Time, code int) {
+	h.prometheusWebhookDurationSeconds.With(prometheus.Labels{"source": "github"}).Observe(time.Since(start).Seconds())
+
+	if code >= http.StatusBadRequest {
+		h.prometheusWebhookErrorTotals.With(prometheus.Labels{"source": "github", "code": strconv.Itoa(code)}).Inc()
+	}
+}
+
 func (h *eventHandlerImpl) Rename(ctx context.Context, fromRepoSource, fromRepoOwner, fromRepoName, toRepoSource, toRepoOwner, toRepoName string) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Github::Rename")
 	defer span.Finish()
@@ -19,6 +19,7 @@ import (
 	prom "github.com/estafette/estafette-ci-api/prometheus"
 	"github.com/estafette/estafette-ci-api/pubsub"
 	"github.com/estafette/estafette-ci-api/slack"
+	"github.com/estafette/estafette-ci-api/webhooks"
 	crypt "github.com/estafette/estafette-ci-crypt"
 	foundation "github.com/estafette/estafette-foundation"
 	"github.com/gin-contrib/gzip"
@@ -64,12 +65,107 @@ var (
 		},
 		[]string{"target"},
 	)
+
+	// prometheusReapedTotals is the prometheus timeline serie that keeps track of jobs and configmaps removed by the job reaper
+	prometheusReapedTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_ci_api_reaped_totals",
+			Help: "Total of build and release jobs and configmaps removed by the job reaper.",
+		},
+		[]string{"kind", "reason"},
+	)
+
+	// prometheusJobDurationSeconds is the prometheus histogram that keeps track of the duration of finished builds and releases
+	prometheusJobDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "estafette_ci_api_job_duration_seconds",
+			Help:    "Duration of finished builds and releases, including the time spent pending.",
+			Buckets: []float64{30, 60, 120, 300, 600, 900, 1200, 1800, 2700, 3600, 5400, 7200, 14400},
+		},
+		[]string{"type", "status", "repo_owner"},
+	)
+
+	// prometheusJobPendingSeconds is the prometheus histogram that keeps track of the time builds and releases wait for their job to start running
+	prometheusJobPendingSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "estafette_ci_api_job_pending_seconds",
+			Help:    "Time builds and releases wait for their job to start running.",
+			Buckets: []float64{1, 2, 5, 10, 20, 30, 60, 120, 300, 600, 900},
+		},
+		[]string{"type"},
+	)
+
+	// prometheusRunningJobs is the prometheus gauge that keeps track of the number of build and release jobs currently running
+	prometheusRunningJobs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "estafette_ci_api_running_jobs",
+			Help: "Number of build and release jobs currently running.",
+		},
+		[]string{"type", "cluster"},
+	)
+
+	// prometheusTriggerFiringTotals is the prometheus timeline serie that keeps track of fired triggers
+	prometheusTriggerFiringTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_ci_api_trigger_firing_totals",
+			Help: "Total of fired triggers.",
+		},
+		[]string{"trigger", "action"},
+	)
+
+	// prometheusWebhookDurationSeconds is the prometheus histogram that keeps track of the time it takes to process inbound webhooks
+	prometheusWebhookDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "estafette_ci_api_webhook_duration_seconds",
+			Help:    "Time it takes to process inbound webhooks.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"source"},
+	)
+
+	// prometheusWebhookErrorTotals is the prometheus timeline serie that keeps track of inbound webhooks that failed processing
+	prometheusWebhookErrorTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_ci_api_webhook_error_totals",
+			Help: "Total of inbound webhooks that failed processing.",
+		},
+		[]string{"source", "code"},
+	)
+
+	// prometheusLogInsertBytes is the prometheus histogram that keeps track of the size of inserted build and release logs
+	prometheusLogInsertBytes = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "estafette_ci_api_log_insert_bytes",
+			Help:    "Size of inserted build and release logs.",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 10),
+		},
+		[]string{"type"},
+	)
+
+	// prometheusDatabaseQueryDurationSeconds is the prometheus histogram that keeps track of the latency of database queries
+	prometheusDatabaseQueryDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "estafette_ci_api_database_query_duration_seconds",
+			Help:    "Latency of database queries per database client method.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method"},
+	)
 )
 
 func init() {
 	// Metrics have to be registered to be exposed:
 	prometheus.MustRegister(prometheusInboundEventTotals)
 	prometheus.MustRegister(prometheusOutboundAPICallTotals)
+	prometheus.MustRegister(prometheusReapedTotals)
+	prometheus.MustRegister(prometheusJobDurationSeconds)
+	prometheus.MustRegister(prometheusJobPendingSeconds)
+	prometheus.MustRegister(prometheusRunningJobs)
+	prometheus.MustRegister(prometheusTriggerFiringTotals)
+	prometheus.MustRegister(prometheusWebhookDurationSeconds)
+	prometheus.MustRegister(prometheusWebhookErrorTotals)
+	prometheus.MustRegister(prometheusLogInsertBytes)
+	prometheus.MustRegister(prometheusDatabaseQueryDurationSeconds)
 }
 
 func main() {
@@ -132,8 +228,8 @@ func initRequestHandlers(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Creating new PubSubAPIClient has failed")
 	}
-	cockroachDBClient := cockroach.NewCockroachDBClient(*config.Database, prometheusOutboundAPICallTotals)
-	ciBuilderClient, err := estafette.NewCiBuilderClient(*config, *encryptedConfig, secretHelper, prometheusOutboundAPICallTotals)
+	cockroachDBClient := cockroach.NewCockroachDBClient(*config.Database, prometheusOutboundAPICallTotals, prometheusDatabaseQueryDurationSeconds, prometheusLogInsertBytes)
+	ciBuilderClient, err := estafette.NewCiBuilderClient(*config, *encryptedConfig, secretHelper, cockroachDBClient, prometheusOutboundAPICallTotals, prometheusRunningJobs)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Creating new CiBuilderClient has failed")
 	}
@@ -155,14 +251,19 @@ func initRequestHandlers(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
 
 	log.Debug().Msg("Creating services, handlers and helpers...")
 	prometheusClient := prom.NewPrometheusClient(*config.Integrations.Prometheus)
-	estafetteBuildService := estafette.NewBuildService(*config.Jobs, cockroachDBClient, ciBuilderClient, githubAPIClient.JobVarsFunc(), bitbucketAPIClient.JobVarsFunc())
-	githubEventHandler := github.NewGithubEventHandler(githubAPIClient, pubSubAPIClient, estafetteBuildService, *config.Integrations.Github, prometheusInboundEventTotals)
-	bitbucketEventHandler := bitbucket.NewBitbucketEventHandler(bitbucketAPIClient, pubSubAPIClient, estafetteBuildService, prometheusInboundEventTotals)
-	slackEventHandler := slack.NewSlackEventHandler(secretHelper, *config.Integrations.Slack, slackAPIClient, cockroachDBClient, *config.APIServer, estafetteBuildService, githubAPIClient.JobVarsFunc(), bitbucketAPIClient.JobVarsFunc(), prometheusInboundEventTotals)
-	pubsubEventHandler := pubsub.NewPubSubEventHandler(pubSubAPIClient, estafetteBuildService)
-	estafetteEventHandler := estafette.NewEstafetteEventHandler(*config.APIServer, ciBuilderClient, prometheusClient, estafetteBuildService, cockroachDBClient, prometheusInboundEventTotals)
+	resourceUsageCollector := estafette.NewResourceUsageCollector(*config.Integrations.Prometheus, prometheusClient, cockroachDBClient)
+	webhookNotifier := webhooks.NewWebhookNotifier(config.Integrations.Webhooks, *config.APIServer, cockroachDBClient, prometheusOutboundAPICallTotals)
+	slackNotifier := slack.NewSlackNotifier(*config.Integrations.Slack, *config.APIServer, slackAPIClient, cockroachDBClient)
+	resourceRecommender := estafette.NewResourceRecommender(*config.Jobs, cockroachDBClient)
+	estafetteBuildService := estafette.NewBuildService(*config.Jobs, cockroachDBClient, ciBuilderClient, resourceRecommender, githubAPIClient.JobVarsFunc(), bitbucketAPIClient.JobVarsFunc(), slackNotifier.BuildNotifyFunc(), slackNotifier.ReleaseNotifyFunc(), webhookNotifier, pubSubAPIClient.BuildEventPublishFunc(), pubSubAPIClient.ReleaseEventPublishFunc(), prometheusJobDurationSeconds, prometheusJobPendingSeconds, prometheusTriggerFiringTotals)
+	githubEventHandler := github.NewGithubEventHandler(githubAPIClient, pubSubAPIClient, estafetteBuildService, *config.Integrations.Github, prometheusInboundEventTotals, prometheusWebhookDurationSeconds, prometheusWebhookErrorTotals)
+	bitbucketEventHandler := bitbucket.NewBitbucketEventHandler(bitbucketAPIClient, pubSubAPIClient, estafetteBuildService, prometheusInboundEventTotals, prometheusWebhookDurationSeconds, prometheusWebhookErrorTotals)
+	slackEventHandler := slack.NewSlackEventHandler(secretHelper, *config.Integrations.Slack, slackAPIClient, slackNotifier, cockroachDBClient, *config.APIServer, estafetteBuildService, githubAPIClient.JobVarsFunc(), bitbucketAPIClient.JobVarsFunc(), prometheusInboundEventTotals)
+	pubsubSubscriptionReconciler := pubsub.NewSubscriptionReconciler(*config.Integrations.Pubsub, pubSubAPIClient, cockroachDBClient)
+	pubsubEventHandler := pubsub.NewPubSubEventHandler(pubSubAPIClient, estafetteBuildService, pubsubSubscriptionReconciler)
+	estafetteEventHandler := estafette.NewEstafetteEventHandler(*config.APIServer, ciBuilderClient, resourceUsageCollector, estafetteBuildService, cockroachDBClient, prometheusInboundEventTotals)
 	warningHelper := estafette.NewWarningHelper()
-	estafetteAPIHandler := estafette.NewAPIHandler(*configFilePath, *config.APIServer, *config.Auth, *encryptedConfig, cockroachDBClient, ciBuilderClient, estafetteBuildService, warningHelper, secretHelper, githubAPIClient.JobVarsFunc(), bitbucketAPIClient.JobVarsFunc())
+	estafetteAPIHandler := estafette.NewAPIHandler(*configFilePath, *config.APIServer, *config.Auth, *encryptedConfig, cockroachDBClient, ciBuilderClient, estafetteBuildService, warningHelper, secretHelper, githubAPIClient.JobVarsFunc(), bitbucketAPIClient.JobVarsFunc(), githubAPIClient.ManifestFunc(), bitbucketAPIClient.ManifestFunc(), webhookNotifier)
 
 	// run gin in release mode and other defaults
 	gin.SetMode(gin.ReleaseMode)
@@ -196,13 +297,14 @@ func initRequestHandlers(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
 	router.POST("/api/integrations/bitbucket/events", bitbucketEventHandler.Handle)
 	router.GET("/api/integrations/bitbucket/status", func(c *gin.Context) { c.String(200, "Bitbucket, I'm cool!") })
 
-	router.POST("/api/integrations/slack/slash", slackEventHandler.Handle)
+	router.POST("/api/integrations/slack/slash", WebhookMetricsMiddleware("slack", prometheusWebhookDurationSeconds, prometheusWebhookErrorTotals), slackEventHandler.Handle)
+	router.POST("/api/integrations/slack/interactive", WebhookMetricsMiddleware("slack", prometheusWebhookDurationSeconds, prometheusWebhookErrorTotals), slackEventHandler.HandleInteractive)
 	router.GET("/api/integrations/slack/status", func(c *gin.Context) { c.String(200, "Slack, I'm cool!") })
 
 	// google jwt auth protected endpoints
 	googleAuthorizedRoutes := router.Group("/", authMiddleware.GoogleJWTMiddlewareFunc())
 	{
-		googleAuthorizedRoutes.POST("/api/integrations/pubsub/events", pubsubEventHandler.PostPubsubEvent)
+		googleAuthorizedRoutes.POST("/api/integrations/pubsub/events", WebhookMetricsMiddleware("pubsub", prometheusWebhookDurationSeconds, prometheusWebhookErrorTotals), pubsubEventHandler.PostPubsubEvent)
 	}
 	router.GET("/api/integrations/pubsub/status", func(c *gin.Context) { c.String(200, "Pub/Sub, I'm cool!") })
 
@@ -212,10 +314,18 @@ func initRequestHandlers(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
 	router.GET("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId", estafetteAPIHandler.GetPipelineBuild)
 	router.GET("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/logs", estafetteAPIHandler.GetPipelineBuildLogs)
 	router.GET("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/warnings", estafetteAPIHandler.GetPipelineBuildWarnings)
+	router.GET("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/retries", estafetteAPIHandler.GetPipelineBuildRetries)
+	router.GET("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/parameters", estafetteAPIHandler.GetPipelineBuildParameters)
+	router.GET("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/resources", estafetteAPIHandler.GetPipelineBuildResources)
+	router.GET("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/resourceusage", estafetteAPIHandler.GetPipelineBuildResourceUsage)
+	router.GET("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/testreports", estafetteAPIHandler.GetPipelineBuildTestReports)
 	router.GET("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/logs/tail", estafetteAPIHandler.TailPipelineBuildLogs)
 	router.GET("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/logs.stream", estafetteAPIHandler.TailPipelineBuildLogs)
 	router.GET("/api/pipelines/:source/:owner/:repo/releases", estafetteAPIHandler.GetPipelineReleases)
 	router.GET("/api/pipelines/:source/:owner/:repo/releases/:id", estafetteAPIHandler.GetPipelineRelease)
+	router.GET("/api/pipelines/:source/:owner/:repo/releases/:id/parameters", estafetteAPIHandler.GetPipelineReleaseParameters)
+	router.GET("/api/pipelines/:source/:owner/:repo/releases/:id/resources", estafetteAPIHandler.GetPipelineReleaseResources)
+	router.GET("/api/pipelines/:source/:owner/:repo/releases/:id/resourceusage", estafetteAPIHandler.GetPipelineReleaseResourceUsage)
 	router.GET("/api/pipelines/:source/:owner/:repo/releases/:id/logs", estafetteAPIHandler.GetPipelineReleaseLogs)
 	router.GET("/api/pipelines/:source/:owner/:repo/releases/:id/logs/tail", estafetteAPIHandler.TailPipelineReleaseLogs)
 	router.GET("/api/pipelines/:source/:owner/:repo/releases/:id/logs.stream", estafetteAPIHandler.TailPipelineReleaseLogs)
@@ -225,15 +335,23 @@ func initRequestHandlers(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
 	router.GET("/api/pipelines/:source/:owner/:repo/stats/releasescpu", estafetteAPIHandler.GetPipelineStatsReleasesCPUUsageMeasurements)
 	router.GET("/api/pipelines/:source/:owner/:repo/stats/buildsmemory", estafetteAPIHandler.GetPipelineStatsBuildsMemoryUsageMeasurements)
 	router.GET("/api/pipelines/:source/:owner/:repo/stats/releasesmemory", estafetteAPIHandler.GetPipelineStatsReleasesMemoryUsageMeasurements)
+	router.GET("/api/pipelines/:source/:owner/:repo/stats/flakiness", estafetteAPIHandler.GetPipelineStatsFlakiness)
+	router.GET("/api/pipelines/:source/:owner/:repo/stats/steps", estafetteAPIHandler.GetPipelineStatsSteps)
 	router.GET("/api/pipelines/:source/:owner/:repo/warnings", estafetteAPIHandler.GetPipelineWarnings)
+	router.GET("/api/pipelines/:source/:owner/:repo/testreports", estafetteAPIHandler.GetPipelineTestReports)
 	router.GET("/api/stats/pipelinescount", estafetteAPIHandler.GetStatsPipelinesCount)
 	router.GET("/api/stats/buildscount", estafetteAPIHandler.GetStatsBuildsCount)
 	router.GET("/api/stats/releasescount", estafetteAPIHandler.GetStatsReleasesCount)
 	router.GET("/api/stats/buildsduration", estafetteAPIHandler.GetStatsBuildsDuration)
+	router.GET("/api/stats/buildsseries", estafetteAPIHandler.GetStatsBuildsTimeSeries)
+	router.GET("/api/stats/releasesseries", estafetteAPIHandler.GetStatsReleasesTimeSeries)
 	router.GET("/api/stats/buildsadoption", estafetteAPIHandler.GetStatsBuildsAdoption)
 	router.GET("/api/stats/releasesadoption", estafetteAPIHandler.GetStatsReleasesAdoption)
 	router.GET("/api/stats/mostbuilds", estafetteAPIHandler.GetStatsMostBuilds)
 	router.GET("/api/stats/mostreleases", estafetteAPIHandler.GetStatsMostReleases)
+	router.GET("/api/stats/mostfailingstages", estafetteAPIHandler.GetStatsMostFailingStepImages)
+	router.GET("/api/stats/dora", estafetteAPIHandler.GetStatsDORA)
+	router.GET("/api/stats/costs", estafetteAPIHandler.GetStatsCosts)
 	router.GET("/api/manifest/templates", estafetteAPIHandler.GetManifestTemplates)
 	router.POST("/api/manifest/generate", estafetteAPIHandler.GenerateManifest)
 	router.POST("/api/manifest/validate", estafetteAPIHandler.ValidateManifest)
@@ -245,6 +363,7 @@ func initRequestHandlers(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
 	{
 		apiKeyAuthorizedRoutes.POST("/api/commands", estafetteEventHandler.Handle)
 		apiKeyAuthorizedRoutes.POST("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/logs", estafetteAPIHandler.PostPipelineBuildLogs)
+		apiKeyAuthorizedRoutes.POST("/api/pipelines/:source/:owner/:repo/builds/:revisionOrId/testreports", estafetteAPIHandler.PostPipelineBuildTestReports)
 		apiKeyAuthorizedRoutes.POST("/api/pipelines/:source/:owner/:repo/releases/:id/logs", estafetteAPIHandler.PostPipelineReleaseLogs)
 		apiKeyAuthorizedRoutes.POST("/api/integrations/cron/events", estafetteAPIHandler.PostCronEvent)
 	}
@@ -261,6 +380,10 @@ func initRequestHandlers(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
 		iapAuthorizedRoutes.GET("/api/config/credentials", estafetteAPIHandler.GetConfigCredentials)
 		iapAuthorizedRoutes.GET("/api/config/trustedimages", estafetteAPIHandler.GetConfigTrustedImages)
 		iapAuthorizedRoutes.GET("/api/update-computed-tables", estafetteAPIHandler.UpdateComputedTables)
+		iapAuthorizedRoutes.GET("/api/webhooks/deliveries", estafetteAPIHandler.GetWebhookDeliveries)
+		iapAuthorizedRoutes.POST("/api/webhooks/deliveries/:id/redeliver", estafetteAPIHandler.RedeliverWebhookDelivery)
+		iapAuthorizedRoutes.GET("/api/integrations/pubsub/subscriptions", pubsubEventHandler.GetSubscriptionsStatus)
+		iapAuthorizedRoutes.POST("/api/integrations/pubsub/subscriptions/reconcile", pubsubEventHandler.ReconcileSubscriptions)
 	}
 
 	// default routes
@@ -274,6 +397,34 @@ func initRequestHandlers(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
 		c.JSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Page not found"})
 	})
 
+	// send webhook deliveries with a bounded number of workers
+	webhookNotifier.Run(stopChannel, waitGroup)
+
+	// keep pubsub trigger subscriptions in line with the triggers in all pipelines
+	pubsubSubscriptionReconciler.Run(stopChannel, waitGroup)
+
+	// pull pubsub messages in-process if the push endpoint isn't publicly reachable
+	pubsubConsumer := pubsub.NewPubSubConsumer(*config.Integrations.Pubsub, pubSubAPIClient, cockroachDBClient, estafetteBuildService)
+	pubsubConsumer.Run(stopChannel, waitGroup)
+
+	// fail builds and releases whose pods got oom-killed, evicted or are stuck and clean up their jobs
+	ciBuilderClient.WatchCiBuilderJobs(stopChannel, waitGroup, estafetteEventHandler.HandleFailedJob)
+
+	// remove jobs and configmaps left behind when the builder's clean-up callback got lost
+	jobReaper := estafette.NewJobReaper(*config.Jobs, ciBuilderClient, cockroachDBClient, prometheusReapedTotals)
+	jobReaper.Run(stopChannel, waitGroup)
+
+	// mark builds and releases exceeding their timeout as timed out and cancel their jobs
+	timeoutSweeper := estafette.NewTimeoutSweeper(*config.Jobs, estafetteBuildService, ciBuilderClient, cockroachDBClient)
+	timeoutSweeper.Run(stopChannel, waitGroup)
+
+	// reconcile builds and releases stuck in pending, running or canceling with the state of their jobs
+	staleStatusSweeper := estafette.NewStaleStatusSweeper(*config.Jobs, estafetteBuildService, ciBuilderClient, cockroachDBClient)
+	staleStatusSweeper.Run(stopChannel, waitGroup)
+
+	// collect the resource usage of finished jobs from prometheus once it has scraped their last samples
+	resourceUsageCollector.Run(stopChannel, waitGroup)
+
 	// instantiate servers instead of using router.Run in order to handle graceful shutdown
 	log.Debug().Msg("Starting server...")
 	srv := &http.Server{
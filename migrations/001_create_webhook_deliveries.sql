@@ -0,0 +1,16 @@
+-- log of webhook deliveries, used to inspect and redeliver events sent to webhook subscribers
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id SERIAL PRIMARY KEY,
+  subscriber_name VARCHAR(256) NOT NULL,
+  url VARCHAR(2048) NOT NULL,
+  event_id VARCHAR(64) NOT NULL,
+  event_type VARCHAR(256) NOT NULL,
+  payload STRING NOT NULL,
+  status_code INT NOT NULL DEFAULT 0,
+  succeeded BOOLEAN NOT NULL DEFAULT false,
+  attempts INT NOT NULL DEFAULT 0,
+  error STRING NOT NULL DEFAULT '',
+  inserted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  INDEX webhook_deliveries_inserted_at (inserted_at DESC)
+);
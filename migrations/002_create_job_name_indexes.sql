@@ -0,0 +1,9 @@
+-- name of the job a build or release got dispatched as and the cluster it runs in
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS job_name VARCHAR(256);
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS cluster VARCHAR(256);
+ALTER TABLE releases ADD COLUMN IF NOT EXISTS job_name VARCHAR(256);
+ALTER TABLE releases ADD COLUMN IF NOT EXISTS cluster VARCHAR(256);
+
+-- lookup of the cluster a build or release job was dispatched to by its job name
+CREATE INDEX IF NOT EXISTS builds_job_name ON builds (job_name);
+CREATE INDEX IF NOT EXISTS releases_job_name ON releases (job_name);
@@ -0,0 +1,6 @@
+-- leases on background tasks that only one api replica runs at a time, held until expires_at unless renewed by the holder
+CREATE TABLE IF NOT EXISTS leases (
+  name VARCHAR(256) PRIMARY KEY,
+  holder VARCHAR(256) NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL
+);
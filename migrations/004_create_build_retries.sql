@@ -0,0 +1,16 @@
+-- retries of builds lost to node preemption; each build gets retried at most once and each attempt for a version gets claimed only once,
+-- so a preemption reported more than once, or by more than one replica, doesn't start several retry builds
+CREATE TABLE IF NOT EXISTS build_retries (
+  id SERIAL PRIMARY KEY,
+  repo_source VARCHAR(256) NOT NULL,
+  repo_owner VARCHAR(256) NOT NULL,
+  repo_name VARCHAR(256) NOT NULL,
+  build_version VARCHAR(256) NOT NULL,
+  build_id INT NOT NULL,
+  retry_build_id INT,
+  attempt INT NOT NULL,
+  reason STRING NOT NULL DEFAULT '',
+  inserted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE INDEX build_retries_build_id (build_id),
+  UNIQUE INDEX build_retries_version_attempt (repo_source, repo_owner, repo_name, build_version, attempt)
+);
@@ -0,0 +1,2 @@
+-- builder track a build was started with manually, if it overrides the one in the manifest, so retries run on the same track
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS builder_track VARCHAR(256);
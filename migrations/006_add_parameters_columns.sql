@@ -0,0 +1,3 @@
+-- values of the parameters a build or release was started with manually
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS parameters JSONB;
+ALTER TABLE releases ADD COLUMN IF NOT EXISTS parameters JSONB;
@@ -0,0 +1,19 @@
+-- results of the individual tests in the test reports posted for builds
+CREATE TABLE IF NOT EXISTS build_test_cases (
+  id SERIAL PRIMARY KEY,
+  repo_source VARCHAR(256) NOT NULL,
+  repo_owner VARCHAR(256) NOT NULL,
+  repo_name VARCHAR(256) NOT NULL,
+  repo_branch VARCHAR(256) NOT NULL,
+  build_id INT NOT NULL,
+  build_version VARCHAR(256) NOT NULL,
+  suite_name VARCHAR(512) NOT NULL,
+  class_name VARCHAR(512) NOT NULL DEFAULT '',
+  name VARCHAR(1024) NOT NULL,
+  status VARCHAR(64) NOT NULL,
+  duration_seconds FLOAT NOT NULL DEFAULT 0,
+  failure_message STRING NOT NULL DEFAULT '',
+  inserted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  INDEX build_test_cases_build_id (repo_source, repo_owner, repo_name, build_id),
+  INDEX build_test_cases_inserted_at (repo_source, repo_owner, repo_name, inserted_at DESC)
+);
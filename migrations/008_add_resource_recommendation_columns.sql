@@ -0,0 +1,5 @@
+-- whether a build or release job ran out of memory, and the resources its job got with the reasoning behind them
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS oom_killed BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS resource_recommendation JSONB;
+ALTER TABLE releases ADD COLUMN IF NOT EXISTS oom_killed BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE releases ADD COLUMN IF NOT EXISTS resource_recommendation JSONB;
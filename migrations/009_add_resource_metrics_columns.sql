@@ -0,0 +1,3 @@
+-- resource usage curves and configured job metrics collected from prometheus once a build or release finished
+ALTER TABLE builds ADD COLUMN IF NOT EXISTS resource_metrics JSONB;
+ALTER TABLE releases ADD COLUMN IF NOT EXISTS resource_metrics JSONB;
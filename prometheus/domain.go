@@ -1,5 +1,9 @@
 package prometheus
 
+import (
+	"time"
+)
+
 // PrometheusQueryResponse is used to unmarshal the response from a prometheus query
 // {
 // 	"status":"success",
@@ -26,6 +30,13 @@ type PrometheusQueryResponseData struct {
 
 // PrometheusQueryResponseDataResult is used to unmarshal the response from a prometheus query
 type PrometheusQueryResponseDataResult struct {
-	Metric interface{}   `json:"metric"`
-	Value  []interface{} `json:"value"`
+	Metric interface{}     `json:"metric"`
+	Value  []interface{}   `json:"value"`
+	Values [][]interface{} `json:"values"`
+}
+
+// PrometheusSample is a single value of a metric at a point in time
+type PrometheusSample struct {
+	Time  time.Time
+	Value float64
 }
@@ -1,7 +1,11 @@
 package prometheus
 
 import (
+	"bytes"
 	"encoding/json"
+	"math"
+	"sort"
+	"text/template"
 	"time"
 
 	"fmt"
@@ -10,61 +14,120 @@ import (
 	"strconv"
 
 	"github.com/estafette/estafette-ci-api/config"
-	"github.com/rs/zerolog/log"
 	"github.com/sethgrid/pester"
 )
 
+const (
+	// the container in build and release job pods running the builder
+	builderContainerName = "estafette-ci-builder"
+
+	// usage curves are stored, so keep the number of samples per metric per job limited
+	maxRangeSamples = 250
+)
+
+// defaultQueries are the templates for the metrics collected for build and release jobs, using the labels of recent cAdvisor versions
+var defaultQueries = map[string]config.PrometheusQueryConfig{
+	"cpu": {
+		Query:      `max_over_time(sum(rate(container_cpu_usage_seconds_total{container="{{.Container}}",pod="{{.PodName}}"}[1m]))[{{.Window}}:])`,
+		RangeQuery: `sum(rate(container_cpu_usage_seconds_total{container="{{.Container}}",pod="{{.PodName}}"}[1m]))`,
+	},
+	"memory": {
+		Query:      `max(max_over_time(container_memory_working_set_bytes{container="{{.Container}}",pod="{{.PodName}}"}[{{.Window}}]))`,
+		RangeQuery: `sum(container_memory_working_set_bytes{container="{{.Container}}",pod="{{.PodName}}"})`,
+	},
+	"networkReceiveBytes": {
+		Query:      `sum(increase(container_network_receive_bytes_total{pod="{{.PodName}}"}[{{.Window}}]))`,
+		RangeQuery: `sum(rate(container_network_receive_bytes_total{pod="{{.PodName}}"}[1m]))`,
+	},
+	"networkTransmitBytes": {
+		Query:      `sum(increase(container_network_transmit_bytes_total{pod="{{.PodName}}"}[{{.Window}}]))`,
+		RangeQuery: `sum(rate(container_network_transmit_bytes_total{pod="{{.PodName}}"}[1m]))`,
+	},
+	"diskReadBytes": {
+		Query:      `sum(increase(container_fs_reads_bytes_total{container="{{.Container}}",pod="{{.PodName}}"}[{{.Window}}]))`,
+		RangeQuery: `sum(rate(container_fs_reads_bytes_total{container="{{.Container}}",pod="{{.PodName}}"}[1m]))`,
+	},
+	"diskWriteBytes": {
+		Query:      `sum(increase(container_fs_writes_bytes_total{container="{{.Container}}",pod="{{.PodName}}"}[{{.Window}}]))`,
+		RangeQuery: `sum(rate(container_fs_writes_bytes_total{container="{{.Container}}",pod="{{.PodName}}"}[1m]))`,
+	},
+	"ephemeralStorageBytes": {
+		Query:      `max(max_over_time(container_fs_usage_bytes{container="{{.Container}}",pod="{{.PodName}}"}[{{.Window}}]))`,
+		RangeQuery: `sum(container_fs_usage_bytes{container="{{.Container}}",pod="{{.PodName}}"})`,
+	},
+}
+
 // PrometheusClient is the interface for communicating with prometheus
 type PrometheusClient interface {
-	AwaitScrapeInterval()
-	GetMaxMemoryByPodName(podName string) (float64, error)
-	GetMaxCPUByPodName(podName string) (float64, error)
+	GetMetricNames() []string
+	GetJobMetric(metric, podName string, start, end time.Time) (float64, error)
+	GetJobMetricRange(metric, podName string, start, end time.Time) ([]PrometheusSample, error)
 }
 
 type prometheusClientImpl struct {
-	config config.PrometheusConfig
+	config  config.PrometheusConfig
+	queries map[string]config.PrometheusQueryConfig
 }
 
 // NewPrometheusClient creates an prometheus.PrometheusClient to communicate with Prometheus
 func NewPrometheusClient(config config.PrometheusConfig) PrometheusClient {
 	return &prometheusClientImpl{
-		config: config,
+		config:  config,
+		queries: getQueries(config),
 	}
 }
 
-func (pc *prometheusClientImpl) AwaitScrapeInterval() {
-	log.Debug().Msgf("Waiting for %v seconds before querying Prometheus", pc.config.ScrapeIntervalSeconds)
-	time.Sleep(time.Duration(pc.config.ScrapeIntervalSeconds) * time.Second)
-}
+// GetMetricNames returns the names of the metrics that have a query configured, in alphabetical order
+func (pc *prometheusClientImpl) GetMetricNames() (names []string) {
+	for name, q := range pc.queries {
+		if q.Query != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
 
-func (pc *prometheusClientImpl) GetMaxMemoryByPodName(podName string) (float64, error) {
-	query := fmt.Sprintf("max_over_time(container_memory_working_set_bytes{container_name=\"estafette-ci-builder\",pod_name=\"%v\"}[3h])", podName)
-	return pc.getQueryResult(query)
+	return
 }
 
-func (pc *prometheusClientImpl) GetMaxCPUByPodName(podName string) (float64, error) {
-	query := fmt.Sprintf("max_over_time(container_cpu_usage_rate1m{container_name=\"estafette-ci-builder\",pod_name=\"%v\"}[3h])", podName)
-	return pc.getQueryResult(query)
-}
+// GetJobMetric returns the value of the metric for the lifetime of a job's pod, evaluated at the time the job ended
+func (pc *prometheusClientImpl) GetJobMetric(metric, podName string, start, end time.Time) (float64, error) {
 
-func (pc *prometheusClientImpl) getQueryResult(query string) (float64, error) {
+	q, ok := pc.queries[metric]
+	if !ok || q.Query == "" {
+		return 0, fmt.Errorf("No query configured for metric %v", metric)
+	}
 
-	prometheusQueryURL := fmt.Sprintf("%v/api/v1/query?query=%v", pc.config.ServerURL, url.QueryEscape(query))
-	resp, err := pester.Get(prometheusQueryURL)
+	query, err := renderQuery(q.Query, podName, end.Sub(start))
 	if err != nil {
-		return 0, fmt.Errorf("Executing prometheus query for query %v failed", query)
+		return 0, err
 	}
 
-	defer resp.Body.Close()
+	return pc.getQueryResult(query, end)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// GetJobMetricRange returns the value of the metric over the lifetime of a job's pod
+func (pc *prometheusClientImpl) GetJobMetricRange(metric, podName string, start, end time.Time) ([]PrometheusSample, error) {
+
+	q, ok := pc.queries[metric]
+	if !ok || q.RangeQuery == "" {
+		return nil, fmt.Errorf("No range query configured for metric %v", metric)
+	}
+
+	query, err := renderQuery(q.RangeQuery, podName, end.Sub(start))
 	if err != nil {
-		return 0, fmt.Errorf("Reading prometheus query response body for query %v failed", query)
+		return nil, err
 	}
 
+	return pc.getRangeQueryResult(query, start, end, getRangeStep(pc.config.RangeStepSeconds, end.Sub(start)))
+}
+
+func (pc *prometheusClientImpl) getQueryResult(query string, at time.Time) (float64, error) {
+
+	prometheusQueryURL := fmt.Sprintf("%v/api/v1/query?query=%v&time=%v", pc.config.ServerURL, url.QueryEscape(query), at.Unix())
+
 	var queryResponse PrometheusQueryResponse
-	if err = json.Unmarshal(body, &queryResponse); err != nil {
-		return 0, fmt.Errorf("Unmarshalling prometheus query response body for query %v failed", query)
+	if err := pc.getResponse(prometheusQueryURL, query, &queryResponse); err != nil {
+		return 0, err
 	}
 
 	if queryResponse.Status != "success" {
@@ -83,10 +146,152 @@ func (pc *prometheusClientImpl) getQueryResult(query string) (float64, error) {
 		return 0, fmt.Errorf("Query response data vector value length %v for query %v not equal to 2", len(queryResponse.Data.Result[0].Value), query)
 	}
 
-	f, err := strconv.ParseFloat(queryResponse.Data.Result[0].Value[1].(string), 64)
+	sample, err := parseSample(queryResponse.Data.Result[0].Value)
 	if err != nil {
 		return 0, err
 	}
 
-	return f, nil
+	return sample.Value, nil
+}
+
+func (pc *prometheusClientImpl) getRangeQueryResult(query string, start, end time.Time, step time.Duration) ([]PrometheusSample, error) {
+
+	prometheusQueryURL := fmt.Sprintf("%v/api/v1/query_range?query=%v&start=%v&end=%v&step=%v", pc.config.ServerURL, url.QueryEscape(query), start.Unix(), end.Unix(), int(step.Seconds()))
+
+	var queryResponse PrometheusQueryResponse
+	if err := pc.getResponse(prometheusQueryURL, query, &queryResponse); err != nil {
+		return nil, err
+	}
+
+	return parseRangeQueryResponse(queryResponse, query)
+}
+
+func (pc *prometheusClientImpl) getResponse(prometheusQueryURL, query string, queryResponse *PrometheusQueryResponse) error {
+
+	resp, err := pester.Get(prometheusQueryURL)
+	if err != nil {
+		return fmt.Errorf("Executing prometheus query for query %v failed", query)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Reading prometheus query response body for query %v failed", query)
+	}
+
+	if err = json.Unmarshal(body, queryResponse); err != nil {
+		return fmt.Errorf("Unmarshalling prometheus query response body for query %v failed", query)
+	}
+
+	return nil
+}
+
+func parseRangeQueryResponse(queryResponse PrometheusQueryResponse, query string) ([]PrometheusSample, error) {
+
+	if queryResponse.Status != "success" {
+		return nil, fmt.Errorf("Query response status %v for query %v not equal to 'success'", queryResponse.Status, query)
+	}
+
+	if queryResponse.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("Query response data result type %v for query %v not equal to 'matrix'", queryResponse.Data.ResultType, query)
+	}
+
+	if len(queryResponse.Data.Result) != 1 {
+		return nil, fmt.Errorf("Query response data matrix length %v for query %v not equal to 1", len(queryResponse.Data.Result), query)
+	}
+
+	samples := make([]PrometheusSample, 0, len(queryResponse.Data.Result[0].Values))
+	for _, v := range queryResponse.Data.Result[0].Values {
+		sample, err := parseSample(v)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, nil
+}
+
+// parseSample converts a [<unix time>, "<value>"] pair into a sample
+func parseSample(value []interface{}) (sample PrometheusSample, err error) {
+
+	if len(value) != 2 {
+		return sample, fmt.Errorf("Sample length %v not equal to 2", len(value))
+	}
+
+	timestamp, ok := value[0].(float64)
+	if !ok {
+		return sample, fmt.Errorf("Sample time %v is not a number", value[0])
+	}
+	sample.Time = time.Unix(0, int64(timestamp*float64(time.Second))).UTC()
+
+	valueString, ok := value[1].(string)
+	if !ok {
+		return sample, fmt.Errorf("Sample value %v is not a string", value[1])
+	}
+	sample.Value, err = strconv.ParseFloat(valueString, 64)
+
+	return
+}
+
+// getQueries returns the built-in query templates overridden by the configured ones
+func getQueries(prometheusConfig config.PrometheusConfig) map[string]config.PrometheusQueryConfig {
+
+	queries := map[string]config.PrometheusQueryConfig{}
+	for name, q := range defaultQueries {
+		queries[name] = q
+	}
+	for name, q := range prometheusConfig.Queries {
+		if q != nil {
+			queries[name] = *q
+		}
+	}
+
+	return queries
+}
+
+// renderQuery fills in a query template for the pod; the window covers the lifetime of the pod in whole seconds
+func renderQuery(queryTemplate, podName string, lifetime time.Duration) (string, error) {
+
+	tmpl, err := template.New("query").Parse(queryTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	window := int(math.Ceil(lifetime.Seconds()))
+	if window < 60 {
+		window = 60
+	}
+
+	var query bytes.Buffer
+	err = tmpl.Execute(&query, struct {
+		PodName   string
+		Container string
+		Window    string
+	}{
+		PodName:   podName,
+		Container: builderContainerName,
+		Window:    fmt.Sprintf("%vs", window),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return query.String(), nil
+}
+
+// getRangeStep returns the configured step between samples, widened for long jobs to stay within the maximum number of samples
+func getRangeStep(rangeStepSeconds int, lifetime time.Duration) time.Duration {
+
+	step := time.Duration(rangeStepSeconds) * time.Second
+	if step <= 0 {
+		step = 15 * time.Second
+	}
+
+	if minStep := time.Duration(math.Ceil(lifetime.Seconds()/maxRangeSamples)) * time.Second; step < minStep {
+		step = minStep
+	}
+
+	return step
 }
@@ -0,0 +1,126 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderQuery(t *testing.T) {
+
+	t.Run("FillsInPodContainerAndWindowInSeconds", func(t *testing.T) {
+
+		// act
+		query, err := renderQuery(`max(max_over_time(container_memory_working_set_bytes{container="{{.Container}}",pod="{{.PodName}}"}[{{.Window}}]))`, "build-estafette-estafette-ci-api-1-abcde", 90500*time.Millisecond)
+
+		assert.Nil(t, err)
+		assert.Equal(t, `max(max_over_time(container_memory_working_set_bytes{container="estafette-ci-builder",pod="build-estafette-estafette-ci-api-1-abcde"}[91s]))`, query)
+	})
+
+	t.Run("UsesAtLeastAMinuteAsWindow", func(t *testing.T) {
+
+		// act
+		query, err := renderQuery(`[{{.Window}}]`, "pod", 5*time.Second)
+
+		assert.Nil(t, err)
+		assert.Equal(t, `[60s]`, query)
+	})
+
+	t.Run("ReturnsErrorForInvalidTemplate", func(t *testing.T) {
+
+		// act
+		_, err := renderQuery(`{{.PodName`, "pod", time.Minute)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetQueries(t *testing.T) {
+
+	t.Run("OverridesDefaultQueriesWithConfiguredOnes", func(t *testing.T) {
+
+		prometheusConfig := config.PrometheusConfig{
+			Queries: map[string]*config.PrometheusQueryConfig{
+				"memory":        &config.PrometheusQueryConfig{Query: "memory-query", RangeQuery: "memory-range-query"},
+				"diskReadBytes": &config.PrometheusQueryConfig{},
+				"gpu":           &config.PrometheusQueryConfig{Query: "gpu-query"},
+			},
+		}
+
+		// act
+		queries := getQueries(prometheusConfig)
+
+		assert.Equal(t, defaultQueries["cpu"], queries["cpu"])
+		assert.Equal(t, "memory-query", queries["memory"].Query)
+		assert.Equal(t, "", queries["diskReadBytes"].Query)
+		assert.Equal(t, "gpu-query", queries["gpu"].Query)
+	})
+
+	t.Run("OnlyReturnsMetricNamesWithQuery", func(t *testing.T) {
+
+		prometheusConfig := config.PrometheusConfig{
+			Queries: map[string]*config.PrometheusQueryConfig{
+				"diskReadBytes": &config.PrometheusQueryConfig{},
+			},
+		}
+		client := NewPrometheusClient(prometheusConfig)
+
+		// act
+		names := client.GetMetricNames()
+
+		assert.Equal(t, []string{"cpu", "diskWriteBytes", "ephemeralStorageBytes", "memory", "networkReceiveBytes", "networkTransmitBytes"}, names)
+	})
+}
+
+func TestGetRangeStep(t *testing.T) {
+
+	t.Run("ReturnsConfiguredStepForShortJobs", func(t *testing.T) {
+
+		// act
+		step := getRangeStep(15, 10*time.Minute)
+
+		assert.Equal(t, 15*time.Second, step)
+	})
+
+	t.Run("WidensStepForLongJobs", func(t *testing.T) {
+
+		// act
+		step := getRangeStep(15, 2*time.Hour)
+
+		assert.Equal(t, 29*time.Second, step)
+	})
+}
+
+func TestParseRangeQueryResponse(t *testing.T) {
+
+	t.Run("ReturnsSamplesOfMatrix", func(t *testing.T) {
+
+		body := `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[1570970970,"558219264"],[1570970985.5,"612000000"]]}]}}`
+		var queryResponse PrometheusQueryResponse
+		json.Unmarshal([]byte(body), &queryResponse)
+
+		// act
+		samples, err := parseRangeQueryResponse(queryResponse, "query")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(samples))
+		assert.Equal(t, time.Unix(1570970970, 0).UTC(), samples[0].Time)
+		assert.Equal(t, 558219264.0, samples[0].Value)
+		assert.Equal(t, time.Unix(1570970985, 500000000).UTC(), samples[1].Time)
+	})
+
+	t.Run("ReturnsErrorForVector", func(t *testing.T) {
+
+		body := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1570970970,"558219264"]}]}}`
+		var queryResponse PrometheusQueryResponse
+		json.Unmarshal([]byte(body), &queryResponse)
+
+		// act
+		_, err := parseRangeQueryResponse(queryResponse, "query")
+
+		assert.NotNil(t, err)
+	})
+}
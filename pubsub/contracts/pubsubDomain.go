@@ -3,6 +3,7 @@ package contracts
 import (
 	"encoding/base64"
 	"strings"
+	"time"
 )
 
 // PubSubPushMessage is a container for a pubsub push message
@@ -34,3 +35,21 @@ func (m PubSubPushMessage) GetDecodedData() string {
 	}
 	return string(data)
 }
+
+// SubscriptionStatus is the outcome of reconciling a single pubsub trigger subscription
+type SubscriptionStatus struct {
+	Name      string   `json:"name"`
+	Project   string   `json:"project,omitempty"`
+	Topic     string   `json:"topic"`
+	Pipelines []string `json:"pipelines,omitempty"`
+	Action    string   `json:"action"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// ReconciliationStatus is the outcome of comparing pubsub triggers with existing subscriptions
+type ReconciliationStatus struct {
+	StartedAt     time.Time            `json:"startedAt"`
+	FinishedAt    time.Time            `json:"finishedAt"`
+	Subscriptions []SubscriptionStatus `json:"subscriptions"`
+	Error         string               `json:"error,omitempty"`
+}
@@ -2,28 +2,45 @@ package pubsub
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	ps "cloud.google.com/go/pubsub"
 	"github.com/estafette/estafette-ci-api/config"
 	pscontracts "github.com/estafette/estafette-ci-api/pubsub/contracts"
+	contracts "github.com/estafette/estafette-ci-contracts"
 	manifest "github.com/estafette/estafette-ci-manifest"
 	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/api/iterator"
 )
 
 // APIClient is the interface for communicating with the pubsub apis
 type APIClient interface {
 	SubscriptionForTopic(ctx context.Context, message pscontracts.PubSubPushMessage) (*manifest.EstafettePubSubEvent, error)
+	EventForSubscription(ctx context.Context, projectID, subscriptionName string) (*manifest.EstafettePubSubEvent, error)
 	SubscribeToTopic(ctx context.Context, projectID, topicID string) error
 	SubscribeToPubsubTriggers(ctx context.Context, manifestString string) error
+	GetTriggerSubscriptions(ctx context.Context, projectID string) ([]string, error)
+	DeleteSubscription(ctx context.Context, projectID, subscriptionName string) error
+	ReceiveMessages(ctx context.Context, projectID, subscriptionName string, handle func(context.Context, []byte, map[string]string) error) error
+	PublishBuildEvent(ctx context.Context, build contracts.Build, event string) error
+	PublishReleaseEvent(ctx context.Context, release contracts.Release, labels []contracts.Label, event string) error
+	BuildEventPublishFunc() func(context.Context, contracts.Build, string) error
+	ReleaseEventPublishFunc() func(context.Context, contracts.Release, []contracts.Label, string) error
 }
 
 type apiClient struct {
 	config       config.PubsubConfig
 	pubsubClient *ps.Client
+	eventsTopic  *ps.Topic
+
+	// projectClients holds clients for listing subscriptions in projects other than the default project
+	projectClientsMutex sync.Mutex
+	projectClients      map[string]*ps.Client
 }
 
 // NewPubSubAPIClient returns a new pubsub.APIClient
@@ -35,19 +52,34 @@ func NewPubSubAPIClient(config config.PubsubConfig) (APIClient, error) {
 		return nil, err
 	}
 
+	// the topic to publish build and release lifecycle events to is optional
+	var eventsTopic *ps.Topic
+	if config.EventsTopic != "" {
+		eventsProject := config.EventsProject
+		if eventsProject == "" {
+			eventsProject = config.DefaultProject
+		}
+		eventsTopic = pubsubClient.TopicInProject(config.EventsTopic, eventsProject)
+	}
+
 	return &apiClient{
 		config:       config,
 		pubsubClient: pubsubClient,
+		eventsTopic:  eventsTopic,
+
+		projectClients: map[string]*ps.Client{},
 	}, nil
 }
 
 func (ac *apiClient) SubscriptionForTopic(ctx context.Context, message pscontracts.PubSubPushMessage) (*manifest.EstafettePubSubEvent, error) {
+	return ac.EventForSubscription(ctx, message.GetProject(), message.GetSubscription())
+}
 
-	span, ctx := opentracing.StartSpanFromContext(ctx, "PubSubApi::SubscriptionForTopic")
-	defer span.Finish()
+// EventForSubscription returns the project and topic of the subscription a message was received on
+func (ac *apiClient) EventForSubscription(ctx context.Context, projectID, subscriptionName string) (*manifest.EstafettePubSubEvent, error) {
 
-	projectID := message.GetProject()
-	subscriptionName := message.GetSubscription()
+	span, ctx := opentracing.StartSpanFromContext(ctx, "PubSubApi::EventForSubscription")
+	defer span.Finish()
 
 	span.SetTag("project", projectID)
 	span.SetTag("subscription", subscriptionName)
@@ -109,20 +141,26 @@ func (ac *apiClient) SubscribeToTopic(ctx context.Context, projectID, topicID st
 	}
 
 	// create a subscription to the topic
-	log.Info().Msgf("Creating subscription %v for topic %v in project %v...", subscriptionName, topicID, projectID)
-	_, err = ac.pubsubClient.CreateSubscription(context.Background(), subscriptionName, ps.SubscriptionConfig{
-		Topic: topic,
-		PushConfig: ps.PushConfig{
+	subscriptionConfig := ps.SubscriptionConfig{
+		Topic:             topic,
+		AckDeadline:       20 * time.Second,
+		RetentionDuration: 3 * time.Hour,
+		ExpirationPolicy:  time.Duration(ac.config.SubscriptionIdleExpirationDays) * 24 * time.Hour,
+	}
+
+	// in pull mode messages are received in-process instead of pushed to the public endpoint
+	if !ac.config.PullMode {
+		subscriptionConfig.PushConfig = ps.PushConfig{
 			Endpoint: ac.config.Endpoint,
 			AuthenticationMethod: &ps.OIDCToken{
 				Audience:            ac.config.Audience,
 				ServiceAccountEmail: ac.config.ServiceAccountEmail,
 			},
-		},
-		AckDeadline:       20 * time.Second,
-		RetentionDuration: 3 * time.Hour,
-		ExpirationPolicy:  time.Duration(ac.config.SubscriptionIdleExpirationDays) * 24 * time.Hour,
-	})
+		}
+	}
+
+	log.Info().Msgf("Creating subscription %v for topic %v in project %v...", subscriptionName, topicID, projectID)
+	_, err = ac.pubsubClient.CreateSubscription(context.Background(), subscriptionName, subscriptionConfig)
 	if err != nil {
 		return err
 	}
@@ -137,6 +175,76 @@ func (ac *apiClient) getSubscriptionName(topicName string) string {
 	return topicName + ac.config.SubscriptionNameSuffix
 }
 
+// GetTriggerSubscriptions returns the names of all subscriptions in a project created for pubsub triggers, recognized by their suffix
+func (ac *apiClient) GetTriggerSubscriptions(ctx context.Context, projectID string) (subscriptionNames []string, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "PubSubApi::GetTriggerSubscriptions")
+	defer span.Finish()
+
+	span.SetTag("project", projectID)
+
+	subscriptionNames = make([]string, 0)
+
+	// subscriptions can only be listed by a client for the project they live in
+	pubsubClient, err := ac.getProjectClient(ctx, projectID)
+	if err != nil {
+		return subscriptionNames, err
+	}
+
+	it := pubsubClient.Subscriptions(ctx)
+	for {
+		subscription, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return subscriptionNames, err
+		}
+		if ac.config.SubscriptionNameSuffix != "" && strings.HasSuffix(subscription.ID(), ac.config.SubscriptionNameSuffix) {
+			subscriptionNames = append(subscriptionNames, subscription.ID())
+		}
+	}
+
+	return subscriptionNames, nil
+}
+
+// DeleteSubscription removes a subscription from a project
+func (ac *apiClient) DeleteSubscription(ctx context.Context, projectID, subscriptionName string) error {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "PubSubApi::DeleteSubscription")
+	defer span.Finish()
+
+	span.SetTag("project", projectID)
+	span.SetTag("subscription", subscriptionName)
+
+	log.Info().Msgf("Deleting subscription %v in project %v...", subscriptionName, projectID)
+
+	return ac.pubsubClient.SubscriptionInProject(subscriptionName, projectID).Delete(ctx)
+}
+
+// getProjectClient returns the default client for the default project and a cached client for any other project
+func (ac *apiClient) getProjectClient(ctx context.Context, projectID string) (*ps.Client, error) {
+
+	if projectID == "" || projectID == ac.config.DefaultProject {
+		return ac.pubsubClient, nil
+	}
+
+	ac.projectClientsMutex.Lock()
+	defer ac.projectClientsMutex.Unlock()
+
+	if pubsubClient, ok := ac.projectClients[projectID]; ok {
+		return pubsubClient, nil
+	}
+
+	pubsubClient, err := ps.NewClient(context.Background(), projectID)
+	if err != nil {
+		return nil, err
+	}
+	ac.projectClients[projectID] = pubsubClient
+
+	return pubsubClient, nil
+}
+
 func (ac *apiClient) SubscribeToPubsubTriggers(ctx context.Context, manifestString string) error {
 
 	span, ctx := opentracing.StartSpanFromContext(ctx, "PubSubApi::SubscribeToPubsubTriggers")
@@ -159,3 +267,116 @@ func (ac *apiClient) SubscribeToPubsubTriggers(ctx context.Context, manifestStri
 	}
 	return nil
 }
+
+// ReceiveMessages pulls messages from a subscription in a project until the context is done; messages get acked if handle succeeds and redelivered otherwise
+func (ac *apiClient) ReceiveMessages(ctx context.Context, projectID, subscriptionName string, handle func(context.Context, []byte, map[string]string) error) error {
+
+	subscription := ac.pubsubClient.SubscriptionInProject(subscriptionName, projectID)
+
+	return subscription.Receive(ctx, func(ctx context.Context, m *ps.Message) {
+		err := handle(ctx, m.Data, m.Attributes)
+		if err != nil {
+			m.Nack()
+			return
+		}
+		m.Ack()
+	})
+}
+
+// PublishBuildEvent publishes a build lifecycle event to the events topic
+func (ac *apiClient) PublishBuildEvent(ctx context.Context, build contracts.Build, event string) error {
+
+	if ac.eventsTopic == nil {
+		return nil
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "PubSubApi::PublishBuildEvent")
+	defer span.Finish()
+
+	span.SetTag("event", event)
+
+	data, err := json.Marshal(build)
+	if err != nil {
+		return err
+	}
+
+	attributes := getEventAttributes(fmt.Sprintf("build.%v", event), build.RepoSource, build.RepoOwner, build.RepoName, build.BuildStatus, build.Labels)
+	attributes["buildID"] = build.ID
+	attributes["buildVersion"] = build.BuildVersion
+	attributes["repoBranch"] = build.RepoBranch
+
+	return ac.publish(ctx, data, attributes)
+}
+
+// PublishReleaseEvent publishes a release lifecycle event to the events topic; releases don't carry labels so they're passed separately
+func (ac *apiClient) PublishReleaseEvent(ctx context.Context, release contracts.Release, labels []contracts.Label, event string) error {
+
+	if ac.eventsTopic == nil {
+		return nil
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "PubSubApi::PublishReleaseEvent")
+	defer span.Finish()
+
+	span.SetTag("event", event)
+
+	data, err := json.Marshal(release)
+	if err != nil {
+		return err
+	}
+
+	attributes := getEventAttributes(fmt.Sprintf("release.%v", event), release.RepoSource, release.RepoOwner, release.RepoName, release.ReleaseStatus, labels)
+	attributes["releaseID"] = release.ID
+	attributes["releaseVersion"] = release.ReleaseVersion
+	attributes["releaseTarget"] = release.Name
+	if release.Action != "" {
+		attributes["releaseAction"] = release.Action
+	}
+
+	return ac.publish(ctx, data, attributes)
+}
+
+// BuildEventPublishFunc returns a function that publishes build lifecycle events
+func (ac *apiClient) BuildEventPublishFunc() func(context.Context, contracts.Build, string) error {
+	return func(ctx context.Context, build contracts.Build, event string) error {
+		return ac.PublishBuildEvent(ctx, build, event)
+	}
+}
+
+// ReleaseEventPublishFunc returns a function that publishes release lifecycle events
+func (ac *apiClient) ReleaseEventPublishFunc() func(context.Context, contracts.Release, []contracts.Label, string) error {
+	return func(ctx context.Context, release contracts.Release, labels []contracts.Label, event string) error {
+		return ac.PublishReleaseEvent(ctx, release, labels, event)
+	}
+}
+
+func (ac *apiClient) publish(ctx context.Context, data []byte, attributes map[string]string) error {
+
+	result := ac.eventsTopic.Publish(ctx, &ps.Message{
+		Data:       data,
+		Attributes: attributes,
+	})
+
+	// wait for the message to be accepted by the server
+	_, err := result.Get(ctx)
+
+	return err
+}
+
+// getEventAttributes returns the message attributes subscribers can filter on, with each label as a separate label_<key> attribute
+func getEventAttributes(eventType, repoSource, repoOwner, repoName, status string, labels []contracts.Label) map[string]string {
+
+	attributes := map[string]string{
+		"event":      eventType,
+		"repoSource": repoSource,
+		"repoOwner":  repoOwner,
+		"repoName":   repoName,
+		"status":     status,
+	}
+
+	for _, l := range labels {
+		attributes["label_"+l.Key] = l.Value
+	}
+
+	return attributes
+}
@@ -0,0 +1,95 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	ps "cloud.google.com/go/pubsub"
+	"github.com/estafette/estafette-ci-api/config"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEventAttributes(t *testing.T) {
+
+	t.Run("ReturnsRepoStatusAndLabelAttributes", func(t *testing.T) {
+
+		labels := []contracts.Label{
+			contracts.Label{Key: "team", Value: "estafette-team"},
+			contracts.Label{Key: "language", Value: "golang"},
+		}
+
+		// act
+		attributes := getEventAttributes("build.finished", "github.com", "estafette", "estafette-ci-api", "succeeded", labels)
+
+		assert.Equal(t, "build.finished", attributes["event"])
+		assert.Equal(t, "github.com", attributes["repoSource"])
+		assert.Equal(t, "estafette", attributes["repoOwner"])
+		assert.Equal(t, "estafette-ci-api", attributes["repoName"])
+		assert.Equal(t, "succeeded", attributes["status"])
+		assert.Equal(t, "estafette-team", attributes["label_team"])
+		assert.Equal(t, "golang", attributes["label_language"])
+	})
+}
+
+// TestPublishBuildEvent runs against the Pub/Sub emulator, start it with 'gcloud beta emulators pubsub start' and set PUBSUB_EMULATOR_HOST
+func TestPublishBuildEvent(t *testing.T) {
+
+	if os.Getenv("PUBSUB_EMULATOR_HOST") == "" {
+		t.Skip("PUBSUB_EMULATOR_HOST is not set, skipping test against Pub/Sub emulator")
+	}
+
+	t.Run("PublishesBuildWithAttributes", func(t *testing.T) {
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		client, err := ps.NewClient(ctx, "estafette-test")
+		assert.Nil(t, err)
+
+		topicName := "estafette-ci-events-" + time.Now().Format("20060102150405")
+		topic, err := client.CreateTopic(ctx, topicName)
+		assert.Nil(t, err)
+		subscription, err := client.CreateSubscription(ctx, topicName+"-test", ps.SubscriptionConfig{Topic: topic})
+		assert.Nil(t, err)
+
+		apiClient, err := NewPubSubAPIClient(config.PubsubConfig{DefaultProject: "estafette-test", EventsTopic: topicName})
+		assert.Nil(t, err)
+
+		build := contracts.Build{
+			ID:          "15",
+			RepoSource:  "github.com",
+			RepoOwner:   "estafette",
+			RepoName:    "estafette-ci-api",
+			BuildStatus: "succeeded",
+			Labels:      []contracts.Label{contracts.Label{Key: "team", Value: "estafette-team"}},
+		}
+
+		// act
+		err = apiClient.PublishBuildEvent(ctx, build, "finished")
+
+		assert.Nil(t, err)
+
+		var received *ps.Message
+		receiveCtx, cancelReceive := context.WithCancel(ctx)
+		err = subscription.Receive(receiveCtx, func(ctx context.Context, m *ps.Message) {
+			m.Ack()
+			received = m
+			cancelReceive()
+		})
+		assert.Nil(t, err)
+		if assert.NotNil(t, received) {
+			assert.Equal(t, "build.finished", received.Attributes["event"])
+			assert.Equal(t, "succeeded", received.Attributes["status"])
+			assert.Equal(t, "estafette-team", received.Attributes["label_team"])
+
+			var receivedBuild contracts.Build
+			err = json.Unmarshal(received.Data, &receivedBuild)
+			assert.Nil(t, err)
+			assert.Equal(t, "15", receivedBuild.ID)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/estafette/estafette-ci-api/estafette"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+// Consumer pulls messages from the pubsub trigger subscriptions for installations without a public endpoint to push to
+type Consumer interface {
+	Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
+}
+
+type consumerImpl struct {
+	config            config.PubsubConfig
+	apiClient         APIClient
+	cockroachDBClient cockroach.DBClient
+	buildService      estafette.BuildService
+
+	// refreshInterval determines how often the consumer checks for added or removed subscriptions
+	refreshInterval time.Duration
+	// receivers are keyed by project and subscription name, since the same topic name can exist in several projects
+	receivers map[string]context.CancelFunc
+}
+
+// NewPubSubConsumer returns a new pubsub.Consumer
+func NewPubSubConsumer(config config.PubsubConfig, apiClient APIClient, cockroachDBClient cockroach.DBClient, buildService estafette.BuildService) Consumer {
+	return &consumerImpl{
+		config:            config,
+		apiClient:         apiClient,
+		cockroachDBClient: cockroachDBClient,
+		buildService:      buildService,
+		refreshInterval:   1 * time.Minute,
+		receivers:         map[string]context.CancelFunc{},
+	}
+}
+
+// Run receives messages from all pubsub trigger subscriptions in pull mode until the stop channel gets closed
+func (c *consumerImpl) Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) {
+
+	if !c.config.PullMode {
+		return
+	}
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+
+		var receiversWaitGroup sync.WaitGroup
+
+		for {
+			c.refreshReceivers(&receiversWaitGroup)
+
+			select {
+			case <-time.After(c.refreshInterval):
+			case <-stopChannel:
+				log.Debug().Msg("Stopping pubsub consumer...")
+				for _, cancel := range c.receivers {
+					cancel()
+				}
+				receiversWaitGroup.Wait()
+				return
+			}
+		}
+	}()
+}
+
+// refreshReceivers starts receiving on new subscriptions and stops receiving on removed ones
+func (c *consumerImpl) refreshReceivers(receiversWaitGroup *sync.WaitGroup) {
+
+	// subscriptions live in the project of the triggering topic, so each of those projects gets pulled from
+	pipelines, err := c.cockroachDBClient.GetPubSubTriggers(context.Background(), manifest.EstafettePubSubEvent{})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed retrieving pubsub triggers to pull for")
+		return
+	}
+
+	current := map[string]bool{}
+	for _, project := range getTriggerProjects(pipelines, c.config.DefaultProject) {
+		subscriptionNames, err := c.apiClient.GetTriggerSubscriptions(context.Background(), project)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed retrieving pubsub trigger subscriptions in project %v to pull from", project)
+			return
+		}
+
+		for _, name := range subscriptionNames {
+			key := project + "/" + name
+			current[key] = true
+			if _, ok := c.receivers[key]; ok {
+				continue
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			c.receivers[key] = cancel
+
+			receiversWaitGroup.Add(1)
+			go func(ctx context.Context, projectID, subscriptionName string) {
+				defer receiversWaitGroup.Done()
+				c.receive(ctx, projectID, subscriptionName)
+			}(ctx, project, name)
+		}
+	}
+
+	for key, cancel := range c.receivers {
+		if !current[key] {
+			log.Info().Msgf("Stopping to pull from removed subscription %v", key)
+			cancel()
+			delete(c.receivers, key)
+		}
+	}
+}
+
+// receive pulls from a subscription until the context is canceled, restarting after errors
+func (c *consumerImpl) receive(ctx context.Context, projectID, subscriptionName string) {
+
+	log.Info().Msgf("Pulling messages from subscription %v in project %v...", subscriptionName, projectID)
+
+	for {
+		err := c.apiClient.ReceiveMessages(ctx, projectID, subscriptionName, func(ctx context.Context, data []byte, attributes map[string]string) error {
+			return c.handleMessage(ctx, projectID, subscriptionName, data, attributes)
+		})
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed pulling messages from subscription %v in project %v", subscriptionName, projectID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+func (c *consumerImpl) handleMessage(ctx context.Context, projectID, subscriptionName string, data []byte, attributes map[string]string) error {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "PubSub::HandleMessage")
+	defer span.Finish()
+
+	log.Info().
+		Str("data", string(data)).
+		Str("project", projectID).
+		Str("subscription", subscriptionName).
+		Msg("Successfully pulled pubsub message")
+
+	return firePubSubTriggers(ctx, c.apiClient, c.buildService, projectID, subscriptionName, estafette.PubSubMessage{
+		Data:       string(data),
+		Attributes: attributes,
+	})
+}
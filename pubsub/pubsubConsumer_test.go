@@ -0,0 +1,151 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	"github.com/estafette/estafette-ci-api/estafette"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAPIClient overrides the APIClient methods used by the consumer
+type fakeAPIClient struct {
+	APIClient
+	mutex         sync.Mutex
+	subscriptions map[string][]string
+	receiving     map[string]bool
+}
+
+func (f *fakeAPIClient) GetTriggerSubscriptions(ctx context.Context, projectID string) ([]string, error) {
+	return f.subscriptions[projectID], nil
+}
+
+func (f *fakeAPIClient) EventForSubscription(ctx context.Context, projectID, subscriptionName string) (*manifest.EstafettePubSubEvent, error) {
+	return &manifest.EstafettePubSubEvent{Project: projectID, Topic: "deployments"}, nil
+}
+
+func (f *fakeAPIClient) ReceiveMessages(ctx context.Context, projectID, subscriptionName string, handle func(context.Context, []byte, map[string]string) error) error {
+	f.mutex.Lock()
+	f.receiving[projectID+"/"+subscriptionName] = true
+	f.mutex.Unlock()
+
+	<-ctx.Done()
+
+	f.mutex.Lock()
+	f.receiving[projectID+"/"+subscriptionName] = false
+	f.mutex.Unlock()
+
+	return nil
+}
+
+// fakeDBClient returns the pipelines with pubsub triggers
+type fakeDBClient struct {
+	cockroach.DBClient
+	pipelines []*contracts.Pipeline
+}
+
+func (f *fakeDBClient) GetPubSubTriggers(ctx context.Context, pubsubEvent manifest.EstafettePubSubEvent) ([]*contracts.Pipeline, error) {
+	return f.pipelines, nil
+}
+
+// fakeBuildService records the pubsub events it fires triggers for
+type fakeBuildService struct {
+	estafette.BuildService
+	events   []manifest.EstafettePubSubEvent
+	messages []estafette.PubSubMessage
+	err      error
+}
+
+func (f *fakeBuildService) FirePubSubTriggers(ctx context.Context, pubsubEvent manifest.EstafettePubSubEvent, message estafette.PubSubMessage) error {
+	f.events = append(f.events, pubsubEvent)
+	f.messages = append(f.messages, message)
+	return f.err
+}
+
+func TestConsumerHandleMessage(t *testing.T) {
+
+	t.Run("FiresTriggersForTopicOfSubscription", func(t *testing.T) {
+
+		buildService := &fakeBuildService{}
+		consumer := NewPubSubConsumer(config.PubsubConfig{DefaultProject: "my-project", PullMode: true}, &fakeAPIClient{}, &fakeDBClient{}, buildService).(*consumerImpl)
+
+		// act
+		err := consumer.handleMessage(context.Background(), "my-project", "deployments~estafette-ci-pubsub-trigger", []byte(`{"env":"prod"}`), map[string]string{"env": "prod"})
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(buildService.events)) {
+			assert.Equal(t, "my-project", buildService.events[0].Project)
+			assert.Equal(t, "deployments", buildService.events[0].Topic)
+			assert.Equal(t, `{"env":"prod"}`, buildService.messages[0].Data)
+			assert.Equal(t, "prod", buildService.messages[0].Attributes["env"])
+		}
+	})
+
+	t.Run("ReturnsErrorSoMessageGetsRedeliveredIfFiringTriggersFails", func(t *testing.T) {
+
+		buildService := &fakeBuildService{err: fmt.Errorf("database unavailable")}
+		consumer := NewPubSubConsumer(config.PubsubConfig{DefaultProject: "my-project", PullMode: true}, &fakeAPIClient{}, &fakeDBClient{}, buildService).(*consumerImpl)
+
+		// act
+		err := consumer.handleMessage(context.Background(), "my-project", "deployments~estafette-ci-pubsub-trigger", []byte(`{}`), nil)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestConsumerRefreshReceivers(t *testing.T) {
+
+	t.Run("StopsReceivingFromRemovedSubscriptions", func(t *testing.T) {
+
+		apiClient := &fakeAPIClient{subscriptions: map[string][]string{"my-project": []string{"a", "b"}}, receiving: map[string]bool{}}
+		consumer := NewPubSubConsumer(config.PubsubConfig{DefaultProject: "my-project", PullMode: true}, apiClient, &fakeDBClient{}, &fakeBuildService{}).(*consumerImpl)
+		var receiversWaitGroup sync.WaitGroup
+
+		consumer.refreshReceivers(&receiversWaitGroup)
+		apiClient.subscriptions = map[string][]string{"my-project": []string{"b"}}
+
+		// act
+		consumer.refreshReceivers(&receiversWaitGroup)
+
+		assert.Equal(t, 1, len(consumer.receivers))
+		_, ok := consumer.receivers["my-project/b"]
+		assert.True(t, ok)
+
+		for _, cancel := range consumer.receivers {
+			cancel()
+		}
+		receiversWaitGroup.Wait()
+		assert.False(t, apiClient.receiving["my-project/a"])
+		assert.False(t, apiClient.receiving["my-project/b"])
+	})
+
+	t.Run("ReceivesFromSubscriptionsInProjectsOfTriggers", func(t *testing.T) {
+
+		apiClient := &fakeAPIClient{subscriptions: map[string][]string{"my-project": []string{"a"}, "other-project": []string{"a"}}, receiving: map[string]bool{}}
+		dbClient := &fakeDBClient{pipelines: []*contracts.Pipeline{
+			&contracts.Pipeline{Triggers: []manifest.EstafetteTrigger{
+				manifest.EstafetteTrigger{PubSub: &manifest.EstafettePubSubTrigger{Project: "other-project", Topic: "a"}},
+			}},
+		}}
+		consumer := NewPubSubConsumer(config.PubsubConfig{DefaultProject: "my-project", PullMode: true}, apiClient, dbClient, &fakeBuildService{}).(*consumerImpl)
+		var receiversWaitGroup sync.WaitGroup
+
+		// act
+		consumer.refreshReceivers(&receiversWaitGroup)
+
+		assert.Equal(t, 2, len(consumer.receivers))
+		_, ok := consumer.receivers["other-project/a"]
+		assert.True(t, ok)
+
+		for _, cancel := range consumer.receivers {
+			cancel()
+		}
+		receiversWaitGroup.Wait()
+	})
+}
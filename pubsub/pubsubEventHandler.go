@@ -1,6 +1,8 @@
 package pubsub
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/estafette/estafette-ci-api/estafette"
@@ -13,18 +15,22 @@ import (
 // EventHandler handles http events for Pubsub integration
 type EventHandler interface {
 	PostPubsubEvent(*gin.Context)
+	GetSubscriptionsStatus(*gin.Context)
+	ReconcileSubscriptions(*gin.Context)
 }
 
 type eventHandler struct {
-	apiClient    APIClient
-	buildService estafette.BuildService
+	apiClient              APIClient
+	buildService           estafette.BuildService
+	subscriptionReconciler SubscriptionReconciler
 }
 
 // NewPubSubEventHandler returns a pubsub.EventHandler
-func NewPubSubEventHandler(apiClient APIClient, buildService estafette.BuildService) EventHandler {
+func NewPubSubEventHandler(apiClient APIClient, buildService estafette.BuildService, subscriptionReconciler SubscriptionReconciler) EventHandler {
 	return &eventHandler{
-		apiClient:    apiClient,
-		buildService: buildService,
+		apiClient:              apiClient,
+		buildService:           buildService,
+		subscriptionReconciler: subscriptionReconciler,
 	}
 }
 
@@ -46,29 +52,23 @@ func (eh *eventHandler) PostPubsubEvent(c *gin.Context) {
 		return
 	}
 
-	pubsubEvent, err := eh.apiClient.SubscriptionForTopic(ctx, message)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed retrieving topic for pubsub subscription")
-		c.String(http.StatusInternalServerError, "Oop, something's wrong!")
-		return
-	}
-	if pubsubEvent == nil {
-		log.Error().Msg("Failed retrieving pubsubEvent for pubsub subscription")
-		c.String(http.StatusInternalServerError, "Oop, something's wrong!")
-		return
-	}
-
 	log.Info().
 		Interface("msg", message).
 		Str("data", message.GetDecodedData()).
 		Str("project", message.GetProject()).
 		Str("subscription", message.GetSubscription()).
-		Str("topic", pubsubEvent.Topic).
 		Msg("Successfully binded pubsub push event")
 
-	err = eh.buildService.FirePubSubTriggers(ctx, *pubsubEvent)
+	attributes := map[string]string{}
+	if message.Message.Attributes != nil {
+		attributes = *message.Message.Attributes
+	}
+
+	err = firePubSubTriggers(ctx, eh.apiClient, eh.buildService, message.GetProject(), message.GetSubscription(), estafette.PubSubMessage{
+		Data:       message.GetDecodedData(),
+		Attributes: attributes,
+	})
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed firing pubsub triggers for topic %v in project %v", pubsubEvent.Topic, pubsubEvent.Project)
 		c.String(http.StatusInternalServerError, "Oop, something's wrong!")
 		return
 	}
@@ -76,3 +76,51 @@ func (eh *eventHandler) PostPubsubEvent(c *gin.Context) {
 	c.String(http.StatusOK, "Aye aye!")
 	return
 }
+
+// firePubSubTriggers looks up the topic for the subscription a message came in on and fires the triggers for it, for both pushed and pulled messages
+func firePubSubTriggers(ctx context.Context, apiClient APIClient, buildService estafette.BuildService, projectID, subscriptionName string, message estafette.PubSubMessage) error {
+
+	pubsubEvent, err := apiClient.EventForSubscription(ctx, projectID, subscriptionName)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed retrieving topic for pubsub subscription")
+		return err
+	}
+	if pubsubEvent == nil {
+		log.Error().Msg("Failed retrieving pubsubEvent for pubsub subscription")
+		return fmt.Errorf("No topic found for subscription %v in project %v", subscriptionName, projectID)
+	}
+
+	err = buildService.FirePubSubTriggers(ctx, *pubsubEvent, message)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed firing pubsub triggers for topic %v in project %v", pubsubEvent.Topic, pubsubEvent.Project)
+		return err
+	}
+
+	return nil
+}
+
+func (eh *eventHandler) GetSubscriptionsStatus(c *gin.Context) {
+
+	status := eh.subscriptionReconciler.GetStatus()
+	if status == nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": http.StatusText(http.StatusNotFound), "message": "Pub/Sub subscriptions haven't been reconciled yet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (eh *eventHandler) ReconcileSubscriptions(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "PubSub::ReconcileSubscriptions")
+	defer span.Finish()
+
+	status, err := eh.subscriptionReconciler.Reconcile(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed reconciling pubsub trigger subscriptions")
+		c.JSON(http.StatusInternalServerError, gin.H{"code": http.StatusText(http.StatusInternalServerError), "message": "Failed reconciling pubsub trigger subscriptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
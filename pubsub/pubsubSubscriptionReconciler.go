@@ -0,0 +1,258 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	pscontracts "github.com/estafette/estafette-ci-api/pubsub/contracts"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+)
+
+// SubscriptionReconciler keeps the pubsub trigger subscriptions in line with the pubsub triggers of all pipelines
+type SubscriptionReconciler interface {
+	Reconcile(ctx context.Context) (pscontracts.ReconciliationStatus, error)
+	GetStatus() *pscontracts.ReconciliationStatus
+	Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
+}
+
+type subscriptionReconcilerImpl struct {
+	config            config.PubsubConfig
+	apiClient         APIClient
+	cockroachDBClient cockroach.DBClient
+
+	mutex  sync.Mutex
+	status *pscontracts.ReconciliationStatus
+}
+
+// NewSubscriptionReconciler returns a new pubsub.SubscriptionReconciler
+func NewSubscriptionReconciler(config config.PubsubConfig, apiClient APIClient, cockroachDBClient cockroach.DBClient) SubscriptionReconciler {
+	return &subscriptionReconcilerImpl{
+		config:            config,
+		apiClient:         apiClient,
+		cockroachDBClient: cockroachDBClient,
+	}
+}
+
+// Reconcile creates subscriptions for topics used in pubsub triggers and deletes subscriptions no pipeline triggers on anymore
+func (r *subscriptionReconcilerImpl) Reconcile(ctx context.Context) (status pscontracts.ReconciliationStatus, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "PubSub::Reconcile")
+	defer span.Finish()
+
+	status.StartedAt = time.Now().UTC()
+	status.Subscriptions = make([]pscontracts.SubscriptionStatus, 0)
+
+	defer func() {
+		status.FinishedAt = time.Now().UTC()
+		if err != nil {
+			status.Error = err.Error()
+		}
+		r.setStatus(status)
+	}()
+
+	// an empty event matches all pipelines with a pubsub trigger
+	pipelines, err := r.cockroachDBClient.GetPubSubTriggers(ctx, manifest.EstafettePubSubEvent{})
+	if err != nil {
+		return
+	}
+
+	// subscriptions are created in the project of the triggering topic, so each of those projects needs checking
+	existingSubscriptions := map[string][]string{}
+	for _, project := range getTriggerProjects(pipelines, r.config.DefaultProject) {
+		existingSubscriptions[project], err = r.apiClient.GetTriggerSubscriptions(ctx, project)
+		if err != nil {
+			return
+		}
+	}
+
+	desiredSubscriptions, staleSubscriptions := getSubscriptionChanges(pipelines, existingSubscriptions, r.config.SubscriptionNameSuffix)
+
+	for _, s := range desiredSubscriptions {
+		if s.Action == "create" {
+			createErr := r.apiClient.SubscribeToTopic(ctx, s.Project, s.Topic)
+			if createErr != nil {
+				log.Warn().Err(createErr).Msgf("Failed creating subscription %v for topic %v in project %v", s.Name, s.Topic, s.Project)
+				s.Action = "failed"
+				s.Error = createErr.Error()
+			} else {
+				s.Action = "created"
+			}
+		}
+		status.Subscriptions = append(status.Subscriptions, s)
+	}
+
+	for _, s := range staleSubscriptions {
+		// without any pubsub trigger all subscriptions look stale; rather keep them than wipe them on a bad read
+		if len(pipelines) == 0 {
+			log.Warn().Msgf("Not deleting subscription %v in project %v since no pipelines with pubsub triggers were returned", s.Name, s.Project)
+			s.Action = "skipped"
+			status.Subscriptions = append(status.Subscriptions, s)
+			continue
+		}
+
+		deleteErr := r.apiClient.DeleteSubscription(ctx, s.Project, s.Name)
+		if deleteErr != nil {
+			log.Warn().Err(deleteErr).Msgf("Failed deleting stale subscription %v in project %v", s.Name, s.Project)
+			s.Action = "failed"
+			s.Error = deleteErr.Error()
+		} else {
+			s.Action = "deleted"
+		}
+		status.Subscriptions = append(status.Subscriptions, s)
+	}
+
+	return
+}
+
+// GetStatus returns the outcome of the last reconciliation or nil if it hasn't run yet
+func (r *subscriptionReconcilerImpl) GetStatus() *pscontracts.ReconciliationStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.status
+}
+
+// Run reconciles at the configured interval on the replica holding the reconciler lease until the stop channel gets closed; without interval it only reconciles on request
+func (r *subscriptionReconcilerImpl) Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) {
+
+	if r.config.SubscriptionReconcileMinutes <= 0 {
+		return
+	}
+
+	interval := time.Duration(r.config.SubscriptionReconcileMinutes) * time.Minute
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+
+		for {
+			// only one replica reconciles, so they don't create and delete the same subscriptions at the same time
+			acquired, err := r.cockroachDBClient.AcquireLease(context.Background(), "pubsub-subscription-reconciler", 2*interval)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed acquiring lease for reconciling pubsub trigger subscriptions")
+			} else if acquired {
+				status, err := r.Reconcile(context.Background())
+				if err != nil {
+					log.Error().Err(err).Msg("Failed reconciling pubsub trigger subscriptions")
+				} else {
+					log.Info().Msgf("Reconciled %v pubsub trigger subscriptions", len(status.Subscriptions))
+				}
+			}
+
+			select {
+			case <-time.After(interval):
+			case <-stopChannel:
+				log.Debug().Msg("Stopping pubsub subscription reconciler...")
+				return
+			}
+		}
+	}()
+}
+
+func (r *subscriptionReconcilerImpl) setStatus(status pscontracts.ReconciliationStatus) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.status = &status
+}
+
+// getTriggerProjects returns the default project and all projects with topics pubsub triggers listen to
+func getTriggerProjects(pipelines []*contracts.Pipeline, defaultProject string) (projects []string) {
+
+	if defaultProject != "" {
+		projects = append(projects, defaultProject)
+	}
+	for _, p := range pipelines {
+		for _, t := range p.Triggers {
+			if t.PubSub == nil || t.PubSub.Project == "" || contains(projects, t.PubSub.Project) {
+				continue
+			}
+			projects = append(projects, t.PubSub.Project)
+		}
+	}
+	sort.Strings(projects)
+
+	return
+}
+
+// getSubscriptionChanges returns the subscriptions needed by the pubsub triggers, marked as 'create' if missing, and the existing subscriptions no trigger needs anymore; existing subscriptions are grouped by project since the same topic name can exist in several projects
+func getSubscriptionChanges(pipelines []*contracts.Pipeline, existingSubscriptions map[string][]string, subscriptionNameSuffix string) (desired, stale []pscontracts.SubscriptionStatus) {
+
+	desiredByKey := map[string]*pscontracts.SubscriptionStatus{}
+	for _, p := range pipelines {
+		for _, t := range p.Triggers {
+			if t.PubSub == nil || t.PubSub.Topic == "" {
+				continue
+			}
+			name := t.PubSub.Topic + subscriptionNameSuffix
+			pipelineName := fmt.Sprintf("%v/%v/%v", p.RepoSource, p.RepoOwner, p.RepoName)
+
+			key := t.PubSub.Project + "/" + name
+
+			s, ok := desiredByKey[key]
+			if !ok {
+				s = &pscontracts.SubscriptionStatus{
+					Name:    name,
+					Project: t.PubSub.Project,
+					Topic:   t.PubSub.Topic,
+					Action:  "create",
+				}
+				desiredByKey[key] = s
+			}
+			if !contains(s.Pipelines, pipelineName) {
+				s.Pipelines = append(s.Pipelines, pipelineName)
+			}
+		}
+	}
+
+	for project, names := range existingSubscriptions {
+		for _, name := range names {
+			if s, ok := desiredByKey[project+"/"+name]; ok {
+				s.Action = "unchanged"
+				continue
+			}
+			stale = append(stale, pscontracts.SubscriptionStatus{
+				Name:    name,
+				Project: project,
+				Topic:   strings.TrimSuffix(name, subscriptionNameSuffix),
+				Action:  "delete",
+			})
+		}
+	}
+
+	for _, s := range desiredByKey {
+		desired = append(desired, *s)
+	}
+	sortSubscriptions(desired)
+	sortSubscriptions(stale)
+
+	return
+}
+
+func sortSubscriptions(subscriptions []pscontracts.SubscriptionStatus) {
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if subscriptions[i].Project != subscriptions[j].Project {
+			return subscriptions[i].Project < subscriptions[j].Project
+		}
+		return subscriptions[i].Name < subscriptions[j].Name
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,100 @@
+package pubsub
+
+import (
+	"testing"
+
+	contracts "github.com/estafette/estafette-ci-contracts"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSubscriptionChanges(t *testing.T) {
+
+	suffix := "~estafette-ci-pubsub-trigger"
+
+	pipelines := []*contracts.Pipeline{
+		&contracts.Pipeline{
+			RepoSource: "github.com",
+			RepoOwner:  "estafette",
+			RepoName:   "estafette-ci-api",
+			Triggers: []manifest.EstafetteTrigger{
+				manifest.EstafetteTrigger{PubSub: &manifest.EstafettePubSubTrigger{Project: "my-project", Topic: "deployments"}},
+				manifest.EstafetteTrigger{Cron: &manifest.EstafetteCronTrigger{Schedule: "*/5 * * * *"}},
+			},
+		},
+		&contracts.Pipeline{
+			RepoSource: "github.com",
+			RepoOwner:  "estafette",
+			RepoName:   "estafette-ci-web",
+			Triggers: []manifest.EstafetteTrigger{
+				manifest.EstafetteTrigger{PubSub: &manifest.EstafettePubSubTrigger{Project: "my-project", Topic: "deployments"}},
+				manifest.EstafetteTrigger{PubSub: &manifest.EstafettePubSubTrigger{Project: "my-project", Topic: "images"}},
+			},
+		},
+	}
+
+	t.Run("MarksMissingSubscriptionsForCreation", func(t *testing.T) {
+
+		// act
+		desired, stale := getSubscriptionChanges(pipelines, map[string][]string{"my-project": []string{"deployments" + suffix}}, suffix)
+
+		assert.Equal(t, 0, len(stale))
+		if assert.Equal(t, 2, len(desired)) {
+			assert.Equal(t, "deployments"+suffix, desired[0].Name)
+			assert.Equal(t, "unchanged", desired[0].Action)
+			assert.Equal(t, []string{"github.com/estafette/estafette-ci-api", "github.com/estafette/estafette-ci-web"}, desired[0].Pipelines)
+			assert.Equal(t, "images"+suffix, desired[1].Name)
+			assert.Equal(t, "my-project", desired[1].Project)
+			assert.Equal(t, "create", desired[1].Action)
+		}
+	})
+
+	t.Run("ReturnsSubscriptionsWithoutTriggerAsStale", func(t *testing.T) {
+
+		// act
+		_, stale := getSubscriptionChanges(pipelines, map[string][]string{"my-project": []string{"deployments" + suffix, "images" + suffix, "archived" + suffix}}, suffix)
+
+		if assert.Equal(t, 1, len(stale)) {
+			assert.Equal(t, "archived"+suffix, stale[0].Name)
+			assert.Equal(t, "my-project", stale[0].Project)
+			assert.Equal(t, "archived", stale[0].Topic)
+			assert.Equal(t, "delete", stale[0].Action)
+		}
+	})
+	t.Run("KeepsSubscriptionsForTheSameTopicInDifferentProjectsApart", func(t *testing.T) {
+
+		existing := map[string][]string{
+			"my-project":    []string{"deployments" + suffix, "images" + suffix},
+			"other-project": []string{"deployments" + suffix},
+		}
+
+		// act
+		desired, stale := getSubscriptionChanges(pipelines, existing, suffix)
+
+		assert.Equal(t, 2, len(desired))
+		if assert.Equal(t, 1, len(stale)) {
+			assert.Equal(t, "deployments"+suffix, stale[0].Name)
+			assert.Equal(t, "other-project", stale[0].Project)
+		}
+	})
+}
+
+func TestGetTriggerProjects(t *testing.T) {
+
+	t.Run("ReturnsDefaultProjectAndTriggerProjects", func(t *testing.T) {
+
+		pipelines := []*contracts.Pipeline{
+			&contracts.Pipeline{
+				Triggers: []manifest.EstafetteTrigger{
+					manifest.EstafetteTrigger{PubSub: &manifest.EstafettePubSubTrigger{Project: "other-project", Topic: "deployments"}},
+					manifest.EstafetteTrigger{PubSub: &manifest.EstafettePubSubTrigger{Project: "my-project", Topic: "images"}},
+				},
+			},
+		}
+
+		// act
+		projects := getTriggerProjects(pipelines, "my-project")
+
+		assert.Equal(t, []string{"my-project", "other-project"}, projects)
+	})
+}
@@ -27,3 +27,105 @@ type GetUserProfileResponse struct {
 type UserProfile struct {
 	Email string `json:"email"`
 }
+
+// PostMessageRequest represents the body for posting a message to a channel with chat.postMessage
+type PostMessageRequest struct {
+	Channel     string       `json:"channel"`
+	Text        string       `json:"text,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// UpdateMessageRequest represents the body for updating a previously posted message with chat.update
+type UpdateMessageRequest struct {
+	Channel     string       `json:"channel"`
+	TS          string       `json:"ts"`
+	Text        string       `json:"text,omitempty"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// PostMessageResponse represents the api response for posting a message
+type PostMessageResponse struct {
+	OK      bool   `json:"ok"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+	Error   string `json:"error"`
+}
+
+// Attachment represents a Slack message attachment
+type Attachment struct {
+	Fallback  string            `json:"fallback"`
+	Color     string            `json:"color,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	TitleLink string            `json:"title_link,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	Fields    []AttachmentField `json:"fields,omitempty"`
+
+	CallbackID string             `json:"callback_id,omitempty"`
+	Actions    []AttachmentAction `json:"actions,omitempty"`
+}
+
+// AttachmentAction represents an interactive button in an attachment
+type AttachmentAction struct {
+	Name  string `json:"name"`
+	Text  string `json:"text,omitempty"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Style string `json:"style,omitempty"`
+}
+
+// AttachmentField represents a field displayed in a table inside an attachment
+type AttachmentField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// LookupUserByEmailResponse represents the api response for retrieving a user by email address
+type LookupUserByEmailResponse struct {
+	OK    bool   `json:"ok"`
+	User  *User  `json:"user"`
+	Error string `json:"error"`
+}
+
+// User represents a Slack user
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// InteractiveMessagePayload represents the payload Slack sends when a user clicks a button in a message
+type InteractiveMessagePayload struct {
+	Type            string             `json:"type"`
+	Actions         []AttachmentAction `json:"actions"`
+	CallbackID      string             `json:"callback_id"`
+	Channel         Channel            `json:"channel"`
+	User            User               `json:"user"`
+	ActionTS        string             `json:"action_ts"`
+	MessageTS       string             `json:"message_ts"`
+	Token           string             `json:"token"`
+	OriginalMessage *Message           `json:"original_message"`
+	ResponseURL     string             `json:"response_url"`
+}
+
+// Channel represents a Slack channel
+type Channel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Message represents a posted Slack message
+type Message struct {
+	Text        string       `json:"text,omitempty"`
+	TS          string       `json:"ts,omitempty"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// ReleaseActionValue is the value of a release button identifying the build and release target to release to
+type ReleaseActionValue struct {
+	RepoSource    string `json:"repoSource"`
+	RepoOwner     string `json:"repoOwner"`
+	RepoName      string `json:"repoName"`
+	BuildID       int    `json:"buildID"`
+	ReleaseName   string `json:"releaseName"`
+	ReleaseAction string `json:"releaseAction,omitempty"`
+}
@@ -1,11 +1,16 @@
 package slack
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/estafette/estafette-ci-api/config"
@@ -16,20 +21,35 @@ import (
 	"github.com/sethgrid/pester"
 )
 
+const defaultAPIBaseURL = "https://slack.com/api"
+
 // APIClient is the interface for communicating with the Slack api
 type APIClient interface {
 	GetUserProfile(context.Context, string) (*slcontracts.UserProfile, error)
+	GetUserIDByEmail(context.Context, string) (string, error)
+	PostMessage(context.Context, slcontracts.PostMessageRequest) (*slcontracts.PostMessageResponse, error)
+	UpdateMessage(context.Context, slcontracts.UpdateMessageRequest) (*slcontracts.PostMessageResponse, error)
 }
 
 type apiClientImpl struct {
 	config                          config.SlackConfig
 	prometheusOutboundAPICallTotals *prometheus.CounterVec
+	rateLimiter                     *rateLimiter
 }
 
+// NewSlackAPIClient returns a new slack.APIClient
 func NewSlackAPIClient(config config.SlackConfig, prometheusOutboundAPICallTotals *prometheus.CounterVec) APIClient {
+
+	// chat.postMessage allows about 1 message per second per channel
+	messagesPerSecond := 1.0
+	if config.Notifications != nil && config.Notifications.MessagesPerSecond > 0 {
+		messagesPerSecond = config.Notifications.MessagesPerSecond
+	}
+
 	return &apiClientImpl{
 		config:                          config,
 		prometheusOutboundAPICallTotals: prometheusOutboundAPICallTotals,
+		rateLimiter:                     newRateLimiter(messagesPerSecond),
 	}
 }
 
@@ -39,10 +59,116 @@ func (sl *apiClientImpl) GetUserProfile(ctx context.Context, userID string) (pro
 	span, ctx := opentracing.StartSpanFromContext(ctx, "SlackApi::GetUserProfile")
 	defer span.Finish()
 
+	body, err := sl.callAPI(span, "GET", fmt.Sprintf("users.profile.get?user=%v", userID), nil)
+	if err != nil {
+		return
+	}
+
+	var profileResponse slcontracts.GetUserProfileResponse
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &profileResponse)
+	if err != nil {
+		return
+	}
+
+	return profileResponse.Profile, nil
+}
+
+// GetUserIDByEmail returns the id of the Slack user with the given email address, to be used as channel for direct messages
+func (sl *apiClientImpl) GetUserIDByEmail(ctx context.Context, email string) (userID string, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SlackApi::GetUserIDByEmail")
+	defer span.Finish()
+
+	body, err := sl.callAPI(span, "GET", fmt.Sprintf("users.lookupByEmail?email=%v", url.QueryEscape(email)), nil)
+	if err != nil {
+		return
+	}
+
+	var lookupResponse slcontracts.LookupUserByEmailResponse
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &lookupResponse)
+	if err != nil {
+		return
+	}
+
+	if !lookupResponse.OK || lookupResponse.User == nil {
+		return "", fmt.Errorf("Looking up Slack user by email %v failed: %v", email, lookupResponse.Error)
+	}
+
+	return lookupResponse.User.ID, nil
+}
+
+// PostMessage posts a message to a channel or user with chat.postMessage, throttled to stay within Slack's rate limits
+func (sl *apiClientImpl) PostMessage(ctx context.Context, message slcontracts.PostMessageRequest) (response *slcontracts.PostMessageResponse, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SlackApi::PostMessage")
+	defer span.Finish()
+
+	err = sl.rateLimiter.Wait(ctx)
+	if err != nil {
+		return
+	}
+
+	body, err := sl.callAPI(span, "POST", "chat.postMessage", message)
+	if err != nil {
+		return
+	}
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return
+	}
+
+	if !response.OK {
+		return response, fmt.Errorf("Posting Slack message to channel %v failed: %v", message.Channel, response.Error)
+	}
+
+	return
+}
+
+// UpdateMessage replaces the content of a previously posted message with chat.update
+func (sl *apiClientImpl) UpdateMessage(ctx context.Context, message slcontracts.UpdateMessageRequest) (response *slcontracts.PostMessageResponse, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SlackApi::UpdateMessage")
+	defer span.Finish()
+
+	err = sl.rateLimiter.Wait(ctx)
+	if err != nil {
+		return
+	}
+
+	body, err := sl.callAPI(span, "POST", "chat.update", message)
+	if err != nil {
+		return
+	}
+
+	// unmarshal json body
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return
+	}
+
+	if !response.OK {
+		return response, fmt.Errorf("Updating Slack message %v in channel %v failed: %v", message.TS, message.Channel, response.Error)
+	}
+
+	return
+}
+
+func (sl *apiClientImpl) callAPI(span opentracing.Span, method, apiMethod string, params interface{}) (body []byte, err error) {
+
 	// track call via prometheus
 	sl.prometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "slack"}).Inc()
 
-	url := fmt.Sprintf("https://slack.com/api/users.profile.get?user=%v", userID)
+	baseURL := defaultAPIBaseURL
+	if sl.config.APIBaseURL != "" {
+		baseURL = strings.TrimSuffix(sl.config.APIBaseURL, "/")
+	}
+	url := fmt.Sprintf("%v/%v", baseURL, apiMethod)
 
 	// create client, in order to add headers
 	client := pester.NewExtendedClient(&http.Client{Transport: &nethttp.Transport{}})
@@ -50,7 +176,17 @@ func (sl *apiClientImpl) GetUserProfile(ctx context.Context, userID string) (pro
 	client.Backoff = pester.ExponentialJitterBackoff
 	client.KeepLog = true
 	client.Timeout = time.Second * 10
-	request, err := http.NewRequest("GET", url, nil)
+
+	var requestBody io.Reader
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		requestBody = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequest(method, url, requestBody)
 	if err != nil {
 		return
 	}
@@ -63,6 +199,9 @@ func (sl *apiClientImpl) GetUserProfile(ctx context.Context, userID string) (pro
 
 	// add headers
 	request.Header.Add("Authorization", fmt.Sprintf("%v %v", "Bearer", sl.config.AppOAuthAccessToken))
+	if params != nil {
+		request.Header.Add("Content-Type", "application/json; charset=utf-8")
+	}
 
 	// perform actual request
 	response, err := client.Do(request)
@@ -72,18 +211,9 @@ func (sl *apiClientImpl) GetUserProfile(ctx context.Context, userID string) (pro
 	defer response.Body.Close()
 	ht.Finish()
 
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return
+	if response.StatusCode == http.StatusTooManyRequests {
+		return nil, errors.New("Slack api is rate limiting requests")
 	}
 
-	var profileResponse slcontracts.GetUserProfileResponse
-
-	// unmarshal json body
-	err = json.Unmarshal(body, &profileResponse)
-	if err != nil {
-		return
-	}
-
-	return profileResponse.Profile, nil
+	return ioutil.ReadAll(response.Body)
 }
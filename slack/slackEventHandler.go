@@ -2,9 +2,18 @@ package slack
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	slcontracts "github.com/estafette/estafette-ci-api/slack/contracts"
 	"github.com/opentracing/opentracing-go"
@@ -24,13 +33,16 @@ import (
 // EventHandler handles http events for Slack integration
 type EventHandler interface {
 	Handle(*gin.Context)
+	HandleInteractive(*gin.Context)
 	HasValidVerificationToken(slcontracts.SlashCommand) bool
+	HasValidSignature(body []byte, timestampHeader, signatureHeader string) bool
 }
 
 type eventHandlerImpl struct {
 	secretHelper                 crypt.SecretHelper
 	config                       config.SlackConfig
 	slackAPIClient               APIClient
+	slackNotifier                Notifier
 	cockroachDBClient            cockroach.DBClient
 	apiConfig                    config.APIServerConfig
 	buildService                 estafette.BuildService
@@ -40,11 +52,12 @@ type eventHandlerImpl struct {
 }
 
 // NewSlackEventHandler returns a new slack.EventHandler
-func NewSlackEventHandler(secretHelper crypt.SecretHelper, config config.SlackConfig, slackAPIClient APIClient, cockroachDBClient cockroach.DBClient, apiConfig config.APIServerConfig, buildService estafette.BuildService, githubJobVarsFunc func(context.Context, string, string, string) (string, string, error), bitbucketJobVarsFunc func(context.Context, string, string, string) (string, string, error), prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
+func NewSlackEventHandler(secretHelper crypt.SecretHelper, config config.SlackConfig, slackAPIClient APIClient, slackNotifier Notifier, cockroachDBClient cockroach.DBClient, apiConfig config.APIServerConfig, buildService estafette.BuildService, githubJobVarsFunc func(context.Context, string, string, string) (string, string, error), bitbucketJobVarsFunc func(context.Context, string, string, string) (string, string, error), prometheusInboundEventTotals *prometheus.CounterVec) EventHandler {
 	return &eventHandlerImpl{
 		secretHelper:                 secretHelper,
 		config:                       config,
 		slackAPIClient:               slackAPIClient,
+		slackNotifier:                slackNotifier,
 		cockroachDBClient:            cockroachDBClient,
 		apiConfig:                    apiConfig,
 		buildService:                 buildService,
@@ -117,8 +130,17 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 					// /estafette release github.com/estafette/estafette-ci-builder beta 0.0.47
 					// /estafette release github.com/estafette/estafette-ci-api beta 0.0.130
 
+					// # release with values for the parameters the release target declares
+					// /estafette release github.com/estafette/estafette-ci-api production 0.0.130 dry-run=true region=europe-west1
+
 					if len(arguments) < 3 {
-						c.String(http.StatusOK, "You have to few arguments, the command has to be of type /estafette release <repo> <release> <version>")
+						c.String(http.StatusOK, "You have to few arguments, the command has to be of type /estafette release <repo> <release> <version> [<parameter>=<value> ...]")
+						return
+					}
+
+					parameterValues, err := getParameterValues(arguments[3:])
+					if err != nil {
+						c.String(http.StatusOK, fmt.Sprintf("%v, the command has to be of type /estafette release <repo> <release> <version> [<parameter>=<value> ...]", err))
 						return
 					}
 
@@ -208,6 +230,12 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 						return
 					}
 
+					parameters, err := estafette.ValidateBuildParameters(build.Manifest, releaseName, parameterValues)
+					if err != nil {
+						c.String(http.StatusOK, fmt.Sprintf("The parameters in your command are not valid: %v", err))
+						return
+					}
+
 					// get user profile from api to set email address for TriggeredBy
 					profile, err := h.slackAPIClient.GetUserProfile(ctx, slashCommand.UserID)
 					if err != nil {
@@ -216,7 +244,7 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 					}
 
 					// create release object and hand off to build service
-					createdRelease, err := h.buildService.CreateRelease(ctx, contracts.Release{
+					createdRelease, err := h.buildService.CreateManualRelease(ctx, contracts.Release{
 						Name:           releaseName,
 						Action:         "", // no support for releas action yet
 						RepoSource:     build.RepoSource,
@@ -231,7 +259,7 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 								},
 							},
 						},
-					}, *build.ManifestObject, build.RepoBranch, build.RepoRevision, false)
+					}, *build.ManifestObject, build.RepoBranch, build.RepoRevision, false, parameters)
 
 					if err != nil {
 						errorMessage := fmt.Sprintf("Failed creating release %v for pipeline %v/%v/%v version %v for release command issued by %v", releaseName, build.RepoSource, build.RepoOwner, build.RepoName, buildVersion, profile.Email)
@@ -250,6 +278,203 @@ func (h *eventHandlerImpl) Handle(c *gin.Context) {
 	c.String(http.StatusOK, "Aye aye!")
 }
 
+// HandleInteractive handles clicks on buttons in messages posted by the notifier
+func (h *eventHandlerImpl) HandleInteractive(c *gin.Context) {
+
+	span, ctx := opentracing.StartSpanFromContext(c.Request.Context(), "Slack::HandleInteractive")
+	defer span.Finish()
+
+	// https://api.slack.com/legacy/interactive-messages
+
+	h.prometheusInboundEventTotals.With(prometheus.Labels{"event": "interactive", "source": "slack"}).Inc()
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Error().Err(err).Msg("Reading body from Slack interactive message failed")
+		c.String(http.StatusInternalServerError, "Reading body from Slack interactive message failed")
+		return
+	}
+
+	// https://api.slack.com/authentication/verifying-requests-from-slack
+	if !h.HasValidSignature(body, c.GetHeader("X-Slack-Request-Timestamp"), c.GetHeader("X-Slack-Signature")) {
+		log.Warn().Msg("Signature for Slack interactive message is invalid")
+		c.String(http.StatusBadRequest, "Signature for Slack interactive message is invalid")
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		log.Error().Err(err).Msg("Parsing form data from Slack interactive message failed")
+		c.String(http.StatusBadRequest, "Parsing form data from Slack interactive message failed")
+		return
+	}
+
+	var payload slcontracts.InteractiveMessagePayload
+	err = json.Unmarshal([]byte(form.Get("payload")), &payload)
+	if err != nil {
+		log.Error().Err(err).Msg("Unmarshalling payload from Slack interactive message failed")
+		c.String(http.StatusInternalServerError, "Unmarshalling payload from Slack interactive message failed")
+		return
+	}
+
+	if payload.CallbackID != "release" || len(payload.Actions) == 0 {
+		c.String(http.StatusOK, "Aye aye!")
+		return
+	}
+
+	var value slcontracts.ReleaseActionValue
+	err = json.Unmarshal([]byte(payload.Actions[0].Value), &value)
+	if err != nil {
+		log.Error().Err(err).Str("value", payload.Actions[0].Value).Msg("Unmarshalling value of Slack release button failed")
+		c.String(http.StatusOK, "The release button is invalid")
+		return
+	}
+
+	build, err := h.cockroachDBClient.GetPipelineBuildByID(ctx, value.RepoSource, value.RepoOwner, value.RepoName, value.BuildID, false)
+	if err != nil {
+		c.String(http.StatusOK, fmt.Sprintf("Retrieving the build for pipeline %v/%v/%v and id %v from the database failed: %v", value.RepoSource, value.RepoOwner, value.RepoName, value.BuildID, err))
+		return
+	}
+	if build == nil || build.ManifestObject == nil {
+		c.String(http.StatusOK, fmt.Sprintf("The build for pipeline %v/%v/%v and id %v does not exist", value.RepoSource, value.RepoOwner, value.RepoName, value.BuildID))
+		return
+	}
+	if build.BuildStatus != "succeeded" {
+		c.String(http.StatusOK, fmt.Sprintf("The build for version %v is not successful and cannot be used", build.BuildVersion))
+		return
+	}
+
+	// the button value comes from the client, so check the release target and action against the build like the release command does
+	if !hasReleaseTargetAction(build.ReleaseTargets, value.ReleaseName, value.ReleaseAction) {
+		c.String(http.StatusOK, fmt.Sprintf("The release %v with action '%v' is not defined in the manifest of version %v", value.ReleaseName, value.ReleaseAction, build.BuildVersion))
+		return
+	}
+
+	// a button can't supply parameter values, so all parameters of the release target need a default
+	parameters, err := estafette.ValidateBuildParameters(build.Manifest, value.ReleaseName, nil)
+	if err != nil {
+		c.String(http.StatusOK, fmt.Sprintf("Release %v needs parameter values, use /estafette release %v/%v/%v %v %v <parameter>=<value> instead: %v", value.ReleaseName, build.RepoSource, build.RepoOwner, build.RepoName, value.ReleaseName, build.BuildVersion, err))
+		return
+	}
+
+	// get user profile from api to set email address for TriggeredBy
+	profile, err := h.slackAPIClient.GetUserProfile(ctx, payload.User.ID)
+	if err != nil {
+		c.String(http.StatusOK, fmt.Sprintf("Failed retrieving Slack user profile for user id %v: %v", payload.User.ID, err))
+		return
+	}
+
+	// create release object and hand off to build service
+	createdRelease, err := h.buildService.CreateManualRelease(ctx, contracts.Release{
+		Name:           value.ReleaseName,
+		Action:         value.ReleaseAction,
+		RepoSource:     build.RepoSource,
+		RepoOwner:      build.RepoOwner,
+		RepoName:       build.RepoName,
+		ReleaseVersion: build.BuildVersion,
+
+		Events: []manifest.EstafetteEvent{
+			manifest.EstafetteEvent{
+				Manual: &manifest.EstafetteManualEvent{
+					UserID: profile.Email,
+				},
+			},
+		},
+	}, *build.ManifestObject, build.RepoBranch, build.RepoRevision, false, parameters)
+
+	if err != nil {
+		errorMessage := fmt.Sprintf("Failed creating release %v for pipeline %v/%v/%v version %v for release button clicked by %v", value.ReleaseName, build.RepoSource, build.RepoOwner, build.RepoName, build.BuildVersion, profile.Email)
+		log.Error().Err(err).Msg(errorMessage)
+		c.String(http.StatusOK, fmt.Sprintf("Inserting starting the release: %v", err))
+		return
+	}
+
+	// replace the buttons with the release progress and have the notifier update it once the release is finished
+	attachments := []slcontracts.Attachment{}
+	if payload.OriginalMessage != nil {
+		for _, a := range payload.OriginalMessage.Attachments {
+			if len(a.Actions) == 0 {
+				attachments = append(attachments, a)
+			}
+		}
+	}
+	h.slackNotifier.TrackReleaseMessage(createdRelease.ID, payload.Channel.ID, payload.MessageTS, attachments)
+
+	title := fmt.Sprintf("Release %v of %v/%v to %v started by %v", build.BuildVersion, build.RepoOwner, build.RepoName, value.ReleaseName, profile.Email)
+	c.JSON(http.StatusOK, slcontracts.Message{
+		Attachments: append(attachments, slcontracts.Attachment{
+			Fallback:  title,
+			Color:     getColorForStatus(createdRelease.ReleaseStatus),
+			Title:     title,
+			TitleLink: fmt.Sprintf("%vpipelines/%v/%v/%v/releases/%v/logs", h.apiConfig.BaseURL, build.RepoSource, build.RepoOwner, build.RepoName, createdRelease.ID),
+		}),
+	})
+}
+
 func (h *eventHandlerImpl) HasValidVerificationToken(slashCommand slcontracts.SlashCommand) bool {
 	return slashCommand.Token == h.config.AppVerificationToken
 }
+
+// HasValidSignature checks the request was signed by Slack with the app's signing secret
+func (h *eventHandlerImpl) HasValidSignature(body []byte, timestampHeader, signatureHeader string) bool {
+	return hasValidSignature(h.config.AppSigningSecret, body, timestampHeader, signatureHeader, time.Now())
+}
+
+// hasValidSignature verifies the hmac-sha256 of the versioned timestamp and body; requests older than 5 minutes are rejected to prevent replays
+func hasValidSignature(signingSecret string, body []byte, timestampHeader, signatureHeader string, now time.Time) bool {
+
+	if signingSecret == "" || !strings.HasPrefix(signatureHeader, "v0=") {
+		return false
+	}
+
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil || math.Abs(now.Sub(time.Unix(timestamp, 0)).Minutes()) > 5 {
+		return false
+	}
+
+	actualMAC, err := hex.DecodeString(strings.TrimPrefix(signatureHeader, "v0="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte(fmt.Sprintf("v0:%v:", timestampHeader)))
+	mac.Write(body)
+
+	return hmac.Equal(actualMAC, mac.Sum(nil))
+}
+
+// hasReleaseTargetAction returns true if the release target exists and either has the action or no action is requested
+func hasReleaseTargetAction(releaseTargets []contracts.ReleaseTarget, releaseName, releaseAction string) bool {
+	for _, releaseTarget := range releaseTargets {
+		if releaseTarget.Name != releaseName {
+			continue
+		}
+		if releaseAction == "" {
+			return true
+		}
+		for _, a := range releaseTarget.Actions {
+			if a.Name == releaseAction {
+				return true
+			}
+		}
+		return false
+	}
+
+	return false
+}
+
+// getParameterValues reads the <parameter>=<value> arguments of the release command
+func getParameterValues(arguments []string) (map[string]string, error) {
+
+	values := map[string]string{}
+	for _, a := range arguments {
+		keyValue := strings.SplitN(a, "=", 2)
+		if len(keyValue) != 2 || keyValue[0] == "" {
+			return nil, fmt.Errorf("Argument %v is not a parameter of the form <parameter>=<value>", a)
+		}
+		values[keyValue[0]] = keyValue[1]
+	}
+
+	return values, nil
+}
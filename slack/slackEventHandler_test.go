@@ -0,0 +1,110 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	contracts "github.com/estafette/estafette-ci-contracts"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasReleaseTargetAction(t *testing.T) {
+
+	releaseTargets := []contracts.ReleaseTarget{
+		contracts.ReleaseTarget{Name: "development"},
+		contracts.ReleaseTarget{Name: "production", Actions: []manifest.EstafetteReleaseAction{
+			manifest.EstafetteReleaseAction{Name: "deploy-canary"},
+			manifest.EstafetteReleaseAction{Name: "deploy-stable"},
+		}},
+	}
+
+	t.Run("ReturnsTrueForTargetWithoutAction", func(t *testing.T) {
+
+		// act
+		exists := hasReleaseTargetAction(releaseTargets, "development", "")
+
+		assert.True(t, exists)
+	})
+
+	t.Run("ReturnsTrueForTargetWithAction", func(t *testing.T) {
+
+		// act
+		exists := hasReleaseTargetAction(releaseTargets, "production", "deploy-stable")
+
+		assert.True(t, exists)
+	})
+
+	t.Run("ReturnsFalseForUnknownAction", func(t *testing.T) {
+
+		// act
+		exists := hasReleaseTargetAction(releaseTargets, "production", "rollback")
+
+		assert.False(t, exists)
+	})
+
+	t.Run("ReturnsFalseForUnknownTarget", func(t *testing.T) {
+
+		// act
+		exists := hasReleaseTargetAction(releaseTargets, "staging", "")
+
+		assert.False(t, exists)
+	})
+}
+
+func TestHasValidSignature(t *testing.T) {
+
+	signingSecret := "8f742231b10e8888abcd99yyyzzz85a5"
+	body := []byte("payload=%7B%22callback_id%22%3A%22release%22%7D")
+	now := time.Unix(1531420618, 0)
+
+	sign := func(secret, timestamp string, body []byte) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("v0:" + timestamp + ":"))
+		mac.Write(body)
+		return "v0=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	t.Run("ReturnsTrueForBodySignedWithSigningSecret", func(t *testing.T) {
+
+		// act
+		valid := hasValidSignature(signingSecret, body, "1531420618", sign(signingSecret, "1531420618", body), now)
+
+		assert.True(t, valid)
+	})
+
+	t.Run("ReturnsFalseForBodySignedWithOtherSecret", func(t *testing.T) {
+
+		// act
+		valid := hasValidSignature(signingSecret, body, "1531420618", sign("other secret", "1531420618", body), now)
+
+		assert.False(t, valid)
+	})
+
+	t.Run("ReturnsFalseForTamperedBody", func(t *testing.T) {
+
+		// act
+		valid := hasValidSignature(signingSecret, []byte("payload=%7B%7D"), "1531420618", sign(signingSecret, "1531420618", body), now)
+
+		assert.False(t, valid)
+	})
+
+	t.Run("ReturnsFalseForTimestampOlderThanFiveMinutes", func(t *testing.T) {
+
+		// act
+		valid := hasValidSignature(signingSecret, body, "1531420018", sign(signingSecret, "1531420018", body), now)
+
+		assert.False(t, valid)
+	})
+
+	t.Run("ReturnsFalseWithoutSigningSecret", func(t *testing.T) {
+
+		// act
+		valid := hasValidSignature("", body, "1531420618", sign("", "1531420618", body), now)
+
+		assert.False(t, valid)
+	})
+}
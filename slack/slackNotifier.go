@@ -0,0 +1,362 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	slcontracts "github.com/estafette/estafette-ci-api/slack/contracts"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Notifier posts build and release outcomes to Slack
+type Notifier interface {
+	NotifyBuild(context.Context, contracts.Build) error
+	NotifyRelease(context.Context, contracts.Release) error
+	BuildNotifyFunc() func(context.Context, contracts.Build) error
+	ReleaseNotifyFunc() func(context.Context, contracts.Release) error
+	TrackReleaseMessage(releaseID, channel, ts string, attachments []slcontracts.Attachment)
+}
+
+// trackedMessage is a message with release buttons that gets updated when the release it started finishes
+type trackedMessage struct {
+	Channel     string
+	TS          string
+	Attachments []slcontracts.Attachment
+}
+
+type notifierImpl struct {
+	config            config.SlackConfig
+	apiConfig         config.APIServerConfig
+	slackAPIClient    APIClient
+	cockroachDBClient cockroach.DBClient
+
+	// releases started from a button; these are kept in memory only, so the message doesn't get updated if the api restarts
+	// before the release finishes, or if the release's status update is handled by another replica than the button click
+	trackedMessages      map[string]trackedMessage
+	trackedMessagesMutex sync.Mutex
+}
+
+// NewSlackNotifier returns a new slack.Notifier
+func NewSlackNotifier(config config.SlackConfig, apiConfig config.APIServerConfig, slackAPIClient APIClient, cockroachDBClient cockroach.DBClient) Notifier {
+	return &notifierImpl{
+		config:            config,
+		apiConfig:         apiConfig,
+		slackAPIClient:    slackAPIClient,
+		cockroachDBClient: cockroachDBClient,
+		trackedMessages:   map[string]trackedMessage{},
+	}
+}
+
+// manifestNotifications represents the optional notifications section in a manifest, which the manifest library ignores
+type manifestNotifications struct {
+	Notifications struct {
+		Slack struct {
+			Channels []string `yaml:"channels"`
+		} `yaml:"slack"`
+	} `yaml:"notifications"`
+}
+
+func (n *notifierImpl) NotifyBuild(ctx context.Context, build contracts.Build) (err error) {
+
+	if !n.isEnabled() || !isFinalStatus(build.BuildStatus) {
+		return
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SlackNotifier::NotifyBuild")
+	defer span.Finish()
+
+	link := fmt.Sprintf("%vpipelines/%v/%v/%v/builds/%v/logs", n.apiConfig.BaseURL, build.RepoSource, build.RepoOwner, build.RepoName, build.ID)
+	title := fmt.Sprintf("Build %v of %v/%v %v", build.BuildVersion, build.RepoOwner, build.RepoName, build.BuildStatus)
+
+	fields := []slcontracts.AttachmentField{
+		slcontracts.AttachmentField{Title: "Branch", Value: build.RepoBranch, Short: true},
+		slcontracts.AttachmentField{Title: "Revision", Value: build.RepoRevision, Short: true},
+	}
+	if len(build.Commits) > 0 {
+		fields = append(fields, slcontracts.AttachmentField{Title: "Commit", Value: fmt.Sprintf("%v (%v)", build.Commits[0].Message, build.Commits[0].Author.Name)})
+	}
+
+	attachment := slcontracts.Attachment{
+		Fallback:  title,
+		Color:     getColorForStatus(build.BuildStatus),
+		Title:     title,
+		TitleLink: link,
+		Fields:    fields,
+	}
+
+	attachments := []slcontracts.Attachment{attachment}
+	if build.BuildStatus == "succeeded" {
+		attachments = append(attachments, getReleaseButtonAttachments(build)...)
+	}
+
+	channels := n.getChannels(build.Labels, build.Manifest)
+
+	// direct message the committer of the last commit when the build failed
+	if build.BuildStatus == "failed" && n.config.Notifications.NotifyCommitterOnFailure && len(build.Commits) > 0 && build.Commits[0].Author.Email != "" {
+		userID, err := n.slackAPIClient.GetUserIDByEmail(ctx, build.Commits[0].Author.Email)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed resolving Slack user for committer %v of build %v/%v/%v id %v", build.Commits[0].Author.Email, build.RepoSource, build.RepoOwner, build.RepoName, build.ID)
+		} else {
+			channels = append(channels, userID)
+		}
+	}
+
+	return n.postToChannels(ctx, channels, attachments)
+}
+
+func (n *notifierImpl) NotifyRelease(ctx context.Context, release contracts.Release) (err error) {
+
+	if !n.isEnabled() || !isFinalStatus(release.ReleaseStatus) {
+		return
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "SlackNotifier::NotifyRelease")
+	defer span.Finish()
+
+	// releases don't carry labels or a manifest, so get them from the pipeline
+	pipeline, err := n.cockroachDBClient.GetPipeline(ctx, release.RepoSource, release.RepoOwner, release.RepoName, false)
+	if err != nil {
+		return
+	}
+	if pipeline == nil {
+		return fmt.Errorf("No pipeline %v/%v/%v for release %v", release.RepoSource, release.RepoOwner, release.RepoName, release.ID)
+	}
+
+	link := fmt.Sprintf("%vpipelines/%v/%v/%v/releases/%v/logs", n.apiConfig.BaseURL, release.RepoSource, release.RepoOwner, release.RepoName, release.ID)
+	title := fmt.Sprintf("Release %v of %v/%v to %v %v", release.ReleaseVersion, release.RepoOwner, release.RepoName, release.Name, release.ReleaseStatus)
+
+	attachment := slcontracts.Attachment{
+		Fallback:  title,
+		Color:     getColorForStatus(release.ReleaseStatus),
+		Title:     title,
+		TitleLink: link,
+	}
+
+	channels := n.getChannels(pipeline.Labels, pipeline.Manifest)
+
+	// direct message whoever started the release when it failed
+	if release.ReleaseStatus == "failed" && n.config.Notifications.NotifyCommitterOnFailure {
+		for _, e := range release.Events {
+			if e.Manual != nil && e.Manual.UserID != "" {
+				userID, err := n.slackAPIClient.GetUserIDByEmail(ctx, e.Manual.UserID)
+				if err != nil {
+					log.Warn().Err(err).Msgf("Failed resolving Slack user for %v of release %v/%v/%v id %v", e.Manual.UserID, release.RepoSource, release.RepoOwner, release.RepoName, release.ID)
+				} else {
+					channels = append(channels, userID)
+				}
+			}
+		}
+	}
+
+	// update the message the release was started from instead of posting a new one to that channel
+	n.trackedMessagesMutex.Lock()
+	message, isTracked := n.trackedMessages[release.ID]
+	delete(n.trackedMessages, release.ID)
+	n.trackedMessagesMutex.Unlock()
+
+	if isTracked {
+		_, err = n.slackAPIClient.UpdateMessage(ctx, slcontracts.UpdateMessageRequest{
+			Channel:     message.Channel,
+			TS:          message.TS,
+			Attachments: append(message.Attachments, attachment),
+		})
+		if err != nil {
+			log.Warn().Err(err).Msgf("Failed updating Slack message %v for release %v/%v/%v id %v", message.TS, release.RepoSource, release.RepoOwner, release.RepoName, release.ID)
+		} else {
+			channels = remove(channels, message.Channel)
+		}
+	}
+
+	return n.postToChannels(ctx, channels, []slcontracts.Attachment{attachment})
+}
+
+// TrackReleaseMessage registers a message to be updated once the release is finished
+func (n *notifierImpl) TrackReleaseMessage(releaseID, channel, ts string, attachments []slcontracts.Attachment) {
+	n.trackedMessagesMutex.Lock()
+	defer n.trackedMessagesMutex.Unlock()
+
+	n.trackedMessages[releaseID] = trackedMessage{
+		Channel:     channel,
+		TS:          ts,
+		Attachments: attachments,
+	}
+}
+
+// BuildNotifyFunc returns a function that notifies Slack about a finished build
+func (n *notifierImpl) BuildNotifyFunc() func(context.Context, contracts.Build) error {
+	return func(ctx context.Context, build contracts.Build) error {
+		return n.NotifyBuild(ctx, build)
+	}
+}
+
+// ReleaseNotifyFunc returns a function that notifies Slack about a finished release
+func (n *notifierImpl) ReleaseNotifyFunc() func(context.Context, contracts.Release) error {
+	return func(ctx context.Context, release contracts.Release) error {
+		return n.NotifyRelease(ctx, release)
+	}
+}
+
+func (n *notifierImpl) isEnabled() bool {
+	return n.config.Notifications != nil && n.config.Notifications.Enable
+}
+
+// isFinalStatus skips notifications for the running status, which also passes through FinishBuild and FinishRelease
+func isFinalStatus(status string) bool {
+	return status == "succeeded" || status == "failed" || status == "canceled" || status == "timedout"
+}
+
+func (n *notifierImpl) postToChannels(ctx context.Context, channels []string, attachments []slcontracts.Attachment) (err error) {
+	for _, channel := range channels {
+		_, postErr := n.slackAPIClient.PostMessage(ctx, slcontracts.PostMessageRequest{
+			Channel:     channel,
+			Attachments: attachments,
+		})
+		if postErr != nil {
+			log.Warn().Err(postErr).Msgf("Failed posting Slack notification to channel %v", channel)
+			err = postErr
+		}
+	}
+
+	return
+}
+
+// getChannels returns the distinct channels configured globally, by label or in the manifest
+func (n *notifierImpl) getChannels(labels []contracts.Label, manifestString string) (channels []string) {
+
+	channels = append(channels, n.config.Notifications.DefaultChannels...)
+
+	for _, lc := range n.config.Notifications.LabelChannels {
+		if lc != nil && hasAllLabels(labels, lc.Labels) {
+			channels = append(channels, lc.Channel)
+		}
+	}
+
+	var mft manifestNotifications
+	if err := yaml.Unmarshal([]byte(manifestString), &mft); err == nil {
+		channels = append(channels, mft.Notifications.Slack.Channels...)
+	}
+
+	return distinct(channels)
+}
+
+// getReleaseButtonAttachments returns a button for each release target and action, in attachments of at most 5 buttons as Slack allows
+func getReleaseButtonAttachments(build contracts.Build) (attachments []slcontracts.Attachment) {
+
+	buildID, err := strconv.Atoi(build.ID)
+	if err != nil {
+		return
+	}
+
+	actions := []slcontracts.AttachmentAction{}
+	for _, rt := range build.ReleaseTargets {
+		releaseActions := []string{""}
+		if len(rt.Actions) > 0 {
+			releaseActions = []string{}
+			for _, a := range rt.Actions {
+				releaseActions = append(releaseActions, a.Name)
+			}
+		}
+
+		for _, ra := range releaseActions {
+			value, err := json.Marshal(slcontracts.ReleaseActionValue{
+				RepoSource:    build.RepoSource,
+				RepoOwner:     build.RepoOwner,
+				RepoName:      build.RepoName,
+				BuildID:       buildID,
+				ReleaseName:   rt.Name,
+				ReleaseAction: ra,
+			})
+			if err != nil {
+				continue
+			}
+
+			text := fmt.Sprintf("Release to %v", rt.Name)
+			if ra != "" {
+				text = fmt.Sprintf("Release to %v (%v)", rt.Name, ra)
+			}
+
+			actions = append(actions, slcontracts.AttachmentAction{
+				Name:  "release",
+				Text:  text,
+				Type:  "button",
+				Value: string(value),
+			})
+		}
+	}
+
+	for i := 0; i < len(actions); i += 5 {
+		end := i + 5
+		if end > len(actions) {
+			end = len(actions)
+		}
+		attachments = append(attachments, slcontracts.Attachment{
+			Fallback:   "Release this version",
+			CallbackID: "release",
+			Actions:    actions[i:end],
+		})
+	}
+
+	return
+}
+
+func hasAllLabels(labels []contracts.Label, wanted map[string]string) bool {
+	if len(wanted) == 0 {
+		return false
+	}
+	for key, value := range wanted {
+		found := false
+		for _, l := range labels {
+			if l.Key == key && l.Value == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func distinct(values []string) (distinctValues []string) {
+	seen := map[string]bool{}
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			distinctValues = append(distinctValues, v)
+		}
+	}
+
+	return
+}
+
+func remove(values []string, value string) (remaining []string) {
+	for _, v := range values {
+		if v != value {
+			remaining = append(remaining, v)
+		}
+	}
+
+	return
+}
+
+func getColorForStatus(status string) string {
+	switch status {
+	case "succeeded":
+		return "good"
+	case "failed",
+		"timedout":
+		return "danger"
+	}
+
+	return "warning"
+}
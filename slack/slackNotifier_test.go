@@ -0,0 +1,221 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/estafette/estafette-ci-api/config"
+	slcontracts "github.com/estafette/estafette-ci-api/slack/contracts"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	manifest "github.com/estafette/estafette-ci-manifest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSlackServer mimics the parts of the Slack web api used for notifications and records posted messages
+type fakeSlackServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	messages []slcontracts.PostMessageRequest
+	users    map[string]string
+}
+
+func newFakeSlackServer(users map[string]string) *fakeSlackServer {
+	fake := &fakeSlackServer{users: users}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		var message slcontracts.PostMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			json.NewEncoder(w).Encode(slcontracts.PostMessageResponse{OK: false, Error: "invalid_json"})
+			return
+		}
+		fake.mutex.Lock()
+		fake.messages = append(fake.messages, message)
+		fake.mutex.Unlock()
+		json.NewEncoder(w).Encode(slcontracts.PostMessageResponse{OK: true, Channel: message.Channel, TS: "1571234567.000100"})
+	})
+	mux.HandleFunc("/users.lookupByEmail", func(w http.ResponseWriter, r *http.Request) {
+		if userID, ok := fake.users[r.URL.Query().Get("email")]; ok {
+			json.NewEncoder(w).Encode(slcontracts.LookupUserByEmailResponse{OK: true, User: &slcontracts.User{ID: userID}})
+			return
+		}
+		json.NewEncoder(w).Encode(slcontracts.LookupUserByEmailResponse{OK: false, Error: "users_not_found"})
+	})
+	fake.Server = httptest.NewServer(mux)
+
+	return fake
+}
+
+func (f *fakeSlackServer) channels() (channels []string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, m := range f.messages {
+		channels = append(channels, m.Channel)
+	}
+	return
+}
+
+func getTestNotifier(serverURL string) Notifier {
+	slackConfig := config.SlackConfig{
+		APIBaseURL: serverURL,
+		Notifications: &config.SlackNotificationsConfig{
+			Enable:          true,
+			DefaultChannels: []string{"#builds"},
+			LabelChannels: []*config.SlackChannelConfig{
+				&config.SlackChannelConfig{
+					Labels:  map[string]string{"team": "estafette-team"},
+					Channel: "#estafette-team",
+				},
+			},
+			NotifyCommitterOnFailure: true,
+			MessagesPerSecond:        100,
+		},
+	}
+	outboundTotals := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_outbound_api_call_totals"}, []string{"target"})
+	apiClient := NewSlackAPIClient(slackConfig, outboundTotals)
+
+	return NewSlackNotifier(slackConfig, config.APIServerConfig{BaseURL: "https://ci.estafette.io/"}, apiClient, nil)
+}
+
+func TestNotifyBuild(t *testing.T) {
+
+	t.Run("PostsToDefaultLabelAndManifestChannels", func(t *testing.T) {
+
+		server := newFakeSlackServer(map[string]string{})
+		defer server.Close()
+		notifier := getTestNotifier(server.URL)
+
+		build := contracts.Build{
+			ID:           "15",
+			RepoSource:   "github.com",
+			RepoOwner:    "estafette",
+			RepoName:     "estafette-ci-api",
+			BuildVersion: "1.0.15",
+			BuildStatus:  "succeeded",
+			Labels:       []contracts.Label{contracts.Label{Key: "team", Value: "estafette-team"}},
+			Manifest:     "notifications:\n  slack:\n    channels:\n    - '#ci-api'\n    - '#builds'\n",
+		}
+
+		// act
+		err := notifier.NotifyBuild(context.Background(), build)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"#builds", "#estafette-team", "#ci-api"}, server.channels())
+	})
+
+	t.Run("SendsDirectMessageToCommitterOnFailure", func(t *testing.T) {
+
+		server := newFakeSlackServer(map[string]string{"me@estafette.io": "U024BE7LH"})
+		defer server.Close()
+		notifier := getTestNotifier(server.URL)
+
+		build := contracts.Build{
+			ID:          "16",
+			BuildStatus: "failed",
+			Commits: []contracts.GitCommit{
+				contracts.GitCommit{Message: "fix it", Author: contracts.GitAuthor{Email: "me@estafette.io", Name: "Me"}},
+			},
+		}
+
+		// act
+		err := notifier.NotifyBuild(context.Background(), build)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"#builds", "U024BE7LH"}, server.channels())
+	})
+
+	t.Run("DoesNotPostForRunningBuild", func(t *testing.T) {
+
+		server := newFakeSlackServer(map[string]string{})
+		defer server.Close()
+		notifier := getTestNotifier(server.URL)
+
+		build := contracts.Build{
+			ID:          "18",
+			BuildStatus: "running",
+		}
+
+		// act
+		err := notifier.NotifyBuild(context.Background(), build)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(server.channels()))
+	})
+
+	t.Run("DoesNotSendDirectMessageOnSuccess", func(t *testing.T) {
+
+		server := newFakeSlackServer(map[string]string{"me@estafette.io": "U024BE7LH"})
+		defer server.Close()
+		notifier := getTestNotifier(server.URL)
+
+		build := contracts.Build{
+			ID:          "17",
+			BuildStatus: "succeeded",
+			Commits: []contracts.GitCommit{
+				contracts.GitCommit{Message: "fix it", Author: contracts.GitAuthor{Email: "me@estafette.io", Name: "Me"}},
+			},
+		}
+
+		// act
+		err := notifier.NotifyBuild(context.Background(), build)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"#builds"}, server.channels())
+	})
+}
+
+func TestGetReleaseButtonAttachments(t *testing.T) {
+
+	t.Run("ReturnsButtonForEachReleaseTargetAndAction", func(t *testing.T) {
+
+		build := contracts.Build{
+			ID:         "15",
+			RepoSource: "github.com",
+			RepoOwner:  "estafette",
+			RepoName:   "estafette-ci-api",
+			ReleaseTargets: []contracts.ReleaseTarget{
+				contracts.ReleaseTarget{Name: "beta"},
+				contracts.ReleaseTarget{Name: "production", Actions: []manifest.EstafetteReleaseAction{
+					manifest.EstafetteReleaseAction{Name: "deploy-canary"},
+					manifest.EstafetteReleaseAction{Name: "deploy-stable"},
+				}},
+			},
+		}
+
+		// act
+		attachments := getReleaseButtonAttachments(build)
+
+		assert.Equal(t, 1, len(attachments))
+		assert.Equal(t, "release", attachments[0].CallbackID)
+		assert.Equal(t, 3, len(attachments[0].Actions))
+		assert.Equal(t, "Release to beta", attachments[0].Actions[0].Text)
+		assert.Equal(t, "Release to production (deploy-canary)", attachments[0].Actions[1].Text)
+
+		var value slcontracts.ReleaseActionValue
+		err := json.Unmarshal([]byte(attachments[0].Actions[2].Value), &value)
+		assert.Nil(t, err)
+		assert.Equal(t, 15, value.BuildID)
+		assert.Equal(t, "production", value.ReleaseName)
+		assert.Equal(t, "deploy-stable", value.ReleaseAction)
+	})
+
+	t.Run("SplitsButtonsInAttachmentsOfAtMostFive", func(t *testing.T) {
+
+		build := contracts.Build{ID: "15"}
+		for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+			build.ReleaseTargets = append(build.ReleaseTargets, contracts.ReleaseTarget{Name: name})
+		}
+
+		// act
+		attachments := getReleaseButtonAttachments(build)
+
+		assert.Equal(t, 2, len(attachments))
+		assert.Equal(t, 5, len(attachments[0].Actions))
+		assert.Equal(t, 1, len(attachments[1].Actions))
+	})
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WebhookMetricsMiddleware records the processing time of inbound webhooks and counts the ones that failed processing
+func WebhookMetricsMiddleware(source string, durationSeconds *prometheus.HistogramVec, errorTotals *prometheus.CounterVec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		start := time.Now()
+
+		// process request
+		c.Next()
+
+		durationSeconds.With(prometheus.Labels{"source": source}).Observe(time.Since(start).Seconds())
+
+		if c.Writer.Status() >= http.StatusBadRequest {
+			errorTotals.With(prometheus.Labels{"source": source, "code": strconv.Itoa(c.Writer.Status())}).Inc()
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package contracts
+
+import (
+	"time"
+)
+
+// CloudEvent represents an event in the CloudEvents 1.0 structured json format, see https://github.com/cloudevents/spec/blob/v1.0/json-format.md
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype,omitempty"`
+	Data            interface{} `json:"data,omitempty"`
+}
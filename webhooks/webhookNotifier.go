@@ -0,0 +1,402 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	whcontracts "github.com/estafette/estafette-ci-api/webhooks/contracts"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"github.com/sethgrid/pester"
+)
+
+// Notifier sends build and release events as CloudEvents to webhook subscribers
+type Notifier interface {
+	NotifyBuild(context.Context, contracts.Build) error
+	NotifyRelease(context.Context, contracts.Release) error
+	Redeliver(context.Context, string) (*cockroach.WebhookDelivery, error)
+	Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup)
+}
+
+type notifierImpl struct {
+	config                          *config.WebhooksConfig
+	apiConfig                       config.APIServerConfig
+	cockroachDBClient               cockroach.DBClient
+	prometheusOutboundAPICallTotals *prometheus.CounterVec
+	backoff                         pester.BackoffStrategy
+	queue                           chan queuedDelivery
+}
+
+// queuedDelivery is a logged delivery waiting for a worker to send it to its subscriber
+type queuedDelivery struct {
+	ctx        context.Context
+	subscriber config.WebhookSubscriberConfig
+	delivery   cockroach.WebhookDelivery
+}
+
+// NewWebhookNotifier returns a new webhooks.Notifier
+func NewWebhookNotifier(config *config.WebhooksConfig, apiConfig config.APIServerConfig, cockroachDBClient cockroach.DBClient, prometheusOutboundAPICallTotals *prometheus.CounterVec) Notifier {
+	return &notifierImpl{
+		config:                          config,
+		apiConfig:                       apiConfig,
+		cockroachDBClient:               cockroachDBClient,
+		prometheusOutboundAPICallTotals: prometheusOutboundAPICallTotals,
+		backoff:                         pester.ExponentialJitterBackoff,
+		queue:                           make(chan queuedDelivery, getQueueSize(config)),
+	}
+}
+
+func (n *notifierImpl) NotifyBuild(ctx context.Context, build contracts.Build) (err error) {
+
+	if n.config == nil || !n.config.Enable {
+		return
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "WebhookNotifier::NotifyBuild")
+	defer span.Finish()
+
+	event := n.newEvent(fmt.Sprintf("io.estafette.ci.build.%v", build.BuildStatus), build.RepoSource, build.RepoOwner, build.RepoName, fmt.Sprintf("builds/%v", build.ID), build)
+
+	return n.notifySubscribers(ctx, event, fmt.Sprintf("%v/%v/%v", build.RepoSource, build.RepoOwner, build.RepoName), build.Labels)
+}
+
+func (n *notifierImpl) NotifyRelease(ctx context.Context, release contracts.Release) (err error) {
+
+	if n.config == nil || !n.config.Enable {
+		return
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "WebhookNotifier::NotifyRelease")
+	defer span.Finish()
+
+	// releases don't carry labels, get them from the pipeline in case subscribers filter on them
+	var labels []contracts.Label
+	for _, s := range n.config.Subscribers {
+		if s != nil && len(s.Labels) > 0 {
+			pipeline, err := n.cockroachDBClient.GetPipeline(ctx, release.RepoSource, release.RepoOwner, release.RepoName, true)
+			if err != nil {
+				return err
+			}
+			if pipeline != nil {
+				labels = pipeline.Labels
+			}
+			break
+		}
+	}
+
+	event := n.newEvent(fmt.Sprintf("io.estafette.ci.release.%v", release.ReleaseStatus), release.RepoSource, release.RepoOwner, release.RepoName, fmt.Sprintf("releases/%v", release.ID), release)
+
+	return n.notifySubscribers(ctx, event, fmt.Sprintf("%v/%v/%v", release.RepoSource, release.RepoOwner, release.RepoName), labels)
+}
+
+// Redeliver sends the payload of an earlier delivery to its subscriber again
+func (n *notifierImpl) Redeliver(ctx context.Context, deliveryID string) (delivery *cockroach.WebhookDelivery, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "WebhookNotifier::Redeliver")
+	defer span.Finish()
+
+	delivery, err = n.cockroachDBClient.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		return
+	}
+	if delivery == nil {
+		return nil, fmt.Errorf("Webhook delivery %v does not exist", deliveryID)
+	}
+
+	subscriber := n.getSubscriber(delivery.SubscriberName)
+	if subscriber == nil {
+		return nil, fmt.Errorf("Webhook subscriber %v for delivery %v is no longer configured", delivery.SubscriberName, deliveryID)
+	}
+
+	statusCode, attempts, sendErr := n.send(ctx, subscriber.URL, subscriber.Secret, []byte(delivery.Payload))
+
+	delivery.StatusCode = statusCode
+	delivery.Attempts += attempts
+	delivery.Succeeded = sendErr == nil
+	delivery.Error = ""
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+	}
+
+	err = n.cockroachDBClient.UpdateWebhookDelivery(ctx, *delivery)
+	if err != nil {
+		return
+	}
+
+	return delivery, sendErr
+}
+
+// Run starts the workers sending queued deliveries; once the stop channel gets closed they send the deliveries still queued and stop
+func (n *notifierImpl) Run(stopChannel <-chan struct{}, waitGroup *sync.WaitGroup) {
+
+	if n.config == nil || !n.config.Enable {
+		return
+	}
+
+	for i := 0; i < getWorkers(n.config); i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			for {
+				select {
+				case d := <-n.queue:
+					n.deliverQueued(d)
+				case <-stopChannel:
+					for {
+						select {
+						case d := <-n.queue:
+							n.deliverQueued(d)
+						default:
+							log.Debug().Msg("Stopping webhook delivery worker...")
+							return
+						}
+					}
+				}
+			}
+		}()
+	}
+}
+
+// notifySubscribers logs a delivery for each matching subscriber and queues it for the workers without waiting for it to be sent; the outcome of each delivery ends up in the delivery log
+func (n *notifierImpl) notifySubscribers(ctx context.Context, event whcontracts.CloudEvent, pipeline string, labels []contracts.Label) (err error) {
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	// deliver in the background so slow subscribers retrying with backoff don't hold up other notifications; the caller's
+	// context gets canceled once it's done, so deliveries get a context of their own that keeps the span
+	deliveryCtx := opentracing.ContextWithSpan(context.Background(), opentracing.SpanFromContext(ctx))
+
+	for _, s := range n.config.Subscribers {
+		if s == nil || !isSubscribed(*s, event.Type, pipeline, labels) {
+			continue
+		}
+
+		delivery, insertErr := n.cockroachDBClient.InsertWebhookDelivery(ctx, cockroach.WebhookDelivery{
+			SubscriberName: s.Name,
+			URL:            s.URL,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        string(payload),
+		})
+		if insertErr != nil {
+			log.Warn().Err(insertErr).Msgf("Failed logging delivery of event %v of type %v to webhook subscriber %v", event.ID, event.Type, s.Name)
+			continue
+		}
+
+		select {
+		case n.queue <- queuedDelivery{ctx: deliveryCtx, subscriber: *s, delivery: *delivery}:
+		default:
+			// a full queue means subscribers can't keep up; the logged delivery can be redelivered once they do
+			log.Warn().Msgf("Webhook delivery queue is full, not delivering event %v of type %v to webhook subscriber %v", event.ID, event.Type, s.Name)
+			delivery.Error = "Webhook delivery queue is full"
+			updateErr := n.cockroachDBClient.UpdateWebhookDelivery(ctx, *delivery)
+			if updateErr != nil {
+				log.Warn().Err(updateErr).Msgf("Failed updating delivery %v of event %v to webhook subscriber %v", delivery.ID, event.ID, s.Name)
+			}
+		}
+	}
+
+	return
+}
+
+func (n *notifierImpl) deliverQueued(d queuedDelivery) {
+	err := n.deliver(d.ctx, d.subscriber, &d.delivery)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed delivering event %v of type %v to webhook subscriber %v", d.delivery.EventID, d.delivery.EventType, d.subscriber.Name)
+	}
+}
+
+// deliver sends the payload of a logged delivery to its subscriber and logs the outcome in the database, so it can be inspected and redelivered
+func (n *notifierImpl) deliver(ctx context.Context, subscriber config.WebhookSubscriberConfig, delivery *cockroach.WebhookDelivery) (err error) {
+
+	statusCode, attempts, sendErr := n.send(ctx, subscriber.URL, subscriber.Secret, []byte(delivery.Payload))
+
+	delivery.StatusCode = statusCode
+	delivery.Attempts = attempts
+	delivery.Succeeded = sendErr == nil
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+	}
+
+	err = n.cockroachDBClient.UpdateWebhookDelivery(ctx, *delivery)
+	if err != nil {
+		return
+	}
+
+	return sendErr
+}
+
+// send posts the payload with a hmac signature and retries with exponential backoff on server errors
+func (n *notifierImpl) send(ctx context.Context, url, secret string, payload []byte) (statusCode, attempts int, err error) {
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "WebhookNotifier::Send")
+	defer span.Finish()
+
+	// track call via prometheus
+	n.prometheusOutboundAPICallTotals.With(prometheus.Labels{"target": "webhook"}).Inc()
+
+	maxRetries := 3
+	if n.config != nil && n.config.MaxRetries > 0 {
+		maxRetries = n.config.MaxRetries
+	}
+
+	// create client, in order to add headers
+	client := pester.NewExtendedClient(&http.Client{Transport: &nethttp.Transport{}})
+	client.MaxRetries = maxRetries
+	client.Backoff = n.backoff
+	client.KeepLog = true
+	client.Timeout = time.Second * 10
+
+	request, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+
+	// add tracing context
+	request = request.WithContext(opentracing.ContextWithSpan(request.Context(), span))
+
+	// collect additional information on setting up connections
+	request, ht := nethttp.TraceRequest(span.Tracer(), request)
+
+	// add headers
+	request.Header.Add("Content-Type", "application/cloudevents+json; charset=utf-8")
+	if secret != "" {
+		request.Header.Add("X-Estafette-Signature", fmt.Sprintf("sha256=%v", sign(payload, secret)))
+	}
+
+	// perform actual request
+	response, err := client.Do(request)
+	attempts = client.LogErrCount()
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+	ht.Finish()
+
+	io.Copy(ioutil.Discard, response.Body)
+
+	statusCode = response.StatusCode
+	if statusCode >= 300 {
+		return statusCode, attempts, fmt.Errorf("Webhook %v responded with status code %v", url, statusCode)
+	}
+
+	// the successful attempt isn't in the error log
+	return statusCode, attempts + 1, nil
+}
+
+func (n *notifierImpl) newEvent(eventType, repoSource, repoOwner, repoName, subject string, data interface{}) whcontracts.CloudEvent {
+	return whcontracts.CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              newEventID(),
+		Source:          fmt.Sprintf("%vpipelines/%v/%v/%v", n.apiConfig.BaseURL, repoSource, repoOwner, repoName),
+		Type:            eventType,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+}
+
+func (n *notifierImpl) getSubscriber(name string) *config.WebhookSubscriberConfig {
+	if n.config == nil {
+		return nil
+	}
+	for _, s := range n.config.Subscribers {
+		if s != nil && s.Name == name {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// isSubscribed checks the event type, pipeline and labels filters of a subscriber; empty filters match everything
+func isSubscribed(subscriber config.WebhookSubscriberConfig, eventType, pipeline string, labels []contracts.Label) bool {
+
+	if len(subscriber.Events) > 0 && !contains(subscriber.Events, eventType) {
+		return false
+	}
+
+	if len(subscriber.Pipelines) > 0 && !contains(subscriber.Pipelines, pipeline) {
+		return false
+	}
+
+	for key, value := range subscriber.Labels {
+		found := false
+		for _, l := range labels {
+			if l.Key == key && l.Value == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getWorkers returns the number of deliveries sent concurrently
+func getWorkers(config *config.WebhooksConfig) int {
+	if config == nil || config.Workers <= 0 {
+		return 5
+	}
+
+	return config.Workers
+}
+
+// getQueueSize returns the number of deliveries that can wait for a worker before new ones get dropped
+func getQueueSize(config *config.WebhooksConfig) int {
+	if config == nil || config.QueueSize <= 0 {
+		return 100
+	}
+
+	return config.QueueSize
+}
+
+func sign(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%v", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
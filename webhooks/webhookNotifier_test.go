@@ -0,0 +1,256 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/estafette/estafette-ci-api/cockroach"
+	"github.com/estafette/estafette-ci-api/config"
+	contracts "github.com/estafette/estafette-ci-contracts"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestNotifier() *notifierImpl {
+	outboundTotals := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_outbound_api_call_totals"}, []string{"target"})
+	notifier := NewWebhookNotifier(&config.WebhooksConfig{Enable: true, MaxRetries: 3}, config.APIServerConfig{BaseURL: "https://ci.estafette.io/"}, nil, outboundTotals).(*notifierImpl)
+	notifier.backoff = func(int) time.Duration { return 0 }
+
+	return notifier
+}
+
+// fakeDBClient keeps webhook deliveries in memory
+type fakeDBClient struct {
+	cockroach.DBClient
+	mutex      sync.Mutex
+	deliveries []cockroach.WebhookDelivery
+}
+
+func (f *fakeDBClient) InsertWebhookDelivery(ctx context.Context, delivery cockroach.WebhookDelivery) (*cockroach.WebhookDelivery, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delivery.ID = fmt.Sprint(len(f.deliveries) + 1)
+	f.deliveries = append(f.deliveries, delivery)
+	return &delivery, nil
+}
+
+func (f *fakeDBClient) UpdateWebhookDelivery(ctx context.Context, delivery cockroach.WebhookDelivery) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i, d := range f.deliveries {
+		if d.ID == delivery.ID {
+			f.deliveries[i] = delivery
+		}
+	}
+	return nil
+}
+
+func TestIsSubscribed(t *testing.T) {
+
+	t.Run("ReturnsTrueIfSubscriberHasNoFilters", func(t *testing.T) {
+
+		subscriber := config.WebhookSubscriberConfig{Name: "all"}
+
+		// act
+		subscribed := isSubscribed(subscriber, "io.estafette.ci.build.succeeded", "github.com/estafette/estafette-ci-api", []contracts.Label{})
+
+		assert.True(t, subscribed)
+	})
+
+	t.Run("ReturnsFalseIfEventTypeIsNotSubscribedTo", func(t *testing.T) {
+
+		subscriber := config.WebhookSubscriberConfig{Name: "failures", Events: []string{"io.estafette.ci.build.failed"}}
+
+		// act
+		subscribed := isSubscribed(subscriber, "io.estafette.ci.build.succeeded", "github.com/estafette/estafette-ci-api", []contracts.Label{})
+
+		assert.False(t, subscribed)
+	})
+
+	t.Run("ReturnsFalseIfPipelineIsNotSubscribedTo", func(t *testing.T) {
+
+		subscriber := config.WebhookSubscriberConfig{Name: "builder", Pipelines: []string{"github.com/estafette/estafette-ci-builder"}}
+
+		// act
+		subscribed := isSubscribed(subscriber, "io.estafette.ci.build.succeeded", "github.com/estafette/estafette-ci-api", []contracts.Label{})
+
+		assert.False(t, subscribed)
+	})
+
+	t.Run("ReturnsTrueIfPipelineHasAllLabels", func(t *testing.T) {
+
+		subscriber := config.WebhookSubscriberConfig{Name: "team", Labels: map[string]string{"team": "estafette-team", "language": "golang"}}
+
+		// act
+		subscribed := isSubscribed(subscriber, "io.estafette.ci.build.succeeded", "github.com/estafette/estafette-ci-api", []contracts.Label{
+			contracts.Label{Key: "team", Value: "estafette-team"},
+			contracts.Label{Key: "language", Value: "golang"},
+		})
+
+		assert.True(t, subscribed)
+	})
+
+	t.Run("ReturnsFalseIfPipelineMissesALabel", func(t *testing.T) {
+
+		subscriber := config.WebhookSubscriberConfig{Name: "team", Labels: map[string]string{"team": "estafette-team", "language": "golang"}}
+
+		// act
+		subscribed := isSubscribed(subscriber, "io.estafette.ci.build.succeeded", "github.com/estafette/estafette-ci-api", []contracts.Label{
+			contracts.Label{Key: "team", Value: "estafette-team"},
+		})
+
+		assert.False(t, subscribed)
+	})
+}
+
+func TestSend(t *testing.T) {
+
+	t.Run("SignsPayloadWithSecret", func(t *testing.T) {
+
+		var signature, contentType, body string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			signature = r.Header.Get("X-Estafette-Signature")
+			contentType = r.Header.Get("Content-Type")
+			data, _ := ioutil.ReadAll(r.Body)
+			body = string(data)
+		}))
+		defer server.Close()
+		notifier := getTestNotifier()
+
+		// act
+		statusCode, attempts, err := notifier.send(context.Background(), server.URL, "my-secret", []byte(`{"specversion":"1.0"}`))
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, 1, attempts)
+		assert.Equal(t, `{"specversion":"1.0"}`, body)
+		assert.Equal(t, "application/cloudevents+json; charset=utf-8", contentType)
+		assert.Equal(t, "sha256="+sign([]byte(`{"specversion":"1.0"}`), "my-secret"), signature)
+	})
+
+	t.Run("RetriesOnServerErrors", func(t *testing.T) {
+
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+		notifier := getTestNotifier()
+
+		// act
+		statusCode, attempts, err := notifier.send(context.Background(), server.URL, "", []byte(`{}`))
+
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("ReturnsErrorIfAllRetriesFail", func(t *testing.T) {
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+		notifier := getTestNotifier()
+
+		// act
+		statusCode, attempts, err := notifier.send(context.Background(), server.URL, "", []byte(`{}`))
+
+		assert.NotNil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, statusCode)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("DoesNotRetryOnClientErrors", func(t *testing.T) {
+
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+		notifier := getTestNotifier()
+
+		// act
+		statusCode, _, err := notifier.send(context.Background(), server.URL, "", []byte(`{}`))
+
+		assert.NotNil(t, err)
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestNotifySubscribers(t *testing.T) {
+
+	getNotifier := func(dbClient *fakeDBClient, queueSize int, urls ...string) *notifierImpl {
+		webhooksConfig := &config.WebhooksConfig{Enable: true, MaxRetries: 3, QueueSize: queueSize}
+		for i, url := range urls {
+			webhooksConfig.Subscribers = append(webhooksConfig.Subscribers, &config.WebhookSubscriberConfig{Name: fmt.Sprintf("subscriber-%v", i), URL: url})
+		}
+		notifier := NewWebhookNotifier(webhooksConfig, config.APIServerConfig{BaseURL: "https://ci.estafette.io/"}, dbClient, prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_outbound_api_call_totals"}, []string{"target"})).(*notifierImpl)
+		notifier.backoff = func(int) time.Duration { return 0 }
+
+		return notifier
+	}
+
+	build := contracts.Build{ID: "15", RepoSource: "github.com", RepoOwner: "estafette", RepoName: "estafette-ci-api", BuildStatus: "succeeded"}
+
+	t.Run("RecordsDeliveryAsFailedWhenQueueIsFull", func(t *testing.T) {
+
+		dbClient := &fakeDBClient{}
+		notifier := getNotifier(dbClient, 1, "https://one.estafette.io", "https://two.estafette.io")
+
+		// act
+		err := notifier.NotifyBuild(context.Background(), build)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(notifier.queue))
+		if assert.Equal(t, 2, len(dbClient.deliveries)) {
+			assert.Equal(t, "", dbClient.deliveries[0].Error)
+			assert.Equal(t, "Webhook delivery queue is full", dbClient.deliveries[1].Error)
+			assert.False(t, dbClient.deliveries[1].Succeeded)
+		}
+	})
+
+	t.Run("SendsQueuedDeliveriesBeforeStopping", func(t *testing.T) {
+
+		var mutex sync.Mutex
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			calls++
+		}))
+		defer server.Close()
+		dbClient := &fakeDBClient{}
+		notifier := getNotifier(dbClient, 10, server.URL, server.URL)
+		err := notifier.NotifyBuild(context.Background(), build)
+		assert.Nil(t, err)
+
+		stopChannel := make(chan struct{})
+		close(stopChannel)
+		waitGroup := &sync.WaitGroup{}
+
+		// act
+		notifier.Run(stopChannel, waitGroup)
+		waitGroup.Wait()
+
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, 0, len(notifier.queue))
+		if assert.Equal(t, 2, len(dbClient.deliveries)) {
+			assert.True(t, dbClient.deliveries[0].Succeeded)
+			assert.True(t, dbClient.deliveries[1].Succeeded)
+		}
+	})
+}